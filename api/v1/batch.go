@@ -0,0 +1,154 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/lifecycle"
+	"github.com/tinoosan/torrus/internal/service"
+)
+
+// BatchHandler serves POST /v1/downloads:batch.
+type BatchHandler struct {
+	l     *slog.Logger
+	batch *service.Batch
+}
+
+type batchFilter struct {
+	Status           []string   `json:"status"`
+	DesiredStatus    []string   `json:"desiredStatus"`
+	TargetPathPrefix string     `json:"targetPathPrefix"`
+	Name             string     `json:"name"`
+	Category         string     `json:"category"`
+	Labels           []string   `json:"labels"`
+	CreatedFrom      *time.Time `json:"createdFrom"`
+	CreatedTo        *time.Time `json:"createdTo"`
+}
+
+type batchBody struct {
+	IDs           []string     `json:"ids"`
+	Filter        *batchFilter `json:"filter"`
+	DesiredStatus string       `json:"desiredStatus"`
+	Delete        bool         `json:"delete"`
+	DeleteFiles   bool         `json:"deleteFiles"`
+}
+
+type batchItem struct {
+	ID       string         `json:"id"`
+	Status   int            `json:"status"`
+	Error    string         `json:"error,omitempty"`
+	Download *data.Download `json:"download,omitempty"`
+}
+
+type batchResponse struct {
+	Succeeded int         `json:"succeeded"`
+	Failed    int         `json:"failed"`
+	Results   []batchItem `json:"results"`
+}
+
+func NewBatchHandler(l *slog.Logger, b *service.Batch) *BatchHandler {
+	return &BatchHandler{l: l, batch: b}
+}
+
+func (bh *BatchHandler) BatchDownloads(w http.ResponseWriter, r *http.Request) {
+	var body batchBody
+	if err := decodeJSONStrict(w, r, &body, 1<<20, "application/json"); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	if body.DeleteFiles && !body.Delete {
+		writeError(w, r, http.StatusBadRequest, ErrDeleteFiles)
+		return
+	}
+
+	req := service.BatchRequest{
+		IDs:           body.IDs,
+		DesiredStatus: data.DownloadStatus(body.DesiredStatus),
+		Delete:        body.Delete,
+		DeleteFiles:   body.DeleteFiles,
+	}
+	if body.Filter != nil {
+		q, err := body.Filter.query()
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		req.Filter = &q
+	}
+
+	results, err := bh.batch.Run(r.Context(), req)
+	switch {
+	case errors.Is(err, data.ErrBadStatus):
+		writeError(w, r, http.StatusBadRequest, errDesiredStatusValue)
+		return
+	case errors.Is(err, service.ErrBatchTarget), errors.Is(err, service.ErrBatchAction), errors.Is(err, service.ErrBatchTooLarge):
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to run batch: %w", err))
+		return
+	}
+
+	resp := batchResponse{Results: make([]batchItem, 0, len(results))}
+	for _, res := range results {
+		item := batchItem{ID: res.ID, Download: res.Download.Redacted()}
+		switch {
+		case res.Err == nil && body.Delete:
+			item.Status = http.StatusNoContent
+		case res.Err == nil:
+			item.Status = http.StatusOK
+		case errors.Is(res.Err, data.ErrNotFound):
+			item.Status, item.Error = http.StatusNotFound, "Not found"
+		case errors.Is(res.Err, data.ErrBadStatus):
+			item.Status, item.Error = http.StatusBadRequest, "Invalid desiredStatus (allowed: Active|Resume|Paused|Cancelled)"
+		case errors.Is(res.Err, lifecycle.ErrIllegalTransition):
+			item.Status, item.Error = http.StatusConflict, res.Err.Error()
+		case errors.Is(res.Err, data.ErrConflict):
+			item.Status, item.Error = http.StatusConflict, "Conflict: target file exists"
+		default:
+			bh.l.Error("batch item failed", "id", res.ID, "err", res.Err)
+			item.Status, item.Error = http.StatusInternalServerError, "Internal error"
+		}
+		if res.Err == nil {
+			resp.Succeeded++
+		} else {
+			resp.Failed++
+		}
+		resp.Results = append(resp.Results, item)
+	}
+	bh.l.Info("batch applied", "targets", len(results), "succeeded", resp.Succeeded, "failed", resp.Failed)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// query converts the filter into a DownloadQuery, rejecting unknown statuses
+// and filters that would select every download.
+func (f *batchFilter) query() (data.DownloadQuery, error) {
+	var q data.DownloadQuery
+	var err error
+	if q.Statuses, err = parseStatuses(f.Status); err != nil {
+		return q, err
+	}
+	if q.DesiredStatuses, err = parseStatuses(f.DesiredStatus); err != nil {
+		return q, err
+	}
+	q.TargetPathPrefix = f.TargetPathPrefix
+	q.NameContains = f.Name
+	q.Category = f.Category
+	q.Labels = f.Labels
+	if f.CreatedFrom != nil {
+		q.CreatedFrom = *f.CreatedFrom
+	}
+	if f.CreatedTo != nil {
+		q.CreatedTo = *f.CreatedTo
+	}
+	if len(q.Statuses) == 0 && len(q.DesiredStatuses) == 0 && q.TargetPathPrefix == "" && q.NameContains == "" && q.Category == "" && len(q.Labels) == 0 && q.CreatedFrom.IsZero() && q.CreatedTo.IsZero() {
+		return q, ErrEmptyFilter
+	}
+	return q, nil
+}
@@ -0,0 +1,113 @@
+package v1
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/service"
+)
+
+// CategoryHandler serves the /v1/categories resource.
+type CategoryHandler struct {
+	l   *slog.Logger
+	svc service.Category
+}
+
+type categoryCreateBody struct {
+	Name        string                `json:"name"`
+	TargetPath  string                `json:"targetPath"`
+	Options     *data.DownloadOptions `json:"options"`
+	PostProcess *data.PostProcess     `json:"postProcess"`
+}
+
+type categoryPatchBody struct {
+	TargetPath  *string               `json:"targetPath"`
+	Options     *data.DownloadOptions `json:"options"`
+	PostProcess *data.PostProcess     `json:"postProcess"`
+}
+
+func NewCategoryHandler(l *slog.Logger, svc service.Category) *CategoryHandler {
+	return &CategoryHandler{l: l, svc: svc}
+}
+
+func (ch *CategoryHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	cats, err := ch.svc.List(r.Context())
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = cats.ToJSON(w)
+}
+
+func (ch *CategoryHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
+	c, err := ch.svc.Get(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		writeCategoryErr(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = c.ToJSON(w)
+}
+
+func (ch *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var body categoryCreateBody
+	if !decodeBody(w, r, &body) {
+		return
+	}
+	saved, err := ch.svc.Create(r.Context(), &data.Category{
+		Name:        body.Name,
+		TargetPath:  body.TargetPath,
+		Options:     body.Options,
+		PostProcess: body.PostProcess,
+	})
+	if err != nil {
+		writeCategoryErr(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = saved.ToJSON(w)
+}
+
+func (ch *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	var body categoryPatchBody
+	if !decodeBody(w, r, &body) {
+		return
+	}
+	updated, err := ch.svc.Update(r.Context(), mux.Vars(r)["name"], service.CategoryPatch{
+		TargetPath:  body.TargetPath,
+		Options:     body.Options,
+		PostProcess: body.PostProcess,
+	})
+	if err != nil {
+		writeCategoryErr(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = updated.ToJSON(w)
+}
+
+func (ch *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	if err := ch.svc.Delete(r.Context(), mux.Vars(r)["name"]); err != nil {
+		writeCategoryErr(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeCategoryErr(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, data.ErrCategoryNotFound):
+		writeError(w, r, http.StatusNotFound, err)
+	case errors.Is(err, data.ErrCategoryExists):
+		writeError(w, r, http.StatusConflict, err)
+	case errors.Is(err, data.ErrInvalidCategory), errors.Is(err, data.ErrInvalidOptions), errors.Is(err, data.ErrInvalidPostProcess):
+		writeError(w, r, http.StatusBadRequest, err)
+	default:
+		writeError(w, r, http.StatusInternalServerError, err)
+	}
+}
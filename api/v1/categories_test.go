@@ -0,0 +1,154 @@
+package v1_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	internaldata "github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/downloader"
+	"github.com/tinoosan/torrus/internal/repo"
+	"github.com/tinoosan/torrus/internal/router"
+	"github.com/tinoosan/torrus/internal/service"
+)
+
+func setupCategories(t *testing.T) http.Handler {
+	t.Helper()
+	t.Setenv("TORRUS_API_TOKEN", testToken)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dlr := downloader.NewNoopDownloader()
+	cats := repo.NewInMemoryCategoryRepo()
+	svc := service.NewDownload(repo.NewInMemoryDownloadRepo(), dlr, service.WithCategories(cats))
+	return router.New(logger, svc, dlr, router.WithCategories(service.NewCategory(cats, nil)))
+}
+
+func TestCategoriesCRUD(t *testing.T) {
+	h := setupCategories(t)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		authReq(req)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/v1/categories", `{"name":"tv","targetPath":"/downloads/tv/","options":{"split":4},"postProcess":{"rename":"{{.Name}}"}}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201 got %d: %s", rr.Code, rr.Body.String())
+	}
+	var c internaldata.Category
+	_ = json.NewDecoder(rr.Body).Decode(&c)
+	if c.TargetPath != "/downloads/tv" || c.Options == nil || c.Options.Split != 4 || c.PostProcess == nil || c.PostProcess.Category != "tv" || c.CreatedAt.IsZero() {
+		t.Fatalf("unexpected category: %+v", c)
+	}
+	if rr := do(http.MethodPost, "/v1/categories", `{"name":"tv","targetPath":"/x"}`); rr.Code != http.StatusConflict {
+		t.Fatalf("duplicate: expected 409 got %d", rr.Code)
+	}
+
+	rr = do(http.MethodPatch, "/v1/categories/tv", `{"targetPath":"/media/tv","options":{}}`)
+	var patched internaldata.Category
+	_ = json.NewDecoder(rr.Body).Decode(&patched)
+	if rr.Code != http.StatusOK || patched.TargetPath != "/media/tv" || patched.Options != nil || patched.PostProcess == nil {
+		t.Fatalf("patch: %d %+v", rr.Code, patched)
+	}
+
+	rr = do(http.MethodGet, "/v1/categories", "")
+	var list []internaldata.Category
+	_ = json.NewDecoder(rr.Body).Decode(&list)
+	if rr.Code != http.StatusOK || len(list) != 1 || list[0].Name != "tv" {
+		t.Fatalf("list: %d %+v", rr.Code, list)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/v1/categories", `{"name":"../x","targetPath":"/x"}`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/categories", `{"name":"movies","targetPath":"relative"}`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/categories", `{"name":"movies","targetPath":"/m","options":{"split":100}}`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/categories", `{"name":"movies","targetPath":"/m","createdAt":"2025-01-01T00:00:00Z"}`, http.StatusBadRequest},
+		{http.MethodPatch, "/v1/categories/tv", `{"targetPath":""}`, http.StatusBadRequest},
+		{http.MethodPatch, "/v1/categories/missing", `{"targetPath":"/x"}`, http.StatusNotFound},
+		{http.MethodGet, "/v1/categories/missing", "", http.StatusNotFound},
+		{http.MethodDelete, "/v1/categories/tv", "", http.StatusNoContent},
+		{http.MethodGet, "/v1/categories/tv", "", http.StatusNotFound},
+	} {
+		if rr := do(tc.method, tc.path, tc.body); rr.Code != tc.want {
+			t.Fatalf("%s %s %s: expected %d got %d", tc.method, tc.path, tc.body, tc.want, rr.Code)
+		}
+	}
+}
+
+func TestDownloadCategoryDefaultsAndFilters(t *testing.T) {
+	h := setupCategories(t)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		authReq(req)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := do(http.MethodPost, "/v1/categories", `{"name":"tv","targetPath":"/media/tv","options":{"split":4,"headers":{"X-A":"1"}},"postProcess":{"dest":"/library/tv"}}`); rr.Code != http.StatusCreated {
+		t.Fatalf("create category: %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr := do(http.MethodPost, "/v1/downloads", `{"source":"https://example.com/a.mkv","category":"tv","labels":["hd"," weekly ","hd"],"options":{"headers":{"X-B":"2"},"split":2}}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201 got %d: %s", rr.Code, rr.Body.String())
+	}
+	var dl internaldata.Download
+	_ = json.NewDecoder(rr.Body).Decode(&dl)
+	if dl.TargetPath != "/media/tv" || dl.Category != "tv" || strings.Join(dl.Labels, ",") != "hd,weekly" {
+		t.Fatalf("category not applied: %+v", dl)
+	}
+	if o := dl.Options; o == nil || o.Split != 2 || o.Headers["X-A"] != internaldata.Redacted || o.Headers["X-B"] != internaldata.Redacted {
+		t.Fatalf("options not merged: %+v", dl.Options)
+	}
+	if pp := dl.PostProcess; pp == nil || pp.Dest != "/library/tv" {
+		t.Fatalf("postProcess not inherited: %+v", dl.PostProcess)
+	}
+	// Post-processing without a destination uses the category's.
+	if rr := do(http.MethodPost, "/v1/categories", `{"name":"anime","targetPath":"/media/anime"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("create category: %d %s", rr.Code, rr.Body.String())
+	}
+	rr = do(http.MethodPost, "/v1/downloads", `{"source":"https://example.com/e.mkv","category":"anime","postProcess":{"extract":true}}`)
+	var anime internaldata.Download
+	_ = json.NewDecoder(rr.Body).Decode(&anime)
+	if rr.Code != http.StatusCreated || anime.PostProcess == nil || anime.PostProcess.Category != "anime" || !anime.PostProcess.Extract {
+		t.Fatalf("postProcess without dest: %d %+v", rr.Code, anime.PostProcess)
+	}
+	if rr := do(http.MethodPost, "/v1/downloads", `{"source":"https://example.com/b.iso","targetPath":"/tmp","labels":["hd"]}`); rr.Code != http.StatusCreated {
+		t.Fatalf("create uncategorized: %d", rr.Code)
+	}
+
+	for query, want := range map[string]int{
+		"category=tv":            1,
+		"label=hd":               2,
+		"label=hd,weekly":        1,
+		"label=hd&label=monthly": 0,
+		"category=movies":        0,
+	} {
+		rr := do(http.MethodGet, "/v1/downloads?"+query, "")
+		var got []internaldata.Download
+		_ = json.NewDecoder(rr.Body).Decode(&got)
+		if rr.Code != http.StatusOK || len(got) != want {
+			t.Fatalf("%s: expected %d downloads got %d (%d)", query, want, len(got), rr.Code)
+		}
+	}
+
+	for _, body := range []string{
+		`{"source":"https://example.com/c","category":"movies"}`,
+		`{"source":"https://example.com/c","targetPath":"/tmp","labels":["a,b"]}`,
+		`{"source":"https://example.com/c"}`,
+	} {
+		if rr := do(http.MethodPost, "/v1/downloads", body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400 got %d", body, rr.Code)
+		}
+	}
+}
@@ -1,15 +1,40 @@
 package v1
 
-import "errors"
+import (
+    "errors"
+    "fmt"
+
+    "github.com/tinoosan/torrus/internal/data"
+)
 
 var (
     ErrDownloadCtx   = errors.New("download missing in context")
     ErrDesiredStatus = errors.New("desired status missing in context")
-    ErrDesiredStatusJSON = errors.New("desired status is required")
+    ErrPatchFields = errors.New("at least one of desiredStatus, priority or options is required")
     ErrTargetPath = errors.New("targetPath is required")
     ErrContentType = errors.New("Content-Type must be application/json")
     ErrMagnetURI = errors.New("invalid magnet link")
     ErrReadOnlyName = errors.New("name is read-only and cannot be set")
     ErrReadOnlyFiles = errors.New("files is read-only and cannot be set")
+    ErrReadOnlyProgress = errors.New("progress is read-only and cannot be set")
+    ErrReadOnlyQueuePosition = errors.New("queuePosition is read-only and cannot be set")
+    ErrReadOnlyPostProcessStatus = errors.New("postProcessStatus is read-only and cannot be set")
+    ErrReadOnlyRetryStatus = errors.New("retryStatus is read-only and cannot be set")
+    ErrReadOnlyFailure = errors.New("failure is read-only and cannot be set")
+    ErrLimit = errors.New("limit must be an integer between 1 and 500")
+    ErrSort = errors.New("sort must be one of createdAt, -createdAt, name, -name, queue")
+    ErrStatusFilter = errors.New("status filters must be one of Queued, Active, Resume, Paused, Seeding, Complete, Cancelled, Failed")
+    ErrCreatedRange = errors.New("createdFrom and createdTo must be RFC 3339 timestamps")
+    ErrDeleteFiles = errors.New("deleteFiles requires delete")
+    ErrEmptyFilter = errors.New("filter must set at least one criterion")
+    ErrMoveTo = errors.New("to must be one of top, bottom, up, down")
+    ErrUploadSource = errors.New("only one of source, torrent or metalink may be set")
+    ErrUploadFile = errors.New("a torrent or metalink file is required")
+    ErrUploadSize = errors.New("uploaded file exceeds 10 MiB")
+    ErrUpload = errors.New("invalid upload")
+    ErrInvalidJSON = errors.New("invalid JSON")
+    ErrLastEventID = errors.New("invalid Last-Event-ID")
+
+    errDesiredStatusValue = fmt.Errorf("%w: desiredStatus must be one of Active, Resume, Paused, Cancelled", data.ErrBadStatus)
 
 )
@@ -0,0 +1,173 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/events"
+)
+
+// heartbeatInterval controls how often a comment line is written to keep
+// idle SSE connections (and intermediate proxies) alive.
+var heartbeatInterval = 15 * time.Second
+
+// EventsHandler streams downloader events to clients as Server-Sent Events.
+type EventsHandler struct {
+	l   *slog.Logger
+	hub *events.Hub
+}
+
+// eventPayload is the JSON wire format of a streamed event.
+type eventPayload struct {
+	Seq      uint64           `json:"seq"`
+	Time     time.Time        `json:"time"`
+	Type     string           `json:"type"`
+	ID       string           `json:"id"`
+	GID      string           `json:"gid,omitempty"`
+	NewGID   string           `json:"newGid,omitempty"`
+	Progress *progressPayload `json:"progress,omitempty"`
+	Meta     *metaPayload     `json:"meta,omitempty"`
+}
+
+type progressPayload struct {
+	Completed int64 `json:"completed"`
+	Total     int64 `json:"total"`
+	Speed     int64 `json:"speed"`
+	// Upload totals are only reported by BitTorrent downloads.
+	Uploaded    int64 `json:"uploaded,omitempty"`
+	UploadSpeed int64 `json:"uploadSpeed,omitempty"`
+}
+
+type metaPayload struct {
+	Name  *string              `json:"name,omitempty"`
+	Files *[]data.DownloadFile `json:"files,omitempty"`
+}
+
+func NewEventsHandler(l *slog.Logger, hub *events.Hub) *EventsHandler {
+	return &EventsHandler{l: l, hub: hub}
+}
+
+// StreamEvents serves GET /v1/events. Clients may filter by download ID with
+// one or more `id` query parameters (repeated or comma-separated) and resume
+// a dropped stream with the Last-Event-ID header (or `lastEventId` query
+// parameter).
+func (eh *EventsHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		err := fmt.Errorf("streaming unsupported")
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	after, err := parseLastEventID(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, fmt.Errorf("%w: %v", ErrLastEventID, err))
+		return
+	}
+	filter := events.Filter{}
+	for _, v := range r.URL.Query()["id"] {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				if filter.IDs == nil {
+					filter.IDs = make(map[string]struct{})
+				}
+				filter.IDs[id] = struct{}{}
+			}
+		}
+	}
+
+	// The server applies a short WriteTimeout to regular requests; a stream
+	// must be allowed to outlive it.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	sub, replay := eh.hub.Subscribe(filter, after)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	for _, env := range replay {
+		if err := writeSSE(w, env); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case env, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client reconnects with
+				// Last-Event-ID and replays what it missed.
+				return
+			}
+			if err := writeSSE(w, env); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func parseLastEventID(r *http.Request) (uint64, error) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("lastEventId")
+	}
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+}
+
+func writeSSE(w http.ResponseWriter, env events.Envelope) error {
+	b, err := json.Marshal(toEventPayload(env))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", env.Seq, b)
+	return err
+}
+
+func toEventPayload(env events.Envelope) eventPayload {
+	e := env.Event
+	p := eventPayload{
+		Seq:    env.Seq,
+		Time:   env.Time,
+		Type:   string(e.Type),
+		ID:     e.ID,
+		GID:    e.GID,
+		NewGID: e.NewGID,
+	}
+	if e.Progress != nil {
+		p.Progress = &progressPayload{
+			Completed:   e.Progress.Completed,
+			Total:       e.Progress.Total,
+			Speed:       e.Progress.Speed,
+			Uploaded:    e.Progress.Uploaded,
+			UploadSpeed: e.Progress.UploadSpeed,
+		}
+	}
+	if e.Meta != nil && (e.Meta.Name != nil || e.Meta.Files != nil) {
+		p.Meta = &metaPayload{Name: e.Meta.Name, Files: e.Meta.Files}
+	}
+	return p
+}
@@ -1,14 +1,18 @@
 package v1
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/lifecycle"
+	"github.com/tinoosan/torrus/internal/pathsafe"
 	"github.com/tinoosan/torrus/internal/service"
 )
 
@@ -19,6 +23,18 @@ type DownloadHandler struct {
 
 type patchBody struct {
 	DesiredStatus string `json:"desiredStatus"`
+	Priority      *int   `json:"priority"`
+	// Options changes the live-changeable transfer options.
+	Options *data.DownloadOptionsPatch `json:"options"`
+}
+
+type moveBody struct {
+	To data.QueueMove `json:"to"`
+}
+
+type filesBody struct {
+	// Selected lists the 1-based indexes of the files to fetch.
+	Selected []int `json:"selected"`
 }
 
 type deleteBody struct {
@@ -37,6 +53,19 @@ func (w *rwLogger) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
+// Flush forwards to the underlying writer so streaming handlers work behind
+// the logging middleware.
+func (w *rwLogger) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (w *rwLogger) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 func (w *rwLogger) SetErr(err error) {
 	w.err = err
 }
@@ -70,17 +99,29 @@ func NewDownloadHandler(l *slog.Logger, svc service.Download) *DownloadHandler {
 }
 
 func (dh *DownloadHandler) GetDownloads(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	dl, err1 := dh.svc.List(r.Context())
-	if err1 != nil {
-		markErr(w, err1)
-		http.Error(w, err1.Error(), http.StatusInternalServerError)
+	q, err := parseDownloadQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
 		return
 	}
-	err := dl.ToJSON(w)
-	if err != nil {
-		markErr(w, err)
-		http.Error(w, "Unable to marshal json", http.StatusInternalServerError)
+	var page *data.DownloadPage
+	if paginated(r.URL.Query()) {
+		page, err = dh.svc.Query(r.Context(), q)
+	} else {
+		page, err = queryAll(r.Context(), dh.svc, q)
+	}
+	switch {
+	case errors.Is(err, data.ErrInvalidCursor), errors.Is(err, data.ErrInvalidQuery):
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	setNextPage(w, r, page.NextCursor)
+	if err := page.Items.ToJSON(w); err != nil {
+		writeError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to marshal json: %w", err))
 		return
 	}
 }
@@ -91,8 +132,7 @@ func (dh *DownloadHandler) GetDownload(w http.ResponseWriter, r *http.Request) {
 
 	dl, err := dh.svc.Get(r.Context(), id)
 	if err != nil {
-		markErr(w, err)
-		http.Error(w, "Not Found", http.StatusNotFound)
+		writeError(w, r, http.StatusNotFound, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -101,23 +141,36 @@ func (dh *DownloadHandler) GetDownload(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func (dh *DownloadHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	history, err := dh.svc.History(r.Context(), mux.Vars(r)["id"])
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, err)
+		return
+	case err != nil:
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = history.ToJSON(w)
+}
+
 func (dh *DownloadHandler) AddDownload(w http.ResponseWriter, r *http.Request) {
 
 	v := r.Context().Value(ctxKeyDownload{})
 	dl, ok := v.(*data.Download)
 	if !ok || dl == nil {
-		markErr(w, ErrDownloadCtx)
-		http.Error(w, ErrDownloadCtx.Error(), http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, ErrDownloadCtx)
 		return
 	}
 	saved, created, err := dh.svc.Add(r.Context(), dl)
 	switch {
-	case errors.Is(err, data.ErrInvalidSource), errors.Is(err, data.ErrTargetPath):
-		markErr(w, err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, data.ErrInvalidSource), errors.Is(err, data.ErrTargetPath), errors.Is(err, data.ErrInvalidOptions), errors.Is(err, data.ErrInvalidPayload), errors.Is(err, data.ErrInvalidFileSelection),
+		errors.Is(err, data.ErrInvalidPostProcess), errors.Is(err, data.ErrInvalidCategory), errors.Is(err, data.ErrInvalidLabels), errors.Is(err, data.ErrInvalidRetry):
+		writeError(w, r, http.StatusBadRequest, err)
 		return
 	case err != nil:
-		http.Error(w, "failed to create", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to create: %w", err))
 		return
 	}
 
@@ -136,30 +189,44 @@ func (dh *DownloadHandler) UpdateDownload(w http.ResponseWriter, r *http.Request
 
 	v := r.Context().Value(ctxKeyPatch{})
 	body, ok := v.(patchBody)
-	if !ok || body.DesiredStatus == "" {
-		markErr(w, ErrDesiredStatus)
-		http.Error(w, ErrDesiredStatus.Error(), http.StatusInternalServerError)
+	if !ok || (body.DesiredStatus == "" && body.Priority == nil && body.Options == nil) {
+		writeError(w, r, http.StatusInternalServerError, ErrDesiredStatus)
 		return
 	}
 
-	updated, err := dh.svc.UpdateDesiredStatus(r.Context(), id, data.DownloadStatus(body.DesiredStatus))
+	var updated *data.Download
+	err := dh.checkPatch(r.Context(), id, body)
+	// Options go first: the backend may still refuse them.
+	if err == nil && body.Options != nil {
+		updated, err = dh.svc.SetOptions(r.Context(), id, *body.Options)
+	}
+	if err == nil && body.Priority != nil {
+		updated, err = dh.svc.SetPriority(r.Context(), id, *body.Priority)
+	}
+	if err == nil && body.DesiredStatus != "" {
+		updated, err = dh.svc.UpdateDesiredStatus(r.Context(), id, data.DownloadStatus(body.DesiredStatus))
+	}
+	if errors.Is(err, data.ErrInvalidOptions) {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if errors.Is(err, lifecycle.ErrIllegalTransition) {
+		writeError(w, r, http.StatusConflict, err)
+		return
+	}
 	if err != nil {
 		switch err {
 		case data.ErrNotFound:
-			markErr(w, err)
-			http.Error(w, "Not found", http.StatusNotFound)
+			writeError(w, r, http.StatusNotFound, err)
 			return
 		case data.ErrBadStatus:
-			markErr(w, err)
-			http.Error(w, "Invalid desiredStatus (allowed: Active|Resume|Paused|Cancelled)", http.StatusBadRequest)
+			writeError(w, r, http.StatusBadRequest, errDesiredStatusValue)
 			return
 		case data.ErrConflict:
-			markErr(w, err)
-			http.Error(w, "Conflict: target file exists", http.StatusConflict)
+			writeError(w, r, http.StatusConflict, fmt.Errorf("%w: target file exists", err))
 			return
 		default:
-			markErr(w, err)
-			http.Error(w, "failed to update", http.StatusInternalServerError)
+			writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to update: %w", err))
 			return
 		}
 	}
@@ -167,6 +234,87 @@ func (dh *DownloadHandler) UpdateDownload(w http.ResponseWriter, r *http.Request
 	_ = updated.ToJSON(w)
 }
 
+// checkPatch validates every field of body before any is applied, so a
+// PATCH that is rejected leaves the download unchanged.
+func (dh *DownloadHandler) checkPatch(ctx context.Context, id string, body patchBody) error {
+	if body.Options != nil {
+		if err := body.Options.Validate(); err != nil {
+			return err
+		}
+	}
+	if body.DesiredStatus == "" {
+		return nil
+	}
+	status := data.DownloadStatus(body.DesiredStatus)
+	switch status {
+	case data.StatusActive, data.StatusResume, data.StatusPaused, data.StatusCancelled:
+	default:
+		return data.ErrBadStatus
+	}
+	cur, err := dh.svc.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return lifecycle.Request(cur.Status, status)
+}
+
+func (dh *DownloadHandler) MoveDownload(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var body moveBody
+	if err := decodeJSONStrict(w, r, &body, 1<<20, "application/json"); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	if !body.To.Valid() {
+		writeError(w, r, http.StatusBadRequest, ErrMoveTo)
+		return
+	}
+
+	moved, err := dh.svc.Move(r.Context(), id, body.To)
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, err)
+		return
+	case errors.Is(err, data.ErrNotQueued):
+		writeError(w, r, http.StatusConflict, err)
+		return
+	case err != nil:
+		writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to move: %w", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = moved.ToJSON(w)
+}
+
+func (dh *DownloadHandler) SelectFiles(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var body filesBody
+	if err := decodeJSONStrict(w, r, &body, 1<<20, "application/json"); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	updated, err := dh.svc.SelectFiles(r.Context(), id, body.Selected)
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, err)
+		return
+	case errors.Is(err, data.ErrInvalidFileSelection):
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, data.ErrFilesUnknown):
+		writeError(w, r, http.StatusConflict, err)
+		return
+	case err != nil:
+		writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to select files: %w", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = updated.ToJSON(w)
+}
+
 func (dh *DownloadHandler) DeleteDownload(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -174,33 +322,32 @@ func (dh *DownloadHandler) DeleteDownload(w http.ResponseWriter, r *http.Request
 	var body deleteBody
 	if r.Body != nil && r.ContentLength != 0 {
 		if ct := r.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "application/json") {
-			markErr(w, ErrContentType)
-			http.Error(w, ErrContentType.Error(), http.StatusBadRequest)
+			writeError(w, r, http.StatusBadRequest, ErrContentType)
 			return
 		}
 		r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&body); err != nil {
-			markErr(w, err)
-			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			writeError(w, r, http.StatusBadRequest, fmt.Errorf("%w: %v", ErrInvalidJSON, err))
 			return
 		}
 	}
 
 	if err := dh.svc.Delete(r.Context(), id, body.DeleteFiles); err != nil {
-		switch err {
-		case data.ErrNotFound:
-			markErr(w, err)
-			http.Error(w, "Not Found", http.StatusNotFound)
+		switch {
+		case errors.Is(err, data.ErrNotFound):
+			writeError(w, r, http.StatusNotFound, err)
 			return
-		case data.ErrConflict:
-			markErr(w, err)
-			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, pathsafe.ErrNotAllowed):
+			// The downloader refused to remove files outside the roots.
+			writeError(w, r, http.StatusBadRequest, err)
+			return
+		case errors.Is(err, data.ErrConflict):
+			writeError(w, r, http.StatusConflict, err)
 			return
 		default:
-			markErr(w, err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, http.StatusInternalServerError, err)
 			return
 		}
 	}
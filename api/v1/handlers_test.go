@@ -1,11 +1,14 @@
 package v1_test
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"encoding/base64"
 	"io"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -13,6 +16,7 @@ import (
 
 	internaldata "github.com/tinoosan/torrus/internal/data"
 	"github.com/tinoosan/torrus/internal/downloader"
+	"github.com/tinoosan/torrus/internal/events"
 	"github.com/tinoosan/torrus/internal/repo"
 	"github.com/tinoosan/torrus/internal/router"
 	"github.com/tinoosan/torrus/internal/service"
@@ -168,6 +172,7 @@ func TestPostDownloadValidation(t *testing.T) {
 		{"body too large", "application/json", `{"source":"magnet:?xt=urn:btih:` + strings.Repeat("a", 1<<20) + `","targetPath":"/tmp"}`, http.StatusBadRequest},
 		{"name provided (read-only)", "application/json", `{"source":"magnet:?xt=urn:btih:abcdef","targetPath":"/tmp","name":"hack"}`, http.StatusBadRequest},
 		{"files provided (read-only)", "application/json", `{"source":"magnet:?xt=urn:btih:abcdef","targetPath":"/tmp","files":[{"path":"a.mkv"}]}`, http.StatusBadRequest},
+		{"progress provided (read-only)", "application/json", `{"source":"magnet:?xt=urn:btih:abcdef","targetPath":"/tmp","progress":{"completed":1}}`, http.StatusBadRequest},
 	}
 
 	for _, tt := range tests {
@@ -468,3 +473,505 @@ func TestPatchReturnsUpdatedDownload(t *testing.T) {
 		t.Fatalf("files missing: %#v", got["files"])
 	}
 }
+
+func TestEventsStream(t *testing.T) {
+	t.Setenv("TORRUS_API_TOKEN", testToken)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	rpo := repo.NewInMemoryDownloadRepo()
+	dlr := downloader.NewNoopDownloader()
+	svc := service.NewDownload(rpo, dlr)
+	hub := events.NewHub(16)
+	srv := httptest.NewServer(router.New(logger, svc, dlr, router.WithEvents(hub)))
+	defer srv.Close()
+
+	// Events published after the client's Last-Event-ID are replayed.
+	hub.Publish(downloader.Event{ID: "a", Type: downloader.EventStart})
+	hub.Publish(downloader.Event{ID: "b", Type: downloader.EventStart})
+	hub.Publish(downloader.Event{ID: "a", Type: downloader.EventMeta})
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/v1/events?id=a", nil)
+	authReq(req)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status=%d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content-type=%q", ct)
+	}
+
+	hub.Publish(downloader.Event{ID: "b", Type: downloader.EventComplete})
+	hub.Publish(downloader.Event{ID: "a", GID: "g", Type: downloader.EventProgress, Progress: &downloader.Progress{Completed: 5, Total: 10}})
+
+	sc := bufio.NewScanner(resp.Body)
+	var ids []string
+	var payload map[string]any
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "id: ") {
+			ids = append(ids, strings.TrimPrefix(line, "id: "))
+		}
+		if strings.HasPrefix(line, "data: ") {
+			_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &payload)
+			if payload["type"] == "Progress" {
+				break
+			}
+		}
+	}
+	if len(ids) != 2 || ids[0] != "3" || ids[1] != "5" {
+		t.Fatalf("unexpected ids (filter/replay): %v", ids)
+	}
+	prog, ok := payload["progress"].(map[string]any)
+	if !ok || prog["completed"].(float64) != 5 || payload["id"] != "a" {
+		t.Fatalf("unexpected payload: %#v", payload)
+	}
+}
+
+func TestEventsStreamRequiresAuth(t *testing.T) {
+	t.Setenv("TORRUS_API_TOKEN", testToken)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dlr := downloader.NewNoopDownloader()
+	svc := service.NewDownload(repo.NewInMemoryDownloadRepo(), dlr)
+	h := router.New(logger, svc, dlr, router.WithEvents(events.NewHub(4)))
+	req := httptest.NewRequest(http.MethodGet, "/v1/events", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rr.Code)
+	}
+}
+
+func TestGetDownloadsPaginationAndFilters(t *testing.T) {
+	h := setup(t)
+	for i, src := range []string{"magnet:?xt=urn:btih:aaa", "magnet:?xt=urn:btih:bbb", "magnet:?xt=urn:btih:ccc"} {
+		body := bytes.NewBufferString(`{"source":"` + src + `","targetPath":"/data/` + string(rune('a'+i)) + `"}`)
+		req := httptest.NewRequest(http.MethodPost, "/v1/downloads", body)
+		authReq(req)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("seed: expected 201 got %d", rr.Code)
+		}
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		authReq(req)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/v1/downloads?limit=2&sort=-createdAt")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rr.Code)
+	}
+	var page []map[string]any
+	_ = json.NewDecoder(rr.Body).Decode(&page)
+	cursor := rr.Header().Get("X-Next-Cursor")
+	if len(page) != 2 || cursor == "" || !strings.Contains(rr.Header().Get("Link"), `rel="next"`) {
+		t.Fatalf("first page = %d items, cursor %q, link %q", len(page), cursor, rr.Header().Get("Link"))
+	}
+	rr = get("/v1/downloads?limit=2&sort=-createdAt&cursor=" + cursor)
+	_ = json.NewDecoder(rr.Body).Decode(&page)
+	if len(page) != 1 || rr.Header().Get("X-Next-Cursor") != "" {
+		t.Fatalf("last page = %d items, cursor %q", len(page), rr.Header().Get("X-Next-Cursor"))
+	}
+
+	// Without limit or cursor the listing is not paginated.
+	rr = get("/v1/downloads?sort=-createdAt")
+	_ = json.NewDecoder(rr.Body).Decode(&page)
+	if len(page) != 3 || rr.Header().Get("X-Next-Cursor") != "" || rr.Header().Get("Link") != "" {
+		t.Fatalf("unpaginated = %d items, cursor %q", len(page), rr.Header().Get("X-Next-Cursor"))
+	}
+
+	rr = get("/v1/downloads?status=Queued,Active&targetPathPrefix=/data/b")
+	_ = json.NewDecoder(rr.Body).Decode(&page)
+	if len(page) != 1 || page[0]["targetPath"] != "/data/b" {
+		t.Fatalf("filtered = %v", page)
+	}
+
+	for _, q := range []string{"limit=0", "limit=501", "sort=size", "status=Bogus", "createdFrom=yesterday", "cursor=notacursor", "sort=name&cursor=" + cursor} {
+		if rr := get("/v1/downloads?" + q); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400 got %d", q, rr.Code)
+		}
+	}
+}
+
+func TestBatchDownloads(t *testing.T) {
+	h := setup(t)
+	var ids []string
+	for _, src := range []string{"magnet:?xt=urn:btih:b1", "magnet:?xt=urn:btih:b2"} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/downloads", bytes.NewBufferString(`{"source":"`+src+`","targetPath":"/tmp/b"}`))
+		authReq(req)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		var dl map[string]any
+		_ = json.NewDecoder(rr.Body).Decode(&dl)
+		ids = append(ids, dl["id"].(string))
+	}
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/downloads:batch", bytes.NewBufferString(body))
+		authReq(req)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post(`{"ids":["` + ids[0] + `","missing"],"desiredStatus":"Paused"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Succeeded int `json:"succeeded"`
+		Failed    int `json:"failed"`
+		Results   []struct {
+			ID       string         `json:"id"`
+			Status   int            `json:"status"`
+			Download map[string]any `json:"download"`
+		} `json:"results"`
+	}
+	_ = json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Succeeded != 1 || resp.Failed != 1 || resp.Results[0].Status != 200 || resp.Results[0].Download["status"] != "Paused" || resp.Results[1].Status != 404 {
+		t.Fatalf("unexpected batch response: %+v", resp)
+	}
+
+	rr = post(`{"filter":{"targetPathPrefix":"/tmp/b"},"delete":true}`)
+	_ = json.NewDecoder(rr.Body).Decode(&resp)
+	if rr.Code != http.StatusOK || resp.Succeeded != 2 || resp.Results[0].Status != http.StatusNoContent {
+		t.Fatalf("filter delete: %d %+v", rr.Code, resp)
+	}
+
+	for _, body := range []string{
+		`{"desiredStatus":"Paused"}`,
+		`{"ids":["a"]}`,
+		`{"ids":["a"],"desiredStatus":"Complete"}`,
+		`{"ids":["a"],"desiredStatus":"Paused","deleteFiles":true}`,
+		`{"filter":{},"delete":true}`,
+		`{"filter":{"status":["Nope"]},"delete":true}`,
+	} {
+		if rr := post(body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400 got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestMoveDownloadAndPatchPriority(t *testing.T) {
+	h := setup(t)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		var rd io.Reader
+		if body != "" {
+			rd = strings.NewReader(body)
+		}
+		req := httptest.NewRequest(method, path, rd)
+		authReq(req)
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	var ids []string
+	for _, src := range []string{"magnet:?xt=urn:btih:q1", "magnet:?xt=urn:btih:q2", "magnet:?xt=urn:btih:q3"} {
+		rr := do(http.MethodPost, "/v1/downloads", `{"source":"`+src+`","targetPath":"/tmp"}`)
+		var d map[string]any
+		_ = json.NewDecoder(rr.Body).Decode(&d)
+		ids = append(ids, d["id"].(string))
+	}
+	queue := func() []string {
+		var page []map[string]any
+		_ = json.NewDecoder(do(http.MethodGet, "/v1/downloads?sort=queue", "").Body).Decode(&page)
+		var out []string
+		for _, d := range page {
+			out = append(out, d["id"].(string))
+		}
+		return out
+	}
+
+	if rr := do(http.MethodPost, "/v1/downloads/"+ids[2]+"/move", `{"to":"top"}`); rr.Code != http.StatusOK {
+		t.Fatalf("move: expected 200 got %d: %s", rr.Code, rr.Body.String())
+	}
+	if q := queue(); len(q) != 3 || q[0] != ids[2] || q[1] != ids[0] {
+		t.Fatalf("queue after move = %v", q)
+	}
+
+	rr := do(http.MethodPatch, "/v1/downloads/"+ids[1], `{"priority":10}`)
+	var patched map[string]any
+	_ = json.NewDecoder(rr.Body).Decode(&patched)
+	if rr.Code != http.StatusOK || patched["priority"] != float64(10) {
+		t.Fatalf("patch priority: %d %v", rr.Code, patched)
+	}
+	if q := queue(); q[0] != ids[1] {
+		t.Fatalf("queue after priority = %v", q)
+	}
+
+	do(http.MethodPatch, "/v1/downloads/"+ids[0], `{"desiredStatus":"Paused"}`)
+	for _, tc := range []struct {
+		id, body string
+		want     int
+	}{
+		{ids[0], `{"to":"top"}`, http.StatusConflict},
+		{ids[1], `{"to":"sideways"}`, http.StatusBadRequest},
+		{ids[1], `{"to":"up","extra":1}`, http.StatusBadRequest},
+		{"00000000-0000-0000-0000-000000000000", `{"to":"up"}`, http.StatusNotFound},
+	} {
+		if rr := do(http.MethodPost, "/v1/downloads/"+tc.id+"/move", tc.body); rr.Code != tc.want {
+			t.Fatalf("%s %s: expected %d got %d", tc.id, tc.body, tc.want, rr.Code)
+		}
+	}
+	if rr := do(http.MethodPatch, "/v1/downloads/"+ids[1], `{}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("empty patch: expected 400 got %d", rr.Code)
+	}
+}
+
+func TestDownloadOptions(t *testing.T) {
+	h := setup(t)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		authReq(req)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/v1/downloads", `{"source":"https://example.com/a.iso","targetPath":"/tmp","options":{"maxDownloadLimit":1048576,"split":4,"headers":{"X-Token":"abc"},"userAgent":"torrus","out":"a.iso"}}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201 got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created internaldata.Download
+	_ = json.NewDecoder(rr.Body).Decode(&created)
+	if o := created.Options; o == nil || o.MaxDownloadLimit != 1048576 || o.Split != 4 || o.Headers["X-Token"] != internaldata.Redacted || o.Out != "a.iso" {
+		t.Fatalf("options not stored: %+v", created.Options)
+	}
+
+	rr = do(http.MethodPatch, "/v1/downloads/"+created.ID, `{"options":{"maxDownloadLimit":0,"maxConnectionsPerServer":8}}`)
+	var patched internaldata.Download
+	_ = json.NewDecoder(rr.Body).Decode(&patched)
+	if rr.Code != http.StatusOK || patched.Options == nil || patched.Options.MaxDownloadLimit != 0 || patched.Options.MaxConnectionsPerServer != 8 || patched.Options.Split != 4 {
+		t.Fatalf("patch options: %d %+v", rr.Code, patched.Options)
+	}
+	// Header values never appear in responses, only their names.
+	if strings.Contains(rr.Body.String(), "abc") {
+		t.Fatalf("header value leaked: %s", rr.Body.String())
+	}
+	if rr := do(http.MethodGet, "/v1/downloads/"+created.ID, ""); strings.Contains(rr.Body.String(), "abc") || !strings.Contains(rr.Body.String(), "X-Token") {
+		t.Fatalf("get: %s", rr.Body.String())
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+	}{
+		{http.MethodPost, "/v1/downloads", `{"source":"https://example.com/b","targetPath":"/tmp","options":{"split":100}}`},
+		{http.MethodPost, "/v1/downloads", `{"source":"https://example.com/b","targetPath":"/tmp","options":{"out":"../escape"}}`},
+		{http.MethodPost, "/v1/downloads", `{"source":"https://example.com/b","targetPath":"/tmp","options":{"headers":{"X-A":"1\r\nX-B: 2"}}}`},
+		{http.MethodPost, "/v1/downloads", `{"source":"https://example.com/b","targetPath":"/tmp","options":{"checksum":"sha-256=abc"}}`},
+		{http.MethodPost, "/v1/downloads", `{"source":"https://example.com/b","targetPath":"/tmp","options":{"seedRatio":-1}}`},
+		{http.MethodPatch, "/v1/downloads/" + created.ID, `{"options":{"seedTime":-5}}`},
+		{http.MethodPatch, "/v1/downloads/" + created.ID, `{"options":{"out":"b.iso"}}`},
+		{http.MethodPatch, "/v1/downloads/" + created.ID, `{"options":{"split":0}}`},
+		{http.MethodPatch, "/v1/downloads/" + created.ID, `{"options":{}}`},
+	} {
+		if rr := do(tc.method, tc.path, tc.body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s %s: expected 400 got %d", tc.method, tc.body, rr.Code)
+		}
+	}
+
+	// A rejected PATCH applies none of its fields.
+	for _, body := range []string{
+		`{"priority":5,"options":{"split":0}}`,
+		`{"priority":5,"desiredStatus":"Bogus"}`,
+	} {
+		if rr := do(http.MethodPatch, "/v1/downloads/"+created.ID, body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400 got %d", body, rr.Code)
+		}
+	}
+	var got internaldata.Download
+	_ = json.NewDecoder(do(http.MethodGet, "/v1/downloads/"+created.ID, "").Body).Decode(&got)
+	if got.Priority != 0 {
+		t.Fatalf("rejected patch changed priority to %d", got.Priority)
+	}
+}
+
+func TestFileSelection(t *testing.T) {
+	h := setup(t)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		authReq(req)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/v1/downloads", `{"source":"magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567","targetPath":"/tmp","fileFilter":{"include":["*.mkv"],"exclude":["*sample*"]}}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201 got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created internaldata.Download
+	_ = json.NewDecoder(rr.Body).Decode(&created)
+	if f := created.FileFilter; f == nil || len(f.Include) != 1 || len(f.Exclude) != 1 {
+		t.Fatalf("fileFilter not stored: %+v", created.FileFilter)
+	}
+
+	if rr := do(http.MethodPost, "/v1/downloads", `{"source":"https://example.com/b","targetPath":"/tmp","fileFilter":{"include":["[a-"]}}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("bad pattern: expected 400 got %d", rr.Code)
+	}
+	// The noop downloader never reports files, so there is nothing to select yet.
+	if rr := do(http.MethodPatch, "/v1/downloads/"+created.ID+"/files", `{"selected":[1]}`); rr.Code != http.StatusConflict {
+		t.Fatalf("files unknown: expected 409 got %d", rr.Code)
+	}
+	if rr := do(http.MethodPatch, "/v1/downloads/missing/files", `{"selected":[1]}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("missing: expected 404 got %d", rr.Code)
+	}
+	if rr := do(http.MethodPatch, "/v1/downloads/"+created.ID+"/files", `{"selected":[1],"extra":true}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("unknown field: expected 400 got %d", rr.Code)
+	}
+}
+
+func TestPostProcess(t *testing.T) {
+	h := setup(t)
+	do := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/downloads", strings.NewReader(body))
+		authReq(req)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(`{"source":"https://example.com/a.zip","targetPath":"/tmp","postProcess":{"category":"tv","rename":"{{.Base}}-final{{.Ext}}","extract":true}}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201 got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created internaldata.Download
+	_ = json.NewDecoder(rr.Body).Decode(&created)
+	if pp := created.PostProcess; pp == nil || pp.Category != "tv" || !pp.Extract {
+		t.Fatalf("postProcess not stored: %+v", created.PostProcess)
+	}
+	if s := created.PostProcessStatus; s == nil || s.State != internaldata.PostProcessPending {
+		t.Fatalf("expected pending postProcessStatus, got %+v", created.PostProcessStatus)
+	}
+
+	for _, body := range []string{
+		`{"source":"https://example.com/b","targetPath":"/tmp","postProcess":{}}`,
+		`{"source":"https://example.com/b","targetPath":"/tmp","postProcess":{"dest":"relative/dir"}}`,
+		`{"source":"https://example.com/b","targetPath":"/tmp","postProcess":{"dest":"/"}}`,
+		`{"source":"https://example.com/b","targetPath":"/tmp","postProcess":{"dest":"/media","mode":"copy"}}`,
+		`{"source":"https://example.com/b","targetPath":"/tmp","postProcess":{"dest":"/media","rename":"{{.Name"}}`,
+		`{"source":"https://example.com/b","targetPath":"/tmp","postProcessStatus":{"state":"done"}}`,
+	} {
+		if rr := do(body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400 got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	h := setup(t)
+	do := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/downloads", strings.NewReader(body))
+		authReq(req)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(`{"source":"https://example.com/r","targetPath":"/tmp","retry":{"maxAttempts":5,"backoffSec":10,"retryableCodes":[6,19]}}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201 got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created internaldata.Download
+	_ = json.NewDecoder(rr.Body).Decode(&created)
+	if p := created.Retry; p == nil || p.MaxAttempts == nil || *p.MaxAttempts != 5 || len(p.RetryableCodes) != 2 || created.RetryStatus != nil {
+		t.Fatalf("retry not stored: %+v %+v", created.Retry, created.RetryStatus)
+	}
+
+	for _, body := range []string{
+		`{"source":"https://example.com/s","targetPath":"/tmp","retry":{"maxAttempts":-1}}`,
+		`{"source":"https://example.com/s","targetPath":"/tmp","retry":{"backoffSec":60,"maxBackoffSec":30}}`,
+		`{"source":"https://example.com/s","targetPath":"/tmp","retry":{"retryableCodes":[0]}}`,
+		`{"source":"https://example.com/s","targetPath":"/tmp","retryStatus":{"attempts":1}}`,
+		`{"source":"https://example.com/s","targetPath":"/tmp","failure":{"code":"network"}}`,
+	} {
+		if rr := do(body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400 got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestCreateFromUploadedTorrent(t *testing.T) {
+	h := setup(t)
+	torrent := []byte("d8:announce14:http://tracker4:infod6:lengthi12e4:name5:a.txt12:piece lengthi16384e6:pieces0:ee")
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	_ = mw.WriteField("targetPath", "/tmp")
+	_ = mw.WriteField("options", `{"maxDownloadLimit":1024}`)
+	fw, _ := mw.CreateFormFile("torrent", "a.torrent")
+	_, _ = fw.Write(torrent)
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/downloads", &buf)
+	authReq(req)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("multipart create: expected 201 got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created internaldata.Download
+	_ = json.NewDecoder(rr.Body).Decode(&created)
+	if !strings.HasPrefix(created.Source, "magnet:?xt=urn:btih:") || created.Options == nil || created.Options.MaxDownloadLimit != 1024 {
+		t.Fatalf("unexpected download: %+v", created)
+	}
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/downloads", strings.NewReader(body))
+		authReq(req)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	// The same torrent as base64 JSON is an idempotent hit.
+	b64 := base64.StdEncoding.EncodeToString(torrent)
+	rr = post(`{"torrent":"` + b64 + `","targetPath":"/tmp"}`)
+	var again internaldata.Download
+	_ = json.NewDecoder(rr.Body).Decode(&again)
+	if rr.Code != http.StatusOK || again.ID != created.ID {
+		t.Fatalf("base64 re-upload: expected 200 with id %s, got %d %s", created.ID, rr.Code, again.ID)
+	}
+
+	for _, body := range []string{
+		`{"torrent":"` + b64 + `","source":"magnet:?xt=urn:btih:x","targetPath":"/tmp"}`,
+		`{"torrent":"` + b64 + `","metalink":"PG1ldGFsaW5rLz4=","targetPath":"/tmp"}`,
+		`{"torrent":"` + base64.StdEncoding.EncodeToString([]byte("garbage")) + `","targetPath":"/tmp"}`,
+	} {
+		if rr := post(body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400 got %d", body, rr.Code)
+		}
+	}
+
+	buf.Reset()
+	mw = multipart.NewWriter(&buf)
+	_ = mw.WriteField("targetPath", "/tmp")
+	_ = mw.Close()
+	req = httptest.NewRequest(http.MethodPost, "/v1/downloads", &buf)
+	authReq(req)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("multipart without file: expected 400 got %d", rr.Code)
+	}
+}
@@ -0,0 +1,88 @@
+package v1_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	internaldata "github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/downloader"
+	"github.com/tinoosan/torrus/internal/history"
+	"github.com/tinoosan/torrus/internal/repo"
+	"github.com/tinoosan/torrus/internal/router"
+	"github.com/tinoosan/torrus/internal/service"
+)
+
+func TestGetHistory(t *testing.T) {
+	t.Setenv("TORRUS_API_TOKEN", testToken)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	hist := repo.NewInMemoryHistoryRepo()
+	dr := history.Wrap(logger, repo.NewInMemoryDownloadRepo(), hist)
+	dlr := downloader.NewNoopDownloader()
+	h := router.New(logger, service.NewDownload(dr, dlr, service.WithHistory(hist)), dlr)
+
+	do := func(method, path, body, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		authReq(req)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Request-ID", id)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/v1/downloads", `{"source":"https://example.com/a","targetPath":"/tmp"}`, "req-add")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("add: %d %s", rr.Code, rr.Body.String())
+	}
+	var dl internaldata.Download
+	if err := json.NewDecoder(rr.Body).Decode(&dl); err != nil {
+		t.Fatal(err)
+	}
+	if rr := do(http.MethodPatch, "/v1/downloads/"+dl.ID, `{"desiredStatus":"Paused"}`, "req-pause"); rr.Code != http.StatusOK {
+		t.Fatalf("pause: %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = do(http.MethodGet, "/v1/downloads/"+dl.ID+"/history", "", "req-get")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("history: %d %s", rr.Code, rr.Body.String())
+	}
+	var got []internaldata.Transition
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) < 2 {
+		t.Fatalf("history = %+v, want at least 2 entries", got)
+	}
+	first, last := got[0], got[len(got)-1]
+	if first.From != "" || first.Cause != internaldata.CauseAPI || first.RequestID != "req-add" {
+		t.Fatalf("first = %+v", first)
+	}
+	if last.DesiredStatus != internaldata.StatusPaused || last.Cause != internaldata.CauseAPI || last.RequestID != "req-pause" {
+		t.Fatalf("last = %+v", last)
+	}
+
+	if rr := do(http.MethodGet, "/v1/downloads/nope/history", "", "req-missing"); rr.Code != http.StatusNotFound {
+		t.Fatalf("missing: %d", rr.Code)
+	}
+
+	// History outlives its download.
+	if rr := do(http.MethodDelete, "/v1/downloads/"+dl.ID, "", "req-delete"); rr.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %s", rr.Code, rr.Body.String())
+	}
+	rr = do(http.MethodGet, "/v1/downloads/"+dl.ID+"/history", "", "req-after")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("history after delete: %d %s", rr.Code, rr.Body.String())
+	}
+	var after []internaldata.Transition
+	if err := json.NewDecoder(rr.Body).Decode(&after); err != nil {
+		t.Fatal(err)
+	}
+	if len(after) < len(got) {
+		t.Fatalf("history after delete = %+v", after)
+	}
+}
@@ -28,3 +28,12 @@ func decodeJSONStrict(w http.ResponseWriter, r *http.Request, dst any, maxBytes
     return nil
 }
 
+// decodeBody decodes a strict JSON body of at most 1 MiB into dst, writing
+// the error response itself and reporting false on failure.
+func decodeBody(w http.ResponseWriter, r *http.Request, dst any) bool {
+    if err := decodeJSONStrict(w, r, dst, 1<<20, "application/json"); err != nil {
+        writeDecodeError(w, r, err)
+        return false
+    }
+    return true
+}
@@ -0,0 +1,136 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/service"
+)
+
+// knownStatuses lists the values accepted by the status and desiredStatus
+// list filters.
+var knownStatuses = map[data.DownloadStatus]bool{
+	data.StatusQueued:    true,
+	data.StatusActive:    true,
+	data.StatusResume:    true,
+	data.StatusPaused:    true,
+	data.StatusSeeding:   true,
+	data.StatusComplete:  true,
+	data.StatusCancelled: true,
+	data.StatusError:     true,
+}
+
+// parseDownloadQuery builds a DownloadQuery from GET /v1/downloads query
+// parameters. Multi-valued filters may be repeated or comma-separated.
+func parseDownloadQuery(v url.Values) (data.DownloadQuery, error) {
+	var q data.DownloadQuery
+	if s := v.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > data.MaxQueryLimit {
+			return q, ErrLimit
+		}
+		q.Limit = n
+	}
+	q.Cursor = v.Get("cursor")
+	if s := v.Get("sort"); s != "" {
+		q.Sort = data.DownloadSort(s)
+		if !q.Sort.Valid() {
+			return q, ErrSort
+		}
+	}
+	var err error
+	if q.Statuses, err = parseStatuses(v["status"]); err != nil {
+		return q, err
+	}
+	if q.DesiredStatuses, err = parseStatuses(v["desiredStatus"]); err != nil {
+		return q, err
+	}
+	q.TargetPathPrefix = v.Get("targetPathPrefix")
+	q.NameContains = v.Get("name")
+	q.Category = v.Get("category")
+	for _, raw := range v["label"] {
+		for _, l := range strings.Split(raw, ",") {
+			if l = strings.TrimSpace(l); l != "" {
+				q.Labels = append(q.Labels, l)
+			}
+		}
+	}
+	if q.CreatedFrom, err = parseTime(v.Get("createdFrom")); err != nil {
+		return q, err
+	}
+	if q.CreatedTo, err = parseTime(v.Get("createdTo")); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+func parseStatuses(raw []string) ([]data.DownloadStatus, error) {
+	var out []data.DownloadStatus
+	for _, r := range raw {
+		for _, s := range strings.Split(r, ",") {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+			st := data.DownloadStatus(s)
+			if !knownStatuses[st] {
+				return nil, ErrStatusFilter
+			}
+			out = append(out, st)
+		}
+	}
+	return out, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, ErrCreatedRange
+	}
+	return t, nil
+}
+
+// paginated reports whether a GET /v1/downloads request asked for a page.
+// Requests without limit or cursor get every matching download, as they did
+// before the listing was paginated.
+func paginated(v url.Values) bool {
+	return v.Has("limit") || v.Has("cursor")
+}
+
+// queryAll collects every page of q into one.
+func queryAll(ctx context.Context, svc service.Download, q data.DownloadQuery) (*data.DownloadPage, error) {
+	q.Limit = data.MaxQueryLimit
+	all := &data.DownloadPage{Items: data.Downloads{}}
+	for {
+		page, err := svc.Query(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		all.Items = append(all.Items, page.Items...)
+		if page.NextCursor == "" {
+			return all, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// setNextPage advertises the next page of a listing through the
+// X-Next-Cursor header and an RFC 8288 Link header.
+func setNextPage(w http.ResponseWriter, r *http.Request, cursor string) {
+	if cursor == "" {
+		return
+	}
+	w.Header().Set("X-Next-Cursor", cursor)
+	q := r.URL.Query()
+	q.Set("cursor", cursor)
+	next := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+	w.Header().Set("Link", "<"+next.String()+`>; rel="next"`)
+}
@@ -2,7 +2,7 @@ package v1
 
 import (
     "context"
-    "errors"
+    "fmt"
     "net/http"
     "time"
 
@@ -12,28 +12,70 @@ import (
 
 func MiddlewareDownloadValidation(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        // Decode with strict fields and consistent validation
-        dl := &data.Download{}
-        if err := decodeJSONStrict(w, r, dl, 1<<20, "application/json"); err != nil {
-            markErr(w, err)
-            if errors.Is(err, ErrContentType) {
-                http.Error(w, ErrContentType.Error(), http.StatusUnsupportedMediaType)
-            } else {
-                http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+        var (
+            dl  *data.Download
+            err error
+        )
+        if isMultipart(r) {
+            // Uploaded .torrent/metalink files arrive as multipart/form-data.
+            if dl, err = decodeUpload(w, r); err != nil {
+                writeError(w, r, http.StatusBadRequest, fmt.Errorf("%w: %w", ErrUpload, err))
+                return
             }
+        } else if dl, err = decodeCreateJSON(w, r); err != nil {
+            // Decode with strict fields and consistent validation
+            writeDecodeError(w, r, err)
             return
         }
 
 		// Enforce read-only fields: reject if client sets name.
 		if dl.Name != "" {
-			markErr(w, ErrReadOnlyName)
-			http.Error(w, ErrReadOnlyName.Error(), http.StatusBadRequest)
+			writeError(w, r, http.StatusBadRequest, ErrReadOnlyName)
 			return
 		}
         // Enforce read-only fields: reject if client sets files.
         if len(dl.Files) > 0 {
-            markErr(w, ErrReadOnlyFiles)
-            http.Error(w, ErrReadOnlyFiles.Error(), http.StatusBadRequest)
+            writeError(w, r, http.StatusBadRequest, ErrReadOnlyFiles)
+            return
+        }
+        // Enforce read-only fields: reject if client sets progress.
+        if dl.Progress != nil {
+            writeError(w, r, http.StatusBadRequest, ErrReadOnlyProgress)
+            return
+        }
+        // Enforce read-only fields: reject if client sets queuePosition.
+        if dl.QueuePosition != 0 {
+            writeError(w, r, http.StatusBadRequest, ErrReadOnlyQueuePosition)
+            return
+        }
+        // Enforce read-only fields: reject if client sets postProcessStatus.
+        if dl.PostProcessStatus != nil {
+            writeError(w, r, http.StatusBadRequest, ErrReadOnlyPostProcessStatus)
+            return
+        }
+        // Enforce read-only fields: reject if client sets retryStatus.
+        if dl.RetryStatus != nil {
+            writeError(w, r, http.StatusBadRequest, ErrReadOnlyRetryStatus)
+            return
+        }
+        // Enforce read-only fields: reject if client sets failure.
+        if dl.Failure != nil {
+            writeError(w, r, http.StatusBadRequest, ErrReadOnlyFailure)
+            return
+        }
+        // Reject options the downloader could not honour.
+        if err := dl.Options.Validate(); err != nil {
+            writeError(w, r, http.StatusBadRequest, err)
+            return
+        }
+        if err := dl.FileFilter.Validate(); err != nil {
+            writeError(w, r, http.StatusBadRequest, err)
+            return
+        }
+        // PostProcess is validated by the service once category defaults
+        // are applied: a category can supply its destination.
+        if err := dl.Retry.Validate(); err != nil {
+            writeError(w, r, http.StatusBadRequest, err)
             return
         }
 
@@ -46,19 +88,12 @@ func MiddlewarePatchDesired(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
         var body patchBody
         if err := decodeJSONStrict(w, r, &body, 1<<20, "application/json"); err != nil {
-            markErr(w, err)
-            if errors.Is(err, ErrContentType) {
-                http.Error(w, ErrContentType.Error(), http.StatusUnsupportedMediaType)
-            } else {
-                // Preserve original behavior: do not prefix with "invalid JSON: "
-                http.Error(w, err.Error(), http.StatusBadRequest)
-            }
+            writeDecodeError(w, r, err)
             return
         }
 
-		if body.DesiredStatus == "" {
-			markErr(w, ErrDesiredStatusJSON)
-			http.Error(w, ErrDesiredStatusJSON.Error(), http.StatusBadRequest)
+		if body.DesiredStatus == "" && body.Priority == nil && body.Options == nil {
+			writeError(w, r, http.StatusBadRequest, ErrPatchFields)
 			return
 		}
 
@@ -0,0 +1,155 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/lifecycle"
+	"github.com/tinoosan/torrus/internal/pathsafe"
+	"github.com/tinoosan/torrus/internal/reqid"
+	"github.com/tinoosan/torrus/internal/service"
+)
+
+// problemContentType is the media type of error responses (RFC 7807).
+const problemContentType = "application/problem+json"
+
+// problemTypePrefix prefixes the stable problem type codes.
+const problemTypePrefix = "urn:torrus:problem:"
+
+// Problem is an RFC 7807 problem details object. Every error response of
+// the v1 API carries one.
+type Problem struct {
+	// Type identifies the kind of problem, e.g. urn:torrus:problem:not-found.
+	// Problems without a specific code use about:blank.
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Instance is the request path.
+	Instance string `json:"instance,omitempty"`
+	// RequestID is the X-Request-ID of the failed request.
+	RequestID string `json:"requestId,omitempty"`
+	// Allowed lists the statuses that may be requested instead, for
+	// urn:torrus:problem:illegal-transition. It is omitted when there are
+	// none.
+	Allowed []data.DownloadStatus `json:"allowed,omitempty"`
+}
+
+// problemTypes maps errors to their problem type code. The first entry the
+// error matches with errors.Is wins.
+var problemTypes = []struct {
+	err  error
+	code string
+}{
+	// Domain errors.
+	{data.ErrNotFound, "not-found"},
+	{data.ErrBadStatus, "bad-status"},
+	{data.ErrConflict, "conflict"},
+	{data.ErrInvalidSource, "invalid-source"},
+	{data.ErrTargetPath, "invalid-target-path"},
+	{data.ErrInvalidPayload, "invalid-payload"},
+	{data.ErrInvalidOptions, "invalid-options"},
+	{data.ErrInvalidFileSelection, "invalid-file-selection"},
+	{data.ErrFilesUnknown, "files-unknown"},
+	{data.ErrInvalidPostProcess, "invalid-post-process"},
+	{data.ErrInvalidRetry, "invalid-retry"},
+	{data.ErrInvalidMove, "invalid-move"},
+	{data.ErrNotQueued, "not-queued"},
+	{data.ErrInvalidCursor, "invalid-cursor"},
+	{data.ErrInvalidQuery, "invalid-query"},
+	{data.ErrCategoryNotFound, "category-not-found"},
+	{data.ErrCategoryExists, "category-exists"},
+	{data.ErrInvalidCategory, "invalid-category"},
+	{data.ErrInvalidLabels, "invalid-labels"},
+	{data.ErrWebhookNotFound, "webhook-not-found"},
+	{data.ErrInvalidWebhook, "invalid-webhook"},
+	{data.ErrInvalidBandwidth, "invalid-bandwidth"},
+	{pathsafe.ErrNotAllowed, "path-not-allowed"},
+	{lifecycle.ErrIllegalTransition, "illegal-transition"},
+	{service.ErrBatchTarget, "batch-target"},
+	{service.ErrBatchAction, "batch-action"},
+	{service.ErrBatchTooLarge, "batch-too-large"},
+
+	// Request errors.
+	{ErrDownloadCtx, "download-context-missing"},
+	{ErrDesiredStatus, "desired-status-missing"},
+	{ErrPatchFields, "patch-fields-required"},
+	{ErrTargetPath, "target-path-required"},
+	{ErrContentType, "unsupported-content-type"},
+	{ErrInvalidJSON, "invalid-json"},
+	{ErrMagnetURI, "invalid-magnet-uri"},
+	{ErrReadOnlyName, "read-only-name"},
+	{ErrReadOnlyFiles, "read-only-files"},
+	{ErrReadOnlyProgress, "read-only-progress"},
+	{ErrReadOnlyQueuePosition, "read-only-queue-position"},
+	{ErrReadOnlyPostProcessStatus, "read-only-post-process-status"},
+	{ErrReadOnlyRetryStatus, "read-only-retry-status"},
+	{ErrReadOnlyFailure, "read-only-failure"},
+	{ErrLimit, "invalid-limit"},
+	{ErrSort, "invalid-sort"},
+	{ErrStatusFilter, "invalid-status-filter"},
+	{ErrCreatedRange, "invalid-created-range"},
+	{ErrDeleteFiles, "delete-files-requires-delete"},
+	{ErrEmptyFilter, "empty-filter"},
+	{ErrMoveTo, "invalid-move-to"},
+	{ErrUploadSource, "upload-source-conflict"},
+	{ErrUploadFile, "upload-file-required"},
+	{ErrUploadSize, "upload-too-large"},
+	{ErrUpload, "invalid-upload"},
+	{ErrLastEventID, "invalid-last-event-id"},
+}
+
+// problemType returns the problem type of err, or about:blank.
+func problemType(err error) string {
+	for _, t := range problemTypes {
+		if errors.Is(err, t.err) {
+			return problemTypePrefix + t.code
+		}
+	}
+	return "about:blank"
+}
+
+// writeDecodeError responds to a request body that could not be decoded.
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrContentType) {
+		writeError(w, r, http.StatusUnsupportedMediaType, err)
+		return
+	}
+	writeError(w, r, http.StatusBadRequest, fmt.Errorf("%w: %v", ErrInvalidJSON, err))
+}
+
+// internalErrorDetail is the detail of every 5xx problem. The cause, which
+// may carry database or aria2 internals, only goes to the request log.
+const internalErrorDetail = "internal error; the cause is logged under this requestId"
+
+// writeError records err for the request log and responds with a problem
+// details body for it. Server errors get a generic detail.
+func writeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	markErr(w, err)
+	p := Problem{
+		Type:     problemType(err),
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   err.Error(),
+		Instance: r.URL.Path,
+	}
+	if status >= http.StatusInternalServerError {
+		p.Detail = internalErrorDetail
+	}
+	if id, ok := reqid.From(r.Context()); ok {
+		p.RequestID = id
+	}
+	var te *lifecycle.TransitionError
+	if errors.As(err, &te) {
+		p.Allowed = te.Allowed
+	}
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", problemContentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,140 @@
+package v1_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "github.com/tinoosan/torrus/api/v1"
+	internaldata "github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/downloader"
+	"github.com/tinoosan/torrus/internal/repo"
+	"github.com/tinoosan/torrus/internal/router"
+	"github.com/tinoosan/torrus/internal/service"
+)
+
+// TestProblemDetails ensures error responses are RFC 7807 problem details
+// with a stable type and the request ID.
+func TestProblemDetails(t *testing.T) {
+	h := setup(t)
+	cases := []struct {
+		name, method, path, ctype, body string
+		status                          int
+		typ                             string
+	}{
+		{"not found", http.MethodGet, "/v1/downloads/nope", "", "", http.StatusNotFound, "urn:torrus:problem:not-found"},
+		{"read-only field", http.MethodPost, "/v1/downloads", "application/json", `{"source":"https://example.com/a","targetPath":"/tmp","name":"x"}`, http.StatusBadRequest, "urn:torrus:problem:read-only-name"},
+		{"invalid JSON", http.MethodPost, "/v1/downloads", "application/json", `{"source":`, http.StatusBadRequest, "urn:torrus:problem:invalid-json"},
+		{"content type", http.MethodPost, "/v1/downloads", "text/plain", `{}`, http.StatusUnsupportedMediaType, "urn:torrus:problem:unsupported-content-type"},
+		{"bad status", http.MethodPatch, "/v1/downloads/nope", "application/json", `{"desiredStatus":"Bogus"}`, http.StatusBadRequest, "urn:torrus:problem:bad-status"},
+		{"invalid query", http.MethodGet, "/v1/downloads?limit=0", "", "", http.StatusBadRequest, "urn:torrus:problem:invalid-limit"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+			authReq(req)
+			if c.ctype != "" {
+				req.Header.Set("Content-Type", c.ctype)
+			}
+			req.Header.Set("X-Request-ID", "req-123")
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != c.status {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, c.status, rr.Body.String())
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Fatalf("Content-Type = %q", ct)
+			}
+			var p v1.Problem
+			if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			want := v1.Problem{Type: c.typ, Title: http.StatusText(c.status), Status: c.status, Instance: strings.Split(c.path, "?")[0], RequestID: "req-123"}
+			if p.Detail == "" {
+				t.Fatalf("empty detail: %+v", p)
+			}
+			p.Detail = ""
+			if !reflect.DeepEqual(p, want) {
+				t.Fatalf("problem = %+v, want %+v", p, want)
+			}
+		})
+	}
+}
+
+// brokenRepo fails every insert with an error carrying database internals.
+type brokenRepo struct {
+	*repo.InMemoryDownloadRepo
+}
+
+func (brokenRepo) AddWithFingerprint(ctx context.Context, d *internaldata.Download, fpv string) (*internaldata.Download, bool, error) {
+	return nil, false, errors.New(`pq: relation "downloads" does not exist`)
+}
+
+// TestServerErrorDetail ensures 5xx problems do not echo the underlying
+// error to the client.
+func TestServerErrorDetail(t *testing.T) {
+	t.Setenv("TORRUS_API_TOKEN", testToken)
+	dlr := downloader.NewNoopDownloader()
+	svc := service.NewDownload(brokenRepo{repo.NewInMemoryDownloadRepo()}, dlr)
+	h := router.New(slog.New(slog.NewTextHandler(io.Discard, nil)), svc, dlr)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/downloads", strings.NewReader(`{"source":"https://example.com/a","targetPath":"/tmp"}`))
+	authReq(req)
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d: %s", rr.Code, rr.Body.String())
+	}
+	var p v1.Problem
+	if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if p.Detail == "" || strings.Contains(p.Detail, "pq:") || strings.Contains(p.Detail, "downloads") {
+		t.Fatalf("detail leaks the cause: %q", p.Detail)
+	}
+}
+
+// TestIllegalTransitionProblem ensures a request the state machine rejects
+// is a 409 listing the statuses that may be requested instead.
+func TestIllegalTransitionProblem(t *testing.T) {
+	h := setup(t)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		authReq(req)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/v1/downloads", `{"source":"https://example.com/a","targetPath":"/tmp"}`)
+	var dl internaldata.Download
+	if err := json.NewDecoder(rr.Body).Decode(&dl); err != nil {
+		t.Fatal(err)
+	}
+	if rr := do(http.MethodPatch, "/v1/downloads/"+dl.ID, `{"desiredStatus":"Cancelled"}`); rr.Code != http.StatusOK {
+		t.Fatalf("cancel: %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = do(http.MethodPatch, "/v1/downloads/"+dl.ID, `{"desiredStatus":"Resume"}`)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("resume: %d %s", rr.Code, rr.Body.String())
+	}
+	var p v1.Problem
+	if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Type != "urn:torrus:problem:illegal-transition" || !reflect.DeepEqual(p.Allowed, []internaldata.DownloadStatus{internaldata.StatusCancelled}) {
+		t.Fatalf("problem = %+v", p)
+	}
+}
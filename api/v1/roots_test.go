@@ -0,0 +1,106 @@
+package v1_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/downloader"
+	"github.com/tinoosan/torrus/internal/pathsafe"
+	"github.com/tinoosan/torrus/internal/repo"
+	"github.com/tinoosan/torrus/internal/router"
+	"github.com/tinoosan/torrus/internal/service"
+)
+
+func TestAllowedRoots(t *testing.T) {
+	t.Setenv("TORRUS_API_TOKEN", testToken)
+	dir := t.TempDir()
+	root := filepath.Join(dir, "downloads")
+	if err := os.Mkdir(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/etc", filepath.Join(root, "etc")); err != nil {
+		t.Fatal(err)
+	}
+	roots, err := pathsafe.ParseRoots(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dlr := downloader.NewNoopDownloader()
+	cats := repo.NewInMemoryCategoryRepo()
+	svc := service.NewDownload(repo.NewInMemoryDownloadRepo(), dlr, service.WithCategories(cats), service.WithRoots(roots))
+	h := router.New(logger, svc, dlr, router.WithCategories(service.NewCategory(cats, roots)))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		authReq(req)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, target := range []string{"/etc", root + "/../escape", root + "/etc", root + "/etc/cron.d"} {
+		rr := do(http.MethodPost, "/v1/downloads", `{"source":"magnet:?xt=urn:btih:abc","targetPath":"`+target+`"}`)
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "allowed roots") {
+			t.Errorf("target %s: expected 400 got %d: %s", target, rr.Code, rr.Body.String())
+		}
+	}
+	rr := do(http.MethodPost, "/v1/downloads", `{"source":"magnet:?xt=urn:btih:abc","targetPath":"`+root+`/tv","postProcess":{"dest":"/etc"}}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("postProcess dest: expected 400 got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/v1/downloads", `{"source":"magnet:?xt=urn:btih:abc","targetPath":"`+root+`/tv"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("allowed: expected 201 got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := do(http.MethodPost, "/v1/categories", `{"name":"sys","targetPath":"/etc"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("category create: expected 400 got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/v1/categories", `{"name":"tv","targetPath":"`+root+`/tv"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("category create: expected 201 got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPatch, "/v1/categories/tv", `{"targetPath":"/var"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("category patch: expected 400 got %d", rr.Code)
+	}
+}
+
+// refusingDL refuses to delete files like the aria2 adapter does for a
+// download outside the allowed roots.
+type refusingDL struct{ downloader.Downloader }
+
+func (refusingDL) Delete(ctx context.Context, d *data.Download, deleteFiles bool) error {
+	return fmt.Errorf("refusing to delete: %w", pathsafe.ErrNotAllowed)
+}
+
+func TestDeleteFilesOutsideRoots(t *testing.T) {
+	t.Setenv("TORRUS_API_TOKEN", testToken)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	rpo := repo.NewInMemoryDownloadRepo()
+	dlr := refusingDL{downloader.NewNoopDownloader()}
+	h := router.New(logger, service.NewDownload(rpo, dlr), dlr)
+	dl, err := rpo.Add(context.Background(), &data.Download{Source: "magnet:?xt=urn:btih:abc", TargetPath: "/etc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/downloads/"+dl.ID, strings.NewReader(`{"deleteFiles":true}`))
+	authReq(req)
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "urn:torrus:problem:path-not-allowed") {
+		t.Fatalf("expected 400 path-not-allowed got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := rpo.Get(context.Background(), dl.ID); err != nil {
+		t.Fatalf("refused delete removed the download: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package v1
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/service"
+)
+
+// SettingsHandler serves the /v1/settings resources.
+type SettingsHandler struct {
+	l         *slog.Logger
+	bandwidth service.Bandwidth
+}
+
+type bandwidthBody struct {
+	MaxDownloadLimit int64                `json:"maxDownloadLimit"`
+	MaxUploadLimit   int64                `json:"maxUploadLimit"`
+	Schedule         []data.BandwidthRule `json:"schedule"`
+}
+
+func NewSettingsHandler(l *slog.Logger, bandwidth service.Bandwidth) *SettingsHandler {
+	return &SettingsHandler{l: l, bandwidth: bandwidth}
+}
+
+func (sh *SettingsHandler) GetBandwidth(w http.ResponseWriter, r *http.Request) {
+	s, err := sh.bandwidth.Get(r.Context())
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = s.ToJSON(w)
+}
+
+func (sh *SettingsHandler) PutBandwidth(w http.ResponseWriter, r *http.Request) {
+	var body bandwidthBody
+	if !decodeBody(w, r, &body) {
+		return
+	}
+	saved, err := sh.bandwidth.Set(r.Context(), &data.BandwidthSettings{
+		BandwidthLimits: data.BandwidthLimits{MaxDownloadLimit: body.MaxDownloadLimit, MaxUploadLimit: body.MaxUploadLimit},
+		Schedule:        body.Schedule,
+	})
+	if err != nil {
+		if errors.Is(err, data.ErrInvalidBandwidth) {
+			writeError(w, r, http.StatusBadRequest, err)
+		} else {
+			writeError(w, r, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = saved.ToJSON(w)
+}
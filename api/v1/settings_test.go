@@ -0,0 +1,67 @@
+package v1_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tinoosan/torrus/internal/bandwidth"
+	internaldata "github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/downloader"
+	"github.com/tinoosan/torrus/internal/repo"
+	"github.com/tinoosan/torrus/internal/router"
+	"github.com/tinoosan/torrus/internal/service"
+)
+
+func TestBandwidthSettings(t *testing.T) {
+	t.Setenv("TORRUS_API_TOKEN", testToken)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dlr := downloader.NewNoopDownloader()
+	settings := repo.NewInMemorySettingsRepo()
+	svc := service.NewBandwidth(settings, bandwidth.New(logger, settings, nil))
+	h := router.New(logger, service.NewDownload(repo.NewInMemoryDownloadRepo(), dlr), dlr, router.WithBandwidth(svc))
+	do := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v1/settings/bandwidth", strings.NewReader(body))
+		authReq(req)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodGet, "")
+	var s internaldata.BandwidthSettings
+	_ = json.NewDecoder(rr.Body).Decode(&s)
+	if rr.Code != http.StatusOK || s.MaxDownloadLimit != 0 || s.Schedule == nil || len(s.Schedule) != 0 {
+		t.Fatalf("initial: %d %+v", rr.Code, s)
+	}
+
+	// A rule spanning the whole day is always in force.
+	rr = do(http.MethodPut, `{"maxDownloadLimit":1048576,"schedule":[{"days":["mon","tue","wed","thu","fri","sat","sun"],"start":"00:00","end":"00:00"}]}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("equal start/end: expected 400 got %d", rr.Code)
+	}
+	rr = do(http.MethodPut, `{"maxDownloadLimit":1048576,"maxUploadLimit":-1}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("negative: expected 400 got %d", rr.Code)
+	}
+	rr = do(http.MethodPut, `{"maxDownloadLimit":1048576,"schedule":[{"start":"00:00","end":"23:59","maxDownloadLimit":2048,"maxUploadLimit":512}],"active":{}}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("read-only field: expected 400 got %d", rr.Code)
+	}
+
+	rr = do(http.MethodPut, `{"maxDownloadLimit":1048576,"schedule":[{"days":["SAT","sun"],"start":"22:00","end":"06:00","maxUploadLimit":512},{"start":"00:00","end":"23:59","maxDownloadLimit":2048}]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("put: expected 200 got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = do(http.MethodGet, "")
+	s = internaldata.BandwidthSettings{}
+	_ = json.NewDecoder(rr.Body).Decode(&s)
+	if s.MaxDownloadLimit != 1048576 || len(s.Schedule) != 2 || s.Schedule[0].Days[0] != "sat" || s.UpdatedAt.IsZero() || s.Active == nil || s.Active.Rule == nil {
+		t.Fatalf("get after put: %+v", s)
+	}
+}
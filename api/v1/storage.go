@@ -0,0 +1,26 @@
+package v1
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/tinoosan/torrus/internal/storage"
+)
+
+// StorageHandler serves the free-space state of the allowed roots.
+type StorageHandler struct {
+	l       *slog.Logger
+	watcher *storage.Watcher
+}
+
+func NewStorageHandler(l *slog.Logger, w *storage.Watcher) *StorageHandler {
+	return &StorageHandler{l: l, watcher: w}
+}
+
+func (sh *StorageHandler) GetStorage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(sh.watcher.Report()); err != nil {
+		sh.l.Error("encode storage report", "err", err)
+	}
+}
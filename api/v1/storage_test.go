@@ -0,0 +1,47 @@
+package v1_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tinoosan/torrus/internal/downloader"
+	"github.com/tinoosan/torrus/internal/pathsafe"
+	"github.com/tinoosan/torrus/internal/repo"
+	"github.com/tinoosan/torrus/internal/router"
+	"github.com/tinoosan/torrus/internal/service"
+	"github.com/tinoosan/torrus/internal/storage"
+)
+
+func TestGetStorage(t *testing.T) {
+	t.Setenv("TORRUS_API_TOKEN", testToken)
+	roots, err := pathsafe.ParseRoots(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dlr := downloader.NewNoopDownloader()
+	r := repo.NewInMemoryDownloadRepo()
+	w := storage.New(logger, r, dlr, storage.Config{Roots: roots, MinFree: 1, CriticalFree: 1})
+	w.Check(context.Background())
+	h := router.New(logger, service.NewDownload(r, dlr), dlr, router.WithStorage(w))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/storage", nil)
+	authReq(req)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rr.Code)
+	}
+	var rep storage.Report
+	if err := json.NewDecoder(rr.Body).Decode(&rep); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if rep.MinFreeBytes != 1 || len(rep.Roots) != 1 || rep.Roots[0].Path != roots[0] || rep.Roots[0].TotalBytes <= 0 || rep.Roots[0].Level != storage.LevelOK || rep.Roots[0].CheckedAt.IsZero() {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+}
@@ -0,0 +1,144 @@
+package v1
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/tinoosan/torrus/internal/data"
+)
+
+// Create body limits. JSON bodies keep the 1 MiB cap of every other JSON
+// endpoint, which bounds base64 uploads to roughly 750 KiB; larger files
+// go through multipart/form-data.
+const (
+    maxCreateJSONBytes = 1 << 20
+    maxUploadBytes     = 10 << 20
+)
+
+// createBody is the JSON create request: a download plus, in place of
+// source, an optional base64-encoded .torrent or metalink file.
+type createBody struct {
+    data.Download
+    Torrent  []byte `json:"torrent"`
+    Metalink []byte `json:"metalink"`
+}
+
+// isMultipart reports whether r carries a multipart/form-data body.
+func isMultipart(r *http.Request) bool {
+    return strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data")
+}
+
+// decodeCreateJSON decodes a JSON create request into a download, moving an
+// embedded torrent or metalink into its payload.
+func decodeCreateJSON(w http.ResponseWriter, r *http.Request) (*data.Download, error) {
+    var body createBody
+    if err := decodeJSONStrict(w, r, &body, maxCreateJSONBytes, "application/json"); err != nil {
+        return nil, err
+    }
+    dl := &body.Download
+    if err := attachPayload(dl, data.PayloadTorrent, body.Torrent); err != nil {
+        return nil, err
+    }
+    if err := attachPayload(dl, data.PayloadMetalink, body.Metalink); err != nil {
+        return nil, err
+    }
+    return dl, nil
+}
+
+// decodeUpload decodes a multipart/form-data create request. The file goes
+// in a "torrent" or "metalink" part; targetPath, desiredStatus, priority,
+// category, and labels, options, fileFilter, postProcess and retry (as JSON)
+// are optional form fields.
+func decodeUpload(w http.ResponseWriter, r *http.Request) (*data.Download, error) {
+    r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes+1<<20)
+    mr, err := r.MultipartReader()
+    if err != nil {
+        return nil, err
+    }
+    dl := &data.Download{}
+    for {
+        part, err := mr.NextPart()
+        if errors.Is(err, io.EOF) {
+            break
+        }
+        if err != nil {
+            return nil, err
+        }
+        b, err := io.ReadAll(io.LimitReader(part, maxUploadBytes+1))
+        _ = part.Close()
+        if err != nil {
+            return nil, err
+        }
+        if len(b) > maxUploadBytes {
+            return nil, ErrUploadSize
+        }
+        switch name := part.FormName(); name {
+        case "torrent", "metalink":
+            if err := attachPayload(dl, data.PayloadType(name), b); err != nil {
+                return nil, err
+            }
+        case "targetPath":
+            dl.TargetPath = string(b)
+        case "desiredStatus":
+            dl.DesiredStatus = data.DownloadStatus(b)
+        case "priority":
+            if dl.Priority, err = strconv.Atoi(string(b)); err != nil {
+                return nil, fmt.Errorf("priority: %w", err)
+            }
+        case "options":
+            dec := json.NewDecoder(strings.NewReader(string(b)))
+            dec.DisallowUnknownFields()
+            if err := dec.Decode(&dl.Options); err != nil {
+                return nil, fmt.Errorf("options: %w", err)
+            }
+        case "fileFilter":
+            dec := json.NewDecoder(strings.NewReader(string(b)))
+            dec.DisallowUnknownFields()
+            if err := dec.Decode(&dl.FileFilter); err != nil {
+                return nil, fmt.Errorf("fileFilter: %w", err)
+            }
+        case "category":
+            dl.Category = string(b)
+        case "labels":
+            if err := json.Unmarshal(b, &dl.Labels); err != nil {
+                return nil, fmt.Errorf("labels: %w", err)
+            }
+        case "postProcess":
+            dec := json.NewDecoder(strings.NewReader(string(b)))
+            dec.DisallowUnknownFields()
+            if err := dec.Decode(&dl.PostProcess); err != nil {
+                return nil, fmt.Errorf("postProcess: %w", err)
+            }
+        case "retry":
+            dec := json.NewDecoder(strings.NewReader(string(b)))
+            dec.DisallowUnknownFields()
+            if err := dec.Decode(&dl.Retry); err != nil {
+                return nil, fmt.Errorf("retry: %w", err)
+            }
+        default:
+            return nil, fmt.Errorf("unknown form field %q", name)
+        }
+    }
+    if len(dl.Payload) == 0 {
+        return nil, ErrUploadFile
+    }
+    return dl, nil
+}
+
+// attachPayload sets b as the payload of dl. A download carries exactly
+// one of source or an uploaded file.
+func attachPayload(dl *data.Download, typ data.PayloadType, b []byte) error {
+    if len(b) == 0 {
+        return nil
+    }
+    if len(dl.Payload) > 0 || dl.Source != "" {
+        return ErrUploadSource
+    }
+    dl.Payload, dl.PayloadType = b, typ
+    return nil
+}
@@ -0,0 +1,138 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/service"
+)
+
+// WebhookHandler serves the /v1/webhooks resource.
+type WebhookHandler struct {
+	l   *slog.Logger
+	svc service.Webhook
+}
+
+type webhookCreateBody struct {
+	URL     string              `json:"url"`
+	Secret  string              `json:"secret"`
+	Events  []data.WebhookEvent `json:"events"`
+	Enabled *bool               `json:"enabled"`
+}
+
+type webhookPatchBody struct {
+	URL     *string              `json:"url"`
+	Secret  *string              `json:"secret"`
+	Events  *[]data.WebhookEvent `json:"events"`
+	Enabled *bool                `json:"enabled"`
+}
+
+func NewWebhookHandler(l *slog.Logger, svc service.Webhook) *WebhookHandler {
+	return &WebhookHandler{l: l, svc: svc}
+}
+
+func (wh *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := wh.svc.List(r.Context())
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = hooks.ToJSON(w)
+}
+
+func (wh *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	h, err := wh.svc.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeWebhookErr(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = h.ToJSON(w)
+}
+
+func (wh *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var body webhookCreateBody
+	if !decodeBody(w, r, &body) {
+		return
+	}
+	enabled := true
+	if body.Enabled != nil {
+		enabled = *body.Enabled
+	}
+	saved, err := wh.svc.Create(r.Context(), &data.Webhook{
+		URL:     body.URL,
+		Secret:  body.Secret,
+		Events:  body.Events,
+		Enabled: enabled,
+	})
+	if err != nil {
+		writeWebhookErr(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = saved.ToJSON(w)
+}
+
+func (wh *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	var body webhookPatchBody
+	if !decodeBody(w, r, &body) {
+		return
+	}
+	updated, err := wh.svc.Update(r.Context(), mux.Vars(r)["id"], service.WebhookPatch{
+		URL:     body.URL,
+		Secret:  body.Secret,
+		Events:  body.Events,
+		Enabled: body.Enabled,
+	})
+	if err != nil {
+		writeWebhookErr(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = updated.ToJSON(w)
+}
+
+func (wh *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := wh.svc.Delete(r.Context(), mux.Vars(r)["id"]); err != nil {
+		writeWebhookErr(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (wh *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 500 {
+			writeError(w, r, http.StatusBadRequest, ErrLimit)
+			return
+		}
+		limit = n
+	}
+	ds, err := wh.svc.Deliveries(r.Context(), mux.Vars(r)["id"], limit)
+	if err != nil {
+		writeWebhookErr(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = ds.ToJSON(w)
+}
+
+func writeWebhookErr(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, data.ErrWebhookNotFound):
+		writeError(w, r, http.StatusNotFound, err)
+	case errors.Is(err, data.ErrInvalidWebhook):
+		writeError(w, r, http.StatusBadRequest, fmt.Errorf("%w: url must be absolute http(s) and events must be known event types", err))
+	default:
+		writeError(w, r, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,115 @@
+package v1_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tinoosan/torrus/internal/downloader"
+	"github.com/tinoosan/torrus/internal/repo"
+	"github.com/tinoosan/torrus/internal/router"
+	"github.com/tinoosan/torrus/internal/service"
+)
+
+func setupWebhooks(t *testing.T) http.Handler {
+	t.Helper()
+	t.Setenv("TORRUS_API_TOKEN", testToken)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dlr := downloader.NewNoopDownloader()
+	svc := service.NewDownload(repo.NewInMemoryDownloadRepo(), dlr)
+	return router.New(logger, svc, dlr, router.WithWebhooks(service.NewWebhook(repo.NewInMemoryWebhookRepo())))
+}
+
+func TestWebhooksCRUD(t *testing.T) {
+	h := setupWebhooks(t)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		var rdr io.Reader
+		if body != "" {
+			rdr = bytes.NewBufferString(body)
+		}
+		req := httptest.NewRequest(method, path, rdr)
+		authReq(req)
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/v1/webhooks", `{"url":"https://example.com/hook","events":["download.complete"]}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201 got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created map[string]any
+	if err := json.NewDecoder(rr.Body).Decode(&created); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	id, _ := created["id"].(string)
+	if id == "" || created["secret"] == "" || created["secret"] == nil || created["enabled"] != true {
+		t.Fatalf("unexpected create response: %v", created)
+	}
+
+	rr = do(http.MethodGet, "/v1/webhooks/"+id, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("get: expected 200 got %d", rr.Code)
+	}
+	var got map[string]any
+	_ = json.NewDecoder(rr.Body).Decode(&got)
+	if _, ok := got["secret"]; ok {
+		t.Fatalf("secret leaked on get: %v", got)
+	}
+
+	rr = do(http.MethodPatch, "/v1/webhooks/"+id, `{"enabled":false}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("patch: expected 200 got %d: %s", rr.Code, rr.Body.String())
+	}
+	_ = json.NewDecoder(rr.Body).Decode(&got)
+	if got["enabled"] != false {
+		t.Fatalf("patch not applied: %v", got)
+	}
+
+	rr = do(http.MethodGet, "/v1/webhooks/"+id+"/deliveries", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("deliveries: expected 200 got %d", rr.Code)
+	}
+
+	rr = do(http.MethodDelete, "/v1/webhooks/"+id, "")
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204 got %d", rr.Code)
+	}
+	rr = do(http.MethodGet, "/v1/webhooks/"+id, "")
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("get after delete: expected 404 got %d", rr.Code)
+	}
+}
+
+func TestWebhookValidation(t *testing.T) {
+	h := setupWebhooks(t)
+	cases := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"relative url", `{"url":"/hook"}`, http.StatusBadRequest},
+		{"bad scheme", `{"url":"ftp://example.com/hook"}`, http.StatusBadRequest},
+		{"unknown event", `{"url":"https://example.com","events":["download.exploded"]}`, http.StatusBadRequest},
+		{"unknown field", `{"url":"https://example.com","foo":1}`, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/webhooks", bytes.NewBufferString(tc.body))
+			authReq(req)
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			if rr.Code != tc.want {
+				t.Fatalf("expected %d got %d: %s", tc.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
@@ -17,13 +17,24 @@ import (
 	lumberjack "gopkg.in/natefinch/lumberjack.v2"
 
 	"github.com/tinoosan/torrus/internal/aria2"
+	"github.com/tinoosan/torrus/internal/bandwidth"
 	"github.com/tinoosan/torrus/internal/downloader"
 	aria2dl "github.com/tinoosan/torrus/internal/downloader/aria2"
+	"github.com/tinoosan/torrus/internal/events"
+	"github.com/tinoosan/torrus/internal/fp"
+	"github.com/tinoosan/torrus/internal/history"
 	"github.com/tinoosan/torrus/internal/metrics"
+	"github.com/tinoosan/torrus/internal/pathsafe"
+	"github.com/tinoosan/torrus/internal/postprocess"
 	"github.com/tinoosan/torrus/internal/reconciler"
 	"github.com/tinoosan/torrus/internal/repo"
+	"github.com/tinoosan/torrus/internal/resync"
+	"github.com/tinoosan/torrus/internal/retry"
 	"github.com/tinoosan/torrus/internal/router"
+	"github.com/tinoosan/torrus/internal/scheduler"
 	"github.com/tinoosan/torrus/internal/service"
+	"github.com/tinoosan/torrus/internal/storage"
+	"github.com/tinoosan/torrus/internal/webhook"
 )
 
 type LogOptions struct {
@@ -68,7 +79,22 @@ func intFromEnv(key string, def int) int {
 	return def
 }
 
+// sizeFromEnv reads a byte size such as "512M" or "10G" from the environment.
+func sizeFromEnv(logger *slog.Logger, key string, def int64) int64 {
+	if v := os.Getenv(key); v != "" {
+		n, err := storage.ParseSize(v)
+		if err == nil {
+			return n
+		}
+		logger.Error("invalid size; using default", "key", key, "err", err, "default", def)
+	}
+	return def
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:], os.Stdout, os.Stderr))
+	}
 
 	var logger *slog.Logger
 
@@ -95,10 +121,25 @@ func main() {
 		logger = slog.New(slog.NewTextHandler(multiOut, nil))
 	}
 
+	// Downloads may only be written under the allowed roots. An invalid
+	// list is fatal rather than silently allowing every path.
+	roots, err := pathsafe.ParseRoots(os.Getenv("TORRUS_ALLOWED_ROOTS"))
+	if err != nil {
+		logger.Error("invalid TORRUS_ALLOWED_ROOTS", "err", err)
+		return
+	}
+	if len(roots) == 0 {
+		logger.Warn("TORRUS_ALLOWED_ROOTS is not set; downloads may target any absolute path")
+	}
+
     var downloadRepo repo.DownloadRepo = repo.NewInMemoryDownloadRepo()
+    var webhookRepo repo.WebhookRepo = repo.NewInMemoryWebhookRepo()
+    var categoryRepo repo.CategoryRepo = repo.NewInMemoryCategoryRepo()
+    var settingsRepo repo.SettingsRepo = repo.NewInMemorySettingsRepo()
+    var historyRepo repo.HistoryRepo = repo.NewInMemoryHistoryRepo()
     var repoCloser interface{ Close() error }
-	events := make(chan downloader.Event, 16)
-	rep := downloader.NewChanReporter(events)
+	eventCh := make(chan downloader.Event, 16)
+	rep := downloader.NewChanReporter(eventCh)
 
     var dlr downloader.Downloader
 	switch os.Getenv("TORRUS_CLIENT") {
@@ -110,6 +151,7 @@ func main() {
         } else {
             a := aria2dl.NewAdapter(aria2Client, rep)
             a.SetLogger(logger)
+            a.SetAllowedRoots(roots)
             dlr = a
         }
 	default:
@@ -122,25 +164,122 @@ func main() {
             logger.Error("postgres repo init failed; falling back to in-memory", "err", err)
         } else {
             downloadRepo = pg
+            webhookRepo = pg
+            categoryRepo = pg
+            settingsRepo = pg
+            historyRepo = pg
             repoCloser = pg
             logger.Info("using postgres storage")
+            if n, dups, err := pg.RefingerprintDownloads(context.Background()); err != nil {
+                logger.Error("re-fingerprinting downloads failed", "err", err)
+            } else if n > 0 || dups > 0 {
+                logger.Info("re-fingerprinted downloads", "updated", n, "duplicates", dups, "version", fp.Version)
+            }
         }
     }
 
-    downloadSvc := service.NewDownload(downloadRepo, dlr)
+    // Record every status change, whoever makes it.
+    downloadRepo = history.Wrap(logger, downloadRepo.(repo.ExtendedRepo), historyRepo)
+
+    sched := scheduler.New(logger, downloadRepo, dlr, intFromEnv("TORRUS_MAX_ACTIVE", scheduler.DefaultMaxActive))
+    downloadSvc := service.NewDownload(downloadRepo, dlr, service.WithScheduler(sched), service.WithCategories(categoryRepo), service.WithRoots(roots), service.WithHistory(historyRepo))
+    webhookSvc := service.NewWebhook(webhookRepo)
+    categorySvc := service.NewCategory(categoryRepo, roots)
+    limiter, _ := dlr.(downloader.GlobalLimiter)
+    bandwidthEngine := bandwidth.New(logger, settingsRepo, limiter)
+    bandwidthSvc := service.NewBandwidth(settingsRepo, bandwidthEngine)
 
 	// Register Prometheus metrics collectors
 	metrics.Register()
 
-	rec := reconciler.New(logger, downloadRepo, events)
+	hub := events.NewHub(intFromEnv("TORRUS_EVENTS_HISTORY", events.DefaultHistory))
+
+	rec := reconciler.New(logger, downloadRepo, eventCh)
+	rec.SetHub(hub)
+	rec.SetProgressInterval(time.Duration(intFromEnv("TORRUS_PROGRESS_PERSIST_MS", 5000)) * time.Millisecond)
+
+	dispatcher := webhook.NewDispatcher(logger, webhookRepo, webhook.Config{
+		MaxAttempts: intFromEnv("TORRUS_WEBHOOK_MAX_ATTEMPTS", 5),
+		Timeout:     time.Duration(intFromEnv("TORRUS_WEBHOOK_TIMEOUT_MS", 10000)) * time.Millisecond,
+	})
+	dispatcher.Start(context.Background())
+	categoryDirs, err := postprocess.ParseCategoryDirs(os.Getenv("TORRUS_CATEGORY_DIRS"))
+	if err == nil {
+		// Post-processing moves payloads there, so they obey the same roots
+		// as download targets.
+		err = postprocess.CheckCategoryDirs(categoryDirs, roots)
+	}
+	if err != nil {
+		categoryDirs = nil
+		logger.Error("invalid TORRUS_CATEGORY_DIRS; categories disabled", "err", err)
+	}
+	processor := postprocess.New(logger, downloadRepo, postprocess.Config{
+		Categories:      categoryRepo,
+		CategoryDirs:    categoryDirs,
+		MaxExtractBytes: sizeFromEnv(logger, "TORRUS_EXTRACT_MAX_BYTES", postprocess.DefaultMaxExtractBytes),
+		MaxExtractFiles: intFromEnv("TORRUS_EXTRACT_MAX_FILES", postprocess.DefaultMaxExtractFiles),
+	})
+	processor.Start(context.Background())
+	// Re-queue downloads that failed with a transient error.
+	retryCodes, err := retry.ParseCodes(os.Getenv("TORRUS_RETRY_CODES"))
+	if err != nil {
+		logger.Error("invalid TORRUS_RETRY_CODES; using defaults", "err", err)
+	}
+	retrier := retry.New(logger, downloadRepo, retry.Config{
+		MaxAttempts: intFromEnv("TORRUS_RETRY_MAX_ATTEMPTS", retry.DefaultMaxAttempts),
+		BaseBackoff: time.Duration(intFromEnv("TORRUS_RETRY_BACKOFF_SEC", int(retry.DefaultBackoff/time.Second))) * time.Second,
+		MaxBackoff:  time.Duration(intFromEnv("TORRUS_RETRY_MAX_BACKOFF_SEC", int(retry.DefaultMaxBackoff/time.Second))) * time.Second,
+		Codes:       retryCodes,
+	})
+	retrier.SetKicker(sched)
+	retrier.Start(context.Background())
+	if fs, ok := dlr.(downloader.FileSelector); ok {
+		rec.SetFileSelector(fs)
+	}
+	rec.AddListener(dispatcher)
+	rec.AddListener(sched)
+	rec.AddListener(processor)
+	rec.AddListener(retrier)
 	rec.Run()
 
+	// Re-attach to backend tasks that outlived a restart, then keep checking
+	// for drift periodically.
+	var resyncer *resync.Runner
+	if rs, ok := dlr.(downloader.Resyncer); ok {
+		resyncer = resync.New(logger, downloadRepo, rs, time.Duration(intFromEnv("TORRUS_RESYNC_INTERVAL_SEC", 300))*time.Second)
+		resyncer.Start(context.Background())
+	}
+
+	// Watch free space on the allowed roots before admitting downloads.
+	storageCfg := storage.Config{
+		Roots:        roots,
+		MinFree:      sizeFromEnv(logger, "TORRUS_STORAGE_MIN_FREE", storage.DefaultMinFree),
+		CriticalFree: sizeFromEnv(logger, "TORRUS_STORAGE_CRITICAL_FREE", storage.DefaultCriticalFree),
+		Interval:     time.Duration(intFromEnv("TORRUS_STORAGE_INTERVAL_SEC", 30)) * time.Second,
+	}
+	watcher := storage.New(logger, downloadRepo, dlr, storageCfg)
+	watcher.SetKicker(sched)
+	sched.SetGate(watcher)
+	watcher.Start(context.Background())
+
+	// Apply global bandwidth limits and follow their schedule.
+	bandwidthEngine.Start(context.Background())
+
+	// Admit downloads queued before the restart and keep filling free slots.
+	sched.Start(context.Background())
+
 	// If the downloader emits events, launch its event loop.
 	if src, ok := dlr.(downloader.EventSource); ok {
 		go src.Run(context.Background())
 	}
 
-	r := router.New(logger, downloadSvc, dlr)
+	r := router.New(logger, downloadSvc, dlr,
+		router.WithEvents(hub),
+		router.WithWebhooks(webhookSvc),
+		router.WithCategories(categorySvc),
+		router.WithStorage(watcher),
+		router.WithBandwidth(bandwidthSvc),
+		router.WithBatchConcurrency(intFromEnv("TORRUS_BATCH_CONCURRENCY", service.DefaultBatchConcurrency)))
 
 	server := &http.Server{
 		Addr:         ":9090",
@@ -149,6 +288,8 @@ func main() {
 		ReadTimeout:  1 * time.Second,
 		WriteTimeout: 1 * time.Second,
 	}
+	// End open event streams on shutdown so Shutdown does not wait on them.
+	server.RegisterOnShutdown(hub.Close)
 
 	go func() {
 		logger.Info("logging configured",
@@ -177,7 +318,16 @@ func main() {
     if err != nil {
         logger.Error("Graceful shutdown failed", "err", err)
     }
+    if resyncer != nil {
+        resyncer.Stop()
+    }
+    watcher.Stop()
+    bandwidthEngine.Stop()
+    sched.Stop()
     rec.Stop()
+    dispatcher.Stop()
+    processor.Stop()
+    retrier.Stop()
 
     if repoCloser != nil {
         if err := repoCloser.Close(); err != nil {
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/migrate"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+const migrateUsage = `usage: torrus migrate <command>
+
+Manages the Postgres schema (POSTGRES_* environment variables).
+
+commands:
+  status     list migrations and whether they are applied
+  up         apply all pending migrations
+  down [N]   revert the latest N applied migrations (default 1);
+             reverting 0001_initial drops every table
+`
+
+// runMigrate implements the migrate subcommand and returns the exit code.
+func runMigrate(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, migrateUsage)
+		return 2
+	}
+	steps := 1
+	switch args[0] {
+	case "status", "up":
+		if len(args) != 1 {
+			fmt.Fprint(stderr, migrateUsage)
+			return 2
+		}
+	case "down":
+		if len(args) > 2 {
+			fmt.Fprint(stderr, migrateUsage)
+			return 2
+		}
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				fmt.Fprintf(stderr, "down: %q is not a positive number of migrations\n", args[1])
+				return 2
+			}
+			steps = n
+		}
+	default:
+		fmt.Fprint(stderr, migrateUsage)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := repo.OpenPostgres(repo.PostgresDSNFromEnv())
+	if err != nil {
+		fmt.Fprintf(stderr, "connect: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	m, err := repo.NewMigrator(db)
+	if err != nil {
+		fmt.Fprintf(stderr, "load migrations: %v\n", err)
+		return 1
+	}
+
+	var done []migrate.Migration
+	switch args[0] {
+	case "status":
+		st, err := m.Status(ctx)
+		if err != nil {
+			fmt.Fprintf(stderr, "status: %v\n", err)
+			return 1
+		}
+		printStatus(stdout, st)
+		return 0
+	case "up":
+		done, err = m.Up(ctx)
+	case "down":
+		done, err = m.Down(ctx, steps)
+	}
+	for _, mig := range done {
+		fmt.Fprintf(stdout, "%s %s\n", args[0], mig)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", args[0], err)
+		return 1
+	}
+	if len(done) == 0 {
+		fmt.Fprintln(stdout, "nothing to do")
+	}
+	return 0
+}
+
+func printStatus(w io.Writer, st []migrate.Status) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range st {
+		state, at, name := "pending", "", s.Name
+		if s.Applied() {
+			state, at = "applied", s.AppliedAt.Format(time.RFC3339)
+		}
+		if name == "" {
+			name = "(unknown to this version)"
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\t%s\n", s.Version, name, state, at)
+	}
+	_ = tw.Flush()
+}
@@ -2,9 +2,12 @@ package auth
 
 import (
 	"crypto/subtle"
+	"encoding/json"
 	"net/http"
 	"os"
 	"strings"
+
+	"github.com/tinoosan/torrus/internal/reqid"
 )
 
 // Middleware returns a handler that verifies requests include the
@@ -25,16 +28,45 @@ func Middleware(next http.Handler) http.Handler {
 		// Expect: Authorization: Bearer <token>
 		authz := r.Header.Get("Authorization")
 		if !strings.HasPrefix(authz, "Bearer ") {
-			http.Error(w, "missing API token", http.StatusUnauthorized)
+			writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "missing API token")
 			return
 		}
 
 		got := strings.TrimSpace(strings.TrimPrefix(authz, "Bearer "))
 		if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
-			http.Error(w, "invalid API token", http.StatusForbidden)
+			writeProblem(w, r, http.StatusForbidden, "forbidden", "invalid API token")
 			return
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+// problem mirrors the RFC 7807 problem details written by the v1 API, so
+// clients handle a rejected token like any other error.
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	p := problem{
+		Type:     "urn:torrus:problem:" + code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	}
+	if id, ok := reqid.From(r.Context()); ok {
+		p.RequestID = id
+	}
+	h := w.Header()
+	h.Set("Content-Type", "application/problem+json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(p)
+}
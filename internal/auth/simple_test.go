@@ -1,9 +1,9 @@
 package auth
 
 import (
+    "encoding/json"
     "net/http"
     "net/http/httptest"
-    "strings"
     "testing"
 )
 
@@ -41,9 +41,7 @@ func TestMiddleware(t *testing.T) {
         if handled {
             t.Fatalf("next handler should not be called")
         }
-        if strings.TrimSpace(rr.Body.String()) != "missing API token" {
-            t.Fatalf("unexpected body %q", rr.Body.String())
-        }
+        assertProblem(t, rr, "urn:torrus:problem:unauthorized", "missing API token")
     })
 
     t.Run("rejects invalid token", func(t *testing.T) {
@@ -62,9 +60,7 @@ func TestMiddleware(t *testing.T) {
         if handled {
             t.Fatalf("next handler should not be called")
         }
-        if strings.TrimSpace(rr.Body.String()) != "invalid API token" {
-            t.Fatalf("unexpected body %q", rr.Body.String())
-        }
+        assertProblem(t, rr, "urn:torrus:problem:forbidden", "invalid API token")
     })
 
     t.Run("allows valid token", func(t *testing.T) {
@@ -87,3 +83,17 @@ func TestMiddleware(t *testing.T) {
     })
 }
 
+
+func assertProblem(t *testing.T, rr *httptest.ResponseRecorder, typ, detail string) {
+    t.Helper()
+    if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+        t.Fatalf("Content-Type = %q", ct)
+    }
+    var p problem
+    if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil {
+        t.Fatalf("decode body %q: %v", rr.Body.String(), err)
+    }
+    if p.Type != typ || p.Detail != detail || p.Status != rr.Code || p.Instance != "/" {
+        t.Fatalf("unexpected problem %+v", p)
+    }
+}
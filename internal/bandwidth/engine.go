@@ -0,0 +1,134 @@
+// Package bandwidth applies the global bandwidth settings to the downloader,
+// switching limits as the rules of their schedule start and end.
+package bandwidth
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/downloader"
+	"github.com/tinoosan/torrus/internal/metrics"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+// DefaultInterval is how often the schedule is evaluated. Rules have minute
+// granularity, so limits switch at most this late.
+const DefaultInterval = 30 * time.Second
+
+// DefaultRefresh is how often unchanged limits are pushed again, so that a
+// downloader that restarted and lost them gets them back.
+const DefaultRefresh = 5 * time.Minute
+
+// Engine evaluates the stored settings and pushes the limits in force to a
+// downloader.GlobalLimiter whenever they change.
+type Engine struct {
+	repo     repo.SettingsRepo
+	lim      downloader.GlobalLimiter
+	log      *slog.Logger
+	now      func() time.Time
+	interval time.Duration
+
+	mu       sync.Mutex
+	active   *data.ActiveBandwidth
+	pushed   *data.BandwidthLimits
+	pushedAt time.Time
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates an Engine. lim may be nil when the downloader has no global
+// limits; the engine then only tracks and reports the limits in force.
+func New(log *slog.Logger, r repo.SettingsRepo, lim downloader.GlobalLimiter) *Engine {
+	if log == nil {
+		log = slog.Default()
+	}
+	return &Engine{repo: r, lim: lim, log: log, now: time.Now, interval: DefaultInterval}
+}
+
+// Start applies the settings immediately and then re-evaluates them every
+// interval until Stop.
+func (e *Engine) Start(ctx context.Context) {
+	ctx, e.cancel = context.WithCancel(ctx)
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		t := time.NewTicker(e.interval)
+		defer t.Stop()
+		for {
+			if err := e.Apply(ctx); err != nil && ctx.Err() == nil {
+				e.log.Error("bandwidth: apply limits", "err", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+// Stop terminates the loop and waits for an in-flight evaluation.
+func (e *Engine) Stop() {
+	if e.cancel != nil {
+		e.cancel()
+		e.wg.Wait()
+	}
+}
+
+// Apply loads the settings, works out the limits in force now and pushes
+// them to the downloader when they differ from the last successful push
+// (or that push is older than DefaultRefresh). A failed push is retried on
+// the next evaluation. Nothing is pushed until settings have been saved, so
+// the downloader keeps its own configured limits until then.
+func (e *Engine) Apply(ctx context.Context) error {
+	s, err := e.repo.GetBandwidth(ctx)
+	if err != nil {
+		return err
+	}
+	now := e.now()
+	limits, rule := s.ActiveAt(now)
+	active := &data.ActiveBandwidth{BandwidthLimits: limits}
+	if rule >= 0 {
+		active.Rule = &rule
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.active == nil || e.active.BandwidthLimits != limits || (e.active.Rule == nil) != (rule < 0) {
+		e.log.Info("bandwidth limits in force", "download", limits.MaxDownloadLimit, "upload", limits.MaxUploadLimit, "rule", rule)
+	}
+	e.active = active
+	metrics.BandwidthDownloadLimit.Set(float64(limits.MaxDownloadLimit))
+	metrics.BandwidthUploadLimit.Set(float64(limits.MaxUploadLimit))
+	metrics.BandwidthScheduleRule.Set(float64(rule))
+
+	if e.lim == nil || s.UpdatedAt.IsZero() || (e.pushed != nil && *e.pushed == limits && now.Sub(e.pushedAt) < DefaultRefresh) {
+		return nil
+	}
+	if err := e.lim.SetGlobalLimits(ctx, limits); err != nil {
+		e.pushed = nil
+		return err
+	}
+	e.pushed, e.pushedAt = &limits, now
+	return nil
+}
+
+// Active returns the limits in force as of the last evaluation, or nil
+// before the first one.
+func (e *Engine) Active() *data.ActiveBandwidth {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.active == nil {
+		return nil
+	}
+	a := *e.active
+	if a.Rule != nil {
+		n := *a.Rule
+		a.Rule = &n
+	}
+	return &a
+}
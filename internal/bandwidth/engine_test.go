@@ -0,0 +1,151 @@
+package bandwidth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+type stubLimiter struct {
+	calls []data.BandwidthLimits
+	err   error
+}
+
+func (s *stubLimiter) SetGlobalLimits(ctx context.Context, l data.BandwidthLimits) error {
+	s.calls = append(s.calls, l)
+	return s.err
+}
+
+func newTestEngine(t *testing.T, s *data.BandwidthSettings, lim *stubLimiter, now *time.Time) *Engine {
+	t.Helper()
+	r := repo.NewInMemorySettingsRepo()
+	if err := s.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	s.UpdatedAt = *now
+	if err := r.SaveBandwidth(context.Background(), s); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	e := New(slog.New(slog.NewTextHandler(io.Discard, nil)), r, lim)
+	e.now = func() time.Time { return *now }
+	return e
+}
+
+// TestActiveAt covers weekday rules, overnight rules that spill into the
+// next day and first-match precedence.
+func TestActiveAt(t *testing.T) {
+	s := &data.BandwidthSettings{
+		BandwidthLimits: data.BandwidthLimits{MaxDownloadLimit: 100},
+		Schedule: []data.BandwidthRule{
+			{Days: []string{"Mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "18:00", BandwidthLimits: data.BandwidthLimits{MaxDownloadLimit: 10}},
+			{Days: []string{"fri"}, Start: "22:00", End: "06:00", BandwidthLimits: data.BandwidthLimits{MaxUploadLimit: 5}},
+			{Start: "08:00", End: "20:00", BandwidthLimits: data.BandwidthLimits{MaxDownloadLimit: 50}},
+		},
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	// 2025-01-03 is a Friday.
+	at := func(day, hour, min int) time.Time { return time.Date(2025, 1, day, hour, min, 0, 0, time.Local) }
+	cases := []struct {
+		t    time.Time
+		rule int
+	}{
+		{at(3, 9, 0), 0},
+		{at(3, 17, 59), 0},
+		{at(3, 18, 0), 2},
+		{at(3, 21, 0), -1},
+		{at(3, 23, 30), 1},
+		{at(4, 5, 59), 1}, // Saturday morning, started Friday
+		{at(4, 6, 0), -1},
+		{at(4, 10, 0), 2},
+		{at(5, 23, 0), -1}, // Sunday night: overnight rule is Friday only
+	}
+	for _, c := range cases {
+		if _, rule := s.ActiveAt(c.t); rule != c.rule {
+			t.Errorf("ActiveAt(%s) rule = %d, want %d", c.t.Format("Mon 15:04"), rule, c.rule)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	bad := []data.BandwidthSettings{
+		{BandwidthLimits: data.BandwidthLimits{MaxDownloadLimit: -1}},
+		{Schedule: []data.BandwidthRule{{Start: "9:00", End: "18:00"}}},
+		{Schedule: []data.BandwidthRule{{Start: "09:00", End: "24:00"}}},
+		{Schedule: []data.BandwidthRule{{Start: "09:00", End: "09:00"}}},
+		{Schedule: []data.BandwidthRule{{Days: []string{"someday"}, Start: "09:00", End: "10:00"}}},
+		{Schedule: []data.BandwidthRule{{Start: "09:00", End: "10:00", BandwidthLimits: data.BandwidthLimits{MaxUploadLimit: -5}}}},
+	}
+	for i, s := range bad {
+		if err := s.Validate(); !errors.Is(err, data.ErrInvalidBandwidth) {
+			t.Errorf("case %d: want ErrInvalidBandwidth, got %v", i, err)
+		}
+	}
+}
+
+// TestApplyPushesOnChange ensures limits are pushed when the rule in force
+// changes, failed pushes are retried and unchanged limits are refreshed.
+func TestApplyPushesOnChange(t *testing.T) {
+	now := time.Date(2025, 1, 3, 8, 0, 0, 0, time.Local)
+	lim := &stubLimiter{}
+	e := newTestEngine(t, &data.BandwidthSettings{
+		BandwidthLimits: data.BandwidthLimits{MaxDownloadLimit: 100},
+		Schedule:        []data.BandwidthRule{{Start: "09:00", End: "18:00", BandwidthLimits: data.BandwidthLimits{MaxDownloadLimit: 10, MaxUploadLimit: 1}}},
+	}, lim, &now)
+	ctx := context.Background()
+
+	if err := e.Apply(ctx); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if a := e.Active(); a == nil || a.Rule != nil || a.MaxDownloadLimit != 100 {
+		t.Fatalf("active = %+v", a)
+	}
+	now = now.Add(time.Minute)
+	_ = e.Apply(ctx)
+	if len(lim.calls) != 1 {
+		t.Fatalf("unchanged limits pushed again: %v", lim.calls)
+	}
+
+	now = time.Date(2025, 1, 3, 9, 0, 0, 0, time.Local)
+	lim.err = errors.New("down")
+	if err := e.Apply(ctx); err == nil {
+		t.Fatal("push error not reported")
+	}
+	lim.err = nil
+	_ = e.Apply(ctx)
+	if len(lim.calls) != 3 || lim.calls[2] != (data.BandwidthLimits{MaxDownloadLimit: 10, MaxUploadLimit: 1}) {
+		t.Fatalf("calls = %v", lim.calls)
+	}
+	if a := e.Active(); a.Rule == nil || *a.Rule != 0 {
+		t.Fatalf("active = %+v", a)
+	}
+
+	now = now.Add(DefaultRefresh)
+	_ = e.Apply(ctx)
+	if len(lim.calls) != 4 {
+		t.Fatalf("limits not refreshed: %v", lim.calls)
+	}
+}
+
+// TestApplyNeverSaved ensures the downloader's own limits are left alone
+// until settings are saved for the first time.
+func TestApplyNeverSaved(t *testing.T) {
+	lim := &stubLimiter{}
+	e := New(slog.New(slog.NewTextHandler(io.Discard, nil)), repo.NewInMemorySettingsRepo(), lim)
+	if err := e.Apply(context.Background()); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if len(lim.calls) != 0 {
+		t.Fatalf("limits pushed before any settings were saved: %v", lim.calls)
+	}
+	if a := e.Active(); a == nil || a.MaxDownloadLimit != 0 {
+		t.Fatalf("active = %+v", a)
+	}
+}
@@ -0,0 +1,170 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrInvalidBandwidth indicates bandwidth settings failed validation.
+var ErrInvalidBandwidth = errors.New("invalid bandwidth settings")
+
+// MaxBandwidthRules caps the number of rules in a bandwidth schedule.
+const MaxBandwidthRules = 64
+
+// BandwidthLimits are global transfer caps in bytes/sec; 0 means unlimited.
+type BandwidthLimits struct {
+	MaxDownloadLimit int64 `json:"maxDownloadLimit"`
+	MaxUploadLimit   int64 `json:"maxUploadLimit"`
+}
+
+// BandwidthRule applies its limits from Start until End on the given days.
+// Times are "HH:MM" in the server's local time zone. A rule whose End is
+// not after its Start runs past midnight into the next day; Days refers to
+// the day it starts on. An empty Days means every day.
+type BandwidthRule struct {
+	Days  []string `json:"days,omitempty"`
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+	BandwidthLimits
+}
+
+// BandwidthSettings are the global limits plus a schedule that overrides
+// them at certain times. The first matching rule wins.
+type BandwidthSettings struct {
+	BandwidthLimits
+	Schedule  []BandwidthRule `json:"schedule"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+	// Active is the limit set currently in force, filled in on read.
+	Active *ActiveBandwidth `json:"active,omitempty"`
+}
+
+// ActiveBandwidth reports the limits currently applied and where they come
+// from: the index of the matching schedule rule, or nil for the defaults.
+type ActiveBandwidth struct {
+	BandwidthLimits
+	Rule *int `json:"rule"`
+}
+
+// weekdays maps the accepted day names to time.Weekday.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Validate reports the first invalid field, wrapping ErrInvalidBandwidth.
+// Day names are lowercased in place.
+func (s *BandwidthSettings) Validate() error {
+	if err := s.BandwidthLimits.validate("limits"); err != nil {
+		return err
+	}
+	if len(s.Schedule) > MaxBandwidthRules {
+		return fmt.Errorf("%w: at most %d schedule rules", ErrInvalidBandwidth, MaxBandwidthRules)
+	}
+	for i := range s.Schedule {
+		r := &s.Schedule[i]
+		for j, d := range r.Days {
+			d = strings.ToLower(strings.TrimSpace(d))
+			if _, ok := weekdays[d]; !ok {
+				return fmt.Errorf("%w: schedule[%d]: day %q must be one of mon, tue, wed, thu, fri, sat, sun", ErrInvalidBandwidth, i, r.Days[j])
+			}
+			r.Days[j] = d
+		}
+		start, ok1 := parseClock(r.Start)
+		end, ok2 := parseClock(r.End)
+		if !ok1 || !ok2 {
+			return fmt.Errorf("%w: schedule[%d]: start and end must be HH:MM", ErrInvalidBandwidth, i)
+		}
+		if start == end {
+			return fmt.Errorf("%w: schedule[%d]: start and end must differ", ErrInvalidBandwidth, i)
+		}
+		if err := r.BandwidthLimits.validate(fmt.Sprintf("schedule[%d]", i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l BandwidthLimits) validate(field string) error {
+	if l.MaxDownloadLimit < 0 || l.MaxUploadLimit < 0 {
+		return fmt.Errorf("%w: %s: limits must not be negative", ErrInvalidBandwidth, field)
+	}
+	return nil
+}
+
+// ActiveAt returns the limits in force at t and the index of the schedule
+// rule they come from, or -1 for the default limits. Settings must have
+// been validated.
+func (s *BandwidthSettings) ActiveAt(t time.Time) (BandwidthLimits, int) {
+	for i, r := range s.Schedule {
+		if r.matches(t) {
+			return r.BandwidthLimits, i
+		}
+	}
+	return s.BandwidthLimits, -1
+}
+
+// matches reports whether the rule is in force at t.
+func (r BandwidthRule) matches(t time.Time) bool {
+	start, _ := parseClock(r.Start)
+	end, _ := parseClock(r.End)
+	now := t.Hour()*60 + t.Minute()
+	if start < end {
+		return start <= now && now < end && r.onDay(t.Weekday())
+	}
+	// Overnight: the evening part belongs to today, the morning part to
+	// the rule that started yesterday.
+	if now >= start {
+		return r.onDay(t.Weekday())
+	}
+	return now < end && r.onDay((t.Weekday()+6)%7)
+}
+
+func (r BandwidthRule) onDay(d time.Weekday) bool {
+	if len(r.Days) == 0 {
+		return true
+	}
+	for _, name := range r.Days {
+		if weekdays[name] == d {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock parses "HH:MM" into minutes after midnight.
+func parseClock(s string) (int, bool) {
+	t, err := time.Parse("15:04", s)
+	if err != nil || len(s) != len("15:04") {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// Clone returns a deep copy of the settings.
+func (s *BandwidthSettings) Clone() *BandwidthSettings {
+	if s == nil {
+		return nil
+	}
+	cp := *s
+	cp.Schedule = make([]BandwidthRule, len(s.Schedule))
+	for i, r := range s.Schedule {
+		r.Days = append([]string(nil), r.Days...)
+		cp.Schedule[i] = r
+	}
+	if s.Active != nil {
+		a := *s.Active
+		if a.Rule != nil {
+			n := *a.Rule
+			a.Rule = &n
+		}
+		cp.Active = &a
+	}
+	return &cp
+}
+
+// ToJSON writes the settings as JSON to the writer.
+func (s *BandwidthSettings) ToJSON(w io.Writer) error { return json.NewEncoder(w).Encode(s) }
@@ -0,0 +1,166 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Limits for category names and download labels.
+const (
+	MaxCategoryNameLen = 64
+	MaxLabels          = 32
+	MaxLabelLen        = 64
+)
+
+// Category groups downloads that share a default target directory,
+// transfer options and post-processing. Downloads refer to it by Name.
+type Category struct {
+	// Name identifies the category; it is immutable.
+	Name string `json:"name"`
+	// TargetPath is the absolute directory used when a download omits one.
+	TargetPath string `json:"targetPath"`
+	// Options are defaults for downloads in the category; fields a download
+	// sets itself take precedence.
+	Options *DownloadOptions `json:"options,omitempty"`
+	// PostProcess is used by downloads in the category that do not set
+	// their own.
+	PostProcess *PostProcess `json:"postProcess,omitempty"`
+	CreatedAt   time.Time    `json:"createdAt"`
+}
+
+// Categories is a slice of Category pointers.
+type Categories []*Category
+
+var (
+	// ErrCategoryNotFound indicates the requested category does not exist.
+	ErrCategoryNotFound = errors.New("category not found")
+	// ErrCategoryExists indicates a category with the same name exists.
+	ErrCategoryExists = errors.New("category already exists")
+	// ErrInvalidCategory signals an invalid category, or a download that
+	// refers to an unknown one.
+	ErrInvalidCategory = errors.New("invalid category")
+	// ErrInvalidLabels signals malformed download labels.
+	ErrInvalidLabels = errors.New("invalid labels")
+)
+
+// ValidCategoryName reports whether name is 1-64 letters, digits, '.', '_'
+// or '-', not starting with '.'.
+func ValidCategoryName(name string) bool {
+	if name == "" || len(name) > MaxCategoryNameLen || name[0] == '.' {
+		return false
+	}
+	for _, r := range name {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate reports the first invalid field, wrapping ErrInvalidCategory,
+// ErrInvalidOptions or ErrInvalidPostProcess.
+func (c *Category) Validate() error {
+	if !ValidCategoryName(c.Name) {
+		return fmt.Errorf("%w: name must be 1-%d letters, digits, '.', '_' or '-'", ErrInvalidCategory, MaxCategoryNameLen)
+	}
+	if !filepath.IsAbs(c.TargetPath) {
+		return fmt.Errorf("%w: targetPath must be an absolute path", ErrInvalidCategory)
+	}
+	if err := c.Options.Validate(); err != nil {
+		return err
+	}
+	return c.PostProcess.Validate()
+}
+
+// Normalize cleans TargetPath, drops empty options and post-processing,
+// and points post-processing without a destination at the category itself.
+func (c *Category) Normalize() {
+	c.TargetPath = filepath.Clean(c.TargetPath)
+	if c.Options.IsZero() {
+		c.Options = nil
+	}
+	if c.PostProcess.IsZero() {
+		c.PostProcess = nil
+	}
+	if c.PostProcess != nil && c.PostProcess.Dest == "" && c.PostProcess.Category == "" {
+		c.PostProcess.Category = c.Name
+	}
+}
+
+// ApplyTo fills in the fields of d that the category provides defaults for.
+// Post-processing that d sets without a destination uses the category's.
+func (c *Category) ApplyTo(d *Download) {
+	if strings.TrimSpace(d.TargetPath) == "" {
+		d.TargetPath = c.TargetPath
+	}
+	d.Options = d.Options.WithDefaults(c.Options)
+	switch {
+	case d.PostProcess == nil:
+		d.PostProcess = c.PostProcess.Clone()
+	case d.PostProcess.Dest == "" && d.PostProcess.Category == "":
+		d.PostProcess.Category = c.Name
+	}
+}
+
+// Clone returns a deep copy of the category.
+func (c *Category) Clone() *Category {
+	if c == nil {
+		return nil
+	}
+	cp := *c
+	cp.Options = c.Options.Clone()
+	cp.PostProcess = c.PostProcess.Clone()
+	return &cp
+}
+
+// ToJSON writes the category as JSON to the writer, with its options
+// redacted (see DownloadOptions.Redacted).
+func (c *Category) ToJSON(w io.Writer) error { return json.NewEncoder(w).Encode(c.redacted()) }
+
+// ToJSON writes the slice of categories as JSON to the writer, redacted.
+func (cs *Categories) ToJSON(w io.Writer) error {
+	out := make(Categories, len(*cs))
+	for i, c := range *cs {
+		out[i] = c.redacted()
+	}
+	return json.NewEncoder(w).Encode(out)
+}
+
+func (c *Category) redacted() *Category {
+	if c == nil {
+		return nil
+	}
+	cp := *c
+	cp.Options = c.Options.Redacted()
+	return &cp
+}
+
+// NormalizeLabels trims labels and drops duplicates, keeping the first
+// occurrence. Labels must be 1-64 printable characters without commas.
+func NormalizeLabels(labels []string) ([]string, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	if len(labels) > MaxLabels {
+		return nil, fmt.Errorf("%w: at most %d labels", ErrInvalidLabels, MaxLabels)
+	}
+	seen := make(map[string]bool, len(labels))
+	out := make([]string, 0, len(labels))
+	for _, l := range labels {
+		l = strings.TrimSpace(l)
+		if l == "" || len(l) > MaxLabelLen || strings.ContainsRune(l, ',') || strings.IndexFunc(l, unicode.IsControl) >= 0 {
+			return nil, fmt.Errorf("%w: label %q", ErrInvalidLabels, l)
+		}
+		if !seen[l] {
+			seen[l] = true
+			out = append(out, l)
+		}
+	}
+	return out, nil
+}
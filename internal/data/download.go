@@ -18,10 +18,69 @@ type Download struct {
 	Name string `json:"name,omitempty"`
 	// Files is an optional, read-only list of files for this download.
 	// It is populated by downloader adapters when available.
-	Files         []DownloadFile `json:"files,omitempty"`
-	Status        DownloadStatus `json:"status"`
-	DesiredStatus DownloadStatus `json:"desiredStatus,omitempty"`
-	CreatedAt     time.Time      `json:"createdAt"`
+	Files []DownloadFile `json:"files,omitempty"`
+	// Progress is a read-only snapshot of transfer progress persisted by the
+	// reconciler from downloader progress events.
+	Progress      *DownloadProgress `json:"progress,omitempty"`
+	Status        DownloadStatus    `json:"status"`
+	DesiredStatus DownloadStatus    `json:"desiredStatus,omitempty"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	// Priority orders the scheduler queue: higher values are admitted first.
+	Priority int `json:"priority"`
+	// QueueOrder breaks ties between queued downloads of equal Priority,
+	// lower values first. Repositories derive it from CreatedAt on insert and
+	// queue moves rewrite it; it is internal and never serialized.
+	QueueOrder int64 `json:"-"`
+	// Payload is the content of an uploaded .torrent or metalink file, which
+	// the downloader starts in place of Source. It is write-only and never
+	// serialized; Source then holds a canonical identifier for it.
+	Payload []byte `json:"-"`
+	// PayloadType identifies the format of Payload.
+	PayloadType PayloadType `json:"-"`
+	// Options are per-download transfer settings passed to the downloader.
+	Options *DownloadOptions `json:"options,omitempty"`
+	// FileFilter picks the files of a multi-file download to fetch once its
+	// file list is known. An explicit file selection replaces it.
+	FileFilter *FileFilter `json:"fileFilter,omitempty"`
+	// PostProcess moves, renames or extracts the payload once Complete.
+	PostProcess *PostProcess `json:"postProcess,omitempty"`
+	// PostProcessStatus is the read-only outcome of PostProcess.
+	PostProcessStatus *PostProcessStatus `json:"postProcessStatus,omitempty"`
+	// Retry overrides the global retry policy for this download.
+	Retry *RetryPolicy `json:"retry,omitempty"`
+	// RetryStatus is the read-only record of automatic retries.
+	RetryStatus *RetryStatus `json:"retryStatus,omitempty"`
+	// Failure is the read-only reason of the most recent failure. It is
+	// cleared once the download runs again.
+	Failure *Failure `json:"failure,omitempty"`
+	// Category names the Category whose defaults the download was created
+	// with.
+	Category string `json:"category,omitempty"`
+	// Labels are free-form tags used to filter listings.
+	Labels []string `json:"labels,omitempty"`
+	// QueuePosition is the read-only, 1-based position of a Queued download
+	// in the scheduler queue. It is computed on read and never persisted.
+	QueuePosition int `json:"queuePosition,omitempty"`
+}
+
+// DownloadProgress captures the most recent progress reported for a download.
+type DownloadProgress struct {
+	// Completed is the number of bytes downloaded so far.
+	Completed int64 `json:"completed"`
+	// Total is the total size in bytes, or 0 if not yet known.
+	Total int64 `json:"total"`
+	// Speed is the current download speed in bytes/sec.
+	Speed int64 `json:"speed"`
+	// ETASeconds is the estimated time to completion, omitted when unknown.
+	ETASeconds int64 `json:"etaSeconds,omitempty"`
+	// Uploaded is the number of bytes uploaded to peers so far.
+	Uploaded int64 `json:"uploaded,omitempty"`
+	// UploadSpeed is the current upload speed in bytes/sec.
+	UploadSpeed int64 `json:"uploadSpeed,omitempty"`
+	// Ratio is Uploaded divided by Completed, omitted until both are known.
+	Ratio float64 `json:"ratio,omitempty"`
+	// UpdatedAt is when this snapshot was recorded.
+	UpdatedAt time.Time `json:"updatedAt"`
 }
 
 // DownloadFile represents a single file within a multi-file download.
@@ -33,12 +92,17 @@ type DownloadFile struct {
 	Length int64 `json:"length,omitempty"`
 	// Completed is the number of bytes downloaded for this file, if known.
 	Completed int64 `json:"completed,omitempty"`
+	// Index is the downloader's 1-based file index used to select files.
+	Index int `json:"index,omitempty"`
+	// Selected reports whether the file is fetched.
+	Selected bool `json:"selected"`
 }
 
 // Possible DownloadStatus values.
 const (
 	StatusQueued    DownloadStatus = "Queued"
 	StatusActive    DownloadStatus = "Active"
+	StatusSeeding   DownloadStatus = "Seeding" // finished torrent uploading until its seed limits
 	StatusResume    DownloadStatus = "Resume"
 	StatusPaused    DownloadStatus = "Paused"
 	StatusComplete  DownloadStatus = "Complete"
@@ -46,6 +110,15 @@ const (
 	StatusError     DownloadStatus = "Failed"
 )
 
+// PayloadType identifies the format of an uploaded download payload.
+type PayloadType string
+
+// Possible PayloadType values.
+const (
+	PayloadTorrent  PayloadType = "torrent"
+	PayloadMetalink PayloadType = "metalink"
+)
+
 // Downloads is a slice of Download pointers.
 type Downloads []*Download
 
@@ -61,15 +134,35 @@ var (
 	ErrInvalidSource = errors.New("invalid source")
 	// ErrTargetPath signals that the provided target path is invalid.
 	ErrTargetPath = errors.New("invalid target path")
+	// ErrInvalidPayload is returned when an uploaded .torrent or metalink
+	// file cannot be parsed or has an unknown type.
+	ErrInvalidPayload = errors.New("invalid torrent or metalink payload")
 	// ErrConflict signals a file collision based on collision policy.
 	ErrConflict = errors.New("file conflict")
 )
 
-// ToJSON writes the slice of downloads as JSON to the writer.
-func (d *Downloads) ToJSON(w io.Writer) error { return json.NewEncoder(w).Encode(d) }
+// ToJSON writes the slice of downloads as JSON to the writer, redacted.
+func (d *Downloads) ToJSON(w io.Writer) error {
+	out := make(Downloads, len(*d))
+	for i, dl := range *d {
+		out[i] = dl.Redacted()
+	}
+	return json.NewEncoder(w).Encode(out)
+}
+
+// ToJSON writes the download as JSON to the writer, redacted.
+func (d *Download) ToJSON(w io.Writer) error { return json.NewEncoder(w).Encode(d.Redacted()) }
 
-// ToJSON writes the download as JSON to the writer.
-func (d *Download) ToJSON(w io.Writer) error { return json.NewEncoder(w).Encode(d) }
+// Redacted returns a shallow copy of d for output, with its options
+// redacted (see DownloadOptions.Redacted).
+func (d *Download) Redacted() *Download {
+	if d == nil {
+		return nil
+	}
+	cp := *d
+	cp.Options = d.Options.Redacted()
+	return &cp
+}
 
 // FromJSON populates the download from JSON read from the reader.
 func (d *Download) FromJSON(r io.Reader) error { return json.NewDecoder(r).Decode(d) }
@@ -85,6 +178,23 @@ func (d *Download) Clone() *Download {
 		cp.Files = make([]DownloadFile, len(d.Files))
 		copy(cp.Files, d.Files)
 	}
+	if d.Progress != nil {
+		p := *d.Progress
+		cp.Progress = &p
+	}
+	cp.Options = d.Options.Clone()
+	cp.FileFilter = d.FileFilter.Clone()
+	cp.PostProcess = d.PostProcess.Clone()
+	cp.PostProcessStatus = d.PostProcessStatus.Clone()
+	cp.Retry = d.Retry.Clone()
+	cp.RetryStatus = d.RetryStatus.Clone()
+	if d.Failure != nil {
+		f := *d.Failure
+		cp.Failure = &f
+	}
+	if d.Labels != nil {
+		cp.Labels = append([]string(nil), d.Labels...)
+	}
 	return &cp
 }
 
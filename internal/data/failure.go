@@ -0,0 +1,55 @@
+package data
+
+import "time"
+
+// FailureCode classifies why a download failed. The values are stable and
+// independent of the downloader backend, so clients can switch on them.
+type FailureCode string
+
+const (
+	FailureUnknown            FailureCode = "unknown"
+	FailureTimeout            FailureCode = "timeout"
+	FailureNotFound           FailureCode = "not_found"
+	FailureTooSlow            FailureCode = "too_slow"
+	FailureNetwork            FailureCode = "network"
+	FailureInterrupted        FailureCode = "interrupted"
+	FailureResumeNotSupported FailureCode = "resume_not_supported"
+	FailureDiskFull           FailureCode = "disk_full"
+	FailureDuplicate          FailureCode = "duplicate"
+	FailureFileExists         FailureCode = "file_exists"
+	FailureFileIO             FailureCode = "file_io"
+	FailureNameResolution     FailureCode = "name_resolution"
+	FailureHTTP               FailureCode = "http"
+	FailureUnauthorized       FailureCode = "unauthorized"
+	FailureInvalidMetadata    FailureCode = "invalid_metadata"
+	FailureInvalidRequest     FailureCode = "invalid_request"
+	FailureServerOverloaded   FailureCode = "server_overloaded"
+	FailureChecksumMismatch   FailureCode = "checksum_mismatch"
+	// FailureBackend means the downloader rejected or could not process a
+	// request, e.g. because it was unreachable.
+	FailureBackend FailureCode = "backend_error"
+)
+
+// FailureSource names the component that recorded a failure.
+type FailureSource string
+
+const (
+	// FailureSourceDownloader is a failure reported by the downloader for a
+	// running transfer.
+	FailureSourceDownloader FailureSource = "downloader"
+	// FailureSourceService is a failed start or resume requested via the API.
+	FailureSourceService FailureSource = "service"
+	// FailureSourceScheduler is a failed start of a queued download.
+	FailureSourceScheduler FailureSource = "scheduler"
+)
+
+// Failure records why a download went to Failed.
+type Failure struct {
+	Code FailureCode `json:"code"`
+	// DownloaderCode is the backend's own error code (aria2 exit status), 0
+	// if it reported none.
+	DownloaderCode int           `json:"downloaderCode,omitempty"`
+	Message        string        `json:"message,omitempty"`
+	Source         FailureSource `json:"source"`
+	Timestamp      time.Time     `json:"timestamp"`
+}
@@ -0,0 +1,150 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+)
+
+var (
+	// ErrInvalidFileSelection indicates a file filter or a list of selected
+	// file indexes failed validation.
+	ErrInvalidFileSelection = errors.New("invalid file selection")
+	// ErrFilesUnknown indicates the downloader has not reported the files of
+	// a download yet (e.g. a magnet whose metadata is still resolving).
+	ErrFilesUnknown = errors.New("file list is not known yet")
+)
+
+// MaxFilePatterns caps the number of include plus exclude patterns.
+const MaxFilePatterns = 64
+
+// FileFilter chooses which files of a multi-file download are fetched once
+// the file list is known. Patterns use path.Match syntax and are matched
+// against each file's Path. A file is selected when it matches any Include
+// pattern (or Include is empty) and no Exclude pattern.
+type FileFilter struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Validate reports the first malformed pattern, wrapping
+// ErrInvalidFileSelection.
+func (f *FileFilter) Validate() error {
+	if f == nil {
+		return nil
+	}
+	if len(f.Include)+len(f.Exclude) > MaxFilePatterns {
+		return fmt.Errorf("%w: at most %d patterns", ErrInvalidFileSelection, MaxFilePatterns)
+	}
+	for _, p := range append(append([]string(nil), f.Include...), f.Exclude...) {
+		if _, err := path.Match(p, ""); p == "" || err != nil {
+			return fmt.Errorf("%w: pattern %q", ErrInvalidFileSelection, p)
+		}
+	}
+	return nil
+}
+
+// IsZero reports whether the filter has no patterns.
+func (f *FileFilter) IsZero() bool {
+	return f == nil || (len(f.Include) == 0 && len(f.Exclude) == 0)
+}
+
+// Clone returns a deep copy of the filter.
+func (f *FileFilter) Clone() *FileFilter {
+	if f == nil {
+		return nil
+	}
+	return &FileFilter{
+		Include: append([]string(nil), f.Include...),
+		Exclude: append([]string(nil), f.Exclude...),
+	}
+}
+
+// Match reports whether a file at name passes the filter.
+func (f *FileFilter) Match(name string) bool {
+	if f == nil {
+		return true
+	}
+	included := len(f.Include) == 0
+	for _, p := range f.Include {
+		if ok, _ := path.Match(p, name); ok {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, p := range f.Exclude {
+		if ok, _ := path.Match(p, name); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Select returns the sorted indexes of the files that pass the filter.
+func (f *FileFilter) Select(files []DownloadFile) []int {
+	var out []int
+	for _, file := range files {
+		if file.Index > 0 && f.Match(file.Path) {
+			out = append(out, file.Index)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// SelectedIndexes returns the sorted indexes of the selected files.
+func SelectedIndexes(files []DownloadFile) []int {
+	var out []int
+	for _, file := range files {
+		if file.Index > 0 && file.Selected {
+			out = append(out, file.Index)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// ValidateSelection checks indexes against the known files and returns them
+// sorted and de-duplicated. At least one file must be selected.
+func ValidateSelection(files []DownloadFile, indexes []int) ([]int, error) {
+	known := make(map[int]bool, len(files))
+	for _, file := range files {
+		if file.Index > 0 {
+			known[file.Index] = true
+		}
+	}
+	if len(known) == 0 {
+		return nil, ErrFilesUnknown
+	}
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("%w: at least one file must be selected", ErrInvalidFileSelection)
+	}
+	seen := make(map[int]bool, len(indexes))
+	out := make([]int, 0, len(indexes))
+	for _, i := range indexes {
+		if !known[i] {
+			return nil, fmt.Errorf("%w: no file with index %d", ErrInvalidFileSelection, i)
+		}
+		if !seen[i] {
+			seen[i] = true
+			out = append(out, i)
+		}
+	}
+	sort.Ints(out)
+	return out, nil
+}
+
+// MarkSelected sets the Selected flag of each file according to indexes.
+func MarkSelected(files []DownloadFile, indexes []int) {
+	want := make(map[int]bool, len(indexes))
+	for _, i := range indexes {
+		want[i] = true
+	}
+	for j := range files {
+		files[j].Selected = want[files[j].Index]
+	}
+}
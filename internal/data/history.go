@@ -0,0 +1,47 @@
+package data
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// TransitionCause names the component that changed a download's status.
+type TransitionCause string
+
+const (
+	// CauseAPI is a change requested through the HTTP API.
+	CauseAPI TransitionCause = "api"
+	// CauseReconciler is a change applied for a downloader event.
+	CauseReconciler TransitionCause = "reconciler"
+	// CauseScheduler is a queued download being started.
+	CauseScheduler TransitionCause = "scheduler"
+	// CauseRetry is a failed download re-queued by the retrier.
+	CauseRetry TransitionCause = "retry"
+	// CauseStorage is a download held or released by the storage watcher.
+	CauseStorage TransitionCause = "storage"
+	// CauseSystem is any other change, e.g. made at startup.
+	CauseSystem TransitionCause = "system"
+)
+
+// Transition is one entry of a download's append-only history: a change of
+// its status and/or desired status.
+type Transition struct {
+	DownloadID string `json:"downloadId"`
+	// From is empty for the entry recording the download's creation.
+	From          DownloadStatus  `json:"from,omitempty"`
+	To            DownloadStatus  `json:"to"`
+	DesiredStatus DownloadStatus  `json:"desiredStatus,omitempty"`
+	Cause         TransitionCause `json:"cause"`
+	// Event is the downloader event type, for reconciler transitions.
+	Event string `json:"event,omitempty"`
+	// RequestID is the X-Request-ID of the API call that caused it.
+	RequestID string    `json:"requestId,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Transitions is a download's history, oldest first.
+type Transitions []*Transition
+
+// ToJSON writes the transitions as JSON to the writer.
+func (ts *Transitions) ToJSON(w io.Writer) error { return json.NewEncoder(w).Encode(ts) }
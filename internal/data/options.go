@@ -0,0 +1,317 @@
+package data
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/textproto"
+	"strings"
+)
+
+// ErrInvalidOptions indicates a download options object failed validation.
+var ErrInvalidOptions = errors.New("invalid options")
+
+// Limits for per-download connection options.
+const (
+	MaxSplit                   = 64
+	MaxConnectionsPerServerCap = 16
+)
+
+// DownloadOptions are per-download transfer settings passed to the
+// downloader. Zero-valued fields leave the downloader's defaults in place.
+type DownloadOptions struct {
+	// MaxDownloadLimit caps the download speed in bytes/sec (0 = unlimited).
+	MaxDownloadLimit int64 `json:"maxDownloadLimit,omitempty"`
+	// Split is the number of connections used to download a file (1-64).
+	Split int `json:"split,omitempty"`
+	// MaxConnectionsPerServer caps connections to one server (1-16).
+	MaxConnectionsPerServer int `json:"maxConnectionsPerServer,omitempty"`
+	// Headers are extra HTTP request headers, keyed by header name.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Cookies are sent as a single Cookie request header.
+	Cookies map[string]string `json:"cookies,omitempty"`
+	// UserAgent overrides the HTTP User-Agent.
+	UserAgent string `json:"userAgent,omitempty"`
+	// Checksum is "<algo>=<hex digest>" (md5, sha-1, sha-224, sha-256,
+	// sha-384, sha-512); the download fails if the payload does not match.
+	Checksum string `json:"checksum,omitempty"`
+	// Out is the output file name, relative to TargetPath.
+	Out string `json:"out,omitempty"`
+	// SeedRatio stops seeding a torrent once uploaded/downloaded reaches it;
+	// 0 seeds regardless of ratio.
+	SeedRatio *float64 `json:"seedRatio,omitempty"`
+	// SeedTime stops seeding a torrent after this many minutes; 0 disables
+	// seeding.
+	SeedTime *int `json:"seedTime,omitempty"`
+}
+
+// DownloadOptionsPatch changes the options a downloader can apply to a
+// running download. Nil fields are left unchanged.
+type DownloadOptionsPatch struct {
+	// MaxDownloadLimit of 0 removes the speed cap.
+	MaxDownloadLimit        *int64   `json:"maxDownloadLimit,omitempty"`
+	Split                   *int     `json:"split,omitempty"`
+	MaxConnectionsPerServer *int     `json:"maxConnectionsPerServer,omitempty"`
+	SeedRatio               *float64 `json:"seedRatio,omitempty"`
+	SeedTime                *int     `json:"seedTime,omitempty"`
+}
+
+// checksumDigestLen maps supported checksum algorithms to their hex digest length.
+var checksumDigestLen = map[string]int{
+	"md5":     32,
+	"sha-1":   40,
+	"sha-224": 56,
+	"sha-256": 64,
+	"sha-384": 96,
+	"sha-512": 128,
+}
+
+// Validate reports the first invalid field, wrapping ErrInvalidOptions.
+func (o *DownloadOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if err := validateLimits(o.MaxDownloadLimit, o.Split, o.MaxConnectionsPerServer); err != nil {
+		return err
+	}
+	if err := validateSeeding(o.SeedRatio, o.SeedTime); err != nil {
+		return err
+	}
+	for name, value := range o.Headers {
+		if !validHeaderName(name) {
+			return fmt.Errorf("%w: header name %q", ErrInvalidOptions, name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("%w: header %q value contains a line break", ErrInvalidOptions, name)
+		}
+	}
+	for name, value := range o.Cookies {
+		if name == "" || strings.ContainsAny(name, "=;, \t\r\n") || strings.ContainsAny(value, ";\r\n") {
+			return fmt.Errorf("%w: cookie %q", ErrInvalidOptions, name)
+		}
+	}
+	if strings.ContainsAny(o.UserAgent, "\r\n") {
+		return fmt.Errorf("%w: userAgent contains a line break", ErrInvalidOptions)
+	}
+	if o.Checksum != "" {
+		algo, digest, ok := strings.Cut(o.Checksum, "=")
+		n, known := checksumDigestLen[strings.ToLower(algo)]
+		if _, err := hex.DecodeString(digest); !ok || !known || len(digest) != n || err != nil {
+			return fmt.Errorf("%w: checksum must be <algo>=<hex digest> with algo one of md5, sha-1, sha-224, sha-256, sha-384, sha-512", ErrInvalidOptions)
+		}
+	}
+	if o.Out != "" && (o.Out == "." || o.Out == ".." || strings.ContainsAny(o.Out, "/\\\x00")) {
+		return fmt.Errorf("%w: out must be a plain file name", ErrInvalidOptions)
+	}
+	return nil
+}
+
+// IsZero reports whether no option is set.
+func (o *DownloadOptions) IsZero() bool {
+	return o == nil || (o.MaxDownloadLimit == 0 && o.Split == 0 && o.MaxConnectionsPerServer == 0 &&
+		len(o.Headers) == 0 && len(o.Cookies) == 0 && o.UserAgent == "" && o.Checksum == "" && o.Out == "" &&
+		o.SeedRatio == nil && o.SeedTime == nil)
+}
+
+// Clone returns a deep copy of the options.
+func (o *DownloadOptions) Clone() *DownloadOptions {
+	if o == nil {
+		return nil
+	}
+	cp := *o
+	cp.Headers = cloneStringMap(o.Headers)
+	cp.Cookies = cloneStringMap(o.Cookies)
+	if o.SeedRatio != nil {
+		r := *o.SeedRatio
+		cp.SeedRatio = &r
+	}
+	if o.SeedTime != nil {
+		t := *o.SeedTime
+		cp.SeedTime = &t
+	}
+	return &cp
+}
+
+// Redacted is the value that replaces header and cookie values in output.
+const Redacted = "[redacted]"
+
+// Redacted returns a copy of o for output: header and cookie values, which
+// often carry credentials, are replaced by Redacted and only their names
+// remain.
+func (o *DownloadOptions) Redacted() *DownloadOptions {
+	cp := o.Clone()
+	if cp == nil {
+		return nil
+	}
+	for _, m := range []map[string]string{cp.Headers, cp.Cookies} {
+		for k := range m {
+			m[k] = Redacted
+		}
+	}
+	return cp
+}
+
+// WithDefaults returns a copy of o with every unset field taken from def.
+// Headers and cookies are merged, with o's entries taking precedence.
+func (o *DownloadOptions) WithDefaults(def *DownloadOptions) *DownloadOptions {
+	if def.IsZero() {
+		return o.Clone()
+	}
+	next := def.Clone()
+	if o == nil {
+		return next
+	}
+	if o.MaxDownloadLimit != 0 {
+		next.MaxDownloadLimit = o.MaxDownloadLimit
+	}
+	if o.Split != 0 {
+		next.Split = o.Split
+	}
+	if o.MaxConnectionsPerServer != 0 {
+		next.MaxConnectionsPerServer = o.MaxConnectionsPerServer
+	}
+	next.Headers = mergeStringMaps(next.Headers, o.Headers)
+	next.Cookies = mergeStringMaps(next.Cookies, o.Cookies)
+	if o.UserAgent != "" {
+		next.UserAgent = o.UserAgent
+	}
+	if o.Checksum != "" {
+		next.Checksum = o.Checksum
+	}
+	if o.Out != "" {
+		next.Out = o.Out
+	}
+	if o.SeedRatio != nil {
+		r := *o.SeedRatio
+		next.SeedRatio = &r
+	}
+	if o.SeedTime != nil {
+		t := *o.SeedTime
+		next.SeedTime = &t
+	}
+	return next
+}
+
+// Validate reports the first invalid field, wrapping ErrInvalidOptions.
+// Unlike DownloadOptions, a patch may not reset split or connections to
+// zero, since a running download has no "unset" value to return to.
+func (p *DownloadOptionsPatch) Validate() error {
+	if p.IsZero() {
+		return fmt.Errorf("%w: no changeable option set", ErrInvalidOptions)
+	}
+	next := p.Apply(nil)
+	if next == nil {
+		next = &DownloadOptions{}
+	}
+	if (p.Split != nil && *p.Split == 0) || (p.MaxConnectionsPerServer != nil && *p.MaxConnectionsPerServer == 0) {
+		return fmt.Errorf("%w: split and maxConnectionsPerServer must be at least 1", ErrInvalidOptions)
+	}
+	if err := validateLimits(next.MaxDownloadLimit, next.Split, next.MaxConnectionsPerServer); err != nil {
+		return err
+	}
+	return validateSeeding(p.SeedRatio, p.SeedTime)
+}
+
+// IsZero reports whether the patch changes nothing.
+func (p *DownloadOptionsPatch) IsZero() bool {
+	return p == nil || (p.MaxDownloadLimit == nil && p.Split == nil && p.MaxConnectionsPerServer == nil &&
+		p.SeedRatio == nil && p.SeedTime == nil)
+}
+
+// Apply merges the patch into o, which may be nil, and returns the result.
+func (p *DownloadOptionsPatch) Apply(o *DownloadOptions) *DownloadOptions {
+	next := o.Clone()
+	if next == nil {
+		next = &DownloadOptions{}
+	}
+	if p.MaxDownloadLimit != nil {
+		next.MaxDownloadLimit = *p.MaxDownloadLimit
+	}
+	if p.Split != nil {
+		next.Split = *p.Split
+	}
+	if p.MaxConnectionsPerServer != nil {
+		next.MaxConnectionsPerServer = *p.MaxConnectionsPerServer
+	}
+	if p.SeedRatio != nil {
+		r := *p.SeedRatio
+		next.SeedRatio = &r
+	}
+	if p.SeedTime != nil {
+		t := *p.SeedTime
+		next.SeedTime = &t
+	}
+	if next.IsZero() {
+		return nil
+	}
+	return next
+}
+
+// validateLimits checks the numeric options; zero means unset.
+func validateLimits(limit int64, split, conns int) error {
+	if limit < 0 {
+		return fmt.Errorf("%w: maxDownloadLimit must not be negative", ErrInvalidOptions)
+	}
+	if split < 0 || split > MaxSplit {
+		return fmt.Errorf("%w: split must be between 1 and %d", ErrInvalidOptions, MaxSplit)
+	}
+	if conns < 0 || conns > MaxConnectionsPerServerCap {
+		return fmt.Errorf("%w: maxConnectionsPerServer must be between 1 and %d", ErrInvalidOptions, MaxConnectionsPerServerCap)
+	}
+	return nil
+}
+
+// validateSeeding checks the seeding limits; nil means unset.
+func validateSeeding(ratio *float64, minutes *int) error {
+	if ratio != nil && (*ratio < 0 || math.IsNaN(*ratio) || math.IsInf(*ratio, 0)) {
+		return fmt.Errorf("%w: seedRatio must be a non-negative number", ErrInvalidOptions)
+	}
+	if minutes != nil && *minutes < 0 {
+		return fmt.Errorf("%w: seedTime must not be negative", ErrInvalidOptions)
+	}
+	return nil
+}
+
+// validHeaderName reports whether name is an HTTP header token that callers
+// may set. Host and Content-Length are reserved for the downloader.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r > 0x7e || r <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	switch textproto.CanonicalMIMEHeaderKey(name) {
+	case "Host", "Content-Length":
+		return false
+	}
+	return true
+}
+
+// mergeStringMaps returns base overlaid with over; base may be modified.
+func mergeStringMaps(base, over map[string]string) map[string]string {
+	if len(over) == 0 {
+		return base
+	}
+	if base == nil {
+		base = make(map[string]string, len(over))
+	}
+	for k, v := range over {
+		base[k] = v
+	}
+	return base
+}
+
+func cloneStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
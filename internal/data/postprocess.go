@@ -0,0 +1,122 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ErrInvalidPostProcess indicates a post-processing request failed validation.
+var ErrInvalidPostProcess = errors.New("invalid postProcess")
+
+// PostProcessMode controls how a finished payload reaches its destination.
+type PostProcessMode string
+
+// Possible PostProcessMode values.
+const (
+	PostProcessMove     PostProcessMode = "move"
+	PostProcessHardlink PostProcessMode = "hardlink"
+)
+
+// PostProcess describes what happens to a download's payload once it is
+// Complete: it is moved (or hardlinked) into Dest, or into the directory
+// configured for Category, optionally renamed and its archives extracted.
+type PostProcess struct {
+	// Category selects a configured destination directory when Dest is empty.
+	Category string `json:"category,omitempty"`
+	// Dest is an absolute destination directory.
+	Dest string `json:"dest,omitempty"`
+	// Mode defaults to move.
+	Mode PostProcessMode `json:"mode,omitempty"`
+	// Rename is a text/template for the new payload name. It can use .ID,
+	// .Name, .Base (Name without extension), .Ext and .Category.
+	Rename string `json:"rename,omitempty"`
+	// Extract unpacks zip, tar and tar.gz archives of the payload into a
+	// directory next to each archive.
+	Extract bool `json:"extract,omitempty"`
+}
+
+// PostProcessState is the state of a post-processing run or one of its steps.
+type PostProcessState string
+
+// Possible PostProcessState values.
+const (
+	PostProcessPending PostProcessState = "pending"
+	PostProcessRunning PostProcessState = "running"
+	PostProcessDone    PostProcessState = "done"
+	PostProcessFailed  PostProcessState = "failed"
+)
+
+// PostProcessStep reports the outcome of one step (move, hardlink, rename,
+// extract) of a post-processing run.
+type PostProcessStep struct {
+	Name   string           `json:"name"`
+	State  PostProcessState `json:"state"`
+	Detail string           `json:"detail,omitempty"`
+	Error  string           `json:"error,omitempty"`
+}
+
+// PostProcessStatus is the read-only outcome of post-processing a download.
+type PostProcessStatus struct {
+	State     PostProcessState  `json:"state"`
+	Steps     []PostProcessStep `json:"steps,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// Validate reports the first invalid field, wrapping ErrInvalidPostProcess.
+func (p *PostProcess) Validate() error {
+	if p == nil {
+		return nil
+	}
+	switch p.Mode {
+	case "", PostProcessMove, PostProcessHardlink:
+	default:
+		return fmt.Errorf("%w: mode must be one of move, hardlink", ErrInvalidPostProcess)
+	}
+	if p.Dest == "" && p.Category == "" {
+		return fmt.Errorf("%w: dest or category is required", ErrInvalidPostProcess)
+	}
+	if p.Dest != "" && !filepath.IsAbs(p.Dest) {
+		return fmt.Errorf("%w: dest must be an absolute path", ErrInvalidPostProcess)
+	}
+	if p.Dest != "" && filepath.Clean(p.Dest) == string(filepath.Separator) {
+		return fmt.Errorf("%w: dest must not be the filesystem root", ErrInvalidPostProcess)
+	}
+	if strings.ContainsAny(p.Category, "/\\\x00") {
+		return fmt.Errorf("%w: category must not contain path separators", ErrInvalidPostProcess)
+	}
+	if p.Rename != "" {
+		if _, err := template.New("rename").Option("missingkey=error").Parse(p.Rename); err != nil {
+			return fmt.Errorf("%w: rename: %v", ErrInvalidPostProcess, err)
+		}
+	}
+	return nil
+}
+
+// IsZero reports whether no post-processing field is set.
+func (p *PostProcess) IsZero() bool {
+	return p == nil || *p == PostProcess{}
+}
+
+// Clone returns a copy of the post-processing request.
+func (p *PostProcess) Clone() *PostProcess {
+	if p == nil {
+		return nil
+	}
+	cp := *p
+	return &cp
+}
+
+// Clone returns a deep copy of the status.
+func (s *PostProcessStatus) Clone() *PostProcessStatus {
+	if s == nil {
+		return nil
+	}
+	cp := *s
+	cp.Steps = append([]PostProcessStep(nil), s.Steps...)
+	return &cp
+}
@@ -0,0 +1,148 @@
+package data
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// DownloadSort selects the ordering of a download listing. A leading "-"
+// sorts descending. Ties are always broken by ID in the same direction.
+type DownloadSort string
+
+// Possible DownloadSort values.
+const (
+	SortCreatedAsc  DownloadSort = "createdAt"
+	SortCreatedDesc DownloadSort = "-createdAt"
+	SortNameAsc     DownloadSort = "name"
+	SortNameDesc    DownloadSort = "-name"
+	// SortQueue is scheduler admission order: Priority descending, then
+	// QueueOrder ascending.
+	SortQueue DownloadSort = "queue"
+)
+
+// Valid reports whether s is a known sort order.
+func (s DownloadSort) Valid() bool {
+	switch s {
+	case SortCreatedAsc, SortCreatedDesc, SortNameAsc, SortNameDesc, SortQueue:
+		return true
+	}
+	return false
+}
+
+// Desc reports whether s sorts in descending order.
+func (s DownloadSort) Desc() bool { return len(s) > 0 && s[0] == '-' }
+
+// Query limits.
+const (
+	DefaultQueryLimit = 100
+	MaxQueryLimit     = 500
+)
+
+// DownloadQuery filters, orders and pages a download listing. Zero-valued
+// fields do not constrain the result.
+type DownloadQuery struct {
+	// Statuses and DesiredStatuses match any of the listed values.
+	Statuses        []DownloadStatus
+	DesiredStatuses []DownloadStatus
+	// TargetPathPrefix matches downloads whose TargetPath starts with it.
+	TargetPathPrefix string
+	// NameContains is a case-insensitive substring match on Name.
+	NameContains string
+	// Category matches downloads in exactly that category.
+	Category string
+	// Labels matches downloads carrying every listed label.
+	Labels []string
+	// CreatedFrom (inclusive) and CreatedTo (exclusive) bound CreatedAt.
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+
+	// Sort defaults to SortCreatedAsc.
+	Sort DownloadSort
+	// Limit defaults to DefaultQueryLimit and may not exceed MaxQueryLimit.
+	Limit int
+	// Cursor is the NextCursor of a previous page with the same Sort.
+	Cursor string
+}
+
+// DownloadPage is one page of a download listing.
+type DownloadPage struct {
+	Items Downloads
+	// NextCursor resumes the listing after the last item; empty on the last page.
+	NextCursor string
+}
+
+var (
+	// ErrInvalidCursor indicates a malformed cursor or one issued for a
+	// different sort order.
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidQuery indicates an unknown sort order or out-of-range limit.
+	ErrInvalidQuery = errors.New("invalid query")
+)
+
+// Normalize applies defaults and validates the sort order and limit.
+func (q *DownloadQuery) Normalize() error {
+	if q.Sort == "" {
+		q.Sort = SortCreatedAsc
+	}
+	if !q.Sort.Valid() {
+		return ErrInvalidQuery
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultQueryLimit
+	}
+	if q.Limit < 0 || q.Limit > MaxQueryLimit {
+		return ErrInvalidQuery
+	}
+	return nil
+}
+
+// Match reports whether d satisfies the query's filters. Sort, Limit and
+// Cursor are ignored.
+func (q *DownloadQuery) Match(d *Download) bool {
+	if len(q.Statuses) > 0 && !containsStatus(q.Statuses, d.Status) {
+		return false
+	}
+	if len(q.DesiredStatuses) > 0 && !containsStatus(q.DesiredStatuses, d.DesiredStatus) {
+		return false
+	}
+	if q.TargetPathPrefix != "" && !strings.HasPrefix(d.TargetPath, q.TargetPathPrefix) {
+		return false
+	}
+	if q.NameContains != "" && !strings.Contains(strings.ToLower(d.Name), strings.ToLower(q.NameContains)) {
+		return false
+	}
+	if q.Category != "" && d.Category != q.Category {
+		return false
+	}
+	for _, l := range q.Labels {
+		if !containsString(d.Labels, l) {
+			return false
+		}
+	}
+	if !q.CreatedFrom.IsZero() && d.CreatedAt.Before(q.CreatedFrom) {
+		return false
+	}
+	if !q.CreatedTo.IsZero() && !d.CreatedAt.Before(q.CreatedTo) {
+		return false
+	}
+	return true
+}
+
+func containsStatus(set []DownloadStatus, s DownloadStatus) bool {
+	for _, v := range set {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(set []string, s string) bool {
+	for _, v := range set {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package data
+
+import "errors"
+
+// QueueMove repositions a queued download relative to the rest of the queue.
+type QueueMove string
+
+// Possible QueueMove values.
+const (
+	MoveTop    QueueMove = "top"
+	MoveBottom QueueMove = "bottom"
+	MoveUp     QueueMove = "up"
+	MoveDown   QueueMove = "down"
+)
+
+// Valid reports whether m is a known move.
+func (m QueueMove) Valid() bool {
+	switch m {
+	case MoveTop, MoveBottom, MoveUp, MoveDown:
+		return true
+	}
+	return false
+}
+
+var (
+	// ErrInvalidMove indicates an unknown QueueMove.
+	ErrInvalidMove = errors.New("invalid queue move")
+	// ErrNotQueued indicates a queue operation on a download that is not Queued.
+	ErrNotQueued = errors.New("download is not queued")
+)
@@ -0,0 +1,103 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidRetry indicates a retry policy failed validation.
+var ErrInvalidRetry = errors.New("invalid retry policy")
+
+// Limits for retry policies.
+const (
+	MaxRetryAttempts   = 100
+	MaxRetryBackoffSec = 7 * 24 * 60 * 60
+)
+
+// RetryPolicy controls automatic retries of a download that failed. Nil
+// fields fall back to the global policy.
+type RetryPolicy struct {
+	// MaxAttempts is how many times a failed download is restarted; 0
+	// disables retries.
+	MaxAttempts *int `json:"maxAttempts,omitempty"`
+	// BackoffSec is the delay before the first retry. It doubles with each
+	// further attempt, up to MaxBackoffSec.
+	BackoffSec    *int `json:"backoffSec,omitempty"`
+	MaxBackoffSec *int `json:"maxBackoffSec,omitempty"`
+	// RetryableCodes are the downloader error codes (aria2 exit status
+	// numbers) that trigger a retry. Other failures are final.
+	RetryableCodes []int `json:"retryableCodes,omitempty"`
+}
+
+// RetryStatus is the read-only record of the retries made for a download.
+// The failure that triggered them is Download.Failure.
+type RetryStatus struct {
+	// Attempts counts the automatic retries made so far.
+	Attempts int `json:"attempts"`
+	// NextRetryAt is when the next retry is due, if one is scheduled.
+	NextRetryAt *time.Time `json:"nextRetryAt,omitempty"`
+}
+
+// Validate reports the first invalid field, wrapping ErrInvalidRetry.
+func (p *RetryPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.MaxAttempts != nil && (*p.MaxAttempts < 0 || *p.MaxAttempts > MaxRetryAttempts) {
+		return fmt.Errorf("%w: maxAttempts must be between 0 and %d", ErrInvalidRetry, MaxRetryAttempts)
+	}
+	for _, v := range []*int{p.BackoffSec, p.MaxBackoffSec} {
+		if v != nil && (*v < 1 || *v > MaxRetryBackoffSec) {
+			return fmt.Errorf("%w: backoffSec and maxBackoffSec must be between 1 and %d", ErrInvalidRetry, MaxRetryBackoffSec)
+		}
+	}
+	if p.BackoffSec != nil && p.MaxBackoffSec != nil && *p.MaxBackoffSec < *p.BackoffSec {
+		return fmt.Errorf("%w: maxBackoffSec must not be less than backoffSec", ErrInvalidRetry)
+	}
+	for _, c := range p.RetryableCodes {
+		if c < 1 {
+			return fmt.Errorf("%w: retryableCodes must be positive", ErrInvalidRetry)
+		}
+	}
+	return nil
+}
+
+// IsZero reports whether the policy sets nothing.
+func (p *RetryPolicy) IsZero() bool {
+	return p == nil || (p.MaxAttempts == nil && p.BackoffSec == nil && p.MaxBackoffSec == nil && len(p.RetryableCodes) == 0)
+}
+
+// Clone returns a deep copy of the policy.
+func (p *RetryPolicy) Clone() *RetryPolicy {
+	if p == nil {
+		return nil
+	}
+	return &RetryPolicy{
+		MaxAttempts:    cloneInt(p.MaxAttempts),
+		BackoffSec:     cloneInt(p.BackoffSec),
+		MaxBackoffSec:  cloneInt(p.MaxBackoffSec),
+		RetryableCodes: append([]int(nil), p.RetryableCodes...),
+	}
+}
+
+func cloneInt(v *int) *int {
+	if v == nil {
+		return nil
+	}
+	n := *v
+	return &n
+}
+
+// Clone returns a deep copy of the status.
+func (s *RetryStatus) Clone() *RetryStatus {
+	if s == nil {
+		return nil
+	}
+	cp := *s
+	if s.NextRetryAt != nil {
+		t := *s.NextRetryAt
+		cp.NextRetryAt = &t
+	}
+	return &cp
+}
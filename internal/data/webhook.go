@@ -0,0 +1,162 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// Webhook is an outbound HTTP subscription to download lifecycle events.
+type Webhook struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Secret signs delivery bodies (HMAC-SHA256). It is only returned when a
+	// webhook is created.
+	Secret string `json:"secret,omitempty"`
+	// Events restricts deliveries to the listed event types. Empty means all.
+	Events    []WebhookEvent `json:"events,omitempty"`
+	Enabled   bool           `json:"enabled"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+// Webhooks is a slice of Webhook pointers.
+type Webhooks []*Webhook
+
+// WebhookEvent names a lifecycle transition that can trigger a delivery.
+type WebhookEvent string
+
+// Possible WebhookEvent values. Each corresponds to a reconciled status.
+const (
+	WebhookDownloadActive    WebhookEvent = "download.active"
+	WebhookDownloadPaused    WebhookEvent = "download.paused"
+	WebhookDownloadSeeding   WebhookEvent = "download.seeding"
+	WebhookDownloadCancelled WebhookEvent = "download.cancelled"
+	WebhookDownloadComplete  WebhookEvent = "download.complete"
+	WebhookDownloadFailed    WebhookEvent = "download.failed"
+)
+
+var webhookEventByStatus = map[DownloadStatus]WebhookEvent{
+	StatusActive:    WebhookDownloadActive,
+	StatusPaused:    WebhookDownloadPaused,
+	StatusSeeding:   WebhookDownloadSeeding,
+	StatusCancelled: WebhookDownloadCancelled,
+	StatusComplete:  WebhookDownloadComplete,
+	StatusError:     WebhookDownloadFailed,
+}
+
+// WebhookEventFor maps a download status to its webhook event, if any.
+func WebhookEventFor(s DownloadStatus) (WebhookEvent, bool) {
+	ev, ok := webhookEventByStatus[s]
+	return ev, ok
+}
+
+// Valid reports whether e is a known webhook event type.
+func (e WebhookEvent) Valid() bool {
+	for _, known := range webhookEventByStatus {
+		if e == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Wants reports whether the webhook subscribes to the given event.
+func (w *Webhook) Wants(e WebhookEvent) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, want := range w.Events {
+		if strings.EqualFold(string(want), string(e)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Clone returns a copy of the webhook. The receiver is left unchanged.
+func (w *Webhook) Clone() *Webhook {
+	if w == nil {
+		return nil
+	}
+	cp := *w
+	if len(w.Events) > 0 {
+		cp.Events = make([]WebhookEvent, len(w.Events))
+		copy(cp.Events, w.Events)
+	}
+	return &cp
+}
+
+// Redacted returns a copy of the webhook without its secret.
+func (w *Webhook) Redacted() *Webhook {
+	cp := w.Clone()
+	if cp != nil {
+		cp.Secret = ""
+	}
+	return cp
+}
+
+// ToJSON writes the webhook as JSON to the writer.
+func (w *Webhook) ToJSON(wr io.Writer) error { return json.NewEncoder(wr).Encode(w) }
+
+// ToJSON writes the slice of webhooks as JSON to the writer.
+func (ws *Webhooks) ToJSON(wr io.Writer) error { return json.NewEncoder(wr).Encode(ws) }
+
+// DeliveryState is the outcome of a webhook delivery.
+type DeliveryState string
+
+// Possible DeliveryState values.
+const (
+	DeliveryPending   DeliveryState = "Pending"
+	DeliverySucceeded DeliveryState = "Succeeded"
+	DeliveryFailed    DeliveryState = "Failed"
+)
+
+// WebhookDelivery records one event sent (or to be sent) to a webhook,
+// including every retry attempt made so far.
+type WebhookDelivery struct {
+	ID         string       `json:"id"`
+	WebhookID  string       `json:"webhookId"`
+	Event      WebhookEvent `json:"event"`
+	DownloadID string       `json:"downloadId"`
+	// Payload is the exact JSON body posted to the webhook.
+	Payload      json.RawMessage `json:"payload,omitempty"`
+	State        DeliveryState   `json:"state"`
+	Attempts     int             `json:"attempts"`
+	ResponseCode int             `json:"responseCode,omitempty"`
+	LastError    string          `json:"lastError,omitempty"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	UpdatedAt    time.Time       `json:"updatedAt"`
+	// NextAttemptAt is set while a retry is scheduled.
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+}
+
+// WebhookDeliveries is a slice of WebhookDelivery pointers.
+type WebhookDeliveries []*WebhookDelivery
+
+// Clone returns a copy of the delivery. The receiver is left unchanged.
+func (d *WebhookDelivery) Clone() *WebhookDelivery {
+	if d == nil {
+		return nil
+	}
+	cp := *d
+	if d.Payload != nil {
+		cp.Payload = append(json.RawMessage(nil), d.Payload...)
+	}
+	if d.NextAttemptAt != nil {
+		t := *d.NextAttemptAt
+		cp.NextAttemptAt = &t
+	}
+	return &cp
+}
+
+// ToJSON writes the slice of deliveries as JSON to the writer.
+func (ds *WebhookDeliveries) ToJSON(w io.Writer) error { return json.NewEncoder(w).Encode(ds) }
+
+var (
+	// ErrWebhookNotFound indicates the requested webhook does not exist.
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrInvalidWebhook signals an invalid webhook URL or event filter.
+	ErrInvalidWebhook = errors.New("invalid webhook")
+)
@@ -4,11 +4,14 @@ import (
     "os"
     "strconv"
     "sync"
+    "sync/atomic"
+    "time"
 
     "log/slog"
 
     "github.com/tinoosan/torrus/internal/aria2"
     "github.com/tinoosan/torrus/internal/downloader"
+    "github.com/tinoosan/torrus/internal/pathsafe"
 )
 
 type fsOps interface {
@@ -34,6 +37,16 @@ type Adapter struct {
     pollMS     int
     log        *slog.Logger
     fs         fsOps
+    // roots, when set, bounds where Delete may remove files.
+    roots pathsafe.Roots
+
+    // reconnectMin and reconnectMax bound the jittered exponential backoff
+    // between notification WebSocket reconnect attempts.
+    reconnectMin time.Duration
+    reconnectMax time.Duration
+    connected    atomic.Bool
+    // established is set once the first notification connection succeeds.
+    established atomic.Bool
 }
 
 // NewAdapter creates a new Adapter using the provided aria2 client and reporter.
@@ -44,13 +57,33 @@ func NewAdapter(cl *aria2.Client, rep downloader.Reporter) *Adapter {
             poll = n
         }
     }
-    return &Adapter{cl: cl, rep: rep, gidToID: make(map[string]string), activeGIDs: make(map[string]struct{}), lastProg: make(map[string]downloader.Progress), pollMS: poll, log: slog.Default(), fs: osFS{}}
+    return &Adapter{
+        cl: cl, rep: rep, gidToID: make(map[string]string), activeGIDs: make(map[string]struct{}), lastProg: make(map[string]downloader.Progress), pollMS: poll, log: slog.Default(), fs: osFS{},
+        reconnectMin: msFromEnv("ARIA2_RECONNECT_MIN_MS", 500),
+        reconnectMax: msFromEnv("ARIA2_RECONNECT_MAX_MS", 30000),
+    }
+}
+
+// msFromEnv reads a positive millisecond duration from the environment.
+func msFromEnv(key string, def int) time.Duration {
+    if v := os.Getenv(key); v != "" {
+        if n, err := strconv.Atoi(v); err == nil && n > 0 {
+            return time.Duration(n) * time.Millisecond
+        }
+    }
+    return time.Duration(def) * time.Millisecond
 }
 
 var _ downloader.Downloader = (*Adapter)(nil)
 var _ downloader.EventSource = (*Adapter)(nil)
 var _ downloader.FileLister = (*Adapter)(nil)
 
+// SetAllowedRoots makes Delete refuse to remove files of a download whose
+// target path is not under one of roots. An empty set disables the check.
+func (a *Adapter) SetAllowedRoots(roots pathsafe.Roots) {
+    a.roots = roots
+}
+
 // SetLogger allows wiring a shared application logger into the adapter.
 func (a *Adapter) SetLogger(l *slog.Logger) {
     if l != nil {
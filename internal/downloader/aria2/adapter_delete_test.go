@@ -2,11 +2,14 @@ package aria2dl
 
 import (
     "context"
+    "errors"
+    "net/http"
     "os"
     "path/filepath"
     "testing"
 
     "github.com/tinoosan/torrus/internal/data"
+    "github.com/tinoosan/torrus/internal/pathsafe"
 )
 
 // Ensure Delete() deduplicates identical payload and sidecar paths, producing
@@ -48,3 +51,59 @@ func TestDelete_DeduplicatesPathsAndSidecars(t *testing.T) {
     }
 }
 
+
+// Delete must refuse to remove anything when the download's target path is
+// outside the allowed roots, even if the paths are under its own base.
+func TestDelete_RefusesOutsideAllowedRoots(t *testing.T) {
+    t.Parallel()
+    allowed := t.TempDir()
+    base := t.TempDir()
+    roots, err := pathsafe.ParseRoots(allowed)
+    if err != nil {
+        t.Fatalf("roots: %v", err)
+    }
+
+    dl := &data.Download{ID: "1", TargetPath: base, Name: "x.mkv", Files: []data.DownloadFile{{Path: "x.mkv"}}}
+    a := newAdapterNoRPC(t)
+    a.SetAllowedRoots(roots)
+    fake := &fakeFS{}
+    a.fs = fake
+
+    if err := a.Delete(context.Background(), dl, true); !errors.Is(err, pathsafe.ErrNotAllowed) {
+        t.Fatalf("Delete: want ErrNotAllowed, got %v", err)
+    }
+    if len(fake.removed)+len(fake.removedAll) != 0 {
+        t.Fatalf("unexpected removals: %#v %#v", fake.removed, fake.removedAll)
+    }
+
+    dl.TargetPath = allowed
+    if err := a.Delete(context.Background(), dl, true); err != nil {
+        t.Fatalf("Delete under allowed root: %v", err)
+    }
+    if len(fake.removedAll) != 1 || fake.removedAll[0] != filepath.Join(allowed, "x.mkv") {
+        t.Fatalf("unexpected RemoveAll calls: %#v", fake.removedAll)
+    }
+}
+
+// A delete refused by the roots guard must not cancel the task first.
+func TestDelete_RefusedBeforeCancel(t *testing.T) {
+    roots, err := pathsafe.ParseRoots(t.TempDir())
+    if err != nil {
+        t.Fatalf("roots: %v", err)
+    }
+    calls := 0
+    rt := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+        calls++
+        return nil, errors.New("unexpected RPC")
+    })
+    a := newTestAdapter(t, "", rt)
+    a.SetAllowedRoots(roots)
+
+    dl := &data.Download{ID: "1", GID: "g1", TargetPath: t.TempDir(), Name: "x.mkv"}
+    if err := a.Delete(context.Background(), dl, true); !errors.Is(err, pathsafe.ErrNotAllowed) {
+        t.Fatalf("Delete: want ErrNotAllowed, got %v", err)
+    }
+    if calls != 0 {
+        t.Fatalf("task touched before the roots guard: %d RPC calls", calls)
+    }
+}
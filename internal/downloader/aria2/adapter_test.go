@@ -935,6 +935,24 @@ func TestAdapterHandleNotification(t *testing.T) {
 	}
 }
 
+// TestAdapterErrorNotificationCarriesCode ensures a failure event reports
+// aria2's errorCode and errorMessage from tellStatus, mapped to a Torrus
+// failure code.
+func TestAdapterErrorNotificationCarriesCode(t *testing.T) {
+	rt := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		rb, _ := json.Marshal(rpcResp{Jsonrpc: "2.0", ID: "torrus", Result: json.RawMessage(`{"gid":"g3","status":"error","errorCode":"6","errorMessage":"Network problem"}`)})
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader(rb)), Header: make(http.Header)}, nil
+	})
+	a, events := newTestAdapterWithEvents(t, "", rt)
+	a.track("g3", "3")
+	a.handleNotification(context.Background(), aria2.Notification{Method: "aria2.onDownloadError", Params: []aria2.NotificationEvent{{GID: "g3"}}})
+	ev := <-events
+	f := ev.Failure
+	if ev.Type != downloader.EventFailed || f == nil || f.Code != data.FailureNetwork || f.DownloaderCode != 6 || f.Message != "Network problem" || f.Source != data.FailureSourceDownloader {
+		t.Fatalf("unexpected event %#v (failure %+v)", ev, f)
+	}
+}
+
 // --- Consolidated delete fallback tests ---
 
 func TestDelete_HTTPFile_Cancelled_Fallback_RemovesFileAndAria2(t *testing.T) {
@@ -1120,3 +1138,47 @@ func TestAdapterMetadataCompleteTriggersFollowedBySwap(t *testing.T) {
 		t.Fatalf("expected meta with name Real.Title, got %#v", ev)
 	}
 }
+
+func TestAdapterReorder(t *testing.T) {
+	var calls [][]any
+	rt := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(r.Body)
+		var req rpcReq
+		if err := json.Unmarshal(b, &req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if req.Method != "aria2.changePosition" {
+			t.Fatalf("method = %s", req.Method)
+		}
+		calls = append(calls, req.Params)
+		resp := rpcResp{Jsonrpc: "2.0", ID: "torrus", Result: json.RawMessage(`0`)}
+		if req.Params[1] == "active" {
+			resp = rpcResp{Jsonrpc: "2.0", ID: "torrus", Error: &rpcError{Code: 1, Message: "GID#active not found in the waiting queue."}}
+		}
+		rb, _ := json.Marshal(resp)
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader(rb)), Header: make(http.Header)}, nil
+	})
+	a := newTestAdapter(t, "secret", rt)
+	ds := data.Downloads{{GID: "g1"}, {GID: ""}, {GID: "active"}, {GID: "g2"}}
+	if err := a.Reorder(context.Background(), ds); err != nil {
+		t.Fatalf("Reorder: %v", err)
+	}
+	want := [][]any{
+		{"token:secret", "g1", float64(0), "POS_SET"},
+		{"token:secret", "active", float64(1), "POS_SET"},
+		{"token:secret", "g2", float64(1), "POS_SET"},
+	}
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+
+	// Other failures, even ones mentioning "not found", are not skipped.
+	rt = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		rb, _ := json.Marshal(rpcResp{Jsonrpc: "2.0", ID: "torrus", Error: &rpcError{Code: 1, Message: "Method not found"}})
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader(rb)), Header: make(http.Header)}, nil
+	})
+	a = newTestAdapter(t, "secret", rt)
+	if err := a.Reorder(context.Background(), data.Downloads{{GID: "g1"}}); err == nil {
+		t.Fatal("Reorder swallowed an unrelated error")
+	}
+}
@@ -12,6 +12,7 @@ import (
 
     "github.com/tinoosan/torrus/internal/data"
     "github.com/tinoosan/torrus/internal/downloader"
+    "github.com/tinoosan/torrus/internal/pathsafe"
     "github.com/tinoosan/torrus/internal/reqid"
 )
 
@@ -19,6 +20,19 @@ import (
 // payload files, known sidecar files and prunes empty directories. If
 // deleteFiles is true and any removal fails, the first error is returned.
 func (a *Adapter) Delete(ctx context.Context, dl *data.Download, deleteFiles bool) error {
+    base := filepath.Clean(dl.TargetPath)
+    if dl.TargetPath == "" {
+        base = ""
+    }
+    // Second guard behind request validation: never remove files of a
+    // download whose target path resolves outside the allowed roots. It runs
+    // before the task is cancelled, so a refused delete leaves it running.
+    if deleteFiles && len(a.roots) > 0 {
+        if _, err := a.roots.Check(base); err != nil {
+            return fmt.Errorf("refusing to delete: %w", err)
+        }
+    }
+
     if dl.GID != "" {
         if err := a.Cancel(ctx, dl); err != nil && !errors.Is(err, downloader.ErrNotFound) {
             return err
@@ -50,29 +64,9 @@ func (a *Adapter) Delete(ctx context.Context, dl *data.Download, deleteFiles boo
         paths = []string{dl.Name}
     }
 
-    base := filepath.Clean(dl.TargetPath)
-    if dl.TargetPath == "" {
-        base = ""
-    }
-
-    // Helper to ensure a path is within the base directory (but not equal to it).
-    baseWithSep := base
-    if baseWithSep != "" && !strings.HasSuffix(baseWithSep, string(os.PathSeparator)) {
-        baseWithSep += string(os.PathSeparator)
-    }
-    isSafe := func(p string) bool {
-        if base == "" {
-            // If no base is configured, only allow absolute paths that were
-            // normalized/cleaned earlier.
-            return filepath.IsAbs(p)
-        }
-        // Never delete the base root itself.
-        if p == base {
-            return false
-        }
-        // Only allow paths under base/
-        return strings.HasPrefix(p, baseWithSep)
-    }
+    // Only touch paths strictly under the base directory (never base itself);
+    // with no base, only absolute paths are allowed.
+    isSafe := func(p string) bool { return pathsafe.Within(base, p) }
 
     var files []string
     sidecars := map[string]struct{}{}
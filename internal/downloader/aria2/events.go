@@ -5,6 +5,7 @@ import (
     "encoding/json"
     "fmt"
     "log/slog"
+    "math/rand/v2"
     "strconv"
     "time"
 
@@ -21,10 +22,18 @@ func (a *Adapter) emitComplete(id string, gid string) {
     }
 }
 
-// EmitFailed signals that a download has failed.
-func (a *Adapter) emitFailed(id string, gid string) {
+// EmitFailed signals that a download has failed, with aria2's error code
+// and message when known.
+func (a *Adapter) emitFailed(id string, gid string, code int, msg string) {
     if a.rep != nil {
-        a.rep.Report(downloader.Event{ID: id, GID: gid, Type: downloader.EventFailed})
+        a.rep.Report(downloader.Event{ID: id, GID: gid, Type: downloader.EventFailed, Failure: failure(code, msg)})
+    }
+}
+
+// emitSeeding signals that a BitTorrent download finished and is seeding.
+func (a *Adapter) emitSeeding(id string, gid string) {
+    if a.rep != nil {
+        a.rep.Report(downloader.Event{ID: id, GID: gid, Type: downloader.EventSeeding})
     }
 }
 
@@ -35,28 +44,120 @@ func (a *Adapter) emitProgress(id string, gid string, p downloader.Progress) {
     }
 }
 
-// Run subscribes to aria2 notifications and emits corresponding downloader events.
+// Run subscribes to aria2 notifications and emits corresponding downloader
+// events until ctx is cancelled. When the WebSocket drops (or cannot be
+// opened) it reconnects with jittered exponential backoff, and after every
+// connect it runs a catch-up pass for changes missed while disconnected.
 func (a *Adapter) Run(ctx context.Context) {
     // Tag this run with a stable operation_id for correlation.
     opID := uuid.NewString()
     lg := a.log.With("operation_id", opID)
-    ch, err := a.cl.Notifications(ctx)
-    if err != nil {
-        return
-    }
     // Start poller goroutine for continuous progress updates
     go a.pollLoopWithLogger(ctx, lg)
+    delay := a.reconnectMin
     for {
-        select {
-        case <-ctx.Done():
-            return
-        case n, ok := <-ch:
-            if !ok {
+        ch, err := a.cl.Notifications(ctx)
+        if err != nil {
+            if ctx.Err() != nil {
+                return
+            }
+            wait := jitter(delay)
+            lg.Warn("aria2 notifications connect failed", "err", err, "retry_in", wait)
+            select {
+            case <-ctx.Done():
                 return
+            case <-time.After(wait):
             }
+            delay = min(2*delay, a.reconnectMax)
+            continue
+        }
+        delay = a.reconnectMin
+        a.setConnected(true)
+        lg.Info("aria2 notifications connected")
+        a.catchUp(ctx, lg)
+        for n := range ch {
             a.handleNotification(ctx, n)
         }
+        a.setConnected(false)
+        if ctx.Err() != nil {
+            return
+        }
+        metrics.Aria2NotificationDisconnects.Inc()
+        lg.Warn("aria2 notifications disconnected; reconnecting")
+    }
+}
+
+// NotificationsConnected reports whether the aria2 notification WebSocket is
+// currently connected.
+func (a *Adapter) NotificationsConnected() bool { return a.connected.Load() }
+
+// NotificationsEstablished reports whether the aria2 notification WebSocket
+// has connected at least once, so that a disconnected stream is a drop
+// rather than the first connection still being set up.
+func (a *Adapter) NotificationsEstablished() bool { return a.established.Load() }
+
+func (a *Adapter) setConnected(v bool) {
+    a.connected.Store(v)
+    if v {
+        a.established.Store(true)
+        metrics.Aria2NotificationsConnected.Set(1)
+    } else {
+        metrics.Aria2NotificationsConnected.Set(0)
+    }
+}
+
+// jitter returns a random duration in [d/2, d] so reconnecting clients do
+// not retry in lockstep.
+func jitter(d time.Duration) time.Duration {
+    half := d / 2
+    return half + rand.N(d-half+1)
+}
+
+// catchUp settles tracked tasks that stopped while notifications were down,
+// using aria2.tellStopped, and refreshes progress for tracked tasks that are
+// still running, using aria2.tellActive.
+func (a *Adapter) catchUp(ctx context.Context, lg *slog.Logger) {
+    a.mu.RLock()
+    tracked := make(map[string]string, len(a.gidToID))
+    for gid, id := range a.gidToID {
+        tracked[gid] = id
+    }
+    a.mu.RUnlock()
+    if len(tracked) == 0 {
+        return
+    }
+    stopped, err := a.tellTasks(ctx, "aria2.tellStopped", 0, maxStoppedScan)
+    if err != nil {
+        lg.Warn("aria2 catch-up: tellStopped failed", "err", err)
+        return
+    }
+    settled := 0
+    for i := range stopped {
+        if id, ok := tracked[stopped[i].GID]; ok {
+            if next := a.settle(id, &stopped[i]); next != "" {
+                // Metadata finished: keep following the spawned task.
+                a.track(next, id)
+            }
+            settled++
+        }
+    }
+    active, err := a.tellTasks(ctx, "aria2.tellActive")
+    if err != nil {
+        lg.Warn("aria2 catch-up: tellActive failed", "err", err)
+        return
+    }
+    for i := range active {
+        a.mu.RLock()
+        id, ok := a.gidToID[active[i].GID]
+        a.mu.RUnlock()
+        if ok {
+            a.emitProgress(id, active[i].GID, active[i].progress())
+            if active[i].Seeder == "true" {
+                a.emitSeeding(id, active[i].GID)
+            }
+        }
     }
+    lg.Info("aria2 catch-up complete", "tracked", len(tracked), "settled", settled)
 }
 
 func (a *Adapter) handleNotification(ctx context.Context, n aria2.Notification) {
@@ -111,8 +212,21 @@ func (a *Adapter) handleNotification(ctx context.Context, n aria2.Notification)
             // update active downloads gauge
             metrics.ActiveDownloads.Set(float64(len(a.activeGIDs)))
             a.mu.Unlock()
+        case "aria2.onBtDownloadComplete":
+            // The payload is on disk; aria2 keeps the task active to seed
+            // until seed-ratio/seed-time is reached, then notifies
+            // onDownloadComplete. Keep tracking so uploads are polled.
+            if prog, err := a.tellStatus(ctx, p.GID); err == nil && prog != nil {
+                a.emitProgress(id, p.GID, *prog)
+            }
+            a.emitSeeding(id, p.GID)
         case "aria2.onDownloadError":
-            a.emitFailed(id, p.GID)
+            // The notification only carries the GID; ask aria2 why it failed.
+            code, msg := 0, ""
+            if st, err := a.taskStatus(ctx, p.GID); err == nil {
+                code, msg = st.errorCode(), st.ErrorMessage
+            }
+            a.emitFailed(id, p.GID, code, msg)
             a.mu.Lock()
             delete(a.gidToID, p.GID)
             delete(a.activeGIDs, p.GID)
@@ -151,6 +265,8 @@ type statusResp struct {
     TotalLength     string `json:"totalLength"`
     CompletedLength string `json:"completedLength"`
     DownloadSpeed   string `json:"downloadSpeed"`
+    UploadLength    string `json:"uploadLength"`
+    UploadSpeed     string `json:"uploadSpeed"`
 }
 
 // tellStatus queries aria2 for the current status of the given GID and maps it to downloader.Progress.
@@ -160,7 +276,7 @@ func (a *Adapter) tellStatus(ctx context.Context, gid string) (*downloader.Progr
         params = append(params, tok...)
     }
     params = append(params, gid)
-    params = append(params, []string{"totalLength", "completedLength", "downloadSpeed"})
+    params = append(params, []string{"totalLength", "completedLength", "downloadSpeed", "uploadLength", "uploadSpeed"})
 
     res, err := a.call(ctx, "aria2.tellStatus", params)
     if err != nil {
@@ -180,7 +296,13 @@ func (a *Adapter) tellStatus(ctx context.Context, gid string) (*downloader.Progr
         }
         return v
     }
-    p := &downloader.Progress{Completed: parse(sr.CompletedLength), Total: parse(sr.TotalLength), Speed: parse(sr.DownloadSpeed)}
+    p := &downloader.Progress{
+        Completed:   parse(sr.CompletedLength),
+        Total:       parse(sr.TotalLength),
+        Speed:       parse(sr.DownloadSpeed),
+        Uploaded:    parse(sr.UploadLength),
+        UploadSpeed: parse(sr.UploadSpeed),
+    }
     return p, nil
 }
 
@@ -215,7 +337,7 @@ func (a *Adapter) pollLoopWithLogger(ctx context.Context, lg *slog.Logger) {
                     }
                     continue
                 }
-                if last.Completed == prog.Completed && last.Speed == prog.Speed {
+                if last.Completed == prog.Completed && last.Speed == prog.Speed && last.Uploaded == prog.Uploaded && last.UploadSpeed == prog.UploadSpeed {
                     continue
                 }
                 a.emitProgress(id, gid, *prog)
@@ -0,0 +1,48 @@
+package aria2dl
+
+import "github.com/tinoosan/torrus/internal/data"
+
+// failureCodes maps aria2 exit status codes to Torrus failure codes. Codes
+// not listed here (including 1, "unknown error") map to FailureUnknown.
+var failureCodes = map[int]data.FailureCode{
+    2:  data.FailureTimeout,
+    3:  data.FailureNotFound,
+    4:  data.FailureNotFound,
+    5:  data.FailureTooSlow,
+    6:  data.FailureNetwork,
+    7:  data.FailureInterrupted,
+    8:  data.FailureResumeNotSupported,
+    9:  data.FailureDiskFull,
+    10: data.FailureFileIO,
+    11: data.FailureDuplicate,
+    12: data.FailureDuplicate,
+    13: data.FailureFileExists,
+    14: data.FailureFileIO,
+    15: data.FailureFileIO,
+    16: data.FailureFileIO,
+    17: data.FailureFileIO,
+    18: data.FailureFileIO,
+    19: data.FailureNameResolution,
+    20: data.FailureInvalidMetadata,
+    21: data.FailureNetwork,
+    22: data.FailureHTTP,
+    23: data.FailureHTTP,
+    24: data.FailureUnauthorized,
+    25: data.FailureInvalidMetadata,
+    26: data.FailureInvalidMetadata,
+    27: data.FailureInvalidMetadata,
+    28: data.FailureInvalidRequest,
+    29: data.FailureServerOverloaded,
+    30: data.FailureInvalidRequest,
+    32: data.FailureChecksumMismatch,
+}
+
+// failure builds the failure record for a task that stopped with aria2 exit
+// status code and message msg.
+func failure(code int, msg string) *data.Failure {
+    fc, ok := failureCodes[code]
+    if !ok {
+        fc = data.FailureUnknown
+    }
+    return &data.Failure{Code: fc, DownloaderCode: code, Message: msg, Source: data.FailureSourceDownloader}
+}
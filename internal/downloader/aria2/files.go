@@ -11,8 +11,11 @@ import (
     "strings"
 
     "github.com/tinoosan/torrus/internal/data"
+    "github.com/tinoosan/torrus/internal/downloader"
 )
 
+var _ downloader.FileSelector = (*Adapter)(nil)
+
 // nameStatus is a partial tellStatus response focused on metadata useful to
 // derive a human-friendly name.
 type nameStatus struct {
@@ -29,9 +32,11 @@ type nameStatus struct {
 
 // fileStatus is a partial tellStatus response for files[] entries.
 type fileStatus struct {
+    Index           string `json:"index"`
     Path            string `json:"path"`
     Length          string `json:"length"`
     CompletedLength string `json:"completedLength"`
+    Selected        string `json:"selected"`
 }
 
 // getFiles queries aria2.getFiles and maps to []data.DownloadFile.
@@ -67,11 +72,37 @@ func (a *Adapter) getFiles(ctx context.Context, gid string) []data.DownloadFile
         if base == "." || base == "" {
             continue
         }
-        out = append(out, data.DownloadFile{Path: base, Length: parse(f.Length), Completed: parse(f.CompletedLength)})
+        out = append(out, data.DownloadFile{
+            Path:      base,
+            Length:    parse(f.Length),
+            Completed: parse(f.CompletedLength),
+            Index:     int(parse(f.Index)),
+            Selected:  f.Selected == "true",
+        })
     }
     return out
 }
 
+// SelectFiles: aria2.changeOption([token?, gid, {"select-file": "1,3"}])
+//
+// For BitTorrent tasks aria2 stops fetching deselected files; pieces
+// shared with a selected file may still be written.
+func (a *Adapter) SelectFiles(ctx context.Context, dl *data.Download, indexes []int) error {
+    list := make([]string, len(indexes))
+    for i, idx := range indexes {
+        list[i] = strconv.Itoa(idx)
+    }
+    opts := map[string]string{"select-file": strings.Join(list, ",")}
+    params := append(a.tokenParam(), dl.GID, opts)
+    if _, err := a.call(ctx, "aria2.changeOption", params); err != nil {
+        if isAria2UnknownGIDError(err) {
+            return downloader.ErrNotFound
+        }
+        return err
+    }
+    return nil
+}
+
 // getFilePaths queries aria2.getFiles and returns the raw paths as reported by aria2.
 func (a *Adapter) getFilePaths(ctx context.Context, gid string) []string {
     params := make([]interface{}, 0, 2)
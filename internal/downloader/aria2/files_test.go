@@ -0,0 +1,58 @@
+package aria2dl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/tinoosan/torrus/internal/data"
+)
+
+// TestAdapterFileSelection checks that getFiles reports aria2's file indexes
+// and selection, and that SelectFiles sets select-file on the task.
+func TestAdapterFileSelection(t *testing.T) {
+	var calls []rpcReq
+	rt := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(r.Body)
+		var req rpcReq
+		if err := json.Unmarshal(b, &req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		calls = append(calls, req)
+		resp := rpcResp{Jsonrpc: "2.0", ID: "torrus", Result: json.RawMessage(`"OK"`)}
+		if req.Method == "aria2.getFiles" {
+			resp.Result = json.RawMessage(`[
+				{"index":"1","path":"/dl/pack/a.mkv","length":"10","completedLength":"5","selected":"true"},
+				{"index":"2","path":"/dl/pack/a.nfo","length":"1","completedLength":"0","selected":"false"}
+			]`)
+		}
+		rb, _ := json.Marshal(resp)
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader(rb)), Header: make(http.Header)}, nil
+	})
+	a, _ := newTestAdapterWithEvents(t, "", rt)
+
+	got := a.getFiles(context.Background(), "g1")
+	want := []data.DownloadFile{
+		{Path: "a.mkv", Length: 10, Completed: 5, Index: 1, Selected: true},
+		{Path: "a.nfo", Length: 1, Index: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("getFiles = %+v, want %+v", got, want)
+	}
+
+	calls = nil
+	if err := a.SelectFiles(context.Background(), &data.Download{ID: "1", GID: "g1"}, []int{1, 3}); err != nil {
+		t.Fatalf("SelectFiles: %v", err)
+	}
+	if len(calls) != 1 || calls[0].Method != "aria2.changeOption" || calls[0].Params[0] != "g1" {
+		t.Fatalf("calls = %+v", calls)
+	}
+	opts := calls[0].Params[1].(map[string]interface{})
+	if !reflect.DeepEqual(opts, map[string]interface{}{"select-file": "1,3"}) {
+		t.Fatalf("changeOption options = %v", opts)
+	}
+}
@@ -2,6 +2,7 @@ package aria2dl
 
 import (
     "context"
+    "encoding/base64"
     "encoding/json"
     "fmt"
 
@@ -20,20 +21,28 @@ func (a *Adapter) Ping(ctx context.Context) error {
     return err
 }
 
-// Start: aria2.addUri([token?, [uris], options])
+// Start: aria2.addUri([token?, [uris], options]), or for uploaded files
+// aria2.addTorrent([token?, torrent, [], options]) and
+// aria2.addMetalink([token?, metalink, options]).
 func (a *Adapter) Start(ctx context.Context, dl *data.Download) (string, error) {
-    params := make([]interface{}, 0, 3)
-    if tok := a.tokenParam(); tok != nil {
-        params = append(params, tok...)
-    }
-    params = append(params, []string{dl.Source})
-    opts := map[string]string{}
+    opts := aria2Options(dl.Options)
     if dl.TargetPath != "" {
         opts["dir"] = dl.TargetPath
     }
-    params = append(params, opts)
+    params := a.tokenParam()
+    method := "aria2.addUri"
+    switch {
+    case len(dl.Payload) > 0 && dl.PayloadType == data.PayloadTorrent:
+        method = "aria2.addTorrent"
+        params = append(params, base64.StdEncoding.EncodeToString(dl.Payload), []string{}, opts)
+    case len(dl.Payload) > 0 && dl.PayloadType == data.PayloadMetalink:
+        method = "aria2.addMetalink"
+        params = append(params, base64.StdEncoding.EncodeToString(dl.Payload), opts)
+    default:
+        params = append(params, []string{dl.Source}, opts)
+    }
 
-    res, err := a.call(ctx, "aria2.addUri", params)
+    res, err := a.call(ctx, method, params)
     if err != nil {
         if isAria2ConflictError(err) {
             return "", data.ErrConflict
@@ -42,8 +51,24 @@ func (a *Adapter) Start(ctx context.Context, dl *data.Download) (string, error)
     }
     // metadata gid (for magnets) or real gid
     var gid string
-    if err := json.Unmarshal(res, &gid); err != nil {
-        return "", fmt.Errorf("parse addUri result: %w", err)
+    if method == "aria2.addMetalink" {
+        // addMetalink returns one GID per file, but a download tracks a
+        // single task. The service only accepts single-file metalinks; should
+        // aria2 still start several tasks, remove them all rather than leave
+        // all but one untracked.
+        var gids []string
+        if err := json.Unmarshal(res, &gids); err != nil || len(gids) == 0 {
+            return "", fmt.Errorf("parse addMetalink result: %s", res)
+        }
+        if len(gids) > 1 {
+            for _, g := range gids {
+                _, _ = a.call(ctx, "aria2.forceRemove", append(a.tokenParam(), g))
+            }
+            return "", fmt.Errorf("%w: metalink started %d tasks, want one", data.ErrInvalidPayload, len(gids))
+        }
+        gid = gids[0]
+    } else if err := json.Unmarshal(res, &gid); err != nil {
+        return "", fmt.Errorf("parse %s result: %w", method, err)
     }
 
     // Immediately ask for followedBy/bittorrent/files
@@ -172,3 +197,24 @@ func (a *Adapter) Cancel(ctx context.Context, dl *data.Download) error {
     a.mu.Unlock()
     return nil
 }
+
+// Reorder: aria2.changePosition([token?, gid, pos, "POS_SET"]) for each
+// download, so the waiting queue starts with ds in order. GIDs aria2 does not
+// hold in its waiting queue (already active, or gone) are skipped.
+func (a *Adapter) Reorder(ctx context.Context, ds data.Downloads) error {
+    pos := 0
+    for _, dl := range ds {
+        if dl.GID == "" {
+            continue
+        }
+        params := append(a.tokenParam(), dl.GID, pos, "POS_SET")
+        if _, err := a.call(ctx, "aria2.changePosition", params); err != nil {
+            if isAria2NotWaitingError(err) {
+                continue
+            }
+            return err
+        }
+        pos++
+    }
+    return nil
+}
@@ -0,0 +1,117 @@
+package aria2dl
+
+import (
+    "context"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/tinoosan/torrus/internal/data"
+    "github.com/tinoosan/torrus/internal/downloader"
+)
+
+var _ downloader.OptionChanger = (*Adapter)(nil)
+var _ downloader.GlobalLimiter = (*Adapter)(nil)
+
+// aria2Options maps download options to aria2 input options. aria2 takes
+// every value as a string, except header which is a list.
+func aria2Options(o *data.DownloadOptions) map[string]interface{} {
+    opts := map[string]interface{}{}
+    if o == nil {
+        return opts
+    }
+    if o.MaxDownloadLimit > 0 {
+        opts["max-download-limit"] = strconv.FormatInt(o.MaxDownloadLimit, 10)
+    }
+    if o.Split > 0 {
+        opts["split"] = strconv.Itoa(o.Split)
+    }
+    if o.MaxConnectionsPerServer > 0 {
+        opts["max-connection-per-server"] = strconv.Itoa(o.MaxConnectionsPerServer)
+    }
+    if o.UserAgent != "" {
+        opts["user-agent"] = o.UserAgent
+    }
+    if o.Checksum != "" {
+        opts["checksum"] = o.Checksum
+    }
+    if o.Out != "" {
+        opts["out"] = o.Out
+    }
+    if o.SeedRatio != nil {
+        opts["seed-ratio"] = strconv.FormatFloat(*o.SeedRatio, 'f', -1, 64)
+    }
+    if o.SeedTime != nil {
+        opts["seed-time"] = strconv.Itoa(*o.SeedTime)
+    }
+    var headers []string
+    for _, name := range sortedKeys(o.Headers) {
+        headers = append(headers, name+": "+o.Headers[name])
+    }
+    if len(o.Cookies) > 0 {
+        var pairs []string
+        for _, name := range sortedKeys(o.Cookies) {
+            pairs = append(pairs, name+"="+o.Cookies[name])
+        }
+        headers = append(headers, "Cookie: "+strings.Join(pairs, "; "))
+    }
+    if len(headers) > 0 {
+        opts["header"] = headers
+    }
+    return opts
+}
+
+// ChangeOptions: aria2.changeOption([token?, gid, options])
+//
+// aria2 applies max-download-limit in place; changing split or
+// max-connection-per-server makes it restart the transfer, resuming from
+// the bytes already on disk.
+func (a *Adapter) ChangeOptions(ctx context.Context, dl *data.Download, p data.DownloadOptionsPatch) error {
+    opts := map[string]string{}
+    if p.MaxDownloadLimit != nil {
+        opts["max-download-limit"] = strconv.FormatInt(*p.MaxDownloadLimit, 10)
+    }
+    if p.Split != nil {
+        opts["split"] = strconv.Itoa(*p.Split)
+    }
+    if p.MaxConnectionsPerServer != nil {
+        opts["max-connection-per-server"] = strconv.Itoa(*p.MaxConnectionsPerServer)
+    }
+    if p.SeedRatio != nil {
+        opts["seed-ratio"] = strconv.FormatFloat(*p.SeedRatio, 'f', -1, 64)
+    }
+    if p.SeedTime != nil {
+        opts["seed-time"] = strconv.Itoa(*p.SeedTime)
+    }
+    if len(opts) == 0 {
+        return nil
+    }
+    params := append(a.tokenParam(), dl.GID, opts)
+    if _, err := a.call(ctx, "aria2.changeOption", params); err != nil {
+        if isAria2UnknownGIDError(err) {
+            return downloader.ErrNotFound
+        }
+        return err
+    }
+    return nil
+}
+
+// SetGlobalLimits: aria2.changeGlobalOption([token?, options]) with
+// max-overall-download-limit and max-overall-upload-limit.
+func (a *Adapter) SetGlobalLimits(ctx context.Context, l data.BandwidthLimits) error {
+    opts := map[string]string{
+        "max-overall-download-limit": strconv.FormatInt(l.MaxDownloadLimit, 10),
+        "max-overall-upload-limit":   strconv.FormatInt(l.MaxUploadLimit, 10),
+    }
+    _, err := a.call(ctx, "aria2.changeGlobalOption", append(a.tokenParam(), opts))
+    return err
+}
+
+func sortedKeys(m map[string]string) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
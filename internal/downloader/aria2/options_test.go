@@ -0,0 +1,152 @@
+package aria2dl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/tinoosan/torrus/internal/data"
+)
+
+func TestAdapterOptions(t *testing.T) {
+	var calls []rpcReq
+	rt := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(r.Body)
+		var req rpcReq
+		if err := json.Unmarshal(b, &req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		calls = append(calls, req)
+		resp := rpcResp{Jsonrpc: "2.0", ID: "torrus", Result: json.RawMessage(`"OK"`)}
+		switch req.Method {
+		case "aria2.addUri":
+			resp.Result = json.RawMessage(`"g1"`)
+		case "aria2.tellStatus", "aria2.getFiles":
+			resp.Result = json.RawMessage(`{}`)
+		}
+		rb, _ := json.Marshal(resp)
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader(rb)), Header: make(http.Header)}, nil
+	})
+	a, _ := newTestAdapterWithEvents(t, "", rt)
+
+	ratio, seedTime := 1.5, 0
+	dl := &data.Download{ID: "1", Source: "https://example.com/f", TargetPath: "/dl", Options: &data.DownloadOptions{
+		MaxDownloadLimit:        1024,
+		Split:                   4,
+		MaxConnectionsPerServer: 2,
+		Headers:                 map[string]string{"X-B": "2", "X-A": "1"},
+		Cookies:                 map[string]string{"sid": "x", "a": "y"},
+		UserAgent:               "torrus",
+		Checksum:                "md5=d41d8cd98f00b204e9800998ecf8427e",
+		Out:                     "f.bin",
+		SeedRatio:               &ratio,
+		SeedTime:                &seedTime,
+	}}
+	if _, err := a.Start(context.Background(), dl); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	got := calls[0].Params[1].(map[string]interface{})
+	want := map[string]interface{}{
+		"dir":                       "/dl",
+		"max-download-limit":        "1024",
+		"split":                     "4",
+		"max-connection-per-server": "2",
+		"user-agent":                "torrus",
+		"checksum":                  "md5=d41d8cd98f00b204e9800998ecf8427e",
+		"out":                       "f.bin",
+		"seed-ratio":                "1.5",
+		"seed-time":                 "0",
+		"header":                    []interface{}{"X-A: 1", "X-B: 2", "Cookie: a=y; sid=x"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("addUri options = %v, want %v", got, want)
+	}
+
+	calls = nil
+	limit, conns := int64(0), 8
+	if err := a.ChangeOptions(context.Background(), &data.Download{ID: "1", GID: "g1"}, data.DownloadOptionsPatch{MaxDownloadLimit: &limit, MaxConnectionsPerServer: &conns}); err != nil {
+		t.Fatalf("ChangeOptions: %v", err)
+	}
+	if len(calls) != 1 || calls[0].Method != "aria2.changeOption" || calls[0].Params[0] != "g1" {
+		t.Fatalf("calls = %+v", calls)
+	}
+	want = map[string]interface{}{"max-download-limit": "0", "max-connection-per-server": "8"}
+	if got := calls[0].Params[1].(map[string]interface{}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("changeOption options = %v, want %v", got, want)
+	}
+
+	calls = nil
+	if err := a.SetGlobalLimits(context.Background(), data.BandwidthLimits{MaxDownloadLimit: 2048}); err != nil {
+		t.Fatalf("SetGlobalLimits: %v", err)
+	}
+	if len(calls) != 1 || calls[0].Method != "aria2.changeGlobalOption" {
+		t.Fatalf("calls = %+v", calls)
+	}
+	want = map[string]interface{}{"max-overall-download-limit": "2048", "max-overall-upload-limit": "0"}
+	if got := calls[0].Params[0].(map[string]interface{}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("changeGlobalOption options = %v, want %v", got, want)
+	}
+}
+
+func TestAdapterStartUploadedPayloads(t *testing.T) {
+	var calls []rpcReq
+	metalinkGIDs := `["m1"]`
+	rt := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(r.Body)
+		var req rpcReq
+		if err := json.Unmarshal(b, &req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		calls = append(calls, req)
+		resp := rpcResp{Jsonrpc: "2.0", ID: "torrus", Result: json.RawMessage(`{}`)}
+		switch req.Method {
+		case "aria2.addTorrent":
+			resp.Result = json.RawMessage(`"t1"`)
+		case "aria2.addMetalink":
+			resp.Result = json.RawMessage(metalinkGIDs)
+		}
+		rb, _ := json.Marshal(resp)
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader(rb)), Header: make(http.Header)}, nil
+	})
+	a, _ := newTestAdapterWithEvents(t, "", rt)
+
+	for _, tc := range []struct {
+		typ     data.PayloadType
+		method  string
+		gid     string
+		nparams int
+	}{
+		{data.PayloadTorrent, "aria2.addTorrent", "t1", 3},
+		{data.PayloadMetalink, "aria2.addMetalink", "m1", 2},
+	} {
+		calls = nil
+		gid, err := a.Start(context.Background(), &data.Download{ID: "1", Source: "x", TargetPath: "/dl", Payload: []byte("file"), PayloadType: tc.typ})
+		if err != nil {
+			t.Fatalf("%s: Start: %v", tc.typ, err)
+		}
+		if gid != tc.gid || calls[0].Method != tc.method || len(calls[0].Params) != tc.nparams || calls[0].Params[0] != "ZmlsZQ==" {
+			t.Fatalf("%s: gid %s, call %+v", tc.typ, gid, calls[0])
+		}
+		a.untrack(gid)
+	}
+
+	// A metalink that starts several tasks is removed, not partly tracked.
+	calls, metalinkGIDs = nil, `["m1","m2"]`
+	if _, err := a.Start(context.Background(), &data.Download{ID: "1", Source: "x", Payload: []byte("file"), PayloadType: data.PayloadMetalink}); !errors.Is(err, data.ErrInvalidPayload) {
+		t.Fatalf("multi-file metalink: err = %v, want ErrInvalidPayload", err)
+	}
+	var removed []any
+	for _, c := range calls[1:] {
+		if c.Method == "aria2.forceRemove" {
+			removed = append(removed, c.Params[0])
+		}
+	}
+	if len(removed) != 2 || removed[0] != "m1" || removed[1] != "m2" {
+		t.Fatalf("removed %v, want m1 and m2", removed)
+	}
+}
@@ -0,0 +1,104 @@
+package aria2dl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/aria2"
+	"github.com/tinoosan/torrus/internal/downloader"
+	"nhooyr.io/websocket"
+)
+
+func TestRunReconnectsAndCatchesUp(t *testing.T) {
+	var conns, stoppedCalls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "" {
+			c, err := websocket.Accept(w, r, &websocket.AcceptOptions{Subprotocols: []string{"jsonrpc"}})
+			if err != nil {
+				return
+			}
+			if conns.Add(1) == 1 {
+				// Drop the first connection to force a reconnect.
+				_ = c.Close(websocket.StatusGoingAway, "restart")
+				return
+			}
+			_, _, _ = c.Read(r.Context())
+			return
+		}
+		var req rpcReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+			return
+		}
+		resp := rpcResp{Jsonrpc: "2.0", ID: "torrus"}
+		switch req.Method {
+		case "aria2.tellStopped":
+			// The task finishes while notifications are down.
+			if stoppedCalls.Add(1) == 1 {
+				resp.Result = json.RawMessage(`[]`)
+			} else {
+				resp.Result = json.RawMessage(`[{"gid":"g1","status":"complete","totalLength":"10","completedLength":"10"}]`)
+			}
+		case "aria2.tellActive":
+			resp.Result = json.RawMessage(`[]`)
+		case "aria2.tellStatus":
+			resp.Result = json.RawMessage(`{"gid":"g1","status":"active","totalLength":"10","completedLength":"5"}`)
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	t.Setenv("ARIA2_RPC_URL", srv.URL+"/jsonrpc")
+	t.Setenv("ARIA2_SECRET", "")
+	c, err := aria2.NewClientFromEnv()
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	events := make(chan downloader.Event, 16)
+	a := NewAdapter(c, downloader.NewChanReporter(events))
+	a.reconnectMin, a.reconnectMax = time.Millisecond, 5*time.Millisecond
+	a.track("g1", "dl1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { a.Run(ctx); close(done) }()
+	defer func() { cancel(); <-done }()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.Type != downloader.EventComplete {
+				continue
+			}
+			if e.ID != "dl1" || e.GID != "g1" {
+				t.Fatalf("unexpected complete event %+v", e)
+			}
+			if n := conns.Load(); n < 2 {
+				t.Fatalf("expected a reconnect, got %d connections", n)
+			}
+			if !a.NotificationsConnected() || !a.NotificationsEstablished() {
+				t.Fatalf("expected notifications to be connected")
+			}
+			return
+		case <-timeout:
+			t.Fatalf("no Complete event after reconnect")
+		}
+	}
+}
+
+func TestJitterBounds(t *testing.T) {
+	d := 100 * time.Millisecond
+	for i := 0; i < 1000; i++ {
+		if j := jitter(d); j < d/2 || j > d {
+			t.Fatalf("jitter(%v) = %v outside [%v, %v]", d, j, d/2, d)
+		}
+	}
+}
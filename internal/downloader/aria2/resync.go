@@ -0,0 +1,249 @@
+package aria2dl
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "strconv"
+
+    "github.com/tinoosan/torrus/internal/data"
+    "github.com/tinoosan/torrus/internal/downloader"
+    "github.com/tinoosan/torrus/internal/metrics"
+)
+
+var _ downloader.Resyncer = (*Adapter)(nil)
+
+// maxWaitingScan bounds how many waiting tasks Resync inspects for orphans.
+const maxWaitingScan = 1000
+
+// maxStoppedScan bounds how many stopped tasks the post-reconnect catch-up
+// inspects.
+const maxStoppedScan = 1000
+
+// taskStatus is the subset of aria2.tellStatus fields Resync relies on.
+type taskStatus struct {
+    GID             string   `json:"gid"`
+    Status          string   `json:"status"`
+    TotalLength     string   `json:"totalLength"`
+    CompletedLength string   `json:"completedLength"`
+    DownloadSpeed   string   `json:"downloadSpeed"`
+    UploadLength    string   `json:"uploadLength"`
+    UploadSpeed     string   `json:"uploadSpeed"`
+    FollowedBy      []string `json:"followedBy"`
+    // Seeder is "true" while a finished BitTorrent task is seeding.
+    Seeder string `json:"seeder"`
+    // ErrorCode is aria2's exit status for a task that stopped with an error.
+    ErrorCode    string `json:"errorCode"`
+    ErrorMessage string `json:"errorMessage"`
+}
+
+var taskStatusKeys = []string{"gid", "status", "totalLength", "completedLength", "downloadSpeed", "uploadLength", "uploadSpeed", "followedBy", "seeder", "errorCode", "errorMessage"}
+
+// errorCode returns ErrorCode as a number, or 0 when aria2 did not report one.
+func (st *taskStatus) errorCode() int {
+    n, _ := strconv.Atoi(st.ErrorCode)
+    return n
+}
+
+// Resync re-attaches to the aria2 tasks of ds after a restart (or as a
+// periodic safety net). For each download it queries aria2.tellStatus,
+// rebuilds gidToID/activeGIDs and reports the events that aria2 would have
+// notified while Torrus was not listening. It then lists active and waiting
+// tasks and returns the GIDs that no download refers to.
+func (a *Adapter) Resync(ctx context.Context, ds data.Downloads) ([]string, error) {
+    known := make(map[string]bool, len(ds))
+    for _, d := range ds {
+        if d.GID == "" {
+            continue
+        }
+        if err := ctx.Err(); err != nil {
+            return nil, err
+        }
+        a.resyncOne(ctx, d, known)
+    }
+
+    live, err := a.liveGIDs(ctx)
+    if err != nil {
+        return nil, err
+    }
+    var orphans []string
+    a.mu.RLock()
+    for _, gid := range live {
+        if _, tracked := a.gidToID[gid]; !known[gid] && !tracked {
+            orphans = append(orphans, gid)
+        }
+    }
+    a.mu.RUnlock()
+    return orphans, nil
+}
+
+// resyncOne reconciles a single download with its aria2 task, following
+// metadata GIDs to the task they spawned.
+func (a *Adapter) resyncOne(ctx context.Context, d *data.Download, known map[string]bool) {
+    lg := a.log.With("id", d.ID, "gid", d.GID)
+    gid := d.GID
+    for {
+        st, err := a.taskStatus(ctx, gid)
+        if err != nil {
+            if isAria2UnknownGIDError(err) {
+                // aria2 lost the task (e.g. restarted without a session file).
+                lg.Warn("resync: task missing from aria2")
+                a.untrack(gid)
+                a.emitFailed(d.ID, gid, 0, "task missing from aria2")
+                return
+            }
+            // Keep listening for notifications; the next resync retries.
+            lg.Warn("resync: tellStatus failed", "err", err)
+            a.track(gid, d.ID)
+            known[gid] = true
+            return
+        }
+        known[gid] = true
+
+        switch st.Status {
+        case "active", "waiting":
+            a.track(gid, d.ID)
+            seeding := st.Status == "active" && st.Seeder == "true"
+            if st.Status == "active" && !seeding && d.Status == data.StatusQueued && a.rep != nil {
+                a.rep.Report(downloader.Event{ID: d.ID, GID: gid, Type: downloader.EventStart})
+            }
+            a.emitProgress(d.ID, gid, st.progress())
+            if seeding && d.Status != data.StatusSeeding {
+                a.emitSeeding(d.ID, gid)
+            }
+        case "paused":
+            a.track(gid, d.ID)
+            if (d.Status == data.StatusActive || d.Status == data.StatusSeeding) && a.rep != nil {
+                a.rep.Report(downloader.Event{ID: d.ID, GID: gid, Type: downloader.EventPaused})
+            }
+        case "complete", "error", "removed":
+            if next := a.settle(d.ID, st); next != "" {
+                // Metadata finished while we were away: move to the real task.
+                gid = next
+                continue
+            }
+        default:
+            lg.Warn("resync: unknown aria2 status", "status", st.Status)
+            a.track(gid, d.ID)
+        }
+        return
+    }
+}
+
+// settle untracks a stopped task and reports the terminal event aria2 would
+// have notified. A completed metadata task instead reports a GID update and
+// returns the GID of the task it spawned for the caller to follow.
+func (a *Adapter) settle(id string, st *taskStatus) string {
+    a.untrack(st.GID)
+    switch st.Status {
+    case "complete":
+        if len(st.FollowedBy) > 0 && st.FollowedBy[0] != "" {
+            next := st.FollowedBy[0]
+            if a.rep != nil {
+                a.rep.Report(downloader.Event{ID: id, GID: st.GID, Type: downloader.EventGIDUpdate, NewGID: next})
+            }
+            return next
+        }
+        a.emitProgress(id, st.GID, st.progress())
+        a.emitComplete(id, st.GID)
+    case "error":
+        a.emitFailed(id, st.GID, st.errorCode(), st.ErrorMessage)
+    case "removed":
+        if a.rep != nil {
+            a.rep.Report(downloader.Event{ID: id, GID: st.GID, Type: downloader.EventCancelled})
+        }
+    }
+    return ""
+}
+
+// taskStatus: aria2.tellStatus([token?, gid, keys])
+func (a *Adapter) taskStatus(ctx context.Context, gid string) (*taskStatus, error) {
+    if a.cl == nil {
+        return nil, fmt.Errorf("aria2 client not initialized")
+    }
+    params := append(a.tokenParam(), gid, taskStatusKeys)
+    res, err := a.call(ctx, "aria2.tellStatus", params)
+    if err != nil {
+        return nil, err
+    }
+    var st taskStatus
+    if err := json.Unmarshal(res, &st); err != nil {
+        return nil, fmt.Errorf("parse tellStatus: %w", err)
+    }
+    return &st, nil
+}
+
+// tellTasks calls one of aria2's listing methods (tellActive, tellWaiting,
+// tellStopped) with an optional offset/num window.
+func (a *Adapter) tellTasks(ctx context.Context, method string, window ...int) ([]taskStatus, error) {
+    params := a.tokenParam()
+    for _, w := range window {
+        params = append(params, w)
+    }
+    params = append(params, taskStatusKeys)
+    res, err := a.call(ctx, method, params)
+    if err != nil {
+        return nil, err
+    }
+    var tasks []taskStatus
+    if err := json.Unmarshal(res, &tasks); err != nil {
+        return nil, fmt.Errorf("parse %s: %w", method, err)
+    }
+    return tasks, nil
+}
+
+// liveGIDs lists the GIDs of active and waiting (including paused) tasks.
+func (a *Adapter) liveGIDs(ctx context.Context) ([]string, error) {
+    active, err := a.tellTasks(ctx, "aria2.tellActive")
+    if err != nil {
+        return nil, err
+    }
+    waiting, err := a.tellTasks(ctx, "aria2.tellWaiting", 0, maxWaitingScan)
+    if err != nil {
+        return nil, err
+    }
+    var out []string
+    for _, t := range append(active, waiting...) {
+        out = append(out, t.GID)
+    }
+    return out, nil
+}
+
+// track records gid as belonging to download id so notifications and
+// progress polling apply to it.
+func (a *Adapter) track(gid, id string) {
+    a.mu.Lock()
+    a.gidToID[gid] = id
+    a.activeGIDs[gid] = struct{}{}
+    metrics.ActiveDownloads.Set(float64(len(a.activeGIDs)))
+    a.mu.Unlock()
+}
+
+// untrack forgets gid.
+func (a *Adapter) untrack(gid string) {
+    a.mu.Lock()
+    delete(a.gidToID, gid)
+    delete(a.activeGIDs, gid)
+    delete(a.lastProg, gid)
+    metrics.ActiveDownloads.Set(float64(len(a.activeGIDs)))
+    a.mu.Unlock()
+}
+
+func (st *taskStatus) progress() downloader.Progress {
+    return downloader.Progress{
+        Completed:   parseDecimal(st.CompletedLength),
+        Total:       parseDecimal(st.TotalLength),
+        Speed:       parseDecimal(st.DownloadSpeed),
+        Uploaded:    parseDecimal(st.UploadLength),
+        UploadSpeed: parseDecimal(st.UploadSpeed),
+    }
+}
+
+// parseDecimal parses aria2's decimal-string numbers, treating junk as 0.
+func parseDecimal(s string) int64 {
+    v, err := strconv.ParseInt(s, 10, 64)
+    if err != nil {
+        return 0
+    }
+    return v
+}
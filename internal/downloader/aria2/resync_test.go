@@ -0,0 +1,102 @@
+package aria2dl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/tinoosan/torrus/internal/aria2"
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/downloader"
+)
+
+func TestAdapterResync(t *testing.T) {
+	statuses := map[string]string{
+		"a1": `{"gid":"a1","status":"active","totalLength":"100","completedLength":"40","downloadSpeed":"10"}`,
+		"p1": `{"gid":"p1","status":"paused"}`,
+		"c1": `{"gid":"c1","status":"complete","totalLength":"100","completedLength":"100"}`,
+		"m1": `{"gid":"m1","status":"complete","followedBy":["r1"]}`,
+		"r1": `{"gid":"r1","status":"active"}`,
+	}
+	rt := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(r.Body)
+		var req rpcReq
+		if err := json.Unmarshal(b, &req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		resp := rpcResp{Jsonrpc: "2.0", ID: "torrus"}
+		switch req.Method {
+		case "aria2.tellStatus":
+			if st, ok := statuses[req.Params[0].(string)]; ok {
+				resp.Result = json.RawMessage(st)
+			} else {
+				resp.Error = &rpcError{Code: 1, Message: "GID " + req.Params[0].(string) + " is not found"}
+			}
+		case "aria2.tellActive":
+			resp.Result = json.RawMessage(`[{"gid":"a1"},{"gid":"r1"},{"gid":"stray"}]`)
+		case "aria2.tellWaiting":
+			resp.Result = json.RawMessage(`[{"gid":"p1"}]`)
+		default:
+			t.Fatalf("unexpected method %s", req.Method)
+		}
+		rb, _ := json.Marshal(resp)
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader(rb)), Header: make(http.Header)}, nil
+	})
+	t.Setenv("ARIA2_RPC_URL", "http://example.com/jsonrpc")
+	t.Setenv("ARIA2_SECRET", "")
+	c, err := aria2.NewClientFromEnv()
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	c.HTTP().Transport = rt
+	events := make(chan downloader.Event, 32)
+	a := NewAdapter(c, downloader.NewChanReporter(events))
+
+	ds := data.Downloads{
+		{ID: "active", GID: "a1", Status: data.StatusQueued},
+		{ID: "paused", GID: "p1", Status: data.StatusActive},
+		{ID: "done", GID: "c1", Status: data.StatusActive},
+		{ID: "lost", GID: "gone", Status: data.StatusPaused},
+		{ID: "magnet", GID: "m1", Status: data.StatusActive},
+	}
+	orphans, err := a.Resync(context.Background(), ds)
+	if err != nil {
+		t.Fatalf("Resync: %v", err)
+	}
+	if len(orphans) != 1 || orphans[0] != "stray" {
+		t.Fatalf("orphans = %v", orphans)
+	}
+	close(events)
+	var got []string
+	for e := range events {
+		got = append(got, e.ID+":"+string(e.Type))
+	}
+	want := []string{
+		"active:Start", "active:Progress",
+		"paused:Paused",
+		"done:Progress", "done:Complete",
+		"lost:Failed",
+		"magnet:GIDUpdate", "magnet:Progress",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("events = %v, want %v", got, want)
+		}
+	}
+	for gid, id := range map[string]string{"a1": "active", "p1": "paused", "r1": "magnet"} {
+		if a.gidToID[gid] != id {
+			t.Fatalf("gidToID[%s] = %q, want %q", gid, a.gidToID[gid], id)
+		}
+	}
+	for _, gid := range []string{"c1", "gone", "m1"} {
+		if _, ok := a.gidToID[gid]; ok {
+			t.Fatalf("gid %s still tracked", gid)
+		}
+	}
+}
@@ -97,3 +97,23 @@ func isAria2GIDNotFoundError(err error) bool {
     return strings.Contains(msg, "gid not found")
 }
 
+
+// isAria2NotWaitingError detects aria2's "GID#<gid> not found in the waiting
+// queue." reply to aria2.changePosition for a task that is active or
+// stopped, and its reply for a GID it does not know at all.
+func isAria2NotWaitingError(err error) bool {
+    if err == nil {
+        return false
+    }
+    return strings.Contains(strings.ToLower(err.Error()), "not found in the waiting queue") || isAria2UnknownGIDError(err)
+}
+
+// isAria2UnknownGIDError detects aria2's "GID <gid> is not found" reply to
+// lookups of tasks it no longer holds.
+func isAria2UnknownGIDError(err error) bool {
+    if err == nil {
+        return false
+    }
+    msg := strings.ToLower(err.Error())
+    return strings.Contains(msg, "gid") && strings.Contains(msg, "not found")
+}
@@ -0,0 +1,58 @@
+package aria2dl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/tinoosan/torrus/internal/aria2"
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/downloader"
+)
+
+// TestAdapterSeeding checks that onBtDownloadComplete reports upload
+// progress and a Seeding event while the task stays tracked, and that
+// resync recognises a seeding task.
+func TestAdapterSeeding(t *testing.T) {
+	rt := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(r.Body)
+		var req rpcReq
+		if err := json.Unmarshal(b, &req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if req.Method != "aria2.tellStatus" {
+			t.Fatalf("unexpected method %s", req.Method)
+		}
+		resp := rpcResp{Jsonrpc: "2.0", ID: "torrus", Result: json.RawMessage(`{"gid":"g1","status":"active","seeder":"true","totalLength":"100","completedLength":"100","uploadLength":"50","uploadSpeed":"10"}`)}
+		rb, _ := json.Marshal(resp)
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader(rb)), Header: make(http.Header)}, nil
+	})
+	a, events := newTestAdapterWithEvents(t, "", rt)
+	a.track("g1", "1")
+
+	a.handleNotification(context.Background(), aria2.Notification{Method: "aria2.onBtDownloadComplete", Params: []aria2.NotificationEvent{{GID: "g1"}}})
+	ev := <-events
+	if ev.Type != downloader.EventProgress || ev.Progress.Uploaded != 50 || ev.Progress.UploadSpeed != 10 {
+		t.Fatalf("unexpected progress event %#v", ev)
+	}
+	if ev = <-events; ev.Type != downloader.EventSeeding || ev.ID != "1" || ev.GID != "g1" {
+		t.Fatalf("unexpected event %#v", ev)
+	}
+	a.mu.RLock()
+	_, tracked := a.gidToID["g1"]
+	a.mu.RUnlock()
+	if !tracked {
+		t.Fatalf("seeding task no longer tracked")
+	}
+
+	a.resyncOne(context.Background(), &data.Download{ID: "1", GID: "g1", Status: data.StatusActive}, map[string]bool{})
+	if ev = <-events; ev.Type != downloader.EventProgress {
+		t.Fatalf("unexpected event %#v", ev)
+	}
+	if ev = <-events; ev.Type != downloader.EventSeeding {
+		t.Fatalf("expected Seeding from resync, got %#v", ev)
+	}
+}
@@ -27,6 +27,43 @@ type FileLister interface {
     GetFiles(ctx context.Context, gid string) ([]string, error)
 }
 
+// OptionChanger is implemented by downloaders that can change the transfer
+// options of an existing backend task. ChangeOptions applies the fields set
+// in p to the task of d, which must have a GID.
+type OptionChanger interface {
+    ChangeOptions(ctx context.Context, d *data.Download, p data.DownloadOptionsPatch) error
+}
+
+// GlobalLimiter is implemented by downloaders with backend-wide transfer
+// caps. SetGlobalLimits replaces them; a zero limit means unlimited.
+type GlobalLimiter interface {
+    SetGlobalLimits(ctx context.Context, l data.BandwidthLimits) error
+}
+
+// FileSelector is implemented by downloaders that can restrict a multi-file
+// task to some of its files. SelectFiles makes the task of d, which must
+// have a GID, fetch only the files with the given 1-based indexes.
+type FileSelector interface {
+    SelectFiles(ctx context.Context, d *data.Download, indexes []int) error
+}
+
+// Reorderer is implemented by downloaders that keep their own waiting queue.
+// Reorder moves the backend tasks of ds, in the given order, to the front of
+// that queue so the backend admits them in the same order Torrus does.
+// Downloads without a GID are skipped.
+type Reorderer interface {
+    Reorder(ctx context.Context, ds data.Downloads) error
+}
+
+// Resyncer is implemented by downloaders that track backend tasks in memory
+// and must re-attach to them after a restart. Resync takes the non-terminal
+// downloads that have a GID, rebuilds tracking for their tasks, reports the
+// events for state changes the backend made while untracked, and returns the
+// GIDs of live backend tasks that none of ds refers to (orphans).
+type Resyncer interface {
+    Resync(ctx context.Context, ds data.Downloads) (orphans []string, err error)
+}
+
 // EventSource is implemented by downloaders that emit asynchronous events.
 // Reconciler wiring can launch Run(ctx) when available to process notifications.
 type EventSource interface {
@@ -19,6 +19,9 @@ type Event struct {
 	Meta     *Meta
 	// NewGID is used with EventGIDUpdate to signal a GID swap.
 	NewGID string
+	// Failure describes why the download failed. It is set on EventFailed
+	// when the backend reports a reason; the reconciler stamps the time.
+	Failure *data.Failure
 }
 
 // EventType defines the set of events that downloaders may emit.
@@ -29,6 +32,7 @@ const (
 	EventPaused    EventType = "Paused"
 	EventCancelled EventType = "Cancelled"
 	EventComplete  EventType = "Complete"
+	EventSeeding   EventType = "Seeding" // torrent downloaded, now seeding; Complete follows
 	EventFailed    EventType = "Failed"
 	EventProgress  EventType = "Progress"
 	EventMeta      EventType = "Meta"
@@ -45,6 +49,9 @@ type Progress struct {
 	// Speed is the current download speed in bytes/sec, if available.
 	// A value of 0 indicates it was not provided by the adapter.
 	Speed int64
+	// Uploaded and UploadSpeed report BitTorrent upload totals, if available.
+	Uploaded    int64
+	UploadSpeed int64
 }
 
 // Meta carries optional metadata about a download that should be persisted
@@ -0,0 +1,22 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+)
+
+// StartFailure builds the failure record of a Start or Resume call that
+// returned err. Errors the backend did not classify are FailureBackend.
+func StartFailure(source data.FailureSource, err error, at time.Time) *data.Failure {
+	code := data.FailureBackend
+	switch {
+	case errors.Is(err, data.ErrConflict):
+		code = data.FailureFileExists
+	case errors.Is(err, context.DeadlineExceeded):
+		code = data.FailureTimeout
+	}
+	return &data.Failure{Code: code, Message: err.Error(), Source: source, Timestamp: at}
+}
@@ -0,0 +1,173 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/downloader"
+	"github.com/tinoosan/torrus/internal/metrics"
+)
+
+// DefaultHistory is the number of recent events retained for replay when a
+// subscriber resumes with a Last-Event-ID.
+const DefaultHistory = 1024
+
+// subscriberBuffer bounds how far a subscriber may fall behind before it is
+// dropped. Dropped subscribers can reconnect and replay from history.
+const subscriberBuffer = 64
+
+// Envelope wraps a downloader event with a monotonically increasing sequence
+// number and the time it was published.
+type Envelope struct {
+	Seq   uint64
+	Time  time.Time
+	Event downloader.Event
+}
+
+// Filter restricts which events a subscriber receives. An empty filter
+// matches every event.
+type Filter struct {
+	// IDs limits delivery to events for the given download IDs.
+	IDs map[string]struct{}
+}
+
+// Match reports whether the event passes the filter.
+func (f Filter) Match(e downloader.Event) bool {
+	if len(f.IDs) == 0 {
+		return true
+	}
+	_, ok := f.IDs[e.ID]
+	return ok
+}
+
+// Subscription is a live feed of events from a Hub. C is closed when the
+// subscription is cancelled or the subscriber falls too far behind.
+type Subscription struct {
+	C <-chan Envelope
+
+	ch     chan Envelope
+	filter Filter
+	hub    *Hub
+	closed bool
+}
+
+// Close detaches the subscription from its hub. It is safe to call more
+// than once.
+func (s *Subscription) Close() {
+	if s == nil || s.hub == nil {
+		return
+	}
+	s.hub.remove(s)
+}
+
+// Hub fans out downloader events to any number of subscribers. Publish never
+// blocks: a subscriber whose buffer is full is dropped instead of stalling
+// the publisher.
+type Hub struct {
+	mu      sync.Mutex
+	seq     uint64
+	history []Envelope
+	next    int
+	full    bool
+	subs    map[*Subscription]struct{}
+	now     func() time.Time
+}
+
+// NewHub returns a Hub that retains up to history events for replay. A
+// non-positive history uses DefaultHistory.
+func NewHub(history int) *Hub {
+	if history <= 0 {
+		history = DefaultHistory
+	}
+	return &Hub{
+		history: make([]Envelope, history),
+		subs:    make(map[*Subscription]struct{}),
+		now:     time.Now,
+	}
+}
+
+// Publish assigns the event a sequence number, stores it in the replay
+// history and delivers it to matching subscribers.
+func (h *Hub) Publish(e downloader.Event) Envelope {
+	if h == nil {
+		return Envelope{Event: e}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	env := Envelope{Seq: h.seq, Time: h.now(), Event: e}
+	h.history[h.next] = env
+	h.next = (h.next + 1) % len(h.history)
+	if h.next == 0 {
+		h.full = true
+	}
+	for s := range h.subs {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case s.ch <- env:
+		default:
+			// Slow subscriber: drop it so the publisher is never blocked.
+			h.closeLocked(s)
+		}
+	}
+	return env
+}
+
+// Subscribe registers a new subscriber. Events retained in history with a
+// sequence number greater than after are returned for replay; pass 0 to skip
+// replay. The replay slice and the live channel never overlap.
+func (h *Hub) Subscribe(f Filter, after uint64) (*Subscription, []Envelope) {
+	ch := make(chan Envelope, subscriberBuffer)
+	s := &Subscription{C: ch, ch: ch, filter: f, hub: h}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var replay []Envelope
+	if after > 0 && after < h.seq {
+		for _, env := range h.snapshotLocked() {
+			if env.Seq > after && f.Match(env.Event) {
+				replay = append(replay, env)
+			}
+		}
+	}
+	h.subs[s] = struct{}{}
+	metrics.EventSubscribers.Set(float64(len(h.subs)))
+	return s, replay
+}
+
+// Close disconnects all subscribers.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		h.closeLocked(s)
+	}
+}
+
+func (h *Hub) remove(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closeLocked(s)
+}
+
+func (h *Hub) closeLocked(s *Subscription) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(h.subs, s)
+	close(s.ch)
+	metrics.EventSubscribers.Set(float64(len(h.subs)))
+}
+
+// snapshotLocked returns retained events ordered oldest first.
+func (h *Hub) snapshotLocked() []Envelope {
+	if !h.full {
+		return append([]Envelope(nil), h.history[:h.next]...)
+	}
+	out := make([]Envelope, 0, len(h.history))
+	out = append(out, h.history[h.next:]...)
+	out = append(out, h.history[:h.next]...)
+	return out
+}
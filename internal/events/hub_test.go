@@ -0,0 +1,76 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/tinoosan/torrus/internal/downloader"
+)
+
+func TestHubFanOutAndFilter(t *testing.T) {
+	h := NewHub(8)
+	all, _ := h.Subscribe(Filter{}, 0)
+	defer all.Close()
+	only, _ := h.Subscribe(Filter{IDs: map[string]struct{}{"b": {}}}, 0)
+	defer only.Close()
+
+	h.Publish(downloader.Event{ID: "a", Type: downloader.EventStart})
+	h.Publish(downloader.Event{ID: "b", Type: downloader.EventProgress})
+
+	if got := <-all.C; got.Seq != 1 || got.Event.ID != "a" {
+		t.Fatalf("unexpected first event: %#v", got)
+	}
+	if got := <-all.C; got.Seq != 2 || got.Event.ID != "b" {
+		t.Fatalf("unexpected second event: %#v", got)
+	}
+	if got := <-only.C; got.Event.ID != "b" {
+		t.Fatalf("filter leaked event: %#v", got)
+	}
+	select {
+	case got := <-only.C:
+		t.Fatalf("unexpected extra event: %#v", got)
+	default:
+	}
+}
+
+func TestHubReplayAfterSeq(t *testing.T) {
+	h := NewHub(3)
+	for _, id := range []string{"a", "b", "c", "d"} {
+		h.Publish(downloader.Event{ID: id, Type: downloader.EventProgress})
+	}
+	sub, replay := h.Subscribe(Filter{}, 2)
+	defer sub.Close()
+	if len(replay) != 2 || replay[0].Seq != 3 || replay[1].Seq != 4 {
+		t.Fatalf("unexpected replay: %#v", replay)
+	}
+
+	// Resuming from before the retained window replays what is still held.
+	sub2, replay2 := h.Subscribe(Filter{}, 1)
+	defer sub2.Close()
+	if len(replay2) != 3 || replay2[0].Event.ID != "b" {
+		t.Fatalf("unexpected replay from evicted seq: %#v", replay2)
+	}
+
+	// Up-to-date or unknown IDs replay nothing.
+	sub3, replay3 := h.Subscribe(Filter{}, 4)
+	defer sub3.Close()
+	if len(replay3) != 0 {
+		t.Fatalf("expected no replay, got %#v", replay3)
+	}
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	h := NewHub(4)
+	sub, _ := h.Subscribe(Filter{}, 0)
+	for i := 0; i < subscriberBuffer+1; i++ {
+		h.Publish(downloader.Event{ID: "a", Type: downloader.EventProgress})
+	}
+	n := 0
+	for range sub.C {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Fatalf("expected %d buffered events before drop, got %d", subscriberBuffer, n)
+	}
+	// Closing an already dropped subscription is a no-op.
+	sub.Close()
+}
@@ -2,15 +2,42 @@ package fp
 
 import (
     "crypto/sha256"
+    "encoding/base32"
     "encoding/hex"
+    "net"
+    "net/url"
     "path/filepath"
+    "sort"
     "strings"
 )
 
-// NormalizeSource trims surrounding whitespace. Further normalization rules
-// (e.g., URL normalization) can be added later as needed.
+// Version identifies the normalization rules Fingerprint applies. Stored
+// fingerprints record the version they were computed with so they can be
+// recomputed when the rules change.
+//
+//   - 1: trimmed source.
+//   - 2: magnets reduced to their btih infohash, URLs canonicalized.
+const Version = 2
+
+// NormalizeSource reduces a source to a canonical form so that equivalent
+// links fingerprint identically:
+//   - magnet links carrying a BitTorrent v1 infohash (hex or base32) become
+//     "btih:<lowercase hex>", ignoring trackers, display name and other params;
+//   - http, https and ftp URLs get a lowercase scheme and host, no default
+//     port, no fragment and query parameters sorted by key;
+//   - anything else is only trimmed.
 func NormalizeSource(s string) string {
-    return strings.TrimSpace(s)
+    s = strings.TrimSpace(s)
+    if len(s) > 7 && strings.EqualFold(s[:7], "magnet:") {
+        if ih := magnetInfoHash(s); ih != "" {
+            return "btih:" + ih
+        }
+        return s
+    }
+    if u, ok := canonicalURL(s); ok {
+        return u
+    }
+    return s
 }
 
 // NormalizeTargetPath trims whitespace and cleans the path using filepath.Clean.
@@ -38,3 +65,73 @@ func Fingerprint(source, targetPath string) string {
     return hex.EncodeToString(sum)
 }
 
+// magnetInfoHash returns the lowercase hex btih infohash of a magnet link,
+// or "" when it has none.
+func magnetInfoHash(magnet string) string {
+    q, err := url.ParseQuery(strings.TrimPrefix(magnet[7:], "?"))
+    if err != nil {
+        return ""
+    }
+    for _, xt := range q["xt"] {
+        if len(xt) < 9 || !strings.EqualFold(xt[:9], "urn:btih:") {
+            continue
+        }
+        ih := xt[9:]
+        switch len(ih) {
+        case 40:
+            if _, err := hex.DecodeString(ih); err == nil {
+                return strings.ToLower(ih)
+            }
+        case 32:
+            if b, err := base32.StdEncoding.DecodeString(strings.ToUpper(ih)); err == nil {
+                return hex.EncodeToString(b)
+            }
+        }
+    }
+    return ""
+}
+
+var defaultPorts = map[string]string{"http": "80", "https": "443", "ftp": "21"}
+
+// canonicalURL canonicalizes http, https and ftp URLs.
+func canonicalURL(s string) (string, bool) {
+    u, err := url.Parse(s)
+    if err != nil || u.Host == "" || u.Opaque != "" {
+        return "", false
+    }
+    u.Scheme = strings.ToLower(u.Scheme)
+    def, ok := defaultPorts[u.Scheme]
+    if !ok {
+        return "", false
+    }
+    host, port := u.Hostname(), u.Port()
+    host = strings.ToLower(host)
+    if strings.Contains(host, ":") {
+        host = "[" + host + "]"
+    }
+    if port != "" && port != def {
+        host = net.JoinHostPort(strings.Trim(host, "[]"), port)
+    }
+    u.Host = host
+    u.Fragment, u.RawFragment = "", ""
+    if u.Path == "" {
+        u.Path = "/"
+    }
+    if u.RawQuery != "" {
+        u.RawQuery = sortQuery(u.RawQuery)
+    }
+    return u.String(), true
+}
+
+// sortQuery orders query parameters by key, keeping the relative order of
+// repeated keys. Parameters are compared in their raw (escaped) form so
+// that no encoding is changed.
+func sortQuery(raw string) string {
+    params := strings.Split(raw, "&")
+    sort.SliceStable(params, func(i, j int) bool {
+        ki, _, _ := strings.Cut(params[i], "=")
+        kj, _, _ := strings.Cut(params[j], "=")
+        return ki < kj
+    })
+    return strings.Join(params, "&")
+}
@@ -24,3 +24,35 @@ func TestNormalizeAndFingerprint(t *testing.T) {
     }
 }
 
+
+func TestNormalizeSourceByType(t *testing.T) {
+    const ih = "c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+    cases := []struct{ in, want string }{
+        // Magnets collapse to their infohash, hex or base32.
+        {"magnet:?xt=urn:btih:" + ih, "btih:" + ih},
+        {"magnet:?xt=urn:btih:C12FE1C06BBA254A9DC9F519B335AA7C1367A88A&dn=x&tr=udp%3A%2F%2Ft", "btih:" + ih},
+        {"MAGNET:?dn=y&xt=urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK", "btih:" + ih},
+        // Magnets without a usable infohash are kept as-is.
+        {"magnet:?xt=urn:btih:abc", "magnet:?xt=urn:btih:abc"},
+        // URLs are canonicalized.
+        {"HTTP://Example.COM:80/a?b=2&a=1#frag", "http://example.com/a?a=1&b=2"},
+        {"https://example.com:443", "https://example.com/"},
+        {"https://example.com:8443/x?z=1&a=2&z=0", "https://example.com:8443/x?a=2&z=1&z=0"},
+        {"ftp://Files.example.com:21/pub/f.iso", "ftp://files.example.com/pub/f.iso"},
+        {"http://[::1]:80/x", "http://[::1]/x"},
+        {"http://[::1]:8080/x", "http://[::1]:8080/x"},
+        // Other schemes are only trimmed.
+        {" s3://Bucket/Key ", "s3://Bucket/Key"},
+    }
+    for _, c := range cases {
+        if got := NormalizeSource(c.in); got != c.want {
+            t.Errorf("NormalizeSource(%q) = %q, want %q", c.in, got, c.want)
+        }
+    }
+
+    a := Fingerprint("magnet:?xt=urn:btih:"+ih+"&tr=udp://a", "/dl")
+    b := Fingerprint("magnet:?xt=urn:btih:"+ih+"&dn=name&tr=udp://b", "/dl/")
+    if a != b {
+        t.Fatalf("magnets for the same infohash fingerprint differently")
+    }
+}
@@ -0,0 +1,156 @@
+package fp
+
+import (
+    "crypto/sha1"
+    "crypto/sha256"
+    "bytes"
+    "encoding/hex"
+    "encoding/xml"
+    "errors"
+    "io"
+    "strconv"
+)
+
+// ErrInvalidTorrent is returned when a .torrent payload is not a bencoded
+// dictionary with an "info" dictionary.
+var ErrInvalidTorrent = errors.New("invalid torrent file")
+
+// ErrInvalidMetalink is returned when a metalink payload is not well-formed
+// XML.
+var ErrInvalidMetalink = errors.New("invalid metalink file")
+
+// maxBencodeDepth bounds nesting so hostile payloads cannot exhaust the stack.
+const maxBencodeDepth = 64
+
+// InfoHash returns the hex-encoded BitTorrent v1 infohash of a .torrent
+// file: the SHA-1 of the bencoded "info" dictionary exactly as it appears
+// in the file.
+func InfoHash(torrent []byte) (string, error) {
+    if len(torrent) == 0 || torrent[0] != 'd' {
+        return "", ErrInvalidTorrent
+    }
+    i := 1
+    for i < len(torrent) && torrent[i] != 'e' {
+        key, next, err := bencodeString(torrent, i)
+        if err != nil {
+            return "", err
+        }
+        end, err := skipBencode(torrent, next, 0)
+        if err != nil {
+            return "", err
+        }
+        if key == "info" {
+            if torrent[next] != 'd' {
+                return "", ErrInvalidTorrent
+            }
+            sum := sha1.Sum(torrent[next:end])
+            return hex.EncodeToString(sum[:]), nil
+        }
+        i = end
+    }
+    return "", ErrInvalidTorrent
+}
+
+// TorrentSource returns the canonical source recorded for an uploaded
+// .torrent file: a magnet URI carrying only its infohash. Fingerprinting
+// that source makes uploads of the same torrent idempotent.
+func TorrentSource(torrent []byte) (string, error) {
+    ih, err := InfoHash(torrent)
+    if err != nil {
+        return "", err
+    }
+    return "magnet:?xt=urn:btih:" + ih, nil
+}
+
+// MetalinkSource returns the canonical source recorded for an uploaded
+// metalink document, derived from the SHA-256 of its content.
+func MetalinkSource(metalink []byte) string {
+    sum := sha256.Sum256(metalink)
+    return "metalink:sha256:" + hex.EncodeToString(sum[:])
+}
+
+// MetalinkFiles returns the number of files a metalink document describes,
+// counting the file elements of both Metalink 3 and Metalink 4 (RFC 5854).
+func MetalinkFiles(metalink []byte) (int, error) {
+    dec := xml.NewDecoder(bytes.NewReader(metalink))
+    n, root := 0, false
+    for {
+        tok, err := dec.Token()
+        if errors.Is(err, io.EOF) {
+            break
+        }
+        if err != nil {
+            return 0, ErrInvalidMetalink
+        }
+        if se, ok := tok.(xml.StartElement); ok {
+            switch {
+            case !root:
+                if se.Name.Local != "metalink" {
+                    return 0, ErrInvalidMetalink
+                }
+                root = true
+            case se.Name.Local == "file":
+                n++
+            }
+        }
+    }
+    if !root {
+        return 0, ErrInvalidMetalink
+    }
+    return n, nil
+}
+
+// bencodeString parses the byte string starting at b[i] and returns it with
+// the index just past it.
+func bencodeString(b []byte, i int) (string, int, error) {
+    colon := i
+    for colon < len(b) && b[colon] >= '0' && b[colon] <= '9' {
+        colon++
+    }
+    if colon == i || colon >= len(b) || b[colon] != ':' {
+        return "", 0, ErrInvalidTorrent
+    }
+    n, err := strconv.Atoi(string(b[i:colon]))
+    if err != nil || n > len(b)-colon-1 {
+        return "", 0, ErrInvalidTorrent
+    }
+    start := colon + 1
+    return string(b[start : start+n]), start + n, nil
+}
+
+// skipBencode returns the index just past the bencoded value at b[i].
+func skipBencode(b []byte, i, depth int) (int, error) {
+    if i >= len(b) || depth > maxBencodeDepth {
+        return 0, ErrInvalidTorrent
+    }
+    switch c := b[i]; {
+    case c == 'i':
+        for j := i + 1; j < len(b); j++ {
+            if b[j] == 'e' {
+                return j + 1, nil
+            }
+        }
+        return 0, ErrInvalidTorrent
+    case c == 'l' || c == 'd':
+        j := i + 1
+        for j < len(b) && b[j] != 'e' {
+            var err error
+            if c == 'd' {
+                if _, j, err = bencodeString(b, j); err != nil {
+                    return 0, err
+                }
+            }
+            if j, err = skipBencode(b, j, depth+1); err != nil {
+                return 0, err
+            }
+        }
+        if j >= len(b) {
+            return 0, ErrInvalidTorrent
+        }
+        return j + 1, nil
+    case c >= '0' && c <= '9':
+        _, j, err := bencodeString(b, i)
+        return j, err
+    }
+    return 0, ErrInvalidTorrent
+}
@@ -0,0 +1,60 @@
+package fp
+
+import (
+    "crypto/sha1"
+    "encoding/hex"
+    "strings"
+    "testing"
+)
+
+func TestInfoHash(t *testing.T) {
+    info := "d6:lengthi12e4:name5:a.txt12:piece lengthi16384e6:pieces0:e"
+    torrent := []byte("d8:announce14:http://tracker13:creation datei1700000000e4:info" + info + "e")
+    sum := sha1.Sum([]byte(info))
+    want := hex.EncodeToString(sum[:])
+
+    got, err := InfoHash(torrent)
+    if err != nil {
+        t.Fatalf("InfoHash: %v", err)
+    }
+    if got != want {
+        t.Fatalf("InfoHash = %s, want %s", got, want)
+    }
+    src, _ := TorrentSource(torrent)
+    if src != "magnet:?xt=urn:btih:"+want {
+        t.Fatalf("TorrentSource = %s", src)
+    }
+
+    for _, bad := range []string{
+        "",
+        "not bencode",
+        "d8:announce3:urle",
+        "d4:infoi1ee",
+        "d4:infod4:name",
+        "d4:infod99:xe",
+        "d4:infod1:x" + strings.Repeat("l", 100) + strings.Repeat("e", 100) + "ee",
+    } {
+        if _, err := InfoHash([]byte(bad)); err != ErrInvalidTorrent {
+            t.Fatalf("InfoHash(%q) err = %v, want ErrInvalidTorrent", bad, err)
+        }
+    }
+}
+
+func TestMetalinkFiles(t *testing.T) {
+    cases := map[string]int{
+        `<?xml version="1.0"?><metalink xmlns="urn:ietf:params:xml:ns:metalink"><file name="a"><url>http://x/a</url></file></metalink>`: 1,
+        `<metalink version="3.0"><files><file name="a"/><file name="b"/></files></metalink>`:                                          2,
+        `<metalink/>`: 0,
+    }
+    for doc, want := range cases {
+        got, err := MetalinkFiles([]byte(doc))
+        if err != nil || got != want {
+            t.Errorf("MetalinkFiles(%q) = %d, %v, want %d", doc, got, err, want)
+        }
+    }
+    for _, bad := range []string{"", "not xml", "<torrent/>", "<metalink><file>"} {
+        if _, err := MetalinkFiles([]byte(bad)); err != ErrInvalidMetalink {
+            t.Errorf("MetalinkFiles(%q) err = %v, want ErrInvalidMetalink", bad, err)
+        }
+    }
+}
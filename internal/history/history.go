@@ -0,0 +1,162 @@
+// Package history records the transition history of downloads. Components
+// tag the contexts of their repository writes with a cause, and Repo turns
+// every write that changes a download's status or desired status into a
+// data.Transition.
+package history
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/repo"
+	"github.com/tinoosan/torrus/internal/reqid"
+)
+
+type causeKey struct{}
+type eventKey struct{}
+
+// WithCause returns a context whose repository writes are recorded as caused
+// by cause.
+func WithCause(ctx context.Context, cause data.TransitionCause) context.Context {
+	return context.WithValue(ctx, causeKey{}, cause)
+}
+
+// WithEvent returns a context whose repository writes are recorded as
+// applying the downloader event ev.
+func WithEvent(ctx context.Context, ev string) context.Context {
+	return context.WithValue(ctx, eventKey{}, ev)
+}
+
+// Repo wraps a download repository and appends a transition to a
+// repo.HistoryRepo after each write that creates a download or changes its
+// status or desired status. The cause, event and request ID come from the
+// write's context; writes without a cause are data.CauseSystem.
+//
+// When the wrapped repository is itself h and implements
+// repo.TransitionRecorder, each transition is written in the transaction of
+// the write that causes it. Otherwise history is written after the download
+// itself and is best effort: a failure to record it is logged and does not
+// fail the write.
+type Repo struct {
+	repo.ExtendedRepo
+	history repo.HistoryRepo
+	// tx is the wrapped repository when it records transitions itself.
+	tx  repo.TransitionRecorder
+	log *slog.Logger
+	now func() time.Time
+}
+
+// Wrap returns r recording its transitions to h.
+func Wrap(log *slog.Logger, r repo.ExtendedRepo, h repo.HistoryRepo) *Repo {
+	if log == nil {
+		log = slog.Default()
+	}
+	w := &Repo{ExtendedRepo: r, history: h, log: log, now: time.Now}
+	if tx, ok := r.(repo.TransitionRecorder); ok && any(r) == any(h) {
+		w.tx = tx
+	}
+	return w
+}
+
+// Add implements repo.DownloadWriter.
+func (r *Repo) Add(ctx context.Context, d *data.Download) (*data.Download, error) {
+	if r.tx != nil {
+		return r.tx.AddRecorded(ctx, d, r.transition(ctx))
+	}
+	saved, err := r.ExtendedRepo.Add(ctx, d)
+	if err == nil {
+		r.record(ctx, nil, saved)
+	}
+	return saved, err
+}
+
+// AddWithFingerprint implements repo.DownloadWriter.
+func (r *Repo) AddWithFingerprint(ctx context.Context, d *data.Download, fingerprint string) (*data.Download, bool, error) {
+	if r.tx != nil {
+		return r.tx.AddWithFingerprintRecorded(ctx, d, fingerprint, r.transition(ctx))
+	}
+	saved, created, err := r.ExtendedRepo.AddWithFingerprint(ctx, d, fingerprint)
+	if err == nil && created {
+		r.record(ctx, nil, saved)
+	}
+	return saved, created, err
+}
+
+// Update implements repo.DownloadWriter.
+func (r *Repo) Update(ctx context.Context, id string, mutate func(*data.Download) error) (*data.Download, error) {
+	if r.tx != nil {
+		return r.tx.UpdateRecorded(ctx, id, mutate, r.transition(ctx))
+	}
+	// mutate may run more than once; the last run is the one that sticks.
+	var before data.Download
+	updated, err := r.ExtendedRepo.Update(ctx, id, func(d *data.Download) error {
+		before.Status, before.DesiredStatus = d.Status, d.DesiredStatus
+		if mutate == nil {
+			return nil
+		}
+		return mutate(d)
+	})
+	if err == nil {
+		r.record(ctx, &before, updated)
+	}
+	return updated, err
+}
+
+// GetPayload implements repo.PayloadReader for the wrapped repository.
+func (r *Repo) GetPayload(ctx context.Context, id string) ([]byte, error) {
+	if pr, ok := r.ExtendedRepo.(repo.PayloadReader); ok {
+		return pr.GetPayload(ctx, id)
+	}
+	d, err := r.ExtendedRepo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return d.Payload, nil
+}
+
+// transition returns the repo.TransitionFunc of writes made with ctx. It
+// records inserts and changes of status or desired status.
+func (r *Repo) transition(ctx context.Context) repo.TransitionFunc {
+	return func(before, after *data.Download) *data.Transition {
+		var from data.DownloadStatus
+		if before != nil {
+			if before.Status == after.Status && before.DesiredStatus == after.DesiredStatus {
+				return nil
+			}
+			from = before.Status
+		}
+		t := &data.Transition{
+			DownloadID:    after.ID,
+			From:          from,
+			To:            after.Status,
+			DesiredStatus: after.DesiredStatus,
+			Cause:         data.CauseSystem,
+			Timestamp:     r.now().UTC(),
+		}
+		if c, ok := ctx.Value(causeKey{}).(data.TransitionCause); ok {
+			t.Cause = c
+		}
+		if ev, ok := ctx.Value(eventKey{}).(string); ok {
+			t.Event = ev
+		}
+		if id, ok := reqid.From(ctx); ok {
+			t.RequestID = id
+		}
+		return t
+	}
+}
+
+// record appends the transition of a write from before to d to the history
+// repository, after the write.
+func (r *Repo) record(ctx context.Context, before, d *data.Download) {
+	t := r.transition(ctx)(before, d)
+	if t == nil {
+		return
+	}
+	// Record even if the caller's context was cancelled after the write.
+	if err := r.history.AddTransition(context.WithoutCancel(ctx), t); err != nil {
+		r.log.Error("history: record transition", "id", d.ID, "from", t.From, "to", t.To, "err", err)
+	}
+}
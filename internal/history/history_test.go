@@ -0,0 +1,129 @@
+package history
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/repo"
+	"github.com/tinoosan/torrus/internal/reqid"
+)
+
+// TestRepoRecordsTransitions ensures status changes are recorded with the
+// cause, event and request ID of the write, and other writes are not.
+func TestRepoRecordsTransitions(t *testing.T) {
+	h := repo.NewInMemoryHistoryRepo()
+	r := Wrap(slog.New(slog.NewTextHandler(io.Discard, nil)), repo.NewInMemoryDownloadRepo(), h)
+
+	ctx := reqid.With(WithCause(context.Background(), data.CauseAPI), "req-1")
+	dl, err := r.Add(ctx, &data.Download{Source: "https://example.com/f", TargetPath: "/tmp", Status: data.StatusQueued, DesiredStatus: data.StatusQueued})
+	if err != nil {
+		t.Fatal(err)
+	}
+	update := func(ctx context.Context, mutate func(*data.Download)) {
+		t.Helper()
+		if _, err := r.Update(ctx, dl.ID, func(d *data.Download) error {
+			mutate(d)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := WithEvent(WithCause(context.Background(), data.CauseReconciler), "Active")
+	update(rec, func(d *data.Download) { d.Status = data.StatusActive })
+	// Progress alone is not a transition.
+	update(rec, func(d *data.Download) { d.Files = []data.DownloadFile{{Path: "/tmp/f"}} })
+	update(context.Background(), func(d *data.Download) { d.DesiredStatus = data.StatusPaused })
+
+	got, err := h.ListTransitions(context.Background(), dl.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []data.Transition{
+		{DownloadID: dl.ID, To: data.StatusQueued, DesiredStatus: data.StatusQueued, Cause: data.CauseAPI, RequestID: "req-1"},
+		{DownloadID: dl.ID, From: data.StatusQueued, To: data.StatusActive, DesiredStatus: data.StatusQueued, Cause: data.CauseReconciler, Event: "Active"},
+		{DownloadID: dl.ID, From: data.StatusActive, To: data.StatusActive, DesiredStatus: data.StatusPaused, Cause: data.CauseSystem},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d transitions, want %d: %+v", len(got), len(want), got)
+	}
+	for i, tr := range got {
+		if tr.Timestamp.IsZero() {
+			t.Fatalf("transition %d has no timestamp", i)
+		}
+		tr.Timestamp = want[i].Timestamp
+		if *tr != want[i] {
+			t.Errorf("transition %d = %+v, want %+v", i, *tr, want[i])
+		}
+	}
+}
+
+// txRepo stands in for a repository that keeps its own history, counting
+// the transitions it records as part of a write.
+type txRepo struct {
+	*repo.InMemoryDownloadRepo
+	*repo.InMemoryHistoryRepo
+	recorded int
+}
+
+func (r *txRepo) commit(before, after *data.Download, record repo.TransitionFunc) {
+	if t := record(before, after); t != nil {
+		r.recorded++
+		_ = r.AddTransition(context.Background(), t)
+	}
+}
+
+func (r *txRepo) AddRecorded(ctx context.Context, d *data.Download, record repo.TransitionFunc) (*data.Download, error) {
+	saved, err := r.InMemoryDownloadRepo.Add(ctx, d)
+	if err == nil {
+		r.commit(nil, saved, record)
+	}
+	return saved, err
+}
+
+func (r *txRepo) AddWithFingerprintRecorded(ctx context.Context, d *data.Download, fingerprint string, record repo.TransitionFunc) (*data.Download, bool, error) {
+	saved, created, err := r.InMemoryDownloadRepo.AddWithFingerprint(ctx, d, fingerprint)
+	if err == nil && created {
+		r.commit(nil, saved, record)
+	}
+	return saved, created, err
+}
+
+func (r *txRepo) UpdateRecorded(ctx context.Context, id string, mutate func(*data.Download) error, record repo.TransitionFunc) (*data.Download, error) {
+	before, err := r.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := r.InMemoryDownloadRepo.Update(ctx, id, mutate)
+	if err == nil {
+		r.commit(before, updated, record)
+	}
+	return updated, err
+}
+
+// TestRepoRecordsInWriteTransaction ensures a repository that is its own
+// history store records transitions as part of the write, exactly once.
+func TestRepoRecordsInWriteTransaction(t *testing.T) {
+	tx := &txRepo{InMemoryDownloadRepo: repo.NewInMemoryDownloadRepo(), InMemoryHistoryRepo: repo.NewInMemoryHistoryRepo()}
+	r := Wrap(slog.New(slog.NewTextHandler(io.Discard, nil)), tx, tx)
+	ctx := WithCause(context.Background(), data.CauseAPI)
+	dl, _, err := r.AddWithFingerprint(ctx, &data.Download{Source: "https://example.com/f", TargetPath: "/tmp", Status: data.StatusQueued, DesiredStatus: data.StatusQueued}, "fp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range []data.DownloadStatus{data.StatusActive, data.StatusActive} {
+		if _, err := r.Update(ctx, dl.ID, func(d *data.Download) error { d.Status = st; return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := tx.ListTransitions(context.Background(), dl.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.recorded != 2 || len(got) != 2 || got[1].From != data.StatusQueued || got[1].To != data.StatusActive || got[1].Cause != data.CauseAPI {
+		t.Fatalf("recorded %d, transitions %+v", tx.recorded, got)
+	}
+}
@@ -0,0 +1,117 @@
+// Package lifecycle is the download state machine. It lists which status
+// changes are legal and which desired statuses may be requested in each
+// status. The service checks requests against it and the reconciler checks
+// the transitions it applies for downloader events.
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tinoosan/torrus/internal/data"
+)
+
+// ErrIllegalTransition is wrapped by *TransitionError.
+var ErrIllegalTransition = errors.New("illegal status transition")
+
+// transitions lists the statuses each status may change to. Staying in the
+// same status is always allowed. Complete and Cancelled are final.
+var transitions = map[data.DownloadStatus][]data.DownloadStatus{
+	// Queued downloads are started by the scheduler, and a start can
+	// finish, fail or be cancelled before the Start event is seen.
+	data.StatusQueued: {data.StatusActive, data.StatusSeeding, data.StatusPaused, data.StatusComplete, data.StatusCancelled, data.StatusError},
+	// Active downloads go back to Queued when held for disk space.
+	data.StatusActive:  {data.StatusQueued, data.StatusSeeding, data.StatusPaused, data.StatusComplete, data.StatusCancelled, data.StatusError},
+	data.StatusSeeding: {data.StatusPaused, data.StatusComplete, data.StatusCancelled, data.StatusError},
+	// Resuming queues the download when there is a scheduler and starts it
+	// directly otherwise. The backend may also finish a download whose pause
+	// raced its completion, or that was resumed outside Torrus.
+	data.StatusPaused: {data.StatusQueued, data.StatusActive, data.StatusComplete, data.StatusCancelled, data.StatusError},
+	// Retries queue failed downloads again.
+	data.StatusError:     {data.StatusQueued, data.StatusActive, data.StatusPaused, data.StatusCancelled},
+	data.StatusComplete:  {},
+	data.StatusCancelled: {},
+}
+
+// requests lists the desired statuses that may be requested in each status.
+// Requesting the status a download is already in is a no-op, so Cancelled
+// may be requested again; nothing else can be asked of a finished download.
+var requests = map[data.DownloadStatus][]data.DownloadStatus{
+	data.StatusQueued:    {data.StatusActive, data.StatusResume, data.StatusPaused, data.StatusCancelled},
+	data.StatusActive:    {data.StatusActive, data.StatusResume, data.StatusPaused, data.StatusCancelled},
+	data.StatusSeeding:   {data.StatusActive, data.StatusResume, data.StatusPaused, data.StatusCancelled},
+	data.StatusPaused:    {data.StatusActive, data.StatusResume, data.StatusPaused, data.StatusCancelled},
+	data.StatusError:     {data.StatusActive, data.StatusResume, data.StatusPaused, data.StatusCancelled},
+	data.StatusComplete:  {},
+	data.StatusCancelled: {data.StatusCancelled},
+}
+
+// TransitionError reports an illegal transition or request and what would
+// have been allowed instead.
+type TransitionError struct {
+	From data.DownloadStatus
+	To   data.DownloadStatus
+	// Allowed are the legal next statuses, or the desired statuses that may
+	// be requested, in From.
+	Allowed []data.DownloadStatus
+}
+
+func (e *TransitionError) Error() string {
+	allowed := "none"
+	if len(e.Allowed) > 0 {
+		s := make([]string, len(e.Allowed))
+		for i, st := range e.Allowed {
+			s[i] = string(st)
+		}
+		allowed = strings.Join(s, ", ")
+	}
+	return fmt.Sprintf("%v: %s to %s (allowed: %s)", ErrIllegalTransition, e.From, e.To, allowed)
+}
+
+func (e *TransitionError) Unwrap() error { return ErrIllegalTransition }
+
+// state returns the state machine state of status. A download stored
+// without a status has not been admitted yet, as if Queued.
+func state(status data.DownloadStatus) data.DownloadStatus {
+	if status == "" {
+		return data.StatusQueued
+	}
+	return status
+}
+
+// Next returns the statuses a download in status from may change to.
+func Next(from data.DownloadStatus) []data.DownloadStatus {
+	return slices.Clone(transitions[state(from)])
+}
+
+// Final reports whether no transition leads out of status.
+func Final(status data.DownloadStatus) bool {
+	next, ok := transitions[state(status)]
+	return ok && len(next) == 0
+}
+
+// Transition returns a *TransitionError unless a download in status from may
+// change to status to.
+func Transition(from, to data.DownloadStatus) error {
+	if state(from) == to || slices.Contains(transitions[state(from)], to) {
+		return nil
+	}
+	return &TransitionError{From: from, To: to, Allowed: Next(from)}
+}
+
+// Requestable returns the desired statuses that may be requested for a
+// download in status.
+func Requestable(status data.DownloadStatus) []data.DownloadStatus {
+	return slices.Clone(requests[state(status)])
+}
+
+// Request returns a *TransitionError unless desired may be requested for a
+// download in status.
+func Request(status, desired data.DownloadStatus) error {
+	if slices.Contains(requests[state(status)], desired) {
+		return nil
+	}
+	return &TransitionError{From: status, To: desired, Allowed: Requestable(status)}
+}
@@ -0,0 +1,137 @@
+package lifecycle
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"slices"
+	"testing"
+	"testing/quick"
+
+	"github.com/tinoosan/torrus/internal/data"
+)
+
+var statuses = []data.DownloadStatus{
+	data.StatusQueued, data.StatusActive, data.StatusSeeding, data.StatusPaused,
+	data.StatusComplete, data.StatusCancelled, data.StatusError,
+}
+
+var desired = []data.DownloadStatus{data.StatusActive, data.StatusResume, data.StatusPaused, data.StatusCancelled}
+
+// TestTables ensures the tables cover every status, lead only to known
+// statuses and that exactly Complete and Cancelled are final.
+func TestTables(t *testing.T) {
+	for _, s := range statuses {
+		if _, ok := transitions[s]; !ok {
+			t.Errorf("no transitions for %s", s)
+		}
+		if _, ok := requests[s]; !ok {
+			t.Errorf("no requests for %s", s)
+		}
+		for _, to := range transitions[s] {
+			if !slices.Contains(statuses, to) || to == s {
+				t.Errorf("%s: bad next status %q", s, to)
+			}
+		}
+		for _, d := range requests[s] {
+			if !slices.Contains(desired, d) {
+				t.Errorf("%s: bad desired status %q", s, d)
+			}
+		}
+		if want := s == data.StatusComplete || s == data.StatusCancelled; Final(s) != want {
+			t.Errorf("Final(%s) = %v, want %v", s, !want, want)
+		}
+	}
+	if len(transitions) != len(statuses) || len(requests) != len(statuses) {
+		t.Fatalf("tables list unknown statuses")
+	}
+}
+
+// TestReachability ensures every status can be reached from Queued and every
+// status can still end in a final one.
+func TestReachability(t *testing.T) {
+	reach := func(from data.DownloadStatus) map[data.DownloadStatus]bool {
+		seen := map[data.DownloadStatus]bool{from: true}
+		for todo := []data.DownloadStatus{from}; len(todo) > 0; todo = todo[1:] {
+			for _, to := range Next(todo[0]) {
+				if !seen[to] {
+					seen[to] = true
+					todo = append(todo, to)
+				}
+			}
+		}
+		return seen
+	}
+	fromQueued := reach(data.StatusQueued)
+	for _, s := range statuses {
+		if !fromQueued[s] {
+			t.Errorf("%s is unreachable from Queued", s)
+		}
+		r := reach(s)
+		if !r[data.StatusComplete] && !r[data.StatusCancelled] {
+			t.Errorf("%s cannot end", s)
+		}
+	}
+}
+
+// walk is a random sequence of statuses to move to and desired statuses to
+// request.
+type walk struct {
+	to  []data.DownloadStatus
+	req []data.DownloadStatus
+}
+
+func (walk) Generate(r *rand.Rand, size int) reflect.Value {
+	w := walk{}
+	for i := 0; i < size; i++ {
+		w.to = append(w.to, statuses[r.Intn(len(statuses))])
+		w.req = append(w.req, desired[r.Intn(len(desired))])
+	}
+	return reflect.ValueOf(w)
+}
+
+// TestRandomWalks applies random transitions and requests: rejections list
+// exactly the allowed alternatives, and nothing leaves a final status.
+func TestRandomWalks(t *testing.T) {
+	property := func(w walk) bool {
+		cur := data.StatusQueued
+		for i, to := range w.to {
+			if err := Request(cur, w.req[i]); err != nil {
+				var te *TransitionError
+				if !errors.As(err, &te) || !errors.Is(err, ErrIllegalTransition) ||
+					!reflect.DeepEqual(te.Allowed, Requestable(cur)) || slices.Contains(te.Allowed, w.req[i]) {
+					t.Logf("request %s in %s: %v", w.req[i], cur, err)
+					return false
+				}
+			}
+			err := Transition(cur, to)
+			if err == nil {
+				if Final(cur) && to != cur {
+					t.Logf("left final status %s for %s", cur, to)
+					return false
+				}
+				cur = to
+				continue
+			}
+			var te *TransitionError
+			if !errors.As(err, &te) || te.From != cur || te.To != to ||
+				!reflect.DeepEqual(te.Allowed, Next(cur)) || slices.Contains(te.Allowed, to) {
+				t.Logf("transition %s to %s: %v", cur, to, err)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnsetStatusIsQueued(t *testing.T) {
+	if err := Transition("", data.StatusActive); err != nil {
+		t.Fatalf("Transition from unset: %v", err)
+	}
+	if err := Request("", data.StatusPaused); err != nil {
+		t.Fatalf("Request in unset: %v", err)
+	}
+}
@@ -37,10 +37,157 @@ var (
             Help:      "Number of active downloads tracked by the adapter.",
         },
     )
+
+    EventSubscribers = prometheus.NewGauge(
+        prometheus.GaugeOpts{
+            Namespace: "torrus",
+            Name:      "event_subscribers",
+            Help:      "Number of clients subscribed to the download event stream.",
+        },
+    )
+
+    QueuedDownloads = prometheus.NewGauge(
+        prometheus.GaugeOpts{
+            Namespace: "torrus",
+            Name:      "queued_downloads",
+            Help:      "Number of downloads waiting in the scheduler queue for an active slot.",
+        },
+    )
+
+    WebhookDeliveries = prometheus.NewCounterVec(
+        prometheus.CounterOpts{
+            Namespace: "torrus",
+            Name:      "webhook_deliveries_total",
+            Help:      "Webhook delivery attempts by outcome (succeeded, retried, failed).",
+        },
+        []string{"outcome"},
+    )
+
+    Resyncs = prometheus.NewCounterVec(
+        prometheus.CounterOpts{
+            Namespace: "torrus",
+            Name:      "resyncs_total",
+            Help:      "Resync passes against the downloader backend by outcome (succeeded, failed).",
+        },
+        []string{"outcome"},
+    )
+
+    OrphanedTasks = prometheus.NewGauge(
+        prometheus.GaugeOpts{
+            Namespace: "torrus",
+            Name:      "orphaned_tasks",
+            Help:      "Backend tasks without a matching download, as of the last resync.",
+        },
+    )
+
+    Aria2NotificationsConnected = prometheus.NewGauge(
+        prometheus.GaugeOpts{
+            Namespace: "torrus",
+            Name:      "aria2_notifications_connected",
+            Help:      "Whether the aria2 notification WebSocket is connected (1) or not (0).",
+        },
+    )
+
+    StorageFreeBytes = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Namespace: "torrus",
+            Name:      "storage_free_bytes",
+            Help:      "Free space available to Torrus on each allowed root, as of the last check.",
+        },
+        []string{"root"},
+    )
+
+    StorageTotalBytes = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Namespace: "torrus",
+            Name:      "storage_total_bytes",
+            Help:      "Size of the filesystem holding each allowed root.",
+        },
+        []string{"root"},
+    )
+
+    StorageReservedBytes = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Namespace: "torrus",
+            Name:      "storage_reserved_bytes",
+            Help:      "Bytes still to be written by active downloads under each allowed root.",
+        },
+        []string{"root"},
+    )
+
+    StorageLevel = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Namespace: "torrus",
+            Name:      "storage_level",
+            Help:      "Free-space level of each allowed root: 0 ok, 1 low (new starts held), 2 critical (active downloads held).",
+        },
+        []string{"root"},
+    )
+
+    StorageHolds = prometheus.NewCounterVec(
+        prometheus.CounterOpts{
+            Namespace: "torrus",
+            Name:      "storage_holds_total",
+            Help:      "Active downloads paused and re-queued because their root reached the critical free-space level.",
+        },
+        []string{"root"},
+    )
+
+    BandwidthDownloadLimit = prometheus.NewGauge(
+        prometheus.GaugeOpts{
+            Namespace: "torrus",
+            Name:      "bandwidth_download_limit_bytes",
+            Help:      "Global download speed limit currently applied, in bytes/sec (0 = unlimited).",
+        },
+    )
+
+    BandwidthUploadLimit = prometheus.NewGauge(
+        prometheus.GaugeOpts{
+            Namespace: "torrus",
+            Name:      "bandwidth_upload_limit_bytes",
+            Help:      "Global upload speed limit currently applied, in bytes/sec (0 = unlimited).",
+        },
+    )
+
+    BandwidthScheduleRule = prometheus.NewGauge(
+        prometheus.GaugeOpts{
+            Namespace: "torrus",
+            Name:      "bandwidth_schedule_rule",
+            Help:      "Index of the bandwidth schedule rule in force, or -1 when the default limits apply.",
+        },
+    )
+
+    DownloadRetries = prometheus.NewCounterVec(
+        prometheus.CounterOpts{
+            Namespace: "torrus",
+            Name:      "download_retries_total",
+            Help:      "Failed downloads handled by the retrier by outcome (scheduled, retried, exhausted, not_retryable).",
+        },
+        []string{"outcome"},
+    )
+
+    IllegalTransitions = prometheus.NewCounterVec(
+        prometheus.CounterOpts{
+            Namespace: "torrus",
+            Name:      "illegal_transitions_total",
+            Help:      "Downloader events ignored by the reconciler because the status change they imply is illegal.",
+        },
+        []string{"from", "to"},
+    )
+
+    Aria2NotificationDisconnects = prometheus.NewCounter(
+        prometheus.CounterOpts{
+            Namespace: "torrus",
+            Name:      "aria2_notification_disconnects_total",
+            Help:      "Times the aria2 notification WebSocket dropped and had to be reconnected.",
+        },
+    )
 )
 
 // Register registers the Torrus metrics into the default registry.
 func Register() {
-    prometheus.MustRegister(DownloadEvents, Aria2RPCErrors, Aria2RPCLatency, ActiveDownloads)
+    prometheus.MustRegister(DownloadEvents, Aria2RPCErrors, Aria2RPCLatency, ActiveDownloads, EventSubscribers, QueuedDownloads, WebhookDeliveries, Resyncs, OrphanedTasks, Aria2NotificationsConnected, Aria2NotificationDisconnects,
+        StorageFreeBytes, StorageTotalBytes, StorageReservedBytes, StorageLevel, StorageHolds,
+        BandwidthDownloadLimit, BandwidthUploadLimit, BandwidthScheduleRule, DownloadRetries, IllegalTransitions)
 }
 
@@ -0,0 +1,270 @@
+// Package migrate applies versioned SQL migrations to a PostgreSQL database.
+//
+// Migrations are pairs of files named NNNN_name.up.sql and
+// NNNN_name.down.sql, where NNNN is the version. Applied versions are
+// recorded in the schema_migrations table. Up and Down hold a Postgres
+// advisory lock, so replicas starting at the same time apply each migration
+// once: the others wait for the lock and then find nothing left to do.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// LockKey is the advisory lock key held while migrating ("torrus").
+const LockKey int64 = 0x746f72727573
+
+// ErrNoDown is returned by Down for an applied migration it cannot revert.
+var ErrNoDown = errors.New("migration has no down script")
+
+// Migration is one schema version.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	// Down reverts Up; empty when the migration cannot be reverted.
+	Down string
+}
+
+// String returns the migration's file name stem, e.g. 0001_initial.
+func (m Migration) String() string { return fmt.Sprintf("%04d_%s", m.Version, m.Name) }
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Load reads the migrations in the root of fsys, ordered by version. Every
+// version needs an up script; the down script is optional.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration %s: want NNNN_name.up.sql or NNNN_name.down.sql", e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		if version < 1 {
+			return nil, fmt.Errorf("migration %s: version must be positive", e.Name())
+		}
+		b, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d: conflicting names %q and %q", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(b)
+		} else {
+			mig.Down = string(b)
+		}
+	}
+	out := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %s: missing up script", mig)
+		}
+		out = append(out, *mig)
+	}
+	slices.SortFunc(out, func(a, b Migration) int { return a.Version - b.Version })
+	return out, nil
+}
+
+// Status is the state of one migration in a database.
+type Status struct {
+	Migration
+	// AppliedAt is when the migration was applied; zero if it is pending.
+	AppliedAt time.Time
+}
+
+// Applied reports whether the migration has been applied.
+func (s Status) Applied() bool { return !s.AppliedAt.IsZero() }
+
+// Runner applies migrations to a database.
+type Runner struct {
+	db         *sql.DB
+	migrations []Migration
+	now        func() time.Time
+}
+
+// New returns a Runner applying migrations, as returned by Load, to db.
+func New(db *sql.DB, migrations []Migration) *Runner {
+	return &Runner{db: db, migrations: migrations, now: time.Now}
+}
+
+// Status lists every known migration, plus any applied version this binary
+// does not know about (with an empty Name), by version. It only reads: it
+// neither creates schema_migrations nor waits for the migration lock, so it
+// may miss a migration being applied concurrently.
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]time.Time)
+	if exists {
+		var err error
+		if applied, err = readApplied(ctx, r.db); err != nil {
+			return nil, err
+		}
+	}
+	return status(r.migrations, applied), nil
+}
+
+// Up applies every pending migration in version order and returns those it
+// applied.
+func (r *Runner) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := r.locked(ctx, func(conn *sql.Conn, applied map[int]time.Time) error {
+		for _, m := range r.migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := r.apply(ctx, conn, m, m.Up, true); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the latest steps applied migrations, newest first, and
+// returns those it reverted.
+func (r *Runner) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := r.locked(ctx, func(conn *sql.Conn, applied map[int]time.Time) error {
+		st := status(r.migrations, applied)
+		for i := len(st) - 1; i >= 0 && len(done) < steps; i-- {
+			if !st[i].Applied() {
+				continue
+			}
+			m := st[i].Migration
+			if m.Down == "" {
+				return fmt.Errorf("revert %s: %w", m, ErrNoDown)
+			}
+			if err := r.apply(ctx, conn, m, m.Down, false); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// locked runs fn on a connection holding the advisory lock, with the
+// versions applied so far.
+func (r *Runner) locked(ctx context.Context, fn func(*sql.Conn, map[int]time.Time) error) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// Session-level lock: it is tied to conn, which is why all work below
+	// runs on it.
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, LockKey); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, LockKey)
+	}()
+
+	if _, err := conn.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL
+)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	applied, err := readApplied(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn, applied)
+}
+
+// readApplied returns the applied versions recorded in schema_migrations.
+func readApplied(ctx context.Context, q interface {
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+}) (map[int]time.Time, error) {
+	rows, err := q.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			v  int
+			at time.Time
+		)
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		applied[v] = at
+	}
+	return applied, rows.Err()
+}
+
+// apply runs script and records (up) or forgets (down) m in one
+// transaction.
+func (r *Runner) apply(ctx context.Context, conn *sql.Conn, m Migration, script string, up bool) error {
+	dir := "down"
+	if up {
+		dir = "up"
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %s %s: %w", m, dir, err)
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`, m.Version, m.Name, r.now().UTC())
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("record migration %s: %w", m, err)
+	}
+	return tx.Commit()
+}
+
+// status merges the known migrations with the applied versions.
+func status(migrations []Migration, applied map[int]time.Time) []Status {
+	out := make([]Status, 0, len(migrations))
+	known := make(map[int]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
+		out = append(out, Status{Migration: m, AppliedAt: applied[m.Version]})
+	}
+	for v, at := range applied {
+		if !known[v] {
+			out = append(out, Status{Migration: Migration{Version: v}, AppliedAt: at})
+		}
+	}
+	slices.SortFunc(out, func(a, b Status) int { return a.Version - b.Version })
+	return out
+}
@@ -0,0 +1,71 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_x.up.sql":     {Data: []byte("ALTER TABLE t ADD COLUMN x INT;")},
+		"0002_add_x.down.sql":   {Data: []byte("ALTER TABLE t DROP COLUMN x;")},
+		"0001_initial.up.sql":   {Data: []byte("CREATE TABLE t (id INT);")},
+		"0001_initial.down.sql": {Data: []byte("DROP TABLE t;")},
+		"0010_backfill.up.sql":  {Data: []byte("UPDATE t SET x = 0;")},
+	}
+	got, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []Migration{
+		{Version: 1, Name: "initial", Up: "CREATE TABLE t (id INT);", Down: "DROP TABLE t;"},
+		{Version: 2, Name: "add_x", Up: "ALTER TABLE t ADD COLUMN x INT;", Down: "ALTER TABLE t DROP COLUMN x;"},
+		{Version: 10, Name: "backfill", Up: "UPDATE t SET x = 0;"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d migrations, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if s := got[2].String(); s != "0010_backfill" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"bad name":         {"initial.sql": {}},
+		"zero version":     {"0000_initial.up.sql": {Data: []byte("x")}},
+		"missing up":       {"0001_initial.down.sql": {Data: []byte("x")}},
+		"conflicting name": {"0001_a.up.sql": {Data: []byte("x")}, "0001_b.down.sql": {Data: []byte("x")}},
+	}
+	for name, fsys := range cases {
+		if _, err := Load(fsys); err == nil {
+			t.Errorf("%s: Load accepted it", name)
+		}
+	}
+}
+
+// TestStatus ensures pending, applied and unknown applied versions are
+// merged in version order.
+func TestStatus(t *testing.T) {
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ms := []Migration{{Version: 1, Name: "initial"}, {Version: 3, Name: "later"}}
+	got := status(ms, map[int]time.Time{1: at, 2: at})
+	if len(got) != 3 {
+		t.Fatalf("got %+v", got)
+	}
+	if got[0].Name != "initial" || !got[0].Applied() {
+		t.Errorf("0001 = %+v", got[0])
+	}
+	if got[1].Version != 2 || got[1].Name != "" || !got[1].Applied() {
+		t.Errorf("unknown version = %+v", got[1])
+	}
+	if got[2].Name != "later" || got[2].Applied() {
+		t.Errorf("0003 = %+v", got[2])
+	}
+}
@@ -0,0 +1,132 @@
+// Package pathsafe holds the rules Torrus applies before it removes, moves
+// or creates files on disk on behalf of a download: every path it touches
+// must resolve strictly inside the directory the download owns, and
+// downloads may be confined to a set of allowed roots.
+package pathsafe
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrOutside indicates a path escapes the directory it must stay within.
+	ErrOutside = errors.New("path outside base directory")
+	// ErrNotAllowed indicates a path resolves outside every allowed root.
+	ErrNotAllowed = errors.New("path outside allowed roots")
+)
+
+// Within reports whether p lies strictly under base; base itself is never
+// within. Both are cleaned first. With an empty base only absolute paths
+// are accepted.
+func Within(base, p string) bool {
+	p = filepath.Clean(p)
+	if base == "" {
+		return filepath.IsAbs(p)
+	}
+	base = filepath.Clean(base)
+	if p == base {
+		return false
+	}
+	prefix := base
+	if !strings.HasSuffix(prefix, string(os.PathSeparator)) {
+		prefix += string(os.PathSeparator)
+	}
+	return strings.HasPrefix(p, prefix)
+}
+
+// Join resolves name against base (absolute names are kept as is), cleans
+// the result and checks it with Within.
+func Join(base, name string) (string, error) {
+	p := name
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(base, p)
+	}
+	p = filepath.Clean(p)
+	if !Within(base, p) {
+		return "", fmt.Errorf("%w: %s", ErrOutside, p)
+	}
+	return p, nil
+}
+
+// ValidName reports whether name is a single, non-special path element.
+func ValidName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\\x00")
+}
+
+// Roots is a set of directories downloads may be written under. An empty set
+// allows any absolute path.
+type Roots []string
+
+// ParseRoots parses a comma-separated list of absolute directories. Each root
+// is cleaned and its symlinks resolved, so that Check compares like with
+// like.
+func ParseRoots(s string) (Roots, error) {
+	var roots Roots
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !filepath.IsAbs(part) {
+			return nil, fmt.Errorf("allowed root %q is not absolute", part)
+		}
+		resolved, err := resolve(filepath.Clean(part))
+		if err != nil {
+			return nil, fmt.Errorf("allowed root %q: %w", part, err)
+		}
+		roots = append(roots, resolved)
+	}
+	return roots, nil
+}
+
+// Check resolves p, which must be absolute, and reports an error wrapping
+// ErrNotAllowed unless the result is one of the roots or lies under one.
+// Symlinks are followed as far as the path exists, so a link pointing out
+// of a root is caught even when the rest of the path is yet to be created.
+// It returns the resolved path.
+func (r Roots) Check(p string) (string, error) {
+	if !filepath.IsAbs(p) {
+		return "", fmt.Errorf("%w: %q is not absolute", ErrNotAllowed, p)
+	}
+	resolved, err := resolve(filepath.Clean(p))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrNotAllowed, p, err)
+	}
+	if len(r) == 0 {
+		return resolved, nil
+	}
+	for _, root := range r {
+		if resolved == root || Within(root, resolved) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s (allowed: %s)", ErrNotAllowed, p, strings.Join(r, ", "))
+}
+
+// resolve evaluates the symlinks of the longest existing prefix of the clean
+// absolute path p and appends the remaining, not yet existing, elements.
+func resolve(p string) (string, error) {
+	var tail []string
+	for {
+		resolved, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, tail...)...), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		if _, lerr := os.Lstat(p); lerr == nil {
+			return "", fmt.Errorf("dangling symlink %s", p)
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return "", err
+		}
+		tail = append([]string{filepath.Base(p)}, tail...)
+		p = parent
+	}
+}
@@ -0,0 +1,101 @@
+package pathsafe
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithin(t *testing.T) {
+	cases := []struct {
+		base, p string
+		want    bool
+	}{
+		{"/data", "/data/a", true},
+		{"/data/", "/data/a/b", true},
+		{"/data", "/data", false},
+		{"/data", "/data/", false},
+		{"/data", "/data2/a", false},
+		{"/data", "/data/../etc", false},
+		{"", "/anything", true},
+		{"", "relative", false},
+	}
+	for _, c := range cases {
+		if got := Within(c.base, c.p); got != c.want {
+			t.Errorf("Within(%q, %q) = %v, want %v", c.base, c.p, got, c.want)
+		}
+	}
+}
+
+func TestJoin(t *testing.T) {
+	if p, err := Join("/data", "a/b"); err != nil || p != "/data/a/b" {
+		t.Fatalf("Join = %q, %v", p, err)
+	}
+	for _, name := range []string{"../x", "a/../../x", "/etc/passwd", "."} {
+		if _, err := Join("/data", name); !errors.Is(err, ErrOutside) {
+			t.Errorf("Join(%q): want ErrOutside, got %v", name, err)
+		}
+	}
+}
+
+func TestValidName(t *testing.T) {
+	for _, name := range []string{"", ".", "..", "a/b", `a\b`, "a\x00"} {
+		if ValidName(name) {
+			t.Errorf("ValidName(%q) = true", name)
+		}
+	}
+	if !ValidName("Show S01E01.mkv") {
+		t.Error("plain name rejected")
+	}
+}
+
+func TestRootsCheck(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "downloads")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{root, outside} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "missing"), filepath.Join(root, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	roots, err := ParseRoots(" " + root + " ,")
+	if err != nil || len(roots) != 1 {
+		t.Fatalf("ParseRoots = %v, %v", roots, err)
+	}
+
+	for _, p := range []string{root, root + "/", filepath.Join(root, "tv"), filepath.Join(root, "new/deeper")} {
+		if _, err := roots.Check(p); err != nil {
+			t.Errorf("Check(%q): %v", p, err)
+		}
+	}
+	for _, p := range []string{
+		"/etc",
+		"relative/path",
+		outside,
+		filepath.Join(root, "../outside"),
+		filepath.Join(root, "escape"),
+		filepath.Join(root, "escape/new"),
+		filepath.Join(root, "dangling"),
+	} {
+		if _, err := roots.Check(p); !errors.Is(err, ErrNotAllowed) {
+			t.Errorf("Check(%q): want ErrNotAllowed, got %v", p, err)
+		}
+	}
+
+	if _, err := Roots(nil).Check("/etc"); err != nil {
+		t.Errorf("empty roots should allow any absolute path: %v", err)
+	}
+	if _, err := ParseRoots("data"); err == nil {
+		t.Error("relative root accepted")
+	}
+}
@@ -0,0 +1,274 @@
+package postprocess
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/tinoosan/torrus/internal/pathsafe"
+)
+
+// move renames src to dst, copying across filesystems when needed.
+func move(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyTree(src, dst); err != nil {
+		_ = os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// linkTree recreates the directories of src under dst and hardlinks every
+// regular file, leaving src in place (e.g. for seeding).
+func linkTree(src, dst string) error {
+	return walkTree(src, dst, os.Link)
+}
+
+// copyTree copies the directories and regular files of src to dst.
+func copyTree(src, dst string) error {
+	return walkTree(src, dst, copyFile)
+}
+
+// walkTree mirrors src at dst, applying file to every regular file. Other
+// file types (symlinks, devices) are skipped.
+func walkTree(src, dst string, file func(from, to string) error) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		to := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(to, 0o755)
+		case d.Type().IsRegular():
+			return file(p, to)
+		}
+		return nil
+	})
+}
+
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+type extractedFile struct {
+	path string
+	size int64
+}
+
+// ErrExtractLimit is returned when archives expand beyond the extraction
+// limits of a run.
+var ErrExtractLimit = errors.New("archive exceeds extraction limits")
+
+// budget is what is left of the extraction limits of one run, shared by all
+// of its archives. Every entry, directories included, takes one of files.
+type budget struct {
+	bytes int64
+	files int
+}
+
+func (b *budget) entry() error {
+	if b.files <= 0 {
+		return fmt.Errorf("%w: too many entries", ErrExtractLimit)
+	}
+	b.files--
+	return nil
+}
+
+// archiveBase returns name without its archive extension, or "" when name
+// is not a supported archive.
+func archiveBase(name string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(lower, ext) && len(name) > len(ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return ""
+}
+
+// extractAll unpacks every supported archive found at root (a file or a
+// directory tree) into a sibling directory named after the archive, within
+// budget b. It returns the files extracted and the directories it created.
+// An archive that fails to extract leaves no directory behind.
+func extractAll(root string, b *budget) ([]extractedFile, []string, error) {
+	var archives []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && archiveBase(d.Name()) != "" {
+			archives = append(archives, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		out  []extractedFile
+		dirs []string
+	)
+	for _, a := range archives {
+		dir := filepath.Join(filepath.Dir(a), archiveBase(filepath.Base(a)))
+		if _, err := os.Lstat(dir); err == nil {
+			return out, dirs, fmt.Errorf("extract %s: %s already exists", filepath.Base(a), dir)
+		}
+		var files []extractedFile
+		if strings.HasSuffix(strings.ToLower(a), ".zip") {
+			files, err = extractZip(a, dir, b)
+		} else {
+			files, err = extractTar(a, dir, b)
+		}
+		if err != nil {
+			_ = os.RemoveAll(dir)
+			return out, dirs, fmt.Errorf("extract %s: %w", filepath.Base(a), err)
+		}
+		out = append(out, files...)
+		dirs = append(dirs, dir)
+	}
+	return out, dirs, nil
+}
+
+func extractZip(archive, dir string, b *budget) ([]extractedFile, error) {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var out []extractedFile
+	for _, f := range zr.File {
+		if err := b.entry(); err != nil {
+			return out, err
+		}
+		if f.FileInfo().IsDir() {
+			p, err := pathsafe.Join(dir, f.Name)
+			if err != nil {
+				return out, err
+			}
+			if err := os.MkdirAll(p, 0o755); err != nil {
+				return out, err
+			}
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return out, err
+		}
+		ef, err := writeEntry(dir, f.Name, f.Mode().Perm(), rc, b)
+		_ = rc.Close()
+		if err != nil {
+			return out, err
+		}
+		out = append(out, ef)
+	}
+	return out, nil
+}
+
+func extractTar(archive, dir string, b *budget) ([]extractedFile, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if lower := strings.ToLower(archive); strings.HasSuffix(lower, ".gz") || strings.HasSuffix(lower, ".tgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	var out []extractedFile
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+		if err := b.entry(); err != nil {
+			return out, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			p, err := pathsafe.Join(dir, hdr.Name)
+			if err != nil {
+				return out, err
+			}
+			if err := os.MkdirAll(p, 0o755); err != nil {
+				return out, err
+			}
+		case tar.TypeReg:
+			ef, err := writeEntry(dir, hdr.Name, fs.FileMode(hdr.Mode).Perm(), tr, b)
+			if err != nil {
+				return out, err
+			}
+			out = append(out, ef)
+		}
+	}
+}
+
+// writeEntry writes an archive entry below dir, refusing names that would
+// escape it and content beyond the bytes left in b. Sizes declared by the
+// archive are not trusted; only bytes actually written count.
+func writeEntry(dir, name string, perm fs.FileMode, r io.Reader, b *budget) (extractedFile, error) {
+	p, err := pathsafe.Join(dir, name)
+	if err != nil {
+		return extractedFile{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return extractedFile{}, err
+	}
+	w, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm|0o200)
+	if err != nil {
+		return extractedFile{}, err
+	}
+	n, err := io.Copy(w, io.LimitReader(r, b.bytes+1))
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > b.bytes {
+		err = fmt.Errorf("%w: more than the allowed extracted size", ErrExtractLimit)
+	}
+	b.bytes -= n
+	return extractedFile{path: p, size: n}, err
+}
@@ -0,0 +1,445 @@
+// Package postprocess moves, renames and extracts the payload of downloads
+// once the reconciler marks them Complete.
+package postprocess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/pathsafe"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+// Config tunes post-processing.
+type Config struct {
+	// Categories resolves PostProcess.Category, when PostProcess.Dest is
+	// empty, to the target path of a category managed through the API.
+	Categories repo.CategoryRepo
+	// CategoryDirs maps a category name to the absolute directory its
+	// downloads are moved into when it is not in Categories.
+	CategoryDirs map[string]string
+	// MaxExtractBytes and MaxExtractFiles bound what the archives of one
+	// download may expand to, so an archive bomb cannot fill the disk.
+	// Zero uses DefaultMaxExtractBytes and DefaultMaxExtractFiles.
+	MaxExtractBytes int64
+	MaxExtractFiles int
+}
+
+// Default extraction limits.
+const (
+	DefaultMaxExtractBytes = 64 << 30
+	DefaultMaxExtractFiles = 10000
+)
+
+func (c Config) withDefaults() Config {
+	if c.MaxExtractBytes <= 0 {
+		c.MaxExtractBytes = DefaultMaxExtractBytes
+	}
+	if c.MaxExtractFiles <= 0 {
+		c.MaxExtractFiles = DefaultMaxExtractFiles
+	}
+	return c
+}
+
+// ParseCategoryDirs parses a comma-separated list of name=/abs/dir pairs,
+// as accepted by TORRUS_CATEGORY_DIRS.
+func ParseCategoryDirs(s string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, dir, ok := strings.Cut(pair, "=")
+		name, dir = strings.TrimSpace(name), strings.TrimSpace(dir)
+		if !ok || name == "" || !filepath.IsAbs(dir) {
+			return nil, fmt.Errorf("category dir %q: want name=/absolute/dir", pair)
+		}
+		out[name] = filepath.Clean(dir)
+	}
+	return out, nil
+}
+
+// CheckCategoryDirs reports an error wrapping pathsafe.ErrNotAllowed for the
+// first category directory outside roots.
+func CheckCategoryDirs(dirs map[string]string, roots pathsafe.Roots) error {
+	names := make([]string, 0, len(dirs))
+	for name := range dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := roots.Check(dirs[name]); err != nil {
+			return fmt.Errorf("category dir %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Processor runs the post-processing pipeline of completed downloads.
+// StatusChanged never blocks the caller; the file work happens on a
+// background goroutine started by Start.
+type Processor struct {
+	repo repo.DownloadRepo
+	log  *slog.Logger
+	cfg  Config
+	now  func() time.Time
+
+	jobs chan string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New constructs a Processor backed by the given repository.
+func New(log *slog.Logger, r repo.DownloadRepo, cfg Config) *Processor {
+	if log == nil {
+		log = slog.Default()
+	}
+	return &Processor{
+		repo: r,
+		log:  log,
+		cfg:  cfg.withDefaults(),
+		now:  time.Now,
+		jobs: make(chan string, 256),
+	}
+}
+
+// Start launches the worker and re-queues completed downloads whose
+// post-processing did not finish before a restart.
+func (p *Processor) Start(ctx context.Context) {
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	p.wg.Add(1)
+	go p.worker()
+	dls, err := p.repo.List(p.ctx)
+	if err != nil {
+		p.log.Error("postprocess: list downloads", "err", err)
+		return
+	}
+	for _, dl := range dls {
+		if pending(dl) {
+			p.enqueue(dl.ID)
+		}
+	}
+}
+
+// Stop terminates background work. Unfinished runs are retried by the next
+// Start.
+func (p *Processor) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+		p.wg.Wait()
+	}
+}
+
+// StatusChanged implements reconciler.StatusListener.
+func (p *Processor) StatusChanged(ctx context.Context, from data.DownloadStatus, dl *data.Download) {
+	if pending(dl) {
+		p.enqueue(dl.ID)
+	}
+}
+
+func (p *Processor) enqueue(id string) {
+	select {
+	case p.jobs <- id:
+	default:
+		p.log.Warn("postprocess: queue full, dropping job", "id", id)
+	}
+}
+
+// pending reports whether dl is Complete and still has post-processing to do.
+func pending(dl *data.Download) bool {
+	if dl == nil || dl.Status != data.StatusComplete || dl.PostProcess == nil {
+		return false
+	}
+	s := dl.PostProcessStatus
+	return s == nil || s.State == data.PostProcessPending || s.State == data.PostProcessRunning
+}
+
+func (p *Processor) worker() {
+	defer p.wg.Done()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case id := <-p.jobs:
+			p.Process(p.ctx, id)
+		}
+	}
+}
+
+// Process runs the pipeline for download id, if it is pending, and records
+// the outcome on the download.
+func (p *Processor) Process(ctx context.Context, id string) {
+	dl, err := p.repo.Get(ctx, id)
+	if err != nil || !pending(dl) {
+		return
+	}
+	if _, err := p.repo.Update(ctx, id, func(d *data.Download) error {
+		d.PostProcessStatus = &data.PostProcessStatus{State: data.PostProcessRunning, UpdatedAt: p.now()}
+		return nil
+	}); err != nil {
+		p.log.Error("postprocess: mark running", "id", id, "err", err)
+		return
+	}
+
+	r := &run{dl: dl, cfg: p.cfg}
+	runErr := r.execute(ctx)
+	status := &data.PostProcessStatus{State: data.PostProcessDone, Steps: r.steps, UpdatedAt: p.now()}
+	if runErr != nil {
+		status.State = data.PostProcessFailed
+		status.Error = runErr.Error()
+	}
+	_, err = p.repo.Update(ctx, id, func(d *data.Download) error {
+		// Once the payload has moved the download follows it, even if a
+		// later step failed.
+		if r.dest != "" {
+			if len(d.Files) == 1 && d.Files[0].Path == r.srcName {
+				d.Files[0].Path = r.dstName
+			}
+			d.Files = append(d.Files, r.extracted...)
+			d.TargetPath, d.Name = r.dest, r.dstName
+		}
+		d.PostProcessStatus = status
+		return nil
+	})
+	if err != nil && r.dest != "" {
+		// The download could not follow its payload, e.g. because another
+		// download already has its source and new target path. Put the
+		// payload back so the download stays accurate.
+		p.log.Error("postprocess: record result; rolling back", "id", id, "err", err)
+		runErr = fmt.Errorf("record result: %w", err)
+		if uerr := r.undo(); uerr != nil {
+			runErr = fmt.Errorf("%w; roll back: %v", runErr, uerr)
+		}
+		status = &data.PostProcessStatus{State: data.PostProcessFailed, Steps: r.steps, Error: runErr.Error(), UpdatedAt: p.now()}
+		_, err = p.repo.Update(ctx, id, func(d *data.Download) error {
+			d.PostProcessStatus = status
+			return nil
+		})
+	}
+	if err != nil {
+		p.log.Error("postprocess: record result", "id", id, "err", err)
+		return
+	}
+	if runErr != nil {
+		p.log.Warn("postprocess: failed", "id", id, "err", runErr)
+		return
+	}
+	p.log.Info("postprocess: done", "id", id, "dest", filepath.Join(r.dest, r.dstName))
+}
+
+// run holds the state of one pipeline execution.
+type run struct {
+	dl  *data.Download
+	cfg Config
+
+	steps     []data.PostProcessStep
+	dest      string
+	srcName   string
+	dstName   string
+	extracted []data.DownloadFile
+
+	// src and dst are the payload before and after placement, and placed
+	// how it got there ("" when it stayed in place); extractDirs are the
+	// directories extraction created. undo uses them.
+	src, dst    string
+	placed      data.PostProcessMode
+	extractDirs []string
+}
+
+// undo reverts a run: it removes what extraction created and puts the
+// payload back where it was.
+func (r *run) undo() error {
+	for _, dir := range r.extractDirs {
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	r.extractDirs = nil
+	switch r.placed {
+	case data.PostProcessHardlink:
+		return os.RemoveAll(r.dst)
+	case data.PostProcessMove:
+		return move(r.dst, r.src)
+	}
+	return nil
+}
+
+func (r *run) step(name string, fn func() (string, error)) error {
+	detail, err := fn()
+	s := data.PostProcessStep{Name: name, State: data.PostProcessDone, Detail: detail}
+	if err != nil {
+		s.State, s.Error = data.PostProcessFailed, err.Error()
+	}
+	r.steps = append(r.steps, s)
+	return err
+}
+
+func (r *run) execute(ctx context.Context) error {
+	pp := r.dl.PostProcess
+	src, err := r.source()
+	if err != nil {
+		return err
+	}
+	dest, err := r.destDir(ctx)
+	if err != nil {
+		return err
+	}
+	name, err := r.rename()
+	if err != nil {
+		return err
+	}
+	dst, err := pathsafe.Join(dest, name)
+	if err != nil {
+		return err
+	}
+	// A download that already landed in its destination, typically its
+	// category's target path, stays where it is.
+	if dst != filepath.Clean(src) {
+		if _, err := os.Lstat(dst); err == nil {
+			return fmt.Errorf("destination %s already exists", dst)
+		}
+		if err := os.MkdirAll(dest, 0o755); err != nil {
+			return err
+		}
+		mode := pp.Mode
+		if mode == "" {
+			mode = data.PostProcessMove
+		}
+		if err := r.step(string(mode), func() (string, error) {
+			if mode == data.PostProcessHardlink {
+				return dst, linkTree(src, dst)
+			}
+			return dst, move(src, dst)
+		}); err != nil {
+			return err
+		}
+		r.placed = mode
+	}
+	r.src, r.dst, r.dest = src, dst, dest
+	if pp.Rename != "" {
+		_ = r.step("rename", func() (string, error) { return r.srcName + " -> " + name, nil })
+	}
+	if pp.Extract {
+		if err := r.step("extract", func() (string, error) {
+			files, dirs, err := extractAll(dst, &budget{bytes: r.cfg.MaxExtractBytes, files: r.cfg.MaxExtractFiles})
+			r.extractDirs = dirs
+			for _, f := range files {
+				rel, _ := filepath.Rel(dest, f.path)
+				r.extracted = append(r.extracted, data.DownloadFile{Path: rel, Length: f.size, Completed: f.size, Selected: true})
+			}
+			return fmt.Sprintf("%d files", len(files)), err
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// source resolves the payload of the download: the file or directory named
+// after it directly under TargetPath.
+func (r *run) source() (string, error) {
+	dl := r.dl
+	name := dl.Name
+	if dl.Options != nil && dl.Options.Out != "" {
+		name = dl.Options.Out
+	}
+	if name == "" && len(dl.Files) == 1 {
+		name = dl.Files[0].Path
+	}
+	if !pathsafe.ValidName(name) {
+		return "", errors.New("download payload name is not known")
+	}
+	if dl.TargetPath == "" {
+		return "", errors.New("download has no target path")
+	}
+	src, err := pathsafe.Join(dl.TargetPath, name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Lstat(src); err != nil {
+		return "", err
+	}
+	r.srcName = name
+	return src, nil
+}
+
+// destDir returns the destination directory. Nothing is ever placed
+// directly in the filesystem root, which is reachable when no allowed roots
+// are configured.
+func (r *run) destDir(ctx context.Context) (string, error) {
+	dir, err := r.resolveDest(ctx)
+	if err != nil {
+		return "", err
+	}
+	if dir == string(filepath.Separator) {
+		return "", errors.New("destination must not be the filesystem root")
+	}
+	return dir, nil
+}
+
+// resolveDest returns PostProcess.Dest or the directory of its category:
+// the target path of an API-managed category, else its TORRUS_CATEGORY_DIRS
+// entry.
+func (r *run) resolveDest(ctx context.Context) (string, error) {
+	pp := r.dl.PostProcess
+	if pp.Dest != "" {
+		return filepath.Clean(pp.Dest), nil
+	}
+	if r.cfg.Categories != nil {
+		c, err := r.cfg.Categories.GetCategory(ctx, pp.Category)
+		switch {
+		case err == nil:
+			return filepath.Clean(c.TargetPath), nil
+		case !errors.Is(err, data.ErrCategoryNotFound):
+			return "", fmt.Errorf("category %q: %w", pp.Category, err)
+		}
+	}
+	dir, ok := r.cfg.CategoryDirs[pp.Category]
+	if !ok {
+		return "", fmt.Errorf("no directory configured for category %q", pp.Category)
+	}
+	return dir, nil
+}
+
+// rename renders the Rename template, or keeps the payload name.
+func (r *run) rename() (string, error) {
+	pp := r.dl.PostProcess
+	if pp.Rename == "" {
+		r.dstName = r.srcName
+		return r.srcName, nil
+	}
+	tmpl, err := template.New("rename").Option("missingkey=error").Parse(pp.Rename)
+	if err != nil {
+		return "", err
+	}
+	ext := filepath.Ext(r.srcName)
+	var b strings.Builder
+	if err := tmpl.Execute(&b, map[string]string{
+		"ID":       r.dl.ID,
+		"Name":     r.srcName,
+		"Base":     strings.TrimSuffix(r.srcName, ext),
+		"Ext":      ext,
+		"Category": pp.Category,
+	}); err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(b.String())
+	if !pathsafe.ValidName(name) {
+		return "", fmt.Errorf("rename produced an invalid name %q", name)
+	}
+	r.dstName = name
+	return name, nil
+}
@@ -0,0 +1,310 @@
+package postprocess
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/pathsafe"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+func newTestProcessor(t *testing.T, cfg Config) (*Processor, repo.DownloadRepo) {
+	t.Helper()
+	rpo := repo.NewInMemoryDownloadRepo()
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), rpo, cfg), rpo
+}
+
+func writeFile(t *testing.T, p, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeZip(t *testing.T, p string, entries map[string]string) {
+	t.Helper()
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+}
+
+// TestProcessMovesRenamesAndExtracts runs the full pipeline for a category
+// destination and checks the download now points at the final location.
+func TestProcessMovesRenamesAndExtracts(t *testing.T) {
+	src, lib := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(src, "Show.S01", "ep1.mkv"), "video")
+	writeZip(t, filepath.Join(src, "Show.S01", "subs.zip"), map[string]string{"en.srt": "hello"})
+
+	p, rpo := newTestProcessor(t, Config{CategoryDirs: map[string]string{"tv": lib}})
+	ctx := context.Background()
+	dl, err := rpo.Add(ctx, &data.Download{
+		Source: "magnet:?xt=urn:btih:abc", TargetPath: src, Name: "Show.S01", Status: data.StatusComplete,
+		PostProcess: &data.PostProcess{Category: "tv", Rename: "{{.Category}}-{{.Name}}", Extract: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Process(ctx, dl.ID)
+
+	got, _ := rpo.Get(ctx, dl.ID)
+	s := got.PostProcessStatus
+	if s == nil || s.State != data.PostProcessDone || len(s.Steps) != 3 {
+		t.Fatalf("unexpected status: %#v", s)
+	}
+	if got.TargetPath != lib || got.Name != "tv-Show.S01" {
+		t.Fatalf("download not relocated: %q %q", got.TargetPath, got.Name)
+	}
+	if _, err := os.Stat(filepath.Join(src, "Show.S01")); !os.IsNotExist(err) {
+		t.Fatalf("source still present: %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(lib, "tv-Show.S01", "subs", "en.srt"))
+	if err != nil || string(b) != "hello" {
+		t.Fatalf("archive not extracted: %q %v", b, err)
+	}
+	if n := len(got.Files); n != 1 || got.Files[0].Path != filepath.Join("tv-Show.S01", "subs", "en.srt") {
+		t.Fatalf("extracted files not recorded: %#v", got.Files)
+	}
+
+	// A finished run is not repeated.
+	p.Process(ctx, dl.ID)
+	again, _ := rpo.Get(ctx, dl.ID)
+	if !again.PostProcessStatus.UpdatedAt.Equal(s.UpdatedAt) {
+		t.Fatal("post-processing ran twice")
+	}
+}
+
+// TestProcessCategoryFromRepo resolves categories created through the API
+// to their target path, ahead of TORRUS_CATEGORY_DIRS, and leaves payloads
+// already there in place.
+func TestProcessCategoryFromRepo(t *testing.T) {
+	src, lib, env := t.TempDir(), t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(src, "a.mkv"), "video")
+	writeFile(t, filepath.Join(lib, "b.mkv"), "video")
+
+	cats := repo.NewInMemoryCategoryRepo()
+	ctx := context.Background()
+	if _, err := cats.AddCategory(ctx, &data.Category{Name: "tv", TargetPath: lib}); err != nil {
+		t.Fatal(err)
+	}
+	p, rpo := newTestProcessor(t, Config{Categories: cats, CategoryDirs: map[string]string{"tv": env}})
+	moved, _ := rpo.Add(ctx, &data.Download{
+		Source: "https://example.com/a.mkv", TargetPath: src, Name: "a.mkv", Status: data.StatusComplete,
+		PostProcess: &data.PostProcess{Category: "tv"},
+	})
+	inPlace, _ := rpo.Add(ctx, &data.Download{
+		Source: "https://example.com/b.mkv", TargetPath: lib, Name: "b.mkv", Status: data.StatusComplete,
+		PostProcess: &data.PostProcess{Category: "tv"},
+	})
+	for _, dl := range []*data.Download{moved, inPlace} {
+		p.Process(ctx, dl.ID)
+		got, _ := rpo.Get(ctx, dl.ID)
+		if s := got.PostProcessStatus; s == nil || s.State != data.PostProcessDone || got.TargetPath != lib {
+			t.Fatalf("%s: status %#v, target %q", dl.Name, got.PostProcessStatus, got.TargetPath)
+		}
+		if _, err := os.Stat(filepath.Join(lib, dl.Name)); err != nil {
+			t.Fatalf("%s: %v", dl.Name, err)
+		}
+	}
+}
+
+// TestProcessHardlinkKeepsSource ensures hardlink mode leaves the original
+// payload in place.
+func TestProcessHardlinkKeepsSource(t *testing.T) {
+	src, dest := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(src, "file.iso"), "data")
+
+	p, rpo := newTestProcessor(t, Config{})
+	ctx := context.Background()
+	dl, _ := rpo.Add(ctx, &data.Download{
+		Source: "https://example.com/file.iso", TargetPath: src, Name: "file.iso", Status: data.StatusComplete,
+		Files:       []data.DownloadFile{{Path: "file.iso", Index: 1, Selected: true}},
+		PostProcess: &data.PostProcess{Dest: dest, Mode: data.PostProcessHardlink, Rename: "{{.Base}}-{{.ID}}{{.Ext}}"},
+	})
+	p.Process(ctx, dl.ID)
+
+	got, _ := rpo.Get(ctx, dl.ID)
+	want := "file-" + dl.ID + ".iso"
+	if got.PostProcessStatus.State != data.PostProcessDone || got.Name != want || got.Files[0].Path != want {
+		t.Fatalf("unexpected result: %#v %#v", got.PostProcessStatus, got.Files)
+	}
+	for _, p := range []string{filepath.Join(src, "file.iso"), filepath.Join(dest, want)} {
+		if _, err := os.Stat(p); err != nil {
+			t.Fatalf("missing %s: %v", p, err)
+		}
+	}
+}
+
+// TestProcessFailures records failures on the download without touching
+// files outside the expected directories.
+func TestProcessFailures(t *testing.T) {
+	cases := map[string]struct {
+		pp        *data.PostProcess
+		payload   string
+		setup     func(t *testing.T, src, dest string)
+		wantMoved bool
+		cfg       Config
+	}{
+		"unknown category": {pp: &data.PostProcess{Category: "music"}},
+		"rename escapes":   {pp: &data.PostProcess{Rename: "../{{.Name}}"}},
+		"dest exists": {pp: &data.PostProcess{}, setup: func(t *testing.T, src, dest string) {
+			writeFile(t, filepath.Join(dest, "a.bin"), "old")
+		}},
+		"zip slip": {pp: &data.PostProcess{Extract: true}, payload: "a.zip", wantMoved: true, setup: func(t *testing.T, src, dest string) {
+			writeZip(t, filepath.Join(src, "a.zip"), map[string]string{"../../evil": "x"})
+		}},
+		"archive too large": {pp: &data.PostProcess{Extract: true}, payload: "b.zip", wantMoved: true, cfg: Config{MaxExtractBytes: 4}, setup: func(t *testing.T, src, dest string) {
+			writeZip(t, filepath.Join(src, "b.zip"), map[string]string{"big": "12345"})
+		}},
+		"too many entries": {pp: &data.PostProcess{Extract: true}, payload: "b.zip", wantMoved: true, cfg: Config{MaxExtractFiles: 2}, setup: func(t *testing.T, src, dest string) {
+			writeZip(t, filepath.Join(src, "b.zip"), map[string]string{"x": "1", "y": "2", "z": "3"})
+		}},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			src, dest := t.TempDir(), t.TempDir()
+			if c.payload == "" {
+				c.payload = "a.bin"
+				writeFile(t, filepath.Join(src, c.payload), "data")
+			}
+			if c.pp.Category == "" {
+				c.pp.Dest = dest
+			}
+			if c.setup != nil {
+				c.setup(t, src, dest)
+			}
+			p, rpo := newTestProcessor(t, c.cfg)
+			ctx := context.Background()
+			dl, _ := rpo.Add(ctx, &data.Download{Source: "https://example.com/" + c.payload, TargetPath: src, Name: c.payload, Status: data.StatusComplete, PostProcess: c.pp})
+			p.Process(ctx, dl.ID)
+
+			got, _ := rpo.Get(ctx, dl.ID)
+			if got.PostProcessStatus == nil || got.PostProcessStatus.State != data.PostProcessFailed || got.PostProcessStatus.Error == "" {
+				t.Fatalf("want failed status, got %#v", got.PostProcessStatus)
+			}
+			want := src
+			if c.wantMoved {
+				want = dest
+			}
+			if got.TargetPath != want {
+				t.Fatalf("targetPath = %q, want %q", got.TargetPath, want)
+			}
+			if _, err := os.Stat(filepath.Join(filepath.Dir(dest), "evil")); err == nil {
+				t.Fatal("archive entry escaped the extraction directory")
+			}
+			if _, err := os.Stat(filepath.Join(dest, "b")); err == nil {
+				t.Fatal("failed extraction left its directory behind")
+			}
+		})
+	}
+}
+
+// conflictRepo fails writes that relocate a download, as a fingerprint
+// conflict would.
+type conflictRepo struct{ repo.DownloadRepo }
+
+func (r conflictRepo) Update(ctx context.Context, id string, mutate func(*data.Download) error) (*data.Download, error) {
+	return r.DownloadRepo.Update(ctx, id, func(d *data.Download) error {
+		target := d.TargetPath
+		if err := mutate(d); err != nil {
+			return err
+		}
+		if d.TargetPath != target {
+			return data.ErrConflict
+		}
+		return nil
+	})
+}
+
+// TestProcessRollsBackWhenNotRecorded puts the payload back, and removes
+// what extraction created, when the download cannot follow it.
+func TestProcessRollsBackWhenNotRecorded(t *testing.T) {
+	for _, mode := range []data.PostProcessMode{data.PostProcessMove, data.PostProcessHardlink} {
+		t.Run(string(mode), func(t *testing.T) {
+			src, dest := t.TempDir(), t.TempDir()
+			writeZip(t, filepath.Join(src, "a.zip"), map[string]string{"f": "x"})
+			mem := repo.NewInMemoryDownloadRepo()
+			p := New(slog.New(slog.NewTextHandler(io.Discard, nil)), conflictRepo{mem}, Config{})
+			ctx := context.Background()
+			dl, _ := mem.Add(ctx, &data.Download{
+				Source: "https://example.com/a.zip", TargetPath: src, Name: "a.zip", Status: data.StatusComplete,
+				PostProcess: &data.PostProcess{Dest: dest, Mode: mode, Extract: true},
+			})
+			p.Process(ctx, dl.ID)
+
+			got, _ := mem.Get(ctx, dl.ID)
+			if s := got.PostProcessStatus; s == nil || s.State != data.PostProcessFailed || got.TargetPath != src {
+				t.Fatalf("status %#v, target %q", got.PostProcessStatus, got.TargetPath)
+			}
+			if _, err := os.Stat(filepath.Join(src, "a.zip")); err != nil {
+				t.Fatalf("payload not back in place: %v", err)
+			}
+			if entries, _ := os.ReadDir(dest); len(entries) != 0 {
+				t.Fatalf("destination not cleaned up: %v", entries)
+			}
+		})
+	}
+}
+
+// TestStatusChangedQueuesCompleteDownloads ensures only completed downloads
+// with post-processing are picked up from reconciler notifications.
+func TestStatusChangedQueuesCompleteDownloads(t *testing.T) {
+	src, dest := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(src, "a.bin"), "data")
+	p, rpo := newTestProcessor(t, Config{})
+	ctx := context.Background()
+	dl, _ := rpo.Add(ctx, &data.Download{Source: "https://example.com/a.bin", TargetPath: src, Name: "a.bin", Status: data.StatusComplete, PostProcess: &data.PostProcess{Dest: dest}})
+	p.Start(ctx)
+	defer p.Stop()
+
+	p.StatusChanged(ctx, data.StatusActive, &data.Download{ID: "other", Status: data.StatusComplete})
+	p.StatusChanged(ctx, data.StatusActive, dl)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		got, _ := rpo.Get(ctx, dl.ID)
+		if s := got.PostProcessStatus; s != nil && s.State == data.PostProcessDone {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("download was not post-processed")
+}
+
+func TestCheckCategoryDirs(t *testing.T) {
+	allowed := t.TempDir()
+	roots, err := pathsafe.ParseRoots(allowed)
+	if err != nil {
+		t.Fatalf("roots: %v", err)
+	}
+	if err := CheckCategoryDirs(map[string]string{"tv": filepath.Join(allowed, "tv")}, roots); err != nil {
+		t.Fatalf("dir under root: %v", err)
+	}
+	err = CheckCategoryDirs(map[string]string{"tv": filepath.Join(allowed, "tv"), "etc": "/etc"}, roots)
+	if !errors.Is(err, pathsafe.ErrNotAllowed) {
+		t.Fatalf("want ErrNotAllowed, got %v", err)
+	}
+}
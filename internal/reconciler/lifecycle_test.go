@@ -0,0 +1,126 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/downloader"
+	"github.com/tinoosan/torrus/internal/history"
+	"github.com/tinoosan/torrus/internal/lifecycle"
+	"github.com/tinoosan/torrus/internal/repo"
+	"github.com/tinoosan/torrus/internal/service"
+)
+
+// gidDownloader hands out a new GID on every start.
+type gidDownloader struct{ n int }
+
+func (g *gidDownloader) Start(context.Context, *data.Download) (string, error) {
+	g.n++
+	return fmt.Sprintf("gid-%d", g.n), nil
+}
+func (g *gidDownloader) Pause(context.Context, *data.Download) error        { return nil }
+func (g *gidDownloader) Resume(context.Context, *data.Download) error       { return nil }
+func (g *gidDownloader) Cancel(context.Context, *data.Download) error       { return nil }
+func (g *gidDownloader) Delete(context.Context, *data.Download, bool) error { return nil }
+
+// step is either a downloader event or, when desired is set, a request to
+// the service.
+type step struct {
+	event   downloader.EventType
+	stale   bool
+	desired data.DownloadStatus
+}
+
+type script []step
+
+var scriptEvents = []downloader.EventType{
+	downloader.EventStart, downloader.EventPaused, downloader.EventSeeding,
+	downloader.EventComplete, downloader.EventFailed, downloader.EventCancelled,
+}
+
+var scriptRequests = []data.DownloadStatus{data.StatusActive, data.StatusResume, data.StatusPaused, data.StatusCancelled}
+
+func (script) Generate(r *rand.Rand, size int) reflect.Value {
+	s := make(script, size)
+	for i := range s {
+		if r.Intn(3) == 0 {
+			s[i].desired = scriptRequests[r.Intn(len(scriptRequests))]
+		} else {
+			s[i].event = scriptEvents[r.Intn(len(scriptEvents))]
+			s[i].stale = r.Intn(5) == 0
+		}
+	}
+	return reflect.ValueOf(s)
+}
+
+// TestRandomEventSequences drives a download with random downloader events
+// (some from an old GID) interleaved with user requests, and checks that
+// every recorded transition is legal, that requests are rejected exactly
+// when the state machine forbids them, and that final statuses stick.
+func TestRandomEventSequences(t *testing.T) {
+	ctx := context.Background()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	property := func(s script) bool {
+		hist := repo.NewInMemoryHistoryRepo()
+		rpo := history.Wrap(log, repo.NewInMemoryDownloadRepo(), hist)
+		svc := service.NewDownload(rpo, &gidDownloader{})
+		r := New(log, rpo, nil)
+
+		dl, _, err := svc.Add(ctx, &data.Download{Source: "https://example.com/f", TargetPath: "/tmp", DesiredStatus: data.StatusPaused})
+		if err != nil {
+			t.Logf("add: %v", err)
+			return false
+		}
+		for _, st := range s {
+			before, err := rpo.Get(ctx, dl.ID)
+			if err != nil {
+				t.Logf("get: %v", err)
+				return false
+			}
+			if st.desired != "" {
+				_, err := svc.UpdateDesiredStatus(ctx, dl.ID, st.desired)
+				if want := lifecycle.Request(before.Status, st.desired); (err == nil) != (want == nil) ||
+					(err != nil && !errors.Is(err, lifecycle.ErrIllegalTransition)) {
+					t.Logf("request %s in %s: err = %v, state machine = %v", st.desired, before.Status, err, want)
+					return false
+				}
+			} else {
+				gid := before.GID
+				if st.stale {
+					gid = "old"
+				}
+				r.handle(downloader.Event{ID: dl.ID, GID: gid, Type: st.event})
+			}
+			after, _ := rpo.Get(ctx, dl.ID)
+			if lifecycle.Final(before.Status) && after.Status != before.Status {
+				t.Logf("step %+v left final status %s for %s", st, before.Status, after.Status)
+				return false
+			}
+		}
+
+		trs, err := hist.ListTransitions(ctx, dl.ID)
+		if err != nil {
+			t.Logf("history: %v", err)
+			return false
+		}
+		for _, tr := range trs[1:] {
+			if err := lifecycle.Transition(tr.From, tr.To); err != nil {
+				t.Logf("recorded %+v: %v", *tr, err)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 300}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,13 +2,19 @@ package reconciler
 
 import (
     "context"
+    "errors"
     "log/slog"
+    "slices"
     "sync"
     "strings"
+    "time"
 
     "github.com/google/uuid"
     "github.com/tinoosan/torrus/internal/data"
+    "github.com/tinoosan/torrus/internal/history"
+    "github.com/tinoosan/torrus/internal/lifecycle"
     "github.com/tinoosan/torrus/internal/downloader"
+    "github.com/tinoosan/torrus/internal/events"
     "github.com/tinoosan/torrus/internal/metrics"
     "github.com/tinoosan/torrus/internal/repo"
 )
@@ -18,9 +24,25 @@ type Reconciler struct {
 	repo   repo.DownloadRepo
 	events <-chan downloader.Event
 	log    *slog.Logger
+	hub    *events.Hub
 	ctx    context.Context
 	cancel context.CancelFunc
 
+	// files applies a download's file filter once its file list is known;
+	// nil when the downloader cannot select files.
+	files downloader.FileSelector
+
+	// listeners are notified after a reconciled status change is persisted.
+	listeners []StatusListener
+
+	// progressEvery throttles how often progress snapshots are persisted per
+	// download; lastProgress records the last write time for each ID and
+	// doneProgress the IDs whose last write already reported completion.
+	progressEvery time.Duration
+	lastProgress  map[string]time.Time
+	doneProgress  map[string]bool
+	now           func() time.Time
+
 	stop chan struct{}
 	wg   sync.WaitGroup
 }
@@ -31,7 +53,50 @@ func New(log *slog.Logger, repo repo.DownloadRepo, events <-chan downloader.Even
 	if log == nil {
 		log = slog.Default()
 	}
-	return &Reconciler{repo: repo, events: events, log: log, ctx: context.Background()}
+	return &Reconciler{
+		repo:          repo,
+		events:        events,
+		log:           log,
+		ctx:           context.Background(),
+		progressEvery: DefaultProgressInterval,
+		lastProgress:  make(map[string]time.Time),
+		doneProgress:  make(map[string]bool),
+		now:           time.Now,
+	}
+}
+
+// DefaultProgressInterval is the minimum time between persisted progress
+// snapshots for a single download.
+const DefaultProgressInterval = 5 * time.Second
+
+// SetProgressInterval overrides how often progress is persisted per download.
+// A zero or negative interval persists every progress event.
+func (r *Reconciler) SetProgressInterval(d time.Duration) {
+	r.progressEvery = d
+}
+
+// SetHub wires an event hub that receives every event after it has been
+// applied to the repository. Publishing never blocks the reconciler.
+func (r *Reconciler) SetHub(h *events.Hub) {
+	r.hub = h
+}
+
+// SetFileSelector wires the downloader capability used to apply file
+// filters when a download's file list arrives.
+func (r *Reconciler) SetFileSelector(fs downloader.FileSelector) {
+	r.files = fs
+}
+
+// StatusListener is notified after the reconciler persists a status change.
+// Implementations must not block; dl is a snapshot owned by the listener.
+type StatusListener interface {
+	StatusChanged(ctx context.Context, from data.DownloadStatus, dl *data.Download)
+}
+
+// AddListener registers l for status change notifications. It must be called
+// before Run.
+func (r *Reconciler) AddListener(l StatusListener) {
+	r.listeners = append(r.listeners, l)
 }
 
 // Run starts the reconciliation loop.
@@ -53,6 +118,9 @@ func (r *Reconciler) Run() {
 					return
 				}
 				r.handle(e)
+				if r.hub != nil {
+					r.hub.Publish(e)
+				}
 			}
 		}
 	}()
@@ -72,6 +140,7 @@ func (r *Reconciler) Stop() {
 func (r *Reconciler) handle(e downloader.Event) {
     // Record event type for observability
     metrics.DownloadEvents.WithLabelValues(strings.ToLower(string(e.Type))).Inc()
+    ctx := history.WithEvent(history.WithCause(r.ctx, data.CauseReconciler), string(e.Type))
     var (
         status        data.DownloadStatus
         checkTerminal bool
@@ -88,7 +157,29 @@ func (r *Reconciler) handle(e downloader.Event) {
 			return
 		}
 		status = data.StatusActive
+	case downloader.EventSeeding:
+		dl, err := r.repo.Get(r.ctx, e.ID)
+		if err != nil {
+			r.log.Error("get", "id", e.ID, "err", err)
+			return
+		}
+		if dl.GID != "" && dl.GID != e.GID {
+			r.log.Info("ignoring stale seeding event", "id", e.ID, "gid", dl.GID, "event_gid", e.GID)
+			return
+		}
+		status = data.StatusSeeding
 	case downloader.EventPaused:
+		dl, err := r.repo.Get(r.ctx, e.ID)
+		if err != nil {
+			r.log.Error("get", "id", e.ID, "err", err)
+			return
+		}
+		// A queued download that should run was paused to wait for the
+		// scheduler (e.g. held for disk space); it stays Queued.
+		if dl.Status == data.StatusQueued && (dl.DesiredStatus == data.StatusActive || dl.DesiredStatus == data.StatusResume) {
+			r.log.Info("ignoring pause of held download", "id", e.ID)
+			return
+		}
 		status = data.StatusPaused
 	case downloader.EventCancelled:
 		status = data.StatusCancelled
@@ -103,7 +194,7 @@ func (r *Reconciler) handle(e downloader.Event) {
 		if e.NewGID == "" {
 			return
 		}
-		_, err := r.repo.Update(r.ctx, e.ID, func(dl *data.Download) error {
+		_, err := r.repo.Update(ctx, e.ID, func(dl *data.Download) error {
 			dl.GID = e.NewGID
 			return nil
 		})
@@ -118,7 +209,7 @@ func (r *Reconciler) handle(e downloader.Event) {
 			return
 		}
 		if e.Meta.Name != nil {
-			_, err := r.repo.Update(r.ctx, e.ID, func(dl *data.Download) error {
+			_, err := r.repo.Update(ctx, e.ID, func(dl *data.Download) error {
 				dl.Name = *e.Meta.Name
 				return nil
 			})
@@ -130,7 +221,7 @@ func (r *Reconciler) handle(e downloader.Event) {
 		}
 		if e.Meta.Files != nil {
 			// Persist files list (read-only field populated by downloader)
-			_, err := r.repo.Update(r.ctx, e.ID, func(dl *data.Download) error {
+			_, err := r.repo.Update(ctx, e.ID, func(dl *data.Download) error {
 				// Replace the slice to reflect latest snapshot from downloader
 				dl.Files = make([]data.DownloadFile, len(*e.Meta.Files))
 				copy(dl.Files, *e.Meta.Files)
@@ -140,12 +231,14 @@ func (r *Reconciler) handle(e downloader.Event) {
 				r.log.Error("update files", "id", e.ID, "err", err)
 			} else {
 				r.log.Info("updated files", "id", e.ID, "count", len(*e.Meta.Files))
+				r.applyFileFilter(e.ID)
 			}
 		}
 		return
 	case downloader.EventProgress:
 		if e.Progress != nil {
 			r.log.Info("progress event", "id", e.ID, "completed", e.Progress.Completed, "total", e.Progress.Total, "speed", e.Progress.Speed)
+			r.persistProgress(e)
 		} else {
 			r.log.Info("progress event", "id", e.ID)
 		}
@@ -159,18 +252,63 @@ func (r *Reconciler) handle(e downloader.Event) {
 		return
 	}
 
-	_, err := r.repo.Update(r.ctx, e.ID, func(dl *data.Download) error {
+	var from data.DownloadStatus
+	updated, err := r.repo.Update(ctx, e.ID, func(dl *data.Download) error {
+		from = dl.Status
+		// Events that arrive after the download moved on, e.g. Complete
+		// after the user cancelled, must not take it back.
+		if err := lifecycle.Transition(dl.Status, status); err != nil {
+			return err
+		}
 		dl.Status = status
 		if checkTerminal {
 			dl.GID = ""
+			if dl.Progress != nil {
+				if status == data.StatusComplete && dl.Progress.Total > 0 {
+					dl.Progress.Completed = dl.Progress.Total
+				}
+				dl.Progress.Speed = 0
+				dl.Progress.UploadSpeed = 0
+				dl.Progress.ETASeconds = 0
+				dl.Progress.UpdatedAt = r.now()
+			}
+		}
+		switch status {
+		case data.StatusError:
+			// Record why it failed; the retrier decides whether to retry.
+			f := data.Failure{Code: data.FailureUnknown, Source: data.FailureSourceDownloader}
+			if e.Failure != nil {
+				f = *e.Failure
+			}
+			f.Timestamp = r.now()
+			dl.Failure = &f
+			if dl.RetryStatus != nil {
+				dl.RetryStatus.NextRetryAt = nil
+			}
+		case data.StatusActive:
+			dl.Failure = nil
 		}
 		return nil
 	})
+	if checkTerminal {
+		delete(r.lastProgress, e.ID)
+		delete(r.doneProgress, e.ID)
+	}
+	if errors.Is(err, lifecycle.ErrIllegalTransition) {
+		r.log.Info("ignoring stale event", "id", e.ID, "type", e.Type, "err", err)
+		metrics.IllegalTransitions.WithLabelValues(string(from), string(status)).Inc()
+		return
+	}
 	if err != nil {
 		r.log.Error("update", "id", e.ID, "status", status, "err", err)
 		return
 	}
 	r.log.Info("reconciled event", "id", e.ID, "type", e.Type)
+	if from != status {
+		for _, l := range r.listeners {
+			l.StatusChanged(r.ctx, from, updated.Clone())
+		}
+	}
 }
 
 func (r *Reconciler) checkTerminal(e downloader.Event) bool {
@@ -185,3 +323,78 @@ func (r *Reconciler) checkTerminal(e downloader.Event) bool {
 	}
 	return true
 }
+
+// persistProgress stores the progress snapshot carried by e, skipping writes
+// that arrive within progressEvery of the previous one for the same download.
+// The first snapshot reporting completion is always written; later ones
+// (uploads while seeding) are throttled like any other.
+func (r *Reconciler) persistProgress(e downloader.Event) {
+	p := e.Progress
+	now := r.now()
+	done := p.Total > 0 && p.Completed >= p.Total
+	if last, ok := r.lastProgress[e.ID]; ok && (!done || r.doneProgress[e.ID]) && now.Sub(last) < r.progressEvery {
+		return
+	}
+	var eta int64
+	if p.Speed > 0 && p.Total > p.Completed {
+		eta = (p.Total - p.Completed + p.Speed - 1) / p.Speed
+	}
+	var ratio float64
+	if p.Uploaded > 0 && p.Completed > 0 {
+		ratio = float64(p.Uploaded) / float64(p.Completed)
+	}
+	_, err := r.repo.Update(r.ctx, e.ID, func(dl *data.Download) error {
+		dl.Progress = &data.DownloadProgress{
+			Completed:   p.Completed,
+			Total:       p.Total,
+			Speed:       p.Speed,
+			ETASeconds:  eta,
+			Uploaded:    p.Uploaded,
+			UploadSpeed: p.UploadSpeed,
+			Ratio:       ratio,
+			UpdatedAt:   now,
+		}
+		return nil
+	})
+	if err != nil {
+		r.log.Error("update progress", "id", e.ID, "err", err)
+		return
+	}
+	r.lastProgress[e.ID] = now
+	r.doneProgress[e.ID] = done
+}
+
+// applyFileFilter selects the files of download id that pass its file
+// filter, if it has one and the selection differs from the downloader's.
+// A filter matching no file is left unapplied so the download is not
+// emptied by a typo.
+func (r *Reconciler) applyFileFilter(id string) {
+	if r.files == nil {
+		return
+	}
+	dl, err := r.repo.Get(r.ctx, id)
+	if err != nil || dl.FileFilter == nil || dl.GID == "" {
+		return
+	}
+	want := dl.FileFilter.Select(dl.Files)
+	if len(want) == 0 {
+		r.log.Warn("file filter matches no files", "id", id)
+		return
+	}
+	if slices.Equal(want, data.SelectedIndexes(dl.Files)) {
+		return
+	}
+	if err := r.files.SelectFiles(r.ctx, dl, want); err != nil {
+		r.log.Error("select files", "id", id, "err", err)
+		return
+	}
+	_, err = r.repo.Update(r.ctx, id, func(dl *data.Download) error {
+		data.MarkSelected(dl.Files, want)
+		return nil
+	})
+	if err != nil {
+		r.log.Error("update files", "id", id, "err", err)
+		return
+	}
+	r.log.Info("applied file filter", "id", id, "selected", len(want))
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"io"
 	"log/slog"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/tinoosan/torrus/internal/data"
 	"github.com/tinoosan/torrus/internal/downloader"
@@ -12,7 +14,7 @@ import (
 )
 
 // TestHandle ensures that terminal events update status and clear GID while
-// progress events do not mutate repository state.
+// progress events do not change status or GID.
 func TestHandle(t *testing.T) {
 	rpo := repo.NewInMemoryDownloadRepo()
 	// Seed repo with a download
@@ -42,12 +44,12 @@ func TestHandle(t *testing.T) {
 		t.Fatalf("gid not cleared on complete: %q", got.GID)
 	}
 
-	// reset gid and test failure case
-	_, err = rpo.Update(context.Background(), dl.ID, func(d *data.Download) error { d.GID = "g2"; return nil })
+	// restart it and test failure case; Complete itself is final
+	_, err = rpo.Update(context.Background(), dl.ID, func(d *data.Download) error { d.GID, d.Status = "g2", data.StatusActive; return nil })
 	if err != nil {
 		t.Fatalf("set gid: %v", err)
 	}
-	r.handle(downloader.Event{ID: dl.ID, GID: "g2", Type: downloader.EventFailed})
+	r.handle(downloader.Event{ID: dl.ID, GID: "g2", Type: downloader.EventFailed, Failure: &data.Failure{Code: data.FailureNetwork, DownloaderCode: 6, Message: "network problem", Source: data.FailureSourceDownloader}})
 	got, _ = rpo.Get(context.Background(), dl.ID)
 	if got.Status != data.StatusError {
 		t.Fatalf("failed status = %v", got.Status)
@@ -55,6 +57,22 @@ func TestHandle(t *testing.T) {
 	if got.GID != "" {
 		t.Fatalf("gid not cleared on failed: %q", got.GID)
 	}
+	if f := got.Failure; f == nil || f.Code != data.FailureNetwork || f.DownloaderCode != 6 || f.Message != "network problem" || f.Timestamp.IsZero() {
+		t.Fatalf("failure not recorded: %+v", got.Failure)
+	}
+
+	// Starting it again clears the failure.
+	_, err = rpo.Update(context.Background(), dl.ID, func(d *data.Download) error {
+		d.Status, d.DesiredStatus, d.GID = data.StatusQueued, data.StatusActive, "g3"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("requeue: %v", err)
+	}
+	r.handle(downloader.Event{ID: dl.ID, GID: "g3", Type: downloader.EventStart})
+	if got, _ = rpo.Get(context.Background(), dl.ID); got.Status != data.StatusActive || got.Failure != nil {
+		t.Fatalf("after start: status=%v failure=%+v", got.Status, got.Failure)
+	}
 }
 
 func TestHandleMetaUpdatesName(t *testing.T) {
@@ -83,6 +101,48 @@ func TestHandleMetaUpdatesFiles(t *testing.T) {
     }
 }
 
+type fakeSelector struct{ calls [][]int }
+
+func (f *fakeSelector) SelectFiles(ctx context.Context, d *data.Download, indexes []int) error {
+	f.calls = append(f.calls, indexes)
+	return nil
+}
+
+// TestHandleMetaAppliesFileFilter ensures a download's file filter is applied
+// once its file list arrives, and not re-applied when already in effect.
+func TestHandleMetaAppliesFileFilter(t *testing.T) {
+	rpo := repo.NewInMemoryDownloadRepo()
+	dl := &data.Download{Source: "s", TargetPath: "t", GID: "g", Status: data.StatusActive,
+		FileFilter: &data.FileFilter{Include: []string{"*.mkv"}, Exclude: []string{"*sample*"}}}
+	if _, err := rpo.Add(context.Background(), dl); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	sel := &fakeSelector{}
+	r := New(slog.New(slog.NewTextHandler(io.Discard, nil)), rpo, nil)
+	r.SetFileSelector(sel)
+	files := []data.DownloadFile{
+		{Path: "a.mkv", Index: 1, Selected: true},
+		{Path: "a.nfo", Index: 2, Selected: true},
+		{Path: "a-sample.mkv", Index: 3, Selected: true},
+		{Path: "b.mkv", Index: 4, Selected: true},
+	}
+	r.handle(downloader.Event{ID: dl.ID, GID: "g", Type: downloader.EventMeta, Meta: &downloader.Meta{Files: &files}})
+	if len(sel.calls) != 1 || !slices.Equal(sel.calls[0], []int{1, 4}) {
+		t.Fatalf("select calls = %v, want [[1 4]]", sel.calls)
+	}
+	got, _ := rpo.Get(context.Background(), dl.ID)
+	if want := []int{1, 4}; !slices.Equal(data.SelectedIndexes(got.Files), want) {
+		t.Fatalf("selected = %v, want %v", data.SelectedIndexes(got.Files), want)
+	}
+
+	// The backend now reports the filtered selection: nothing to change.
+	files = got.Files
+	r.handle(downloader.Event{ID: dl.ID, GID: "g", Type: downloader.EventMeta, Meta: &downloader.Meta{Files: &files}})
+	if len(sel.calls) != 1 {
+		t.Fatalf("filter re-applied: %v", sel.calls)
+	}
+}
+
 // TestHandleStartDoesNotOverrideStatus ensures that Start events do not
 // resurrect downloads that have been paused or cancelled by the user before
 // the downloader emitted the start signal.
@@ -155,3 +215,156 @@ func TestHandleIgnoresStaleTerminalEvents(t *testing.T) {
 		}
 	})
 }
+
+// TestHandleProgressPersistsThrottled ensures progress snapshots are stored
+// with a computed ETA, throttled per download, and finalized on completion.
+func TestHandleProgressPersistsThrottled(t *testing.T) {
+	rpo := repo.NewInMemoryDownloadRepo()
+	dl := &data.Download{Source: "s", TargetPath: "t", Status: data.StatusActive, GID: "g"}
+	if _, err := rpo.Add(context.Background(), dl); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	r := New(slog.New(slog.NewTextHandler(io.Discard, nil)), rpo, nil)
+	r.SetProgressInterval(5 * time.Second)
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return clock }
+
+	r.handle(downloader.Event{ID: dl.ID, GID: "g", Type: downloader.EventProgress, Progress: &downloader.Progress{Completed: 10, Total: 100, Speed: 30}})
+	got, _ := rpo.Get(context.Background(), dl.ID)
+	if got.Progress == nil || got.Progress.Completed != 10 || got.Progress.ETASeconds != 3 || !got.Progress.UpdatedAt.Equal(clock) {
+		t.Fatalf("progress not persisted: %#v", got.Progress)
+	}
+
+	// Within the interval: skipped.
+	clock = clock.Add(time.Second)
+	r.handle(downloader.Event{ID: dl.ID, GID: "g", Type: downloader.EventProgress, Progress: &downloader.Progress{Completed: 40, Total: 100, Speed: 30}})
+	got, _ = rpo.Get(context.Background(), dl.ID)
+	if got.Progress.Completed != 10 {
+		t.Fatalf("throttled progress was written: %#v", got.Progress)
+	}
+
+	// After the interval: written.
+	clock = clock.Add(5 * time.Second)
+	r.handle(downloader.Event{ID: dl.ID, GID: "g", Type: downloader.EventProgress, Progress: &downloader.Progress{Completed: 70, Total: 100, Speed: 30}})
+	got, _ = rpo.Get(context.Background(), dl.ID)
+	if got.Progress.Completed != 70 {
+		t.Fatalf("progress not updated after interval: %#v", got.Progress)
+	}
+
+	r.handle(downloader.Event{ID: dl.ID, GID: "g", Type: downloader.EventComplete})
+	got, _ = rpo.Get(context.Background(), dl.ID)
+	if got.Progress.Completed != 100 || got.Progress.Speed != 0 || got.Progress.ETASeconds != 0 {
+		t.Fatalf("progress not finalized on complete: %#v", got.Progress)
+	}
+}
+
+// TestHandleSeeding ensures a Seeding event moves an Active download to
+// Seeding, upload totals and ratio are persisted (throttled once complete),
+// and Complete ends seeding.
+func TestHandleSeeding(t *testing.T) {
+	rpo := repo.NewInMemoryDownloadRepo()
+	dl := &data.Download{Source: "s", TargetPath: "t", Status: data.StatusActive, GID: "g"}
+	if _, err := rpo.Add(context.Background(), dl); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	r := New(slog.New(slog.NewTextHandler(io.Discard, nil)), rpo, nil)
+	r.SetProgressInterval(5 * time.Second)
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return clock }
+
+	r.handle(downloader.Event{ID: dl.ID, GID: "g", Type: downloader.EventProgress, Progress: &downloader.Progress{Completed: 200, Total: 200, Uploaded: 100, UploadSpeed: 20}})
+	r.handle(downloader.Event{ID: dl.ID, GID: "g", Type: downloader.EventSeeding})
+	got, _ := rpo.Get(context.Background(), dl.ID)
+	if got.Status != data.StatusSeeding || got.GID != "g" {
+		t.Fatalf("expected Seeding with gid kept, got %s %q", got.Status, got.GID)
+	}
+	if p := got.Progress; p == nil || p.Uploaded != 100 || p.UploadSpeed != 20 || p.Ratio != 0.5 {
+		t.Fatalf("upload progress not persisted: %#v", got.Progress)
+	}
+
+	// Further uploads within the interval are throttled even though the
+	// snapshot reports completion.
+	clock = clock.Add(time.Second)
+	r.handle(downloader.Event{ID: dl.ID, GID: "g", Type: downloader.EventProgress, Progress: &downloader.Progress{Completed: 200, Total: 200, Uploaded: 150, UploadSpeed: 20}})
+	if got, _ = rpo.Get(context.Background(), dl.ID); got.Progress.Uploaded != 100 {
+		t.Fatalf("seeding progress not throttled: %#v", got.Progress)
+	}
+	clock = clock.Add(5 * time.Second)
+	r.handle(downloader.Event{ID: dl.ID, GID: "g", Type: downloader.EventProgress, Progress: &downloader.Progress{Completed: 200, Total: 200, Uploaded: 400, UploadSpeed: 20}})
+	if got, _ = rpo.Get(context.Background(), dl.ID); got.Progress.Uploaded != 400 || got.Progress.Ratio != 2 {
+		t.Fatalf("seeding progress not updated: %#v", got.Progress)
+	}
+
+	r.handle(downloader.Event{ID: dl.ID, GID: "g", Type: downloader.EventComplete})
+	got, _ = rpo.Get(context.Background(), dl.ID)
+	if got.Status != data.StatusComplete || got.Progress.UploadSpeed != 0 || got.Progress.Uploaded != 400 {
+		t.Fatalf("unexpected state after complete: %s %#v", got.Status, got.Progress)
+	}
+
+	// A late Seeding event does not resurrect a finished download.
+	r.handle(downloader.Event{ID: dl.ID, GID: "g", Type: downloader.EventSeeding})
+	if got, _ = rpo.Get(context.Background(), dl.ID); got.Status != data.StatusComplete {
+		t.Fatalf("stale seeding event applied: %s", got.Status)
+	}
+}
+
+type recordingListener struct {
+	from []data.DownloadStatus
+	to   []data.DownloadStatus
+}
+
+func (l *recordingListener) StatusChanged(ctx context.Context, from data.DownloadStatus, dl *data.Download) {
+	l.from = append(l.from, from)
+	l.to = append(l.to, dl.Status)
+}
+
+// TestHandleNotifiesListeners ensures listeners observe persisted status
+// transitions but not progress or repeated statuses.
+func TestHandleNotifiesListeners(t *testing.T) {
+	rpo := repo.NewInMemoryDownloadRepo()
+	dl := &data.Download{Source: "s", TargetPath: "t", Status: data.StatusActive, GID: "g"}
+	if _, err := rpo.Add(context.Background(), dl); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	r := New(slog.New(slog.NewTextHandler(io.Discard, nil)), rpo, nil)
+	l := &recordingListener{}
+	r.AddListener(l)
+
+	r.handle(downloader.Event{ID: dl.ID, GID: "g", Type: downloader.EventProgress, Progress: &downloader.Progress{Completed: 1, Total: 2}})
+	r.handle(downloader.Event{ID: dl.ID, GID: "g", Type: downloader.EventPaused})
+	r.handle(downloader.Event{ID: dl.ID, GID: "g", Type: downloader.EventPaused})
+	r.handle(downloader.Event{ID: dl.ID, GID: "g", Type: downloader.EventComplete})
+
+	if len(l.to) != 2 || l.from[0] != data.StatusActive || l.to[0] != data.StatusPaused || l.from[1] != data.StatusPaused || l.to[1] != data.StatusComplete {
+		t.Fatalf("unexpected notifications: from=%v to=%v", l.from, l.to)
+	}
+}
+
+// TestHandlePauseKeepsHeldDownloadQueued ensures pausing a download that is
+// queued to run again (e.g. held for disk space) does not mark it Paused,
+// while a pause the user asked for still applies.
+func TestHandlePauseKeepsHeldDownloadQueued(t *testing.T) {
+	cases := []struct {
+		desired data.DownloadStatus
+		want    data.DownloadStatus
+	}{
+		{data.StatusActive, data.StatusQueued},
+		{data.StatusPaused, data.StatusPaused},
+	}
+	for _, c := range cases {
+		t.Run(string(c.desired), func(t *testing.T) {
+			rpo := repo.NewInMemoryDownloadRepo()
+			dl := &data.Download{Source: "s", TargetPath: "t", GID: "g", Status: data.StatusQueued, DesiredStatus: c.desired}
+			if _, err := rpo.Add(context.Background(), dl); err != nil {
+				t.Fatalf("add: %v", err)
+			}
+			r := New(slog.New(slog.NewTextHandler(io.Discard, nil)), rpo, nil)
+
+			r.handle(downloader.Event{ID: dl.ID, GID: "g", Type: downloader.EventPaused})
+
+			if got, _ := rpo.Get(context.Background(), dl.ID); got.Status != c.want {
+				t.Fatalf("status = %v, want %v", got.Status, c.want)
+			}
+		})
+	}
+}
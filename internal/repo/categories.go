@@ -0,0 +1,21 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/tinoosan/torrus/internal/data"
+)
+
+// CategoryRepo persists download categories, keyed by name.
+type CategoryRepo interface {
+	// ListCategories returns all categories sorted by name.
+	ListCategories(ctx context.Context) (data.Categories, error)
+	GetCategory(ctx context.Context, name string) (*data.Category, error)
+	// AddCategory inserts a category, returning data.ErrCategoryExists when
+	// the name is taken.
+	AddCategory(ctx context.Context, c *data.Category) (*data.Category, error)
+	// UpdateCategory applies mutate atomically, like DownloadWriter.Update.
+	// The name and creation time cannot change.
+	UpdateCategory(ctx context.Context, name string, mutate func(*data.Category) error) (*data.Category, error)
+	DeleteCategory(ctx context.Context, name string) error
+}
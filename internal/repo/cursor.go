@@ -0,0 +1,110 @@
+package repo
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+)
+
+// pageCursor is the decoded form of data.DownloadQuery.Cursor. It records the
+// sort key and ID of the last item on a page so the next page can resume with
+// a keyset comparison instead of an offset.
+type pageCursor struct {
+	Sort data.DownloadSort `json:"s"`
+	Key  string            `json:"k,omitempty"`
+	// Priority and QueueOrder form the key of data.SortQueue.
+	Priority   int    `json:"p,omitempty"`
+	QueueOrder int64  `json:"o,omitempty"`
+	ID         string `json:"i"`
+}
+
+// sortKey returns d's value for the column s orders by, as stored in cursors.
+func sortKey(s data.DownloadSort, d *data.Download) string {
+	switch s {
+	case data.SortNameAsc, data.SortNameDesc:
+		return d.Name
+	case data.SortQueue:
+		return ""
+	default:
+		return d.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+}
+
+func encodeCursor(s data.DownloadSort, last *data.Download) string {
+	c := pageCursor{Sort: s, Key: sortKey(s, last), ID: last.ID}
+	if s == data.SortQueue {
+		c.Priority, c.QueueOrder = last.Priority, last.QueueOrder
+	}
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor parses raw and checks it was issued for sort order s. An empty
+// raw cursor yields nil.
+func decodeCursor(raw string, s data.DownloadSort) (*pageCursor, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, data.ErrInvalidCursor
+	}
+	var c pageCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Sort != s || c.ID == "" {
+		return nil, data.ErrInvalidCursor
+	}
+	if s == data.SortCreatedAsc || s == data.SortCreatedDesc {
+		if _, err := time.Parse(time.RFC3339Nano, c.Key); err != nil {
+			return nil, data.ErrInvalidCursor
+		}
+	}
+	return &c, nil
+}
+
+// createdAt returns the cursor key parsed as a timestamp.
+func (c *pageCursor) createdAt() time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, c.Key)
+	return t
+}
+
+// after reports whether d sorts strictly after the cursor position under s.
+func (c *pageCursor) after(s data.DownloadSort, d *data.Download) bool {
+	last := &data.Download{ID: c.ID, Priority: c.Priority, QueueOrder: c.QueueOrder}
+	switch s {
+	case data.SortNameAsc, data.SortNameDesc:
+		last.Name = c.Key
+	case data.SortQueue:
+	default:
+		last.CreatedAt = c.createdAt()
+	}
+	return compareDownloads(s, d, last) > 0
+}
+
+// compareDownloads orders a and b as a listing sorted by s would, breaking
+// ties by ID. It returns a negative number when a comes first.
+func compareDownloads(s data.DownloadSort, a, b *data.Download) int {
+	var n int
+	switch s {
+	case data.SortNameAsc, data.SortNameDesc:
+		n = strings.Compare(a.Name, b.Name)
+	case data.SortQueue:
+		// Higher priority first, then lower queue order.
+		n = cmp.Compare(b.Priority, a.Priority)
+		if n == 0 {
+			n = cmp.Compare(a.QueueOrder, b.QueueOrder)
+		}
+	default:
+		n = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if n == 0 {
+		n = strings.Compare(a.ID, b.ID)
+	}
+	if s.Desc() {
+		return -n
+	}
+	return n
+}
@@ -2,6 +2,7 @@ package repo
 
 import (
 	"context"
+	"time"
 
 	"github.com/tinoosan/torrus/internal/data"
 )
@@ -15,6 +16,10 @@ type DownloadRepo interface {
 // DownloadReader defines read-only access to downloads.
 type DownloadReader interface {
 	List(ctx context.Context) (data.Downloads, error)
+	// Query returns one page of downloads matching q's filters in q's sort
+	// order. It returns data.ErrInvalidQuery or data.ErrInvalidCursor when q
+	// cannot be served.
+	Query(ctx context.Context, q data.DownloadQuery) (*data.DownloadPage, error)
 	Get(ctx context.Context, id string) (*data.Download, error)
 }
 
@@ -46,9 +51,48 @@ type DownloadFinder interface {
 	GetByFingerprint(ctx context.Context, fingerprint string) (*data.Download, error)
 }
 
+// PayloadReader loads uploaded .torrent and metalink payloads. Repositories
+// implementing it may leave Download.Payload empty on every other read:
+// payloads can be large and are only needed to start a download.
+type PayloadReader interface {
+	// GetPayload returns the payload of download id, or nil if it has none.
+	GetPayload(ctx context.Context, id string) ([]byte, error)
+}
+
+// LoadPayload fills in the payload of d from r when d has one that r left
+// out of the read.
+func LoadPayload(ctx context.Context, r DownloadReader, d *data.Download) error {
+	if d.PayloadType == "" || len(d.Payload) > 0 {
+		return nil
+	}
+	pr, ok := r.(PayloadReader)
+	if !ok {
+		return nil
+	}
+	b, err := pr.GetPayload(ctx, d.ID)
+	if err != nil {
+		return err
+	}
+	d.Payload = b
+	return nil
+}
+
 // ExtendedRepo combines reader, writer and finder interfaces.
 type ExtendedRepo interface {
 	DownloadReader
 	DownloadWriter
 	DownloadFinder
 }
+
+// initQueueOrder derives d's QueueOrder from its creation time, when unset,
+// so downloads of equal priority are queued first-in first-out.
+func initQueueOrder(d *data.Download) {
+	if d.QueueOrder != 0 {
+		return
+	}
+	t := d.CreatedAt
+	if t.IsZero() {
+		t = time.Now()
+	}
+	d.QueueOrder = t.UnixNano()
+}
@@ -0,0 +1,34 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/tinoosan/torrus/internal/data"
+)
+
+// HistoryRepo persists the append-only transition history of downloads.
+// Entries are never changed or removed, and outlive their download.
+type HistoryRepo interface {
+	// AddTransition appends t to its download's history.
+	AddTransition(ctx context.Context, t *data.Transition) error
+	// ListTransitions returns the history of a download, oldest first. It
+	// returns an empty list for downloads without any.
+	ListTransitions(ctx context.Context, downloadID string) (data.Transitions, error)
+}
+
+// TransitionFunc returns the transition a write from before to after
+// records, or nil when it records none. before is nil for an insert.
+type TransitionFunc func(before, after *data.Download) *data.Transition
+
+// TransitionRecorder is implemented by download repositories that are also
+// their own HistoryRepo. Its writes append the transition built by record in
+// the same transaction, so a write and its history entry commit together.
+type TransitionRecorder interface {
+	// AddRecorded is DownloadWriter.Add, recording the insert.
+	AddRecorded(ctx context.Context, d *data.Download, record TransitionFunc) (*data.Download, error)
+	// AddWithFingerprintRecorded is DownloadWriter.AddWithFingerprint,
+	// recording the insert when a download is created.
+	AddWithFingerprintRecorded(ctx context.Context, d *data.Download, fingerprint string, record TransitionFunc) (*data.Download, bool, error)
+	// UpdateRecorded is DownloadWriter.Update, recording the change.
+	UpdateRecorded(ctx context.Context, id string, mutate func(*data.Download) error, record TransitionFunc) (*data.Download, error)
+}
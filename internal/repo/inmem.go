@@ -18,6 +18,9 @@ type InMemoryDownloadRepo struct {
 	mu      sync.RWMutex
 	byID    map[string]*data.Download
 	fpIndex map[string]string // fingerprint -> id
+	// created holds every download ordered by (CreatedAt, ID) so Query can
+	// page the default sort without scanning and sorting the whole map.
+	created []*data.Download
 }
 
 // NewInMemoryDownloadRepo returns an initialized in-memory repository.
@@ -40,6 +43,89 @@ func (r *InMemoryDownloadRepo) List(ctx context.Context) (data.Downloads, error)
 	return res, nil
 }
 
+// Query returns one page of downloads matching q. Listings sorted by creation
+// time walk the created index from the cursor and stop once the page is
+// full; other orders filter and sort the matching subset.
+func (r *InMemoryDownloadRepo) Query(ctx context.Context, q data.DownloadQuery) (*data.DownloadPage, error) {
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+	cur, err := decodeCursor(q.Cursor, q.Sort)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var items data.Downloads
+	switch q.Sort {
+	case data.SortCreatedAsc, data.SortCreatedDesc:
+		n := len(r.created)
+		start := 0
+		if cur != nil {
+			if q.Sort.Desc() {
+				start = n - sort.Search(n, func(i int) bool { return !cur.after(q.Sort, r.created[i]) })
+			} else {
+				start = sort.Search(n, func(i int) bool { return cur.after(q.Sort, r.created[i]) })
+			}
+		}
+		for i := start; i < n && len(items) <= q.Limit; i++ {
+			d := r.created[i]
+			if q.Sort.Desc() {
+				d = r.created[n-1-i]
+			}
+			if q.Match(d) {
+				items = append(items, d)
+			}
+		}
+	default:
+		for _, d := range r.byID {
+			if q.Match(d) && (cur == nil || cur.after(q.Sort, d)) {
+				items = append(items, d)
+			}
+		}
+		sort.Slice(items, func(i, j int) bool { return compareDownloads(q.Sort, items[i], items[j]) < 0 })
+		if len(items) > q.Limit+1 {
+			items = items[:q.Limit+1]
+		}
+	}
+
+	page := &data.DownloadPage{Items: make(data.Downloads, 0, len(items))}
+	if len(items) > q.Limit {
+		items = items[:q.Limit]
+		page.NextCursor = encodeCursor(q.Sort, items[len(items)-1])
+	}
+	for _, d := range items {
+		page.Items = append(page.Items, d.Clone())
+	}
+	return page, nil
+}
+
+// createdBefore orders downloads by (CreatedAt, ID).
+func createdBefore(a, b *data.Download) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// indexCreated inserts d into the created index. Callers hold r.mu.
+func (r *InMemoryDownloadRepo) indexCreated(d *data.Download) {
+	i := sort.Search(len(r.created), func(i int) bool { return !createdBefore(r.created[i], d) })
+	r.created = append(r.created, nil)
+	copy(r.created[i+1:], r.created[i:])
+	r.created[i] = d
+}
+
+// unindexCreated removes the entry for d (located by its CreatedAt and ID)
+// from the created index. Callers hold r.mu.
+func (r *InMemoryDownloadRepo) unindexCreated(d *data.Download) {
+	i := sort.Search(len(r.created), func(i int) bool { return !createdBefore(r.created[i], d) })
+	if i < len(r.created) && r.created[i].ID == d.ID {
+		r.created = append(r.created[:i], r.created[i+1:]...)
+	}
+}
+
 // Get retrieves a download by its ID.
 func (r *InMemoryDownloadRepo) Get(ctx context.Context, id string) (*data.Download, error) {
 	r.mu.RLock()
@@ -56,7 +142,9 @@ func (r *InMemoryDownloadRepo) Add(ctx context.Context, d *data.Download) (*data
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	d.ID = uuid.NewString()
+	initQueueOrder(d)
 	r.byID[d.ID] = d
+	r.indexCreated(d)
 	return d.Clone(), nil
 }
 
@@ -91,7 +179,9 @@ func (r *InMemoryDownloadRepo) AddWithFingerprint(ctx context.Context, d *data.D
 	}
 
 	d.ID = uuid.NewString()
+	initQueueOrder(d)
 	r.byID[d.ID] = d
+	r.indexCreated(d)
 	r.fpIndex[fpv] = d.ID
 	return d.Clone(), true, nil
 }
@@ -127,7 +217,9 @@ func (r *InMemoryDownloadRepo) Update(ctx context.Context, id string, mutate fun
 		delete(r.fpIndex, oldFP)
 		r.fpIndex[newFP] = id
 	}
+	r.unindexCreated(dl)
 	r.byID[id] = clone
+	r.indexCreated(clone)
 	return clone.Clone(), nil
 }
 
@@ -141,6 +233,7 @@ func (r *InMemoryDownloadRepo) Delete(ctx context.Context, id string) error {
 		return data.ErrNotFound
 	}
 	fpv := fp.Fingerprint(dl.Source, dl.TargetPath)
+	r.unindexCreated(dl)
 	delete(r.byID, id)
 	delete(r.fpIndex, fpv)
 	return nil
@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/tinoosan/torrus/internal/data"
+)
+
+// InMemoryCategoryRepo stores categories in memory. Like
+// InMemoryDownloadRepo it is intended for tests and development.
+type InMemoryCategoryRepo struct {
+	mu   sync.RWMutex
+	cats map[string]*data.Category
+}
+
+// NewInMemoryCategoryRepo returns an initialized in-memory category repository.
+func NewInMemoryCategoryRepo() *InMemoryCategoryRepo {
+	return &InMemoryCategoryRepo{cats: make(map[string]*data.Category)}
+}
+
+var _ CategoryRepo = (*InMemoryCategoryRepo)(nil)
+
+// ListCategories returns all categories sorted by name.
+func (r *InMemoryCategoryRepo) ListCategories(ctx context.Context) (data.Categories, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := make(data.Categories, 0, len(r.cats))
+	for _, c := range r.cats {
+		res = append(res, c.Clone())
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+// GetCategory retrieves a category by name.
+func (r *InMemoryCategoryRepo) GetCategory(ctx context.Context, name string) (*data.Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.cats[name]
+	if !ok {
+		return nil, data.ErrCategoryNotFound
+	}
+	return c.Clone(), nil
+}
+
+// AddCategory inserts a new category.
+func (r *InMemoryCategoryRepo) AddCategory(ctx context.Context, c *data.Category) (*data.Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.cats[c.Name]; ok {
+		return nil, data.ErrCategoryExists
+	}
+	r.cats[c.Name] = c.Clone()
+	return c.Clone(), nil
+}
+
+// UpdateCategory applies mutate to the stored category while holding the lock.
+func (r *InMemoryCategoryRepo) UpdateCategory(ctx context.Context, name string, mutate func(*data.Category) error) (*data.Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.cats[name]
+	if !ok {
+		return nil, data.ErrCategoryNotFound
+	}
+	clone := c.Clone()
+	if mutate != nil {
+		if err := mutate(clone); err != nil {
+			return nil, err
+		}
+	}
+	clone.Name, clone.CreatedAt = c.Name, c.CreatedAt
+	r.cats[name] = clone
+	return clone.Clone(), nil
+}
+
+// DeleteCategory removes the category.
+func (r *InMemoryCategoryRepo) DeleteCategory(ctx context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.cats[name]; !ok {
+		return data.ErrCategoryNotFound
+	}
+	delete(r.cats, name)
+	return nil
+}
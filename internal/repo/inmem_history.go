@@ -0,0 +1,43 @@
+package repo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tinoosan/torrus/internal/data"
+)
+
+// InMemoryHistoryRepo stores transition history in memory. Like
+// InMemoryDownloadRepo it is intended for tests and development.
+type InMemoryHistoryRepo struct {
+	mu      sync.RWMutex
+	history map[string]data.Transitions
+}
+
+// NewInMemoryHistoryRepo returns an empty in-memory history repository.
+func NewInMemoryHistoryRepo() *InMemoryHistoryRepo {
+	return &InMemoryHistoryRepo{history: make(map[string]data.Transitions)}
+}
+
+var _ HistoryRepo = (*InMemoryHistoryRepo)(nil)
+
+// AddTransition appends a copy of t.
+func (r *InMemoryHistoryRepo) AddTransition(ctx context.Context, t *data.Transition) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := *t
+	r.history[t.DownloadID] = append(r.history[t.DownloadID], &cp)
+	return nil
+}
+
+// ListTransitions returns copies of the transitions of a download.
+func (r *InMemoryHistoryRepo) ListTransitions(ctx context.Context, downloadID string) (data.Transitions, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(data.Transitions, 0, len(r.history[downloadID]))
+	for _, t := range r.history[downloadID] {
+		cp := *t
+		out = append(out, &cp)
+	}
+	return out, nil
+}
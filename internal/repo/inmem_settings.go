@@ -0,0 +1,41 @@
+package repo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tinoosan/torrus/internal/data"
+)
+
+// InMemorySettingsRepo stores settings in memory. Like InMemoryDownloadRepo
+// it is intended for tests and development.
+type InMemorySettingsRepo struct {
+	mu        sync.RWMutex
+	bandwidth *data.BandwidthSettings
+}
+
+// NewInMemorySettingsRepo returns an empty in-memory settings repository.
+func NewInMemorySettingsRepo() *InMemorySettingsRepo {
+	return &InMemorySettingsRepo{}
+}
+
+var _ SettingsRepo = (*InMemorySettingsRepo)(nil)
+
+// GetBandwidth returns a copy of the stored bandwidth settings.
+func (r *InMemorySettingsRepo) GetBandwidth(ctx context.Context) (*data.BandwidthSettings, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.bandwidth == nil {
+		return &data.BandwidthSettings{}, nil
+	}
+	return r.bandwidth.Clone(), nil
+}
+
+// SaveBandwidth stores a copy of s.
+func (r *InMemorySettingsRepo) SaveBandwidth(ctx context.Context, s *data.BandwidthSettings) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bandwidth = s.Clone()
+	r.bandwidth.Active = nil
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/tinoosan/torrus/internal/data"
@@ -375,3 +376,102 @@ func TestAddWithFingerprint_ConcurrentSingleCreate(t *testing.T) {
 		t.Fatalf("expected 1 row, got %d", len(list))
 	}
 }
+
+// TestInMemoryDownloadRepo_QueryPages walks every sort order page by page and
+// checks the concatenation matches a full sort, then exercises filters.
+func TestInMemoryDownloadRepo_QueryPages(t *testing.T) {
+	ctx := context.Background()
+	r := NewInMemoryDownloadRepo()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	names := []string{"delta", "Alpha", "charlie", "bravo", "echo", "alpha two", "foxtrot"}
+	for i, n := range names {
+		st := data.StatusActive
+		if i%2 == 0 {
+			st = data.StatusComplete
+		}
+		// Two downloads share a timestamp to exercise the ID tie-break.
+		created := base.Add(time.Duration(i/2*2) * time.Minute)
+		_, err := r.Add(ctx, &data.Download{Source: fmt.Sprintf("s%d", i), TargetPath: fmt.Sprintf("/data/%d", i%3), Name: n, Status: st, CreatedAt: created, Priority: i % 3})
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	for _, s := range []data.DownloadSort{data.SortCreatedAsc, data.SortCreatedDesc, data.SortNameAsc, data.SortNameDesc, data.SortQueue} {
+		t.Run(string(s), func(t *testing.T) {
+			full, err := r.Query(ctx, data.DownloadQuery{Sort: s, Limit: data.MaxQueryLimit})
+			if err != nil || len(full.Items) != len(names) || full.NextCursor != "" {
+				t.Fatalf("full query = %d items, cursor %q, err %v", len(full.Items), full.NextCursor, err)
+			}
+			var got []string
+			cursor := ""
+			for pages := 0; ; pages++ {
+				if pages > len(names) {
+					t.Fatal("pagination did not terminate")
+				}
+				p, err := r.Query(ctx, data.DownloadQuery{Sort: s, Limit: 2, Cursor: cursor})
+				if err != nil {
+					t.Fatalf("Query: %v", err)
+				}
+				for _, d := range p.Items {
+					got = append(got, d.ID)
+				}
+				if p.NextCursor == "" {
+					break
+				}
+				cursor = p.NextCursor
+			}
+			for i, d := range full.Items {
+				if i >= len(got) || got[i] != d.ID {
+					t.Fatalf("paged order differs from full order at %d", i)
+				}
+				if i > 0 {
+					prev := full.Items[i-1]
+					var ordered bool
+					switch s {
+					case data.SortCreatedAsc:
+						ordered = createdBefore(prev, d)
+					case data.SortCreatedDesc:
+						ordered = createdBefore(d, prev)
+					case data.SortNameAsc:
+						ordered = prev.Name < d.Name
+					case data.SortNameDesc:
+						ordered = prev.Name > d.Name
+					case data.SortQueue:
+						ordered = prev.Priority > d.Priority || (prev.Priority == d.Priority && prev.QueueOrder <= d.QueueOrder)
+					}
+					if !ordered {
+						t.Fatalf("items %d and %d out of order for %s", i-1, i, s)
+					}
+				}
+			}
+		})
+	}
+
+	p, _ := r.Query(ctx, data.DownloadQuery{Statuses: []data.DownloadStatus{data.StatusActive}, NameContains: "ALPHA", Sort: data.SortNameDesc})
+	if len(p.Items) != 2 || p.Items[0].Name != "alpha two" || p.Items[1].Name != "Alpha" {
+		t.Fatalf("status+name filter = %#v", p.Items)
+	}
+	p, _ = r.Query(ctx, data.DownloadQuery{TargetPathPrefix: "/data/1", CreatedFrom: base.Add(time.Minute), CreatedTo: base.Add(6 * time.Minute)})
+	if len(p.Items) != 1 || p.Items[0].Name != "echo" {
+		t.Fatalf("prefix+range filter = %#v", p.Items)
+	}
+
+	first, _ := r.Query(ctx, data.DownloadQuery{Limit: 1})
+	if _, err := r.Query(ctx, data.DownloadQuery{Sort: data.SortNameAsc, Cursor: first.NextCursor}); !errors.Is(err, data.ErrInvalidCursor) {
+		t.Fatalf("cursor reused across sorts: err = %v", err)
+	}
+	if _, err := r.Query(ctx, data.DownloadQuery{Cursor: "!!"}); !errors.Is(err, data.ErrInvalidCursor) {
+		t.Fatalf("garbage cursor: err = %v", err)
+	}
+
+	// Deleting and updating keep the created index consistent.
+	victim := first.Items[0]
+	if err := r.Delete(ctx, victim.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	p, _ = r.Query(ctx, data.DownloadQuery{})
+	if len(p.Items) != len(names)-1 || p.Items[0].ID == victim.ID {
+		t.Fatalf("deleted download still indexed")
+	}
+}
@@ -0,0 +1,152 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/tinoosan/torrus/internal/data"
+)
+
+// InMemoryWebhookRepo stores webhooks and deliveries in memory. Like
+// InMemoryDownloadRepo it is intended for tests and development.
+type InMemoryWebhookRepo struct {
+	mu         sync.RWMutex
+	hooks      map[string]*data.Webhook
+	deliveries map[string]*data.WebhookDelivery
+}
+
+// NewInMemoryWebhookRepo returns an initialized in-memory webhook repository.
+func NewInMemoryWebhookRepo() *InMemoryWebhookRepo {
+	return &InMemoryWebhookRepo{
+		hooks:      make(map[string]*data.Webhook),
+		deliveries: make(map[string]*data.WebhookDelivery),
+	}
+}
+
+var _ WebhookRepo = (*InMemoryWebhookRepo)(nil)
+
+// ListWebhooks returns all webhooks sorted by creation time ascending.
+func (r *InMemoryWebhookRepo) ListWebhooks(ctx context.Context) (data.Webhooks, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := make(data.Webhooks, 0, len(r.hooks))
+	for _, w := range r.hooks {
+		res = append(res, w.Clone())
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.Before(res[j].CreatedAt) })
+	return res, nil
+}
+
+// GetWebhook retrieves a webhook by ID.
+func (r *InMemoryWebhookRepo) GetWebhook(ctx context.Context, id string) (*data.Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	w, ok := r.hooks[id]
+	if !ok {
+		return nil, data.ErrWebhookNotFound
+	}
+	return w.Clone(), nil
+}
+
+// AddWebhook inserts a new webhook and assigns it a unique ID.
+func (r *InMemoryWebhookRepo) AddWebhook(ctx context.Context, w *data.Webhook) (*data.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w.ID = uuid.NewString()
+	r.hooks[w.ID] = w.Clone()
+	return w.Clone(), nil
+}
+
+// UpdateWebhook applies mutate to the stored webhook while holding the lock.
+func (r *InMemoryWebhookRepo) UpdateWebhook(ctx context.Context, id string, mutate func(*data.Webhook) error) (*data.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w, ok := r.hooks[id]
+	if !ok {
+		return nil, data.ErrWebhookNotFound
+	}
+	clone := w.Clone()
+	if mutate != nil {
+		if err := mutate(clone); err != nil {
+			return nil, err
+		}
+	}
+	clone.ID = id
+	r.hooks[id] = clone
+	return clone.Clone(), nil
+}
+
+// DeleteWebhook removes the webhook and its deliveries.
+func (r *InMemoryWebhookRepo) DeleteWebhook(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.hooks[id]; !ok {
+		return data.ErrWebhookNotFound
+	}
+	delete(r.hooks, id)
+	for did, d := range r.deliveries {
+		if d.WebhookID == id {
+			delete(r.deliveries, did)
+		}
+	}
+	return nil
+}
+
+// AddDelivery inserts a delivery and assigns it a unique ID.
+func (r *InMemoryWebhookRepo) AddDelivery(ctx context.Context, d *data.WebhookDelivery) (*data.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.hooks[d.WebhookID]; !ok {
+		return nil, data.ErrWebhookNotFound
+	}
+	d.ID = uuid.NewString()
+	r.deliveries[d.ID] = d.Clone()
+	return d.Clone(), nil
+}
+
+// SaveDelivery replaces the stored delivery with d.
+func (r *InMemoryWebhookRepo) SaveDelivery(ctx context.Context, d *data.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.deliveries[d.ID]; !ok {
+		return data.ErrNotFound
+	}
+	r.deliveries[d.ID] = d.Clone()
+	return nil
+}
+
+// ListDeliveries returns up to limit deliveries for a webhook, newest first.
+func (r *InMemoryWebhookRepo) ListDeliveries(ctx context.Context, webhookID string, limit int) (data.WebhookDeliveries, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if _, ok := r.hooks[webhookID]; !ok {
+		return nil, data.ErrWebhookNotFound
+	}
+	res := data.WebhookDeliveries{}
+	for _, d := range r.deliveries {
+		if d.WebhookID == webhookID {
+			res = append(res, d.Clone())
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.After(res[j].CreatedAt) })
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+// PendingDeliveries returns all deliveries still in the Pending state.
+func (r *InMemoryWebhookRepo) PendingDeliveries(ctx context.Context) (data.WebhookDeliveries, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := data.WebhookDeliveries{}
+	for _, d := range r.deliveries {
+		if d.State == data.DeliveryPending {
+			res = append(res, d.Clone())
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.Before(res[j].CreatedAt) })
+	return res, nil
+}
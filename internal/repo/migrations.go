@@ -0,0 +1,34 @@
+package repo
+
+import (
+    "database/sql"
+    "embed"
+    "io/fs"
+
+    "github.com/tinoosan/torrus/internal/migrate"
+)
+
+// migrationFiles holds the Postgres schema migrations. Add a new
+// NNNN_name.up.sql and .down.sql pair for every schema change; never edit
+// one that has been released.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations returns the Postgres schema migrations, oldest first.
+func Migrations() ([]migrate.Migration, error) {
+    sub, err := fs.Sub(migrationFiles, "migrations")
+    if err != nil {
+        return nil, err
+    }
+    return migrate.Load(sub)
+}
+
+// NewMigrator returns a runner for the Postgres schema migrations on db.
+func NewMigrator(db *sql.DB) (*migrate.Runner, error) {
+    ms, err := Migrations()
+    if err != nil {
+        return nil, err
+    }
+    return migrate.New(db, ms), nil
+}
@@ -0,0 +1,20 @@
+-- Reverts the initial schema. This drops every table and all the data in
+-- them, so only run it against a database you mean to empty.
+DROP INDEX IF EXISTS download_transitions_download_idx;
+DROP INDEX IF EXISTS webhook_deliveries_pending_idx;
+DROP INDEX IF EXISTS webhook_deliveries_webhook_created_idx;
+DROP INDEX IF EXISTS downloads_labels_idx;
+DROP INDEX IF EXISTS downloads_category_created_at_idx;
+DROP INDEX IF EXISTS downloads_status_queue_idx;
+DROP INDEX IF EXISTS downloads_target_path_prefix_idx;
+DROP INDEX IF EXISTS downloads_desired_status_created_at_idx;
+DROP INDEX IF EXISTS downloads_status_created_at_idx;
+DROP INDEX IF EXISTS downloads_name_id_idx;
+DROP INDEX IF EXISTS downloads_created_at_id_idx;
+
+DROP TABLE IF EXISTS download_transitions;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS settings;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS webhooks;
+DROP TABLE IF EXISTS downloads;
@@ -0,0 +1,89 @@
+-- Initial schema: the tables and indexes that were created on start before
+-- versioned migrations existed. Every statement is idempotent so databases
+-- created by those versions adopt this migration unchanged.
+CREATE TABLE IF NOT EXISTS downloads (
+    id UUID PRIMARY KEY,
+    gid TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL,
+    target_path TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    files JSONB,
+    status TEXT NOT NULL,
+    desired_status TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    fingerprint TEXT NOT NULL UNIQUE
+);
+ALTER TABLE downloads ADD COLUMN IF NOT EXISTS progress JSONB;
+ALTER TABLE downloads ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE downloads ADD COLUMN IF NOT EXISTS queue_order BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE downloads ADD COLUMN IF NOT EXISTS options JSONB;
+ALTER TABLE downloads ADD COLUMN IF NOT EXISTS payload BYTEA;
+ALTER TABLE downloads ADD COLUMN IF NOT EXISTS payload_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE downloads ADD COLUMN IF NOT EXISTS file_filter JSONB;
+ALTER TABLE downloads ADD COLUMN IF NOT EXISTS post_process JSONB;
+ALTER TABLE downloads ADD COLUMN IF NOT EXISTS post_process_status JSONB;
+ALTER TABLE downloads ADD COLUMN IF NOT EXISTS category TEXT NOT NULL DEFAULT '';
+ALTER TABLE downloads ADD COLUMN IF NOT EXISTS labels JSONB;
+ALTER TABLE downloads ADD COLUMN IF NOT EXISTS retry JSONB;
+ALTER TABLE downloads ADD COLUMN IF NOT EXISTS retry_status JSONB;
+ALTER TABLE downloads ADD COLUMN IF NOT EXISTS failure JSONB;
+-- Rows fingerprinted before versioning used version 1 (trimmed source).
+ALTER TABLE downloads ADD COLUMN IF NOT EXISTS fingerprint_version INTEGER NOT NULL DEFAULT 1;
+-- Rows created before queue ordering existed queue by creation time.
+UPDATE downloads SET queue_order = (EXTRACT(EPOCH FROM created_at) * 1000000)::BIGINT * 1000 WHERE queue_order = 0;
+CREATE TABLE IF NOT EXISTS webhooks (
+    id UUID PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL DEFAULT '',
+    events JSONB,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS categories (
+    name TEXT PRIMARY KEY,
+    target_path TEXT NOT NULL,
+    options JSONB,
+    post_process JSONB,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS settings (
+    key TEXT PRIMARY KEY,
+    value JSONB NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id UUID PRIMARY KEY,
+    webhook_id UUID NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    download_id TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    state TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_code INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    next_attempt_at TIMESTAMPTZ
+);
+CREATE TABLE IF NOT EXISTS download_transitions (
+    id BIGSERIAL PRIMARY KEY,
+    download_id TEXT NOT NULL,
+    from_status TEXT NOT NULL DEFAULT '',
+    to_status TEXT NOT NULL,
+    desired_status TEXT NOT NULL DEFAULT '',
+    cause TEXT NOT NULL,
+    event TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    occurred_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS downloads_created_at_id_idx ON downloads (created_at, id);
+CREATE INDEX IF NOT EXISTS downloads_name_id_idx ON downloads (name COLLATE "C", id);
+CREATE INDEX IF NOT EXISTS downloads_status_created_at_idx ON downloads (status, created_at, id);
+CREATE INDEX IF NOT EXISTS downloads_desired_status_created_at_idx ON downloads (desired_status, created_at, id);
+CREATE INDEX IF NOT EXISTS downloads_target_path_prefix_idx ON downloads (target_path text_pattern_ops);
+CREATE INDEX IF NOT EXISTS downloads_status_queue_idx ON downloads (status, (-priority), queue_order, id);
+CREATE INDEX IF NOT EXISTS downloads_category_created_at_idx ON downloads (category, created_at, id);
+CREATE INDEX IF NOT EXISTS downloads_labels_idx ON downloads USING gin (labels jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_created_idx ON webhook_deliveries (webhook_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON webhook_deliveries (state) WHERE state = 'Pending';
+CREATE INDEX IF NOT EXISTS download_transitions_download_idx ON download_transitions (download_id, id);
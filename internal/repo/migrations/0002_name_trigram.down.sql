@@ -0,0 +1,2 @@
+-- The pg_trgm extension is left installed: other schemas may use it.
+DROP INDEX IF EXISTS downloads_name_trgm_idx;
@@ -0,0 +1,12 @@
+-- Trigram index for name substring search. pg_trgm may not be installed or
+-- the role may lack the privilege to create it; name filtering still works
+-- without the index, so those errors are reported and the migration applies.
+DO $$
+BEGIN
+    CREATE EXTENSION IF NOT EXISTS pg_trgm;
+    CREATE INDEX IF NOT EXISTS downloads_name_trgm_idx ON downloads USING gin (name gin_trgm_ops);
+EXCEPTION
+    WHEN insufficient_privilege OR undefined_file OR feature_not_supported THEN
+        RAISE NOTICE 'skipping downloads_name_trgm_idx: %', SQLERRM;
+END
+$$;
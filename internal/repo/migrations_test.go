@@ -0,0 +1,39 @@
+package repo
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestMigrations ensures the embedded migrations load and start with the
+// initial schema, which must stay idempotent for pre-migration databases,
+// and that every migration can be reverted.
+func TestMigrations(t *testing.T) {
+	ms, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	if len(ms) == 0 || ms[0].Version != 1 || ms[0].Name != "initial" {
+		t.Fatalf("first migration = %+v", ms)
+	}
+	for i, m := range ms {
+		if m.Version != i+1 {
+			t.Errorf("migration %s: versions must be consecutive from 1", m)
+		}
+		if m.Down == "" {
+			t.Errorf("migration %s has no down script", m)
+		}
+	}
+	var sql []string
+	for _, line := range strings.Split(ms[0].Up, "\n") {
+		if !strings.HasPrefix(line, "--") {
+			sql = append(sql, line)
+		}
+	}
+	for _, stmt := range strings.Split(strings.Join(sql, "\n"), ";") {
+		stmt = strings.TrimSpace(stmt)
+		if strings.HasPrefix(stmt, "CREATE") && !strings.Contains(stmt, "IF NOT EXISTS") {
+			t.Errorf("0001 statement is not idempotent: %s", stmt)
+		}
+	}
+}
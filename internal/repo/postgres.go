@@ -1,6 +1,7 @@
 package repo
 
 import (
+    "bytes"
     "context"
     "database/sql"
     "encoding/json"
@@ -8,6 +9,7 @@ import (
     "net"
     "net/url"
     "os"
+    "strconv"
     "strings"
     "time"
 
@@ -24,8 +26,25 @@ type PostgresRepo struct {
     db *sql.DB
 }
 
-// NewPostgresRepo constructs a repository using the provided DSN.
+// NewPostgresRepo constructs a repository using the provided DSN, applying
+// pending schema migrations first.
 func NewPostgresRepo(dsn string) (*PostgresRepo, error) {
+    db, err := OpenPostgres(dsn)
+    if err != nil {
+        return nil, err
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+    defer cancel()
+    r := &PostgresRepo{db: db}
+    if err := r.migrateSchema(ctx); err != nil {
+        _ = db.Close()
+        return nil, err
+    }
+    return r, nil
+}
+
+// OpenPostgres connects to dsn without touching the schema.
+func OpenPostgres(dsn string) (*sql.DB, error) {
     db, err := sql.Open("pgx", dsn)
     if err != nil {
         return nil, err
@@ -37,20 +56,20 @@ func NewPostgresRepo(dsn string) (*PostgresRepo, error) {
         _ = db.Close()
         return nil, err
     }
-    r := &PostgresRepo{db: db}
-    if err := r.ensureSchema(ctx); err != nil {
-        _ = db.Close()
-        return nil, err
-    }
-    return r, nil
+    return db, nil
 }
 
-// NewPostgresRepoFromEnv constructs a DSN using component env vars.
+// NewPostgresRepoFromEnv constructs a repository using PostgresDSNFromEnv.
+func NewPostgresRepoFromEnv() (*PostgresRepo, error) {
+    return NewPostgresRepo(PostgresDSNFromEnv())
+}
+
+// PostgresDSNFromEnv constructs a DSN using component env vars.
 // Recognized envs (with defaults):
 //   POSTGRES_HOST (postgres), POSTGRES_PORT (5432), POSTGRES_DB (torrus),
 //   POSTGRES_USER (torrus), POSTGRES_PASSWORD (empty), POSTGRES_SSLMODE (disable)
 // Credentials and db name are URL-encoded to handle special characters safely.
-func NewPostgresRepoFromEnv() (*PostgresRepo, error) {
+func PostgresDSNFromEnv() string {
     host := getenv("POSTGRES_HOST", "postgres")
     port := getenv("POSTGRES_PORT", "5432")
     db := getenv("POSTGRES_DB", "torrus")
@@ -67,7 +86,7 @@ func NewPostgresRepoFromEnv() (*PostgresRepo, error) {
     q := url.Values{}
     q.Set("sslmode", ssl)
     u.RawQuery = q.Encode()
-    return NewPostgresRepo(u.String())
+    return u.String()
 }
 
 func getenv(k, def string) string {
@@ -79,28 +98,25 @@ func getenv(k, def string) string {
 
 func (r *PostgresRepo) Close() error { return r.db.Close() }
 
-func (r *PostgresRepo) ensureSchema(ctx context.Context) error {
-    // Create table if not exists
-    _, err := r.db.ExecContext(ctx, `
-CREATE TABLE IF NOT EXISTS downloads (
-    id UUID PRIMARY KEY,
-    gid TEXT NOT NULL DEFAULT '',
-    source TEXT NOT NULL,
-    target_path TEXT NOT NULL,
-    name TEXT NOT NULL DEFAULT '',
-    files JSONB,
-    status TEXT NOT NULL,
-    desired_status TEXT NOT NULL DEFAULT '',
-    created_at TIMESTAMPTZ NOT NULL,
-    fingerprint TEXT NOT NULL UNIQUE
-);
-`)
+// migrateTimeout bounds schema migration on start, including the wait for
+// another replica that holds the migration lock.
+const migrateTimeout = 5 * time.Minute
+
+// migrateSchema applies pending schema migrations.
+func (r *PostgresRepo) migrateSchema(ctx context.Context) error {
+    m, err := NewMigrator(r.db)
+    if err != nil { return err }
+    _, err = m.Up(ctx)
     return err
 }
 
+// downloadColumns lists the columns read by scanDownload, in scan order. The
+// payload is left out; GetPayload reads it when a download is started.
+const downloadColumns = `id,gid,source,target_path,name,files,status,desired_status,created_at,progress,priority,queue_order,options,payload_type,file_filter,post_process,post_process_status,category,labels,retry,retry_status,failure`
+
 // List implements DownloadReader.List
 func (r *PostgresRepo) List(ctx context.Context) (data.Downloads, error) {
-    rows, err := r.db.QueryContext(ctx, `SELECT id,gid,source,target_path,name,files,status,desired_status,created_at FROM downloads ORDER BY created_at ASC`)
+    rows, err := r.db.QueryContext(ctx, `SELECT `+downloadColumns+` FROM downloads ORDER BY created_at ASC`)
     if err != nil { return nil, err }
     defer rows.Close()
     var out data.Downloads
@@ -112,9 +128,103 @@ func (r *PostgresRepo) List(ctx context.Context) (data.Downloads, error) {
     return out, rows.Err()
 }
 
+// Query implements DownloadReader.Query using keyset pagination: the cursor
+// carries the last row's sort key and ID, so each page is an index range scan.
+func (r *PostgresRepo) Query(ctx context.Context, q data.DownloadQuery) (*data.DownloadPage, error) {
+    if err := q.Normalize(); err != nil { return nil, err }
+    cur, err := decodeCursor(q.Cursor, q.Sort)
+    if err != nil { return nil, err }
+
+    var (
+        where []string
+        args  []any
+    )
+    arg := func(v any) string {
+        args = append(args, v)
+        return "$" + strconv.Itoa(len(args))
+    }
+    if len(q.Statuses) > 0 {
+        where = append(where, "status = ANY("+arg(statusStrings(q.Statuses))+")")
+    }
+    if len(q.DesiredStatuses) > 0 {
+        where = append(where, "desired_status = ANY("+arg(statusStrings(q.DesiredStatuses))+")")
+    }
+    if q.TargetPathPrefix != "" {
+        where = append(where, "target_path LIKE "+arg(escapeLike(q.TargetPathPrefix)+"%")+` ESCAPE '\'`)
+    }
+    if q.NameContains != "" {
+        where = append(where, "name ILIKE "+arg("%"+escapeLike(q.NameContains)+"%")+` ESCAPE '\'`)
+    }
+    if q.Category != "" {
+        where = append(where, "category = "+arg(q.Category))
+    }
+    if len(q.Labels) > 0 {
+        labelsJSON, _ := json.Marshal(q.Labels)
+        where = append(where, "labels @> "+arg(string(labelsJSON))+"::jsonb")
+    }
+    if !q.CreatedFrom.IsZero() {
+        where = append(where, "created_at >= "+arg(q.CreatedFrom))
+    }
+    if !q.CreatedTo.IsZero() {
+        where = append(where, "created_at < "+arg(q.CreatedTo))
+    }
+
+    // Queue order is priority descending, so it sorts on -priority to keep
+    // every key column ascending and usable in a single row comparison.
+    cols, dir, cmp := []string{"created_at"}, "ASC", ">"
+    switch q.Sort {
+    case data.SortNameAsc, data.SortNameDesc:
+        cols = []string{`name COLLATE "C"`}
+    case data.SortQueue:
+        cols = []string{"(-priority)", "queue_order"}
+    }
+    cols = append(cols, "id")
+    if q.Sort.Desc() {
+        dir, cmp = "DESC", "<"
+    }
+    if cur != nil {
+        var keys []string
+        switch q.Sort {
+        case data.SortNameAsc, data.SortNameDesc:
+            keys = []string{arg(cur.Key)}
+        case data.SortQueue:
+            keys = []string{arg(-cur.Priority), arg(cur.QueueOrder)}
+        default:
+            keys = []string{arg(cur.createdAt())}
+        }
+        keys = append(keys, arg(cur.ID)+"::uuid")
+        where = append(where, "("+strings.Join(cols, ", ")+") "+cmp+" ("+strings.Join(keys, ", ")+")")
+    }
+
+    stmt := `SELECT ` + downloadColumns + ` FROM downloads`
+    if len(where) > 0 {
+        stmt += ` WHERE ` + strings.Join(where, " AND ")
+    }
+    stmt += ` ORDER BY ` + strings.Join(cols, ` `+dir+`, `) + ` ` + dir + ` LIMIT ` + arg(q.Limit+1)
+
+    rows, err := r.db.QueryContext(ctx, stmt, args...)
+    if err != nil {
+        if isInvalidUUID(err) { return nil, data.ErrInvalidCursor }
+        return nil, err
+    }
+    defer rows.Close()
+    page := &data.DownloadPage{Items: data.Downloads{}}
+    for rows.Next() {
+        dl, err := scanDownload(rows)
+        if err != nil { return nil, err }
+        page.Items = append(page.Items, dl)
+    }
+    if err := rows.Err(); err != nil { return nil, err }
+    if len(page.Items) > q.Limit {
+        page.Items = page.Items[:q.Limit]
+        page.NextCursor = encodeCursor(q.Sort, page.Items[len(page.Items)-1])
+    }
+    return page, nil
+}
+
 // Get implements DownloadReader.Get
 func (r *PostgresRepo) Get(ctx context.Context, id string) (*data.Download, error) {
-    row := r.db.QueryRowContext(ctx, `SELECT id,gid,source,target_path,name,files,status,desired_status,created_at FROM downloads WHERE id=$1`, id)
+    row := r.db.QueryRowContext(ctx, `SELECT `+downloadColumns+` FROM downloads WHERE id=$1`, id)
     dl, err := scanDownload(row)
     if err != nil {
         if errors.Is(err, sql.ErrNoRows) { return nil, data.ErrNotFound }
@@ -123,38 +233,90 @@ func (r *PostgresRepo) Get(ctx context.Context, id string) (*data.Download, erro
     return dl, nil
 }
 
+// GetPayload implements PayloadReader.
+func (r *PostgresRepo) GetPayload(ctx context.Context, id string) ([]byte, error) {
+    var payload []byte
+    if err := r.db.QueryRowContext(ctx, `SELECT payload FROM downloads WHERE id=$1`, id).Scan(&payload); err != nil {
+        if errors.Is(err, sql.ErrNoRows) { return nil, data.ErrNotFound }
+        return nil, err
+    }
+    return payload, nil
+}
+
 // Add implements DownloadWriter.Add (no fingerprint enforcement)
 func (r *PostgresRepo) Add(ctx context.Context, d *data.Download) (*data.Download, error) {
+    return r.AddRecorded(ctx, d, nil)
+}
+
+// AddRecorded implements TransitionRecorder.AddRecorded
+func (r *PostgresRepo) AddRecorded(ctx context.Context, d *data.Download, record TransitionFunc) (*data.Download, error) {
     id := uuid.NewString()
+    initQueueOrder(d)
     filesJSON, _ := json.Marshal(d.Files)
-    _, err := r.db.ExecContext(ctx, `INSERT INTO downloads (id,gid,source,target_path,name,files,status,desired_status,created_at,fingerprint) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)`,
-        id, d.GID, d.Source, d.TargetPath, d.Name, nullJSON(filesJSON), string(d.Status), string(d.DesiredStatus), d.CreatedAt, fp.Fingerprint(d.Source, d.TargetPath))
+    progressJSON, _ := json.Marshal(d.Progress)
+    optionsJSON, _ := json.Marshal(d.Options)
+    filterJSON, _ := json.Marshal(d.FileFilter)
+    ppJSON, _ := json.Marshal(d.PostProcess)
+    ppStatusJSON, _ := json.Marshal(d.PostProcessStatus)
+    labelsJSON, _ := json.Marshal(d.Labels)
+    retryJSON, _ := json.Marshal(d.Retry)
+    retryStatusJSON, _ := json.Marshal(d.RetryStatus)
+    failureJSON, _ := json.Marshal(d.Failure)
+    tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
     if err != nil { return nil, err }
-    return r.Get(ctx, id)
+    defer func() { _ = tx.Rollback() }()
+    _, err = tx.ExecContext(ctx, `INSERT INTO downloads (id,gid,source,target_path,name,files,status,desired_status,created_at,fingerprint,progress,priority,queue_order,options,payload,payload_type,fingerprint_version,file_filter,post_process,post_process_status,category,labels,retry,retry_status,failure) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25)`,
+        id, d.GID, d.Source, d.TargetPath, d.Name, nullJSON(filesJSON), string(d.Status), string(d.DesiredStatus), d.CreatedAt, fp.Fingerprint(d.Source, d.TargetPath), nullJSON(progressJSON), d.Priority, d.QueueOrder, nullJSON(optionsJSON), d.Payload, string(d.PayloadType), fp.Version, nullJSON(filterJSON), nullJSON(ppJSON), nullJSON(ppStatusJSON), d.Category, nullJSON(labelsJSON), nullJSON(retryJSON), nullJSON(retryStatusJSON), nullJSON(failureJSON))
+    if err != nil { return nil, err }
+    saved, err := insertedDownload(ctx, tx, id, record)
+    if err != nil { return nil, err }
+    if err := tx.Commit(); err != nil { return nil, err }
+    return saved, nil
 }
 
 // AddWithFingerprint implements atomic check-then-insert based on fingerprint.
 func (r *PostgresRepo) AddWithFingerprint(ctx context.Context, d *data.Download, fprint string) (*data.Download, bool, error) {
+    return r.AddWithFingerprintRecorded(ctx, d, fprint, nil)
+}
+
+// AddWithFingerprintRecorded implements TransitionRecorder.AddWithFingerprintRecorded
+func (r *PostgresRepo) AddWithFingerprintRecorded(ctx context.Context, d *data.Download, fprint string, record TransitionFunc) (*data.Download, bool, error) {
     id := uuid.NewString()
+    initQueueOrder(d)
     filesJSON, _ := json.Marshal(d.Files)
+    progressJSON, _ := json.Marshal(d.Progress)
+    optionsJSON, _ := json.Marshal(d.Options)
+    filterJSON, _ := json.Marshal(d.FileFilter)
+    ppJSON, _ := json.Marshal(d.PostProcess)
+    ppStatusJSON, _ := json.Marshal(d.PostProcessStatus)
+    labelsJSON, _ := json.Marshal(d.Labels)
+    retryJSON, _ := json.Marshal(d.Retry)
+    retryStatusJSON, _ := json.Marshal(d.RetryStatus)
+    failureJSON, _ := json.Marshal(d.Failure)
+    tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return nil, false, err }
+    defer func() { _ = tx.Rollback() }()
     // Try insert; on conflict do nothing, then fetch existing
-    err := r.db.QueryRowContext(ctx, `
+    err = tx.QueryRowContext(ctx, `
 WITH ins AS (
-    INSERT INTO downloads (id,gid,source,target_path,name,files,status,desired_status,created_at,fingerprint)
-    VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+    INSERT INTO downloads (id,gid,source,target_path,name,files,status,desired_status,created_at,fingerprint,progress,priority,queue_order,options,payload,payload_type,fingerprint_version,file_filter,post_process,post_process_status,category,labels,retry,retry_status,failure)
+    VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25)
     ON CONFLICT (fingerprint) DO NOTHING
     RETURNING id
 )
 SELECT id FROM ins
-`, id, d.GID, d.Source, d.TargetPath, d.Name, nullJSON(filesJSON), string(d.Status), string(d.DesiredStatus), d.CreatedAt, fprint).Scan(&id)
+`, id, d.GID, d.Source, d.TargetPath, d.Name, nullJSON(filesJSON), string(d.Status), string(d.DesiredStatus), d.CreatedAt, fprint, nullJSON(progressJSON), d.Priority, d.QueueOrder, nullJSON(optionsJSON), d.Payload, string(d.PayloadType), fp.Version, nullJSON(filterJSON), nullJSON(ppJSON), nullJSON(ppStatusJSON), d.Category, nullJSON(labelsJSON), nullJSON(retryJSON), nullJSON(retryStatusJSON), nullJSON(failureJSON)).Scan(&id)
     if err != nil && !errors.Is(err, sql.ErrNoRows) {
         return nil, false, err
     }
     if err == nil {
         // Inserted new row
-        dl, err := r.Get(ctx, id)
-        return dl, true, err
+        dl, err := insertedDownload(ctx, tx, id, record)
+        if err != nil { return nil, false, err }
+        if err := tx.Commit(); err != nil { return nil, false, err }
+        return dl, true, nil
     }
+    _ = tx.Rollback()
     // Fetch existing by fingerprint
     dl, err := r.GetByFingerprint(ctx, fprint)
     if err != nil { return nil, false, err }
@@ -163,6 +325,11 @@ SELECT id FROM ins
 
 // Update implements DownloadWriter.Update by fetching, mutating, and writing back with conflict detection.
 func (r *PostgresRepo) Update(ctx context.Context, id string, mutate func(*data.Download) error) (*data.Download, error) {
+    return r.UpdateRecorded(ctx, id, mutate, nil)
+}
+
+// UpdateRecorded implements TransitionRecorder.UpdateRecorded
+func (r *PostgresRepo) UpdateRecorded(ctx context.Context, id string, mutate func(*data.Download) error, record TransitionFunc) (*data.Download, error) {
     // Serialize updates per row using a transaction with SELECT ... FOR UPDATE
     tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
     if err != nil { return nil, err }
@@ -172,7 +339,7 @@ func (r *PostgresRepo) Update(ctx context.Context, id string, mutate func(*data.
     }()
 
     // Load the latest row under lock
-    row := tx.QueryRowContext(ctx, `SELECT id,gid,source,target_path,name,files,status,desired_status,created_at FROM downloads WHERE id=$1 FOR UPDATE`, id)
+    row := tx.QueryRowContext(ctx, `SELECT `+downloadColumns+` FROM downloads WHERE id=$1 FOR UPDATE`, id)
     cur, err := scanDownload(row)
     if err != nil {
         if errors.Is(err, sql.ErrNoRows) { return nil, data.ErrNotFound }
@@ -190,13 +357,29 @@ func (r *PostgresRepo) Update(ctx context.Context, id string, mutate func(*data.
         return cur, nil
     }
 
-    // Preserve original creation time (immutable) and write back other columns.
-    // Recompute fingerprint for potential conflict
-    newFP := fp.Fingerprint(next.Source, next.TargetPath)
+    // Preserve original creation time (immutable) and write back other
+    // columns. The payload is immutable too and is not read by scanDownload,
+    // so it is left alone.
+    // Recompute the fingerprint only when its inputs change. Other writes
+    // keep the stored fingerprint and version, so a legacy duplicate left on
+    // its old fingerprint by RefingerprintDownloads stays updatable.
+    var newFP, fpVersion any
+    if next.Source != cur.Source || next.TargetPath != cur.TargetPath {
+        newFP, fpVersion = fp.Fingerprint(next.Source, next.TargetPath), fp.Version
+    }
     filesJSON, _ := json.Marshal(next.Files)
+    progressJSON, _ := json.Marshal(next.Progress)
+    optionsJSON, _ := json.Marshal(next.Options)
+    filterJSON, _ := json.Marshal(next.FileFilter)
+    ppJSON, _ := json.Marshal(next.PostProcess)
+    ppStatusJSON, _ := json.Marshal(next.PostProcessStatus)
+    labelsJSON, _ := json.Marshal(next.Labels)
+    retryJSON, _ := json.Marshal(next.Retry)
+    retryStatusJSON, _ := json.Marshal(next.RetryStatus)
+    failureJSON, _ := json.Marshal(next.Failure)
 
-    if _, err := tx.ExecContext(ctx, `UPDATE downloads SET gid=$1, source=$2, target_path=$3, name=$4, files=$5, status=$6, desired_status=$7, fingerprint=$8 WHERE id=$9`,
-        next.GID, next.Source, next.TargetPath, next.Name, nullJSON(filesJSON), string(next.Status), string(next.DesiredStatus), newFP, id); err != nil {
+    if _, err := tx.ExecContext(ctx, `UPDATE downloads SET gid=$1, source=$2, target_path=$3, name=$4, files=$5, status=$6, desired_status=$7, fingerprint=COALESCE($8, fingerprint), progress=$9, priority=$10, queue_order=$11, options=$12, fingerprint_version=COALESCE($13, fingerprint_version), file_filter=$14, post_process=$15, post_process_status=$16, category=$17, labels=$18, retry=$19, retry_status=$20, failure=$21 WHERE id=$22`,
+        next.GID, next.Source, next.TargetPath, next.Name, nullJSON(filesJSON), string(next.Status), string(next.DesiredStatus), newFP, nullJSON(progressJSON), next.Priority, next.QueueOrder, nullJSON(optionsJSON), fpVersion, nullJSON(filterJSON), nullJSON(ppJSON), nullJSON(ppStatusJSON), next.Category, nullJSON(labelsJSON), nullJSON(retryJSON), nullJSON(retryStatusJSON), nullJSON(failureJSON), id); err != nil {
         if isUniqueViolation(err) {
             return nil, data.ErrConflict
         }
@@ -204,13 +387,64 @@ func (r *PostgresRepo) Update(ctx context.Context, id string, mutate func(*data.
     }
 
     // Return the updated snapshot from within the txn for consistency
-    row2 := tx.QueryRowContext(ctx, `SELECT id,gid,source,target_path,name,files,status,desired_status,created_at FROM downloads WHERE id=$1`, id)
+    row2 := tx.QueryRowContext(ctx, `SELECT `+downloadColumns+` FROM downloads WHERE id=$1`, id)
     updated, err := scanDownload(row2)
     if err != nil { return nil, err }
+    if err := recordTransition(ctx, tx, record, cur, updated); err != nil { return nil, err }
     if err := tx.Commit(); err != nil { return nil, err }
     return updated, nil
 }
 
+// insertedDownload reads back the download id inserted within tx and
+// records its insert.
+func insertedDownload(ctx context.Context, tx *sql.Tx, id string, record TransitionFunc) (*data.Download, error) {
+    saved, err := scanDownload(tx.QueryRowContext(ctx, `SELECT `+downloadColumns+` FROM downloads WHERE id=$1`, id))
+    if err != nil { return nil, err }
+    if err := recordTransition(ctx, tx, record, nil, saved); err != nil { return nil, err }
+    return saved, nil
+}
+
+// RefingerprintDownloads recomputes fingerprints stored with an older
+// fp.Version, oldest rows first. A row whose new fingerprint is already
+// taken is a duplicate the older rules missed: it keeps its old fingerprint
+// and version so the UNIQUE constraint holds, is counted in duplicates, and
+// is retried on the next call. Update leaves both alone unless the source or
+// target path changes, so such rows can still be updated.
+func (r *PostgresRepo) RefingerprintDownloads(ctx context.Context) (updated, duplicates int, err error) {
+    rows, err := r.db.QueryContext(ctx, `SELECT id, source, target_path FROM downloads WHERE fingerprint_version < $1 ORDER BY created_at, id`, fp.Version)
+    if err != nil { return 0, 0, err }
+    type stale struct{ id, source, target string }
+    var todo []stale
+    for rows.Next() {
+        var s stale
+        if err := rows.Scan(&s.id, &s.source, &s.target); err != nil {
+            _ = rows.Close()
+            return 0, 0, err
+        }
+        todo = append(todo, s)
+    }
+    if err := rows.Close(); err != nil { return 0, 0, err }
+
+    for _, s := range todo {
+        res, err := r.db.ExecContext(ctx, `UPDATE downloads SET fingerprint=$1, fingerprint_version=$2 WHERE id=$3 AND NOT EXISTS (SELECT 1 FROM downloads WHERE fingerprint=$1 AND id<>$3)`,
+            fp.Fingerprint(s.source, s.target), fp.Version, s.id)
+        if err != nil {
+            // A concurrent insert claimed the fingerprint first.
+            if isUniqueViolation(err) {
+                duplicates++
+                continue
+            }
+            return updated, duplicates, err
+        }
+        if n, _ := res.RowsAffected(); n == 0 {
+            duplicates++
+            continue
+        }
+        updated++
+    }
+    return updated, duplicates, nil
+}
+
 // Delete implements DownloadWriter.Delete
 func (r *PostgresRepo) Delete(ctx context.Context, id string) error {
     res, err := r.db.ExecContext(ctx, `DELETE FROM downloads WHERE id=$1`, id)
@@ -222,7 +456,7 @@ func (r *PostgresRepo) Delete(ctx context.Context, id string) error {
 
 // GetByFingerprint implements DownloadFinder
 func (r *PostgresRepo) GetByFingerprint(ctx context.Context, fprint string) (*data.Download, error) {
-    row := r.db.QueryRowContext(ctx, `SELECT id,gid,source,target_path,name,files,status,desired_status,created_at FROM downloads WHERE fingerprint=$1`, fprint)
+    row := r.db.QueryRowContext(ctx, `SELECT `+downloadColumns+` FROM downloads WHERE fingerprint=$1`, fprint)
     dl, err := scanDownload(row)
     if err != nil {
         if errors.Is(err, sql.ErrNoRows) { return nil, data.ErrNotFound }
@@ -237,11 +471,13 @@ type rowScanner interface{ Scan(dest ...any) error }
 
 func scanDownload(rs rowScanner) (*data.Download, error) {
     var (
-        id, gid, source, target, name, status, desired string
+        id, gid, source, target, name, status, desired, payloadType, category string
         created time.Time
-        filesRaw sql.NullString
+        filesRaw, progressRaw, optionsRaw, filterRaw, ppRaw, ppStatusRaw, labelsRaw, retryRaw, retryStatusRaw, failureRaw sql.NullString
+        priority int
+        queueOrder int64
     )
-    if err := rs.Scan(&id, &gid, &source, &target, &name, &filesRaw, &status, &desired, &created); err != nil {
+    if err := rs.Scan(&id, &gid, &source, &target, &name, &filesRaw, &status, &desired, &created, &progressRaw, &priority, &queueOrder, &optionsRaw, &payloadType, &filterRaw, &ppRaw, &ppStatusRaw, &category, &labelsRaw, &retryRaw, &retryStatusRaw, &failureRaw); err != nil {
         return nil, err
     }
     dl := &data.Download{
@@ -253,20 +489,102 @@ func scanDownload(rs rowScanner) (*data.Download, error) {
         Status:       data.DownloadStatus(status),
         DesiredStatus:data.DownloadStatus(desired),
         CreatedAt:    created,
+        Priority:     priority,
+        QueueOrder:   queueOrder,
+        PayloadType:  data.PayloadType(payloadType),
+        Category:     category,
     }
     if filesRaw.Valid && filesRaw.String != "" {
         _ = json.Unmarshal([]byte(filesRaw.String), &dl.Files)
     }
+    if progressRaw.Valid && progressRaw.String != "" {
+        var p data.DownloadProgress
+        if json.Unmarshal([]byte(progressRaw.String), &p) == nil {
+            dl.Progress = &p
+        }
+    }
+    if optionsRaw.Valid && optionsRaw.String != "" {
+        var o data.DownloadOptions
+        if json.Unmarshal([]byte(optionsRaw.String), &o) == nil {
+            dl.Options = &o
+        }
+    }
+    if filterRaw.Valid && filterRaw.String != "" {
+        var f data.FileFilter
+        if json.Unmarshal([]byte(filterRaw.String), &f) == nil {
+            dl.FileFilter = &f
+        }
+    }
+    if ppRaw.Valid && ppRaw.String != "" {
+        var pp data.PostProcess
+        if json.Unmarshal([]byte(ppRaw.String), &pp) == nil {
+            dl.PostProcess = &pp
+        }
+    }
+    if labelsRaw.Valid && labelsRaw.String != "" {
+        _ = json.Unmarshal([]byte(labelsRaw.String), &dl.Labels)
+    }
+    if ppStatusRaw.Valid && ppStatusRaw.String != "" {
+        var ps data.PostProcessStatus
+        if json.Unmarshal([]byte(ppStatusRaw.String), &ps) == nil {
+            dl.PostProcessStatus = &ps
+        }
+    }
+    if retryRaw.Valid && retryRaw.String != "" {
+        var rp data.RetryPolicy
+        if json.Unmarshal([]byte(retryRaw.String), &rp) == nil {
+            dl.Retry = &rp
+        }
+    }
+    if retryStatusRaw.Valid && retryStatusRaw.String != "" {
+        var rs data.RetryStatus
+        if json.Unmarshal([]byte(retryStatusRaw.String), &rs) == nil {
+            dl.RetryStatus = &rs
+        }
+    }
+    if failureRaw.Valid && failureRaw.String != "" {
+        var f data.Failure
+        if json.Unmarshal([]byte(failureRaw.String), &f) == nil {
+            dl.Failure = &f
+        }
+    }
     return dl, nil
 }
 
 func equalDownloads(a, b *data.Download) bool {
     if a == nil || b == nil { return a == b }
-    if a.ID != b.ID || a.GID != b.GID || a.Source != b.Source || a.TargetPath != b.TargetPath || a.Name != b.Name || a.Status != b.Status || a.DesiredStatus != b.DesiredStatus || !a.CreatedAt.Equal(b.CreatedAt) { return false }
+    if a.ID != b.ID || a.GID != b.GID || a.Source != b.Source || a.TargetPath != b.TargetPath || a.Name != b.Name || a.Status != b.Status || a.DesiredStatus != b.DesiredStatus || !a.CreatedAt.Equal(b.CreatedAt) || a.Priority != b.Priority || a.QueueOrder != b.QueueOrder || a.PayloadType != b.PayloadType || !bytes.Equal(a.Payload, b.Payload) || a.Category != b.Category { return false }
     // compare files shallowly via JSON to avoid manual deep compare
     aj, _ := json.Marshal(a.Files)
     bj, _ := json.Marshal(b.Files)
-    return string(aj) == string(bj)
+    if string(aj) != string(bj) { return false }
+    ap, _ := json.Marshal(a.Progress)
+    bp, _ := json.Marshal(b.Progress)
+    if string(ap) != string(bp) { return false }
+    ao, _ := json.Marshal(a.Options)
+    bo, _ := json.Marshal(b.Options)
+    if string(ao) != string(bo) { return false }
+    af, _ := json.Marshal(a.FileFilter)
+    bf, _ := json.Marshal(b.FileFilter)
+    if string(af) != string(bf) { return false }
+    app, _ := json.Marshal(a.PostProcess)
+    bpp, _ := json.Marshal(b.PostProcess)
+    if string(app) != string(bpp) { return false }
+    al, _ := json.Marshal(a.Labels)
+    bl, _ := json.Marshal(b.Labels)
+    if string(al) != string(bl) { return false }
+    aps, _ := json.Marshal(a.PostProcessStatus)
+    bps, _ := json.Marshal(b.PostProcessStatus)
+    if string(aps) != string(bps) { return false }
+    ar, _ := json.Marshal(a.Retry)
+    br, _ := json.Marshal(b.Retry)
+    if string(ar) != string(br) { return false }
+    ars, _ := json.Marshal(a.RetryStatus)
+    brs, _ := json.Marshal(b.RetryStatus)
+    if string(ars) != string(brs) { return false }
+    afl, _ := json.Marshal(a.Failure)
+    bfl, _ := json.Marshal(b.Failure)
+    return string(afl) == string(bfl)
 }
 
 func isUniqueViolation(err error) bool {
@@ -280,3 +598,26 @@ func nullJSON(b []byte) any {
     if len(b) == 0 || string(b) == "null" { return nil }
     return string(b)
 }
+
+func statusStrings(ss []data.DownloadStatus) []string {
+    out := make([]string, len(ss))
+    for i, s := range ss { out[i] = string(s) }
+    return out
+}
+
+// escapeLike escapes LIKE wildcards so s matches literally (ESCAPE '\').
+func escapeLike(s string) string {
+    return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func isForeignKeyViolation(err error) bool {
+    if err == nil { return false }
+    return strings.Contains(strings.ToLower(err.Error()), "foreign key constraint")
+}
+
+// isInvalidUUID detects Postgres rejecting a malformed UUID literal, which
+// callers treat the same as a missing row.
+func isInvalidUUID(err error) bool {
+    if err == nil { return false }
+    return strings.Contains(strings.ToLower(err.Error()), "invalid input syntax for type uuid")
+}
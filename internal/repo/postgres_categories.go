@@ -0,0 +1,111 @@
+package repo
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+
+    "github.com/tinoosan/torrus/internal/data"
+)
+
+var _ CategoryRepo = (*PostgresRepo)(nil)
+
+const categoryColumns = `name,target_path,options,post_process,created_at`
+
+// ListCategories implements CategoryRepo.ListCategories
+func (r *PostgresRepo) ListCategories(ctx context.Context) (data.Categories, error) {
+    rows, err := r.db.QueryContext(ctx, `SELECT `+categoryColumns+` FROM categories ORDER BY name ASC`)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    out := data.Categories{}
+    for rows.Next() {
+        c, err := scanCategory(rows)
+        if err != nil { return nil, err }
+        out = append(out, c)
+    }
+    return out, rows.Err()
+}
+
+// GetCategory implements CategoryRepo.GetCategory
+func (r *PostgresRepo) GetCategory(ctx context.Context, name string) (*data.Category, error) {
+    c, err := scanCategory(r.db.QueryRowContext(ctx, `SELECT `+categoryColumns+` FROM categories WHERE name=$1`, name))
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) { return nil, data.ErrCategoryNotFound }
+        return nil, err
+    }
+    return c, nil
+}
+
+// AddCategory implements CategoryRepo.AddCategory
+func (r *PostgresRepo) AddCategory(ctx context.Context, c *data.Category) (*data.Category, error) {
+    optionsJSON, _ := json.Marshal(c.Options)
+    ppJSON, _ := json.Marshal(c.PostProcess)
+    _, err := r.db.ExecContext(ctx, `INSERT INTO categories (`+categoryColumns+`) VALUES ($1,$2,$3,$4,$5)`,
+        c.Name, c.TargetPath, nullJSON(optionsJSON), nullJSON(ppJSON), c.CreatedAt)
+    if err != nil {
+        if isUniqueViolation(err) { return nil, data.ErrCategoryExists }
+        return nil, err
+    }
+    return r.GetCategory(ctx, c.Name)
+}
+
+// UpdateCategory implements CategoryRepo.UpdateCategory using SELECT ... FOR UPDATE.
+func (r *PostgresRepo) UpdateCategory(ctx context.Context, name string, mutate func(*data.Category) error) (*data.Category, error) {
+    tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return nil, err }
+    defer func() { _ = tx.Rollback() }()
+
+    cur, err := scanCategory(tx.QueryRowContext(ctx, `SELECT `+categoryColumns+` FROM categories WHERE name=$1 FOR UPDATE`, name))
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) { return nil, data.ErrCategoryNotFound }
+        return nil, err
+    }
+    next := cur.Clone()
+    if mutate != nil {
+        if err := mutate(next); err != nil { return nil, err }
+    }
+    optionsJSON, _ := json.Marshal(next.Options)
+    ppJSON, _ := json.Marshal(next.PostProcess)
+    if _, err := tx.ExecContext(ctx, `UPDATE categories SET target_path=$1, options=$2, post_process=$3 WHERE name=$4`,
+        next.TargetPath, nullJSON(optionsJSON), nullJSON(ppJSON), name); err != nil {
+        return nil, err
+    }
+    if err := tx.Commit(); err != nil { return nil, err }
+    next.Name = cur.Name
+    next.CreatedAt = cur.CreatedAt
+    return next, nil
+}
+
+// DeleteCategory implements CategoryRepo.DeleteCategory. Downloads keep
+// the category name they were created with.
+func (r *PostgresRepo) DeleteCategory(ctx context.Context, name string) error {
+    res, err := r.db.ExecContext(ctx, `DELETE FROM categories WHERE name=$1`, name)
+    if err != nil { return err }
+    n, _ := res.RowsAffected()
+    if n == 0 { return data.ErrCategoryNotFound }
+    return nil
+}
+
+func scanCategory(rs rowScanner) (*data.Category, error) {
+    var (
+        c                 data.Category
+        optionsRaw, ppRaw sql.NullString
+    )
+    if err := rs.Scan(&c.Name, &c.TargetPath, &optionsRaw, &ppRaw, &c.CreatedAt); err != nil {
+        return nil, err
+    }
+    if optionsRaw.Valid && optionsRaw.String != "" {
+        var o data.DownloadOptions
+        if json.Unmarshal([]byte(optionsRaw.String), &o) == nil {
+            c.Options = &o
+        }
+    }
+    if ppRaw.Valid && ppRaw.String != "" {
+        var pp data.PostProcess
+        if json.Unmarshal([]byte(ppRaw.String), &pp) == nil {
+            c.PostProcess = &pp
+        }
+    }
+    return &c, nil
+}
@@ -0,0 +1,60 @@
+package repo
+
+import (
+    "context"
+    "database/sql"
+
+    "github.com/tinoosan/torrus/internal/data"
+)
+
+var (
+    _ HistoryRepo        = (*PostgresRepo)(nil)
+    _ TransitionRecorder = (*PostgresRepo)(nil)
+)
+
+// execer is satisfied by *sql.DB and *sql.Tx.
+type execer interface {
+    ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// AddTransition implements HistoryRepo.AddTransition
+func (r *PostgresRepo) AddTransition(ctx context.Context, t *data.Transition) error {
+    return insertTransition(ctx, r.db, t)
+}
+
+// recordTransition appends the transition record builds for a write from
+// before to after, if any, within tx.
+func recordTransition(ctx context.Context, tx *sql.Tx, record TransitionFunc, before, after *data.Download) error {
+    if record == nil { return nil }
+    t := record(before, after)
+    if t == nil { return nil }
+    return insertTransition(ctx, tx, t)
+}
+
+func insertTransition(ctx context.Context, ex execer, t *data.Transition) error {
+    _, err := ex.ExecContext(ctx, `INSERT INTO download_transitions (download_id,from_status,to_status,desired_status,cause,event,request_id,occurred_at) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`,
+        t.DownloadID, string(t.From), string(t.To), string(t.DesiredStatus), string(t.Cause), t.Event, t.RequestID, t.Timestamp)
+    return err
+}
+
+// ListTransitions implements HistoryRepo.ListTransitions
+func (r *PostgresRepo) ListTransitions(ctx context.Context, downloadID string) (data.Transitions, error) {
+    rows, err := r.db.QueryContext(ctx, `SELECT from_status,to_status,desired_status,cause,event,request_id,occurred_at FROM download_transitions WHERE download_id=$1 ORDER BY id ASC`, downloadID)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    out := data.Transitions{}
+    for rows.Next() {
+        var (
+            t                         data.Transition
+            from, to, desired, cause string
+        )
+        if err := rows.Scan(&from, &to, &desired, &cause, &t.Event, &t.RequestID, &t.Timestamp); err != nil {
+            return nil, err
+        }
+        t.DownloadID = downloadID
+        t.From, t.To, t.DesiredStatus = data.DownloadStatus(from), data.DownloadStatus(to), data.DownloadStatus(desired)
+        t.Cause = data.TransitionCause(cause)
+        out = append(out, &t)
+    }
+    return out, rows.Err()
+}
@@ -0,0 +1,38 @@
+package repo
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+
+    "github.com/tinoosan/torrus/internal/data"
+)
+
+var _ SettingsRepo = (*PostgresRepo)(nil)
+
+// settingBandwidth is the settings row holding data.BandwidthSettings.
+const settingBandwidth = "bandwidth"
+
+// GetBandwidth implements SettingsRepo.GetBandwidth
+func (r *PostgresRepo) GetBandwidth(ctx context.Context) (*data.BandwidthSettings, error) {
+    var raw []byte
+    err := r.db.QueryRowContext(ctx, `SELECT value FROM settings WHERE key=$1`, settingBandwidth).Scan(&raw)
+    if errors.Is(err, sql.ErrNoRows) { return &data.BandwidthSettings{}, nil }
+    if err != nil { return nil, err }
+    var s data.BandwidthSettings
+    if err := json.Unmarshal(raw, &s); err != nil { return nil, err }
+    s.Active = nil
+    return &s, nil
+}
+
+// SaveBandwidth implements SettingsRepo.SaveBandwidth
+func (r *PostgresRepo) SaveBandwidth(ctx context.Context, s *data.BandwidthSettings) error {
+    cp := s.Clone()
+    cp.Active = nil
+    raw, err := json.Marshal(cp)
+    if err != nil { return err }
+    _, err = r.db.ExecContext(ctx, `INSERT INTO settings (key, value, updated_at) VALUES ($1,$2,$3)
+ON CONFLICT (key) DO UPDATE SET value=EXCLUDED.value, updated_at=EXCLUDED.updated_at`, settingBandwidth, raw, s.UpdatedAt)
+    return err
+}
@@ -0,0 +1,180 @@
+package repo
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/tinoosan/torrus/internal/data"
+)
+
+var _ WebhookRepo = (*PostgresRepo)(nil)
+
+const webhookColumns = `id,url,secret,events,enabled,created_at`
+
+const deliveryColumns = `id,webhook_id,event,download_id,payload,state,attempts,response_code,last_error,created_at,updated_at,next_attempt_at`
+
+// ListWebhooks implements WebhookRepo.ListWebhooks
+func (r *PostgresRepo) ListWebhooks(ctx context.Context) (data.Webhooks, error) {
+    rows, err := r.db.QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY created_at ASC`)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    out := data.Webhooks{}
+    for rows.Next() {
+        w, err := scanWebhook(rows)
+        if err != nil { return nil, err }
+        out = append(out, w)
+    }
+    return out, rows.Err()
+}
+
+// GetWebhook implements WebhookRepo.GetWebhook
+func (r *PostgresRepo) GetWebhook(ctx context.Context, id string) (*data.Webhook, error) {
+    w, err := scanWebhook(r.db.QueryRowContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id=$1`, id))
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) || isInvalidUUID(err) { return nil, data.ErrWebhookNotFound }
+        return nil, err
+    }
+    return w, nil
+}
+
+// AddWebhook implements WebhookRepo.AddWebhook
+func (r *PostgresRepo) AddWebhook(ctx context.Context, w *data.Webhook) (*data.Webhook, error) {
+    id := uuid.NewString()
+    eventsJSON, _ := json.Marshal(w.Events)
+    _, err := r.db.ExecContext(ctx, `INSERT INTO webhooks (id,url,secret,events,enabled,created_at) VALUES ($1,$2,$3,$4,$5,$6)`,
+        id, w.URL, w.Secret, nullJSON(eventsJSON), w.Enabled, w.CreatedAt)
+    if err != nil { return nil, err }
+    return r.GetWebhook(ctx, id)
+}
+
+// UpdateWebhook implements WebhookRepo.UpdateWebhook using SELECT ... FOR UPDATE.
+func (r *PostgresRepo) UpdateWebhook(ctx context.Context, id string, mutate func(*data.Webhook) error) (*data.Webhook, error) {
+    tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
+    if err != nil { return nil, err }
+    defer func() { _ = tx.Rollback() }()
+
+    cur, err := scanWebhook(tx.QueryRowContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id=$1 FOR UPDATE`, id))
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) || isInvalidUUID(err) { return nil, data.ErrWebhookNotFound }
+        return nil, err
+    }
+    next := cur.Clone()
+    if mutate != nil {
+        if err := mutate(next); err != nil { return nil, err }
+    }
+    eventsJSON, _ := json.Marshal(next.Events)
+    if _, err := tx.ExecContext(ctx, `UPDATE webhooks SET url=$1, secret=$2, events=$3, enabled=$4 WHERE id=$5`,
+        next.URL, next.Secret, nullJSON(eventsJSON), next.Enabled, id); err != nil {
+        return nil, err
+    }
+    if err := tx.Commit(); err != nil { return nil, err }
+    next.ID = cur.ID
+    next.CreatedAt = cur.CreatedAt
+    return next, nil
+}
+
+// DeleteWebhook implements WebhookRepo.DeleteWebhook; deliveries cascade.
+func (r *PostgresRepo) DeleteWebhook(ctx context.Context, id string) error {
+    res, err := r.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id=$1`, id)
+    if err != nil {
+        if isInvalidUUID(err) { return data.ErrWebhookNotFound }
+        return err
+    }
+    n, _ := res.RowsAffected()
+    if n == 0 { return data.ErrWebhookNotFound }
+    return nil
+}
+
+// AddDelivery implements WebhookRepo.AddDelivery
+func (r *PostgresRepo) AddDelivery(ctx context.Context, d *data.WebhookDelivery) (*data.WebhookDelivery, error) {
+    cp := d.Clone()
+    cp.ID = uuid.NewString()
+    _, err := r.db.ExecContext(ctx, `INSERT INTO webhook_deliveries (`+deliveryColumns+`) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)`,
+        cp.ID, cp.WebhookID, string(cp.Event), cp.DownloadID, string(cp.Payload), string(cp.State), cp.Attempts, cp.ResponseCode, cp.LastError, cp.CreatedAt, cp.UpdatedAt, nullTime(cp.NextAttemptAt))
+    if err != nil {
+        if isForeignKeyViolation(err) { return nil, data.ErrWebhookNotFound }
+        return nil, err
+    }
+    d.ID = cp.ID
+    return cp, nil
+}
+
+// SaveDelivery implements WebhookRepo.SaveDelivery
+func (r *PostgresRepo) SaveDelivery(ctx context.Context, d *data.WebhookDelivery) error {
+    res, err := r.db.ExecContext(ctx, `UPDATE webhook_deliveries SET state=$1, attempts=$2, response_code=$3, last_error=$4, updated_at=$5, next_attempt_at=$6 WHERE id=$7`,
+        string(d.State), d.Attempts, d.ResponseCode, d.LastError, d.UpdatedAt, nullTime(d.NextAttemptAt), d.ID)
+    if err != nil { return err }
+    n, _ := res.RowsAffected()
+    if n == 0 { return data.ErrNotFound }
+    return nil
+}
+
+// ListDeliveries implements WebhookRepo.ListDeliveries
+func (r *PostgresRepo) ListDeliveries(ctx context.Context, webhookID string, limit int) (data.WebhookDeliveries, error) {
+    if _, err := r.GetWebhook(ctx, webhookID); err != nil { return nil, err }
+    if limit <= 0 { limit = 100 }
+    rows, err := r.db.QueryContext(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE webhook_id=$1 ORDER BY created_at DESC LIMIT $2`, webhookID, limit)
+    if err != nil { return nil, err }
+    return collectDeliveries(rows)
+}
+
+// PendingDeliveries implements WebhookRepo.PendingDeliveries
+func (r *PostgresRepo) PendingDeliveries(ctx context.Context) (data.WebhookDeliveries, error) {
+    rows, err := r.db.QueryContext(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE state=$1 ORDER BY created_at ASC`, string(data.DeliveryPending))
+    if err != nil { return nil, err }
+    return collectDeliveries(rows)
+}
+
+func collectDeliveries(rows *sql.Rows) (data.WebhookDeliveries, error) {
+    defer rows.Close()
+    out := data.WebhookDeliveries{}
+    for rows.Next() {
+        d, err := scanDelivery(rows)
+        if err != nil { return nil, err }
+        out = append(out, d)
+    }
+    return out, rows.Err()
+}
+
+func scanWebhook(rs rowScanner) (*data.Webhook, error) {
+    var (
+        w         data.Webhook
+        eventsRaw sql.NullString
+    )
+    if err := rs.Scan(&w.ID, &w.URL, &w.Secret, &eventsRaw, &w.Enabled, &w.CreatedAt); err != nil {
+        return nil, err
+    }
+    if eventsRaw.Valid && eventsRaw.String != "" {
+        _ = json.Unmarshal([]byte(eventsRaw.String), &w.Events)
+    }
+    return &w, nil
+}
+
+func scanDelivery(rs rowScanner) (*data.WebhookDelivery, error) {
+    var (
+        d              data.WebhookDelivery
+        event, state   string
+        payload        string
+        next           sql.NullTime
+    )
+    if err := rs.Scan(&d.ID, &d.WebhookID, &event, &d.DownloadID, &payload, &state, &d.Attempts, &d.ResponseCode, &d.LastError, &d.CreatedAt, &d.UpdatedAt, &next); err != nil {
+        return nil, err
+    }
+    d.Event = data.WebhookEvent(event)
+    d.State = data.DeliveryState(state)
+    d.Payload = json.RawMessage(payload)
+    if next.Valid {
+        t := next.Time
+        d.NextAttemptAt = &t
+    }
+    return &d, nil
+}
+
+func nullTime(t *time.Time) any {
+    if t == nil { return nil }
+    return *t
+}
@@ -0,0 +1,16 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/tinoosan/torrus/internal/data"
+)
+
+// SettingsRepo persists global settings.
+type SettingsRepo interface {
+	// GetBandwidth returns the stored bandwidth settings, or zero settings
+	// (no limits, no schedule) when none were saved.
+	GetBandwidth(ctx context.Context) (*data.BandwidthSettings, error)
+	// SaveBandwidth replaces the stored bandwidth settings.
+	SaveBandwidth(ctx context.Context, s *data.BandwidthSettings) error
+}
@@ -0,0 +1,29 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/tinoosan/torrus/internal/data"
+)
+
+// WebhookRepo persists webhook subscriptions and their delivery log.
+type WebhookRepo interface {
+	ListWebhooks(ctx context.Context) (data.Webhooks, error)
+	GetWebhook(ctx context.Context, id string) (*data.Webhook, error)
+	// AddWebhook inserts a webhook and assigns it a new ID.
+	AddWebhook(ctx context.Context, w *data.Webhook) (*data.Webhook, error)
+	// UpdateWebhook applies mutate atomically, like DownloadWriter.Update.
+	UpdateWebhook(ctx context.Context, id string, mutate func(*data.Webhook) error) (*data.Webhook, error)
+	// DeleteWebhook removes a webhook together with its delivery log.
+	DeleteWebhook(ctx context.Context, id string) error
+
+	// AddDelivery inserts a delivery record and assigns it a new ID.
+	AddDelivery(ctx context.Context, d *data.WebhookDelivery) (*data.WebhookDelivery, error)
+	// SaveDelivery overwrites the mutable fields of an existing delivery.
+	SaveDelivery(ctx context.Context, d *data.WebhookDelivery) error
+	// ListDeliveries returns the most recent deliveries for a webhook,
+	// newest first, up to limit entries.
+	ListDeliveries(ctx context.Context, webhookID string, limit int) (data.WebhookDeliveries, error)
+	// PendingDeliveries returns deliveries that still await an attempt.
+	PendingDeliveries(ctx context.Context) (data.WebhookDeliveries, error)
+}
@@ -0,0 +1,118 @@
+// Package resync periodically re-attaches the downloader to the backend tasks
+// recorded in the repository, so state survives Torrus restarts.
+package resync
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/downloader"
+	"github.com/tinoosan/torrus/internal/metrics"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+// DefaultInterval is how often a resync pass runs after the startup pass.
+const DefaultInterval = 5 * time.Minute
+
+// liveStatuses are the statuses whose downloads may still have a task in the
+// backend.
+var liveStatuses = []data.DownloadStatus{data.StatusQueued, data.StatusActive, data.StatusPaused, data.StatusSeeding}
+
+// Runner feeds every non-terminal download with a GID to a
+// downloader.Resyncer, once at start and then on a fixed interval. State
+// changes are reported by the downloader as events, so they reach the
+// repository through the reconciler like any live notification.
+type Runner struct {
+	repo     repo.DownloadReader
+	rs       downloader.Resyncer
+	log      *slog.Logger
+	interval time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a Runner. A non-positive interval uses DefaultInterval.
+func New(log *slog.Logger, r repo.DownloadReader, rs downloader.Resyncer, interval time.Duration) *Runner {
+	if log == nil {
+		log = slog.Default()
+	}
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Runner{repo: r, rs: rs, log: log, interval: interval}
+}
+
+// Start runs a pass immediately and then every interval until Stop.
+func (r *Runner) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		t := time.NewTicker(r.interval)
+		defer t.Stop()
+		for {
+			if err := r.Pass(ctx); err != nil && ctx.Err() == nil {
+				r.log.Error("resync failed", "err", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+// Stop terminates the loop and waits for an in-flight pass.
+func (r *Runner) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+		r.wg.Wait()
+	}
+}
+
+// Pass performs a single resync and reports orphaned backend tasks.
+func (r *Runner) Pass(ctx context.Context) error {
+	ds, err := r.live(ctx)
+	if err != nil {
+		metrics.Resyncs.WithLabelValues("failed").Inc()
+		return err
+	}
+	orphans, err := r.rs.Resync(ctx, ds)
+	if err != nil {
+		metrics.Resyncs.WithLabelValues("failed").Inc()
+		return err
+	}
+	for _, gid := range orphans {
+		r.log.Warn("resync: backend task has no download", "gid", gid)
+	}
+	metrics.OrphanedTasks.Set(float64(len(orphans)))
+	metrics.Resyncs.WithLabelValues("succeeded").Inc()
+	r.log.Info("resync complete", "downloads", len(ds), "orphans", len(orphans))
+	return nil
+}
+
+// live lists the downloads that may have a backend task.
+func (r *Runner) live(ctx context.Context) (data.Downloads, error) {
+	q := data.DownloadQuery{Statuses: liveStatuses, Limit: data.MaxQueryLimit}
+	var out data.Downloads
+	for {
+		page, err := r.repo.Query(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range page.Items {
+			if d.GID != "" {
+				out = append(out, d)
+			}
+		}
+		if page.NextCursor == "" {
+			return out, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
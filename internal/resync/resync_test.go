@@ -0,0 +1,58 @@
+package resync
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+type stubResyncer struct {
+	got     data.Downloads
+	orphans []string
+	err     error
+}
+
+func (s *stubResyncer) Resync(ctx context.Context, ds data.Downloads) ([]string, error) {
+	s.got = ds
+	return s.orphans, s.err
+}
+
+// TestPassSelectsLiveDownloadsWithGID ensures only non-terminal downloads
+// that reference a backend task are handed to the downloader.
+func TestPassSelectsLiveDownloadsWithGID(t *testing.T) {
+	ctx := context.Background()
+	r := repo.NewInMemoryDownloadRepo()
+	for _, d := range []*data.Download{
+		{Source: "1", TargetPath: "/t", Status: data.StatusActive, GID: "g1"},
+		{Source: "2", TargetPath: "/t", Status: data.StatusPaused, GID: "g2"},
+		{Source: "3", TargetPath: "/t", Status: data.StatusQueued},
+		{Source: "4", TargetPath: "/t", Status: data.StatusComplete, GID: "g4"},
+		{Source: "5", TargetPath: "/t", Status: data.StatusQueued, GID: "g5"},
+	} {
+		if _, err := r.Add(ctx, d); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+	rs := &stubResyncer{orphans: []string{"stray"}}
+	run := New(slog.New(slog.NewTextHandler(io.Discard, nil)), r, rs, 0)
+	if err := run.Pass(ctx); err != nil {
+		t.Fatalf("Pass: %v", err)
+	}
+	gids := map[string]bool{}
+	for _, d := range rs.got {
+		gids[d.GID] = true
+	}
+	if len(rs.got) != 3 || !gids["g1"] || !gids["g2"] || !gids["g5"] {
+		t.Fatalf("resynced = %v", gids)
+	}
+
+	rs.err = errors.New("aria2 down")
+	if err := run.Pass(ctx); !errors.Is(err, rs.err) {
+		t.Fatalf("expected resync error, got %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+// Package retry restarts downloads that failed with a transient error,
+// with exponential backoff and a bounded number of attempts.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/history"
+	"github.com/tinoosan/torrus/internal/metrics"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+// Defaults for the global policy.
+const (
+	DefaultMaxAttempts = 3
+	DefaultBackoff     = 30 * time.Second
+	DefaultMaxBackoff  = 15 * time.Minute
+)
+
+// DefaultCodes are the aria2 exit statuses treated as transient: timeout
+// (2), too slow (5), network problem (6), name resolution failed (19), bad
+// HTTP response (22) and server overloaded (29).
+var DefaultCodes = []int{2, 5, 6, 19, 22, 29}
+
+// Config is the global retry policy. A download's own data.RetryPolicy
+// overrides it field by field.
+type Config struct {
+	// MaxAttempts is how many times a failed download is restarted; 0
+	// disables retries.
+	MaxAttempts int
+	// BaseBackoff is the delay before the first retry; it doubles on each
+	// subsequent retry up to MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Codes are the downloader error codes that are retried; nil means
+	// DefaultCodes.
+	Codes []int
+}
+
+func (c Config) withDefaults() Config {
+	if c.MaxAttempts < 0 {
+		c.MaxAttempts = 0
+	}
+	if c.BaseBackoff <= 0 {
+		c.BaseBackoff = DefaultBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = DefaultMaxBackoff
+	}
+	if c.MaxBackoff < c.BaseBackoff {
+		c.MaxBackoff = c.BaseBackoff
+	}
+	if c.Codes == nil {
+		c.Codes = DefaultCodes
+	}
+	return c
+}
+
+// ParseCodes parses a comma-separated list of error codes, as accepted by
+// TORRUS_RETRY_CODES. An empty string yields nil (the defaults).
+func ParseCodes(s string) ([]int, error) {
+	var out []int
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("error code %q: want a positive integer", f)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// Kicker requests a scheduling pass.
+type Kicker interface {
+	Kick()
+}
+
+// errSkip aborts a repository update that no longer applies.
+var errSkip = errors.New("skip")
+
+// Retrier schedules retries of failed downloads. A retry puts the download
+// back in the queue, so the scheduler restarts it through
+// downloader.Downloader.Start once a slot is free, subject to the same
+// limits as any other queued download. StatusChanged never blocks the
+// caller.
+type Retrier struct {
+	repo   repo.DownloadRepo
+	log    *slog.Logger
+	cfg    Config
+	now    func() time.Time
+	kicker Kicker
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New constructs a Retrier with cfg as the global policy.
+func New(log *slog.Logger, r repo.DownloadRepo, cfg Config) *Retrier {
+	if log == nil {
+		log = slog.Default()
+	}
+	return &Retrier{
+		repo: r,
+		log:  log,
+		cfg:  cfg.withDefaults(),
+		now:  time.Now,
+		ctx:  context.Background(),
+	}
+}
+
+// SetKicker wires the scheduler to kick once a retried download is queued.
+func (r *Retrier) SetKicker(k Kicker) {
+	r.kicker = k
+}
+
+// Start enables retries and re-arms those scheduled before a restart.
+func (r *Retrier) Start(ctx context.Context) {
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	dls, err := r.repo.List(r.ctx)
+	if err != nil {
+		r.log.Error("retry: list downloads", "err", err)
+		return
+	}
+	for _, dl := range dls {
+		if dl.Status == data.StatusError && dl.RetryStatus != nil && dl.RetryStatus.NextRetryAt != nil {
+			r.arm(dl.ID, dl.RetryStatus.NextRetryAt.Sub(r.now()))
+		}
+	}
+}
+
+// Stop cancels pending timers and waits for an in-flight retry. Scheduled
+// retries stay recorded on their downloads and are re-armed by the next
+// Start.
+func (r *Retrier) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+		r.wg.Wait()
+	}
+}
+
+// StatusChanged implements reconciler.StatusListener.
+func (r *Retrier) StatusChanged(ctx context.Context, from data.DownloadStatus, dl *data.Download) {
+	if dl.Status == data.StatusError {
+		r.Schedule(dl)
+	}
+}
+
+// policy is the effective policy of one download.
+type policy struct {
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	codes       []int
+}
+
+func (r *Retrier) policy(p *data.RetryPolicy) policy {
+	out := policy{maxAttempts: r.cfg.MaxAttempts, backoff: r.cfg.BaseBackoff, maxBackoff: r.cfg.MaxBackoff, codes: r.cfg.Codes}
+	if p == nil {
+		return out
+	}
+	if p.MaxAttempts != nil {
+		out.maxAttempts = *p.MaxAttempts
+	}
+	if p.BackoffSec != nil {
+		out.backoff = time.Duration(*p.BackoffSec) * time.Second
+	}
+	if p.MaxBackoffSec != nil {
+		out.maxBackoff = time.Duration(*p.MaxBackoffSec) * time.Second
+	}
+	if len(p.RetryableCodes) > 0 {
+		out.codes = p.RetryableCodes
+	}
+	return out
+}
+
+// delay returns the wait before retry number attempt (1-based).
+func (p policy) delay(attempt int) time.Duration {
+	wait := p.backoff
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if wait >= p.maxBackoff {
+			return p.maxBackoff
+		}
+	}
+	return min(wait, p.maxBackoff)
+}
+
+// Schedule records when the failed download dl is retried and arms a timer
+// for it, unless its error is not retryable, its attempts are used up or
+// the user paused or cancelled it.
+func (r *Retrier) Schedule(dl *data.Download) {
+	p := r.policy(dl.Retry)
+	attempts, code := 0, 0
+	if dl.RetryStatus != nil {
+		attempts = dl.RetryStatus.Attempts
+	}
+	if dl.Failure != nil {
+		code = dl.Failure.DownloaderCode
+	}
+	lg := r.log.With("id", dl.ID, "code", code, "attempts", attempts)
+	switch {
+	case !wantsRun(dl):
+		return
+	case !slices.Contains(p.codes, code):
+		metrics.DownloadRetries.WithLabelValues("not_retryable").Inc()
+		lg.Info("retry: error is not retryable")
+		return
+	case attempts >= p.maxAttempts:
+		metrics.DownloadRetries.WithLabelValues("exhausted").Inc()
+		lg.Warn("retry: attempts exhausted", "max_attempts", p.maxAttempts)
+		return
+	}
+
+	wait := p.delay(attempts + 1)
+	at := r.now().Add(wait)
+	_, err := r.repo.Update(r.ctx, dl.ID, func(d *data.Download) error {
+		if d.Status != data.StatusError || !wantsRun(d) {
+			return errSkip
+		}
+		if d.RetryStatus == nil {
+			d.RetryStatus = &data.RetryStatus{}
+		}
+		d.RetryStatus.NextRetryAt = &at
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, errSkip) && !errors.Is(err, data.ErrNotFound) {
+			lg.Error("retry: record schedule", "err", err)
+		}
+		return
+	}
+	metrics.DownloadRetries.WithLabelValues("scheduled").Inc()
+	lg.Info("retry: scheduled", "wait", wait)
+	r.arm(dl.ID, wait)
+}
+
+// arm runs the retry of download id after wait, unless Stop comes first.
+func (r *Retrier) arm(id string, wait time.Duration) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		t := time.NewTimer(max(wait, 0))
+		defer t.Stop()
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-t.C:
+		}
+		r.retry(id)
+	}()
+}
+
+// retry re-queues download id if its retry is still due. A download that
+// was deleted, cancelled, paused or restarted by the user in the meantime
+// is left alone.
+func (r *Retrier) retry(id string) {
+	attempt := 0
+	_, err := r.repo.Update(history.WithCause(r.ctx, data.CauseRetry), id, func(d *data.Download) error {
+		rs := d.RetryStatus
+		if d.Status != data.StatusError || !wantsRun(d) || rs == nil || rs.NextRetryAt == nil || r.now().Before(*rs.NextRetryAt) {
+			return errSkip
+		}
+		rs.Attempts++
+		rs.NextRetryAt = nil
+		attempt = rs.Attempts
+		d.GID = ""
+		d.Status = data.StatusQueued
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, errSkip) && !errors.Is(err, data.ErrNotFound) {
+			r.log.Error("retry: requeue", "id", id, "err", err)
+		}
+		return
+	}
+	metrics.DownloadRetries.WithLabelValues("retried").Inc()
+	r.log.Info("retry: download requeued", "id", id, "attempt", attempt)
+	if r.kicker != nil {
+		r.kicker.Kick()
+	}
+}
+
+// wantsRun reports whether the user still wants dl to run; paused and
+// cancelled downloads are never retried.
+func wantsRun(dl *data.Download) bool {
+	return dl.DesiredStatus != data.StatusPaused && dl.DesiredStatus != data.StatusCancelled
+}
@@ -0,0 +1,171 @@
+package retry
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+type kicker struct{ n int }
+
+func (k *kicker) Kick() { k.n++ }
+
+// newTestRetrier returns a started Retrier whose clock is read from now.
+func newTestRetrier(t *testing.T, r repo.DownloadRepo, cfg Config, now *time.Time) (*Retrier, *kicker) {
+	t.Helper()
+	rt := New(slog.New(slog.NewTextHandler(io.Discard, nil)), r, cfg)
+	rt.now = func() time.Time { return *now }
+	k := &kicker{}
+	rt.SetKicker(k)
+	rt.Start(context.Background())
+	t.Cleanup(rt.Stop)
+	return rt, k
+}
+
+func failed(t *testing.T, r repo.DownloadRepo, code int, policy *data.RetryPolicy) *data.Download {
+	t.Helper()
+	dl, err := r.Add(context.Background(), &data.Download{
+		Source: "https://example.com/f", TargetPath: "/tmp", Status: data.StatusError, DesiredStatus: data.StatusActive,
+		Retry:   policy,
+		Failure: &data.Failure{Code: data.FailureUnknown, DownloaderCode: code, Message: "boom", Source: data.FailureSourceDownloader},
+	})
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	return dl
+}
+
+func get(t *testing.T, r repo.DownloadRepo, id string) *data.Download {
+	t.Helper()
+	dl, err := r.Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	return dl
+}
+
+// TestRetryRequeuesWithBackoff ensures a transient failure is retried after
+// an exponentially growing delay until the attempts run out.
+func TestRetryRequeuesWithBackoff(t *testing.T) {
+	r := repo.NewInMemoryDownloadRepo()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	rt, k := newTestRetrier(t, r, Config{MaxAttempts: 2, BaseBackoff: time.Minute, MaxBackoff: time.Hour}, &now)
+	dl := failed(t, r, 6, nil)
+
+	for attempt, wait := range []time.Duration{time.Minute, 2 * time.Minute} {
+		rt.StatusChanged(context.Background(), data.StatusActive, get(t, r, dl.ID))
+		got := get(t, r, dl.ID)
+		if got.RetryStatus.NextRetryAt == nil || !got.RetryStatus.NextRetryAt.Equal(now.Add(wait)) {
+			t.Fatalf("attempt %d: nextRetryAt = %v, want +%v", attempt+1, got.RetryStatus.NextRetryAt, wait)
+		}
+		// Not due yet: nothing happens.
+		rt.retry(dl.ID)
+		if get(t, r, dl.ID).Status != data.StatusError {
+			t.Fatal("retried before the backoff elapsed")
+		}
+		now = now.Add(wait)
+		rt.retry(dl.ID)
+		got = get(t, r, dl.ID)
+		if got.Status != data.StatusQueued || got.RetryStatus.Attempts != attempt+1 || got.RetryStatus.NextRetryAt != nil || k.n != attempt+1 {
+			t.Fatalf("attempt %d: status=%v retry=%+v kicks=%d", attempt+1, got.Status, got.RetryStatus, k.n)
+		}
+		// The restart fails again.
+		if _, err := r.Update(context.Background(), dl.ID, func(d *data.Download) error {
+			d.Status = data.StatusError
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rt.StatusChanged(context.Background(), data.StatusActive, get(t, r, dl.ID))
+	if got := get(t, r, dl.ID); got.RetryStatus.NextRetryAt != nil {
+		t.Fatalf("retry scheduled after attempts were exhausted: %+v", got.RetryStatus)
+	}
+}
+
+// TestRetryPolicy covers which failures are retried under the global and
+// per-download policies.
+func TestRetryPolicy(t *testing.T) {
+	zero := 0
+	cases := []struct {
+		name   string
+		code   int
+		policy *data.RetryPolicy
+		want   bool
+	}{
+		{"transient", 6, nil, true},
+		{"permanent", 3, nil, false},
+		{"unknown", 0, nil, false},
+		{"per-download codes", 3, &data.RetryPolicy{RetryableCodes: []int{3}}, true},
+		{"per-download disabled", 6, &data.RetryPolicy{MaxAttempts: &zero}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := repo.NewInMemoryDownloadRepo()
+			now := time.Now()
+			rt, _ := newTestRetrier(t, r, Config{MaxAttempts: 3}, &now)
+			dl := failed(t, r, c.code, c.policy)
+			rt.StatusChanged(context.Background(), data.StatusActive, dl)
+			rs := get(t, r, dl.ID).RetryStatus
+			if got := rs != nil && rs.NextRetryAt != nil; got != c.want {
+				t.Fatalf("scheduled = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+// TestRetryYieldsToUser ensures a pending retry does nothing once the user
+// cancels, pauses or deletes the download.
+func TestRetryYieldsToUser(t *testing.T) {
+	r := repo.NewInMemoryDownloadRepo()
+	now := time.Now()
+	rt, k := newTestRetrier(t, r, Config{MaxAttempts: 3, BaseBackoff: time.Hour}, &now)
+
+	for _, desired := range []data.DownloadStatus{data.StatusCancelled, data.StatusPaused} {
+		dl := failed(t, r, 6, nil)
+		rt.StatusChanged(context.Background(), data.StatusActive, dl)
+		if _, err := r.Update(context.Background(), dl.ID, func(d *data.Download) error {
+			d.DesiredStatus = desired
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(2 * time.Hour)
+		rt.retry(dl.ID)
+		if got := get(t, r, dl.ID); got.Status != data.StatusError || got.RetryStatus.Attempts != 0 {
+			t.Fatalf("%s download retried: %v %+v", desired, got.Status, got.RetryStatus)
+		}
+	}
+
+	dl := failed(t, r, 6, nil)
+	rt.StatusChanged(context.Background(), data.StatusActive, dl)
+	if err := r.Delete(context.Background(), dl.ID); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Hour)
+	rt.retry(dl.ID)
+	if k.n != 0 {
+		t.Fatalf("kicks = %d, want 0", k.n)
+	}
+}
+
+func TestParseCodes(t *testing.T) {
+	got, err := ParseCodes(" 2, 6 ,19")
+	if err != nil || len(got) != 3 || got[0] != 2 || got[1] != 6 || got[2] != 19 {
+		t.Fatalf("ParseCodes = %v, %v", got, err)
+	}
+	if got, err := ParseCodes(""); err != nil || got != nil {
+		t.Fatalf("empty = %v, %v", got, err)
+	}
+	for _, in := range []string{"x", "0", "-1"} {
+		if _, err := ParseCodes(in); err == nil {
+			t.Errorf("ParseCodes(%q) accepted", in)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package router
 
 import (
     "context"
+    "encoding/json"
 	"log/slog"
 	"net/http"
     "time"
@@ -10,12 +11,61 @@ import (
 	v1 "github.com/tinoosan/torrus/api/v1"
 	"github.com/tinoosan/torrus/internal/auth"
     "github.com/tinoosan/torrus/internal/downloader"
+    "github.com/tinoosan/torrus/internal/events"
     "github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/tinoosan/torrus/internal/service"
+	"github.com/tinoosan/torrus/internal/storage"
 )
 
+// Option configures optional subsystems exposed by the router.
+type Option func(*options)
+
+type options struct {
+    hub              *events.Hub
+    webhooks         service.Webhook
+    categories       service.Category
+    storage          *storage.Watcher
+    bandwidth        service.Bandwidth
+    batchConcurrency int
+}
+
+// WithEvents enables the GET /v1/events Server-Sent Events stream backed by hub.
+func WithEvents(hub *events.Hub) Option {
+    return func(o *options) { o.hub = hub }
+}
+
+// WithWebhooks enables the /v1/webhooks management endpoints backed by svc.
+func WithWebhooks(svc service.Webhook) Option {
+    return func(o *options) { o.webhooks = svc }
+}
+
+// WithCategories enables the /v1/categories management endpoints backed by svc.
+func WithCategories(svc service.Category) Option {
+    return func(o *options) { o.categories = svc }
+}
+
+// WithStorage enables GET /v1/storage, reporting the free space w watches.
+func WithStorage(w *storage.Watcher) Option {
+    return func(o *options) { o.storage = w }
+}
+
+// WithBandwidth enables GET and PUT /v1/settings/bandwidth backed by svc.
+func WithBandwidth(svc service.Bandwidth) Option {
+    return func(o *options) { o.bandwidth = svc }
+}
+
+// WithBatchConcurrency bounds how many downloads POST /v1/downloads:batch
+// processes in parallel.
+func WithBatchConcurrency(n int) Option {
+    return func(o *options) { o.batchConcurrency = n }
+}
+
 // New sets up the application routes and required middleware.
-func New(logger *slog.Logger, downloadSvc service.Download, dlr downloader.Downloader) *mux.Router {
+func New(logger *slog.Logger, downloadSvc service.Download, dlr downloader.Downloader, opts ...Option) *mux.Router {
+    var o options
+    for _, opt := range opts {
+        opt(&o)
+    }
 
     r := mux.NewRouter()
     // Request ID must be first so all downstream middleware/handlers see it
@@ -32,26 +82,47 @@ func New(logger *slog.Logger, downloadSvc service.Download, dlr downloader.Downl
     // Readiness probe: try a fast Ping() when supported
     r.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
         type pinger interface{ Ping(context.Context) error }
+        type notifier interface {
+            NotificationsConnected() bool
+            NotificationsEstablished() bool
+        }
         ctx, cancel := context.WithTimeout(r.Context(), 300*time.Millisecond)
         defer cancel()
 
-        ready := true
-        var errStr string
+        body := struct {
+            Ready         bool   `json:"ready"`
+            Error         string `json:"error,omitempty"`
+            Notifications string `json:"notifications,omitempty"`
+        }{Ready: true} // No ping capability; consider ready
         if p, ok := dlr.(pinger); ok {
             if err := p.Ping(ctx); err != nil {
-                ready = false
-                errStr = err.Error()
+                body.Ready = false
+                body.Error = err.Error()
+            }
+        }
+        // Events are missed while the notification stream is down. Until
+        // it first connects there is nothing to miss, so only a stream
+        // that dropped makes the service unready.
+        if n, ok := dlr.(notifier); ok {
+            switch {
+            case n.NotificationsConnected():
+                body.Notifications = "connected"
+            case !n.NotificationsEstablished():
+                body.Notifications = "connecting"
+            default:
+                body.Notifications = "disconnected"
+                if body.Ready {
+                    body.Ready = false
+                    body.Error = "aria2 notifications disconnected"
+                }
             }
-        } else {
-            // No ping capability; consider ready
-            ready = true
         }
 
         w.Header().Set("Content-Type", "application/json")
-        if !ready {
+        if !body.Ready {
             w.WriteHeader(http.StatusServiceUnavailable)
         }
-        _, _ = w.Write([]byte(`{"ready":` + map[bool]string{true: "true", false: "false"}[ready] + func() string { if errStr != "" { return ",\"error\":\"" + errStr + "\"" } ; return "" }() + "}"))
+        _ = json.NewEncoder(w).Encode(body)
     }).Methods("GET")
 
     // Prometheus metrics endpoint
@@ -64,10 +135,53 @@ func New(logger *slog.Logger, downloadSvc service.Download, dlr downloader.Downl
 
 	api := r.PathPrefix("/v1").Subrouter()
 
+	// Batch operations, queue moves and file selection decode their own body, so register
+	// them outside the POST subrouter and its download validation middleware.
+	batchHandler := v1.NewBatchHandler(logger, service.NewBatch(downloadSvc, o.batchConcurrency))
+	api.HandleFunc("/downloads:batch", batchHandler.BatchDownloads).Methods("POST")
+	api.HandleFunc("/downloads/{id}/move", downloadHandler.MoveDownload).Methods("POST")
+	api.HandleFunc("/downloads/{id}/files", downloadHandler.SelectFiles).Methods("PATCH")
+
+	// Webhooks, settings and categories have their own subrouters so the download body middlewares on
+	// the per-method subrouters below do not apply to them.
+	if o.webhooks != nil {
+		webhookHandler := v1.NewWebhookHandler(logger, o.webhooks)
+		hooks := api.PathPrefix("/webhooks").Subrouter()
+		hooks.HandleFunc("", webhookHandler.ListWebhooks).Methods("GET")
+		hooks.HandleFunc("", webhookHandler.CreateWebhook).Methods("POST")
+		hooks.HandleFunc("/{id}", webhookHandler.GetWebhook).Methods("GET")
+		hooks.HandleFunc("/{id}", webhookHandler.UpdateWebhook).Methods("PATCH")
+		hooks.HandleFunc("/{id}", webhookHandler.DeleteWebhook).Methods("DELETE")
+		hooks.HandleFunc("/{id}/deliveries", webhookHandler.ListDeliveries).Methods("GET")
+	}
+	if o.bandwidth != nil {
+		settingsHandler := v1.NewSettingsHandler(logger, o.bandwidth)
+		settings := api.PathPrefix("/settings").Subrouter()
+		settings.HandleFunc("/bandwidth", settingsHandler.GetBandwidth).Methods("GET")
+		settings.HandleFunc("/bandwidth", settingsHandler.PutBandwidth).Methods("PUT")
+	}
+	if o.categories != nil {
+		categoryHandler := v1.NewCategoryHandler(logger, o.categories)
+		cats := api.PathPrefix("/categories").Subrouter()
+		cats.HandleFunc("", categoryHandler.ListCategories).Methods("GET")
+		cats.HandleFunc("", categoryHandler.CreateCategory).Methods("POST")
+		cats.HandleFunc("/{name}", categoryHandler.GetCategory).Methods("GET")
+		cats.HandleFunc("/{name}", categoryHandler.UpdateCategory).Methods("PATCH")
+		cats.HandleFunc("/{name}", categoryHandler.DeleteCategory).Methods("DELETE")
+	}
+
 	// GETs
 	get := api.Methods("GET").Subrouter()
 	get.HandleFunc("/downloads", downloadHandler.GetDownloads)
 	get.HandleFunc("/downloads/{id}", downloadHandler.GetDownload)
+	get.HandleFunc("/downloads/{id}/history", downloadHandler.GetHistory)
+	if o.hub != nil {
+		eventsHandler := v1.NewEventsHandler(logger, o.hub)
+		get.HandleFunc("/events", eventsHandler.StreamEvents)
+	}
+	if o.storage != nil {
+		get.HandleFunc("/storage", v1.NewStorageHandler(logger, o.storage).GetStorage)
+	}
 
 	// POSTs
 	post := api.Methods("POST").Subrouter()
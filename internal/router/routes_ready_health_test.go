@@ -6,6 +6,7 @@ import (
     "log/slog"
     "net/http"
     "net/http/httptest"
+    "strings"
     "testing"
 
     "github.com/tinoosan/torrus/internal/data"
@@ -16,6 +17,9 @@ import (
 type fakeDownloadSvc struct{}
 
 func (f *fakeDownloadSvc) List(ctx context.Context) (data.Downloads, error) { return nil, nil }
+func (f *fakeDownloadSvc) Query(ctx context.Context, q data.DownloadQuery) (*data.DownloadPage, error) {
+    return &data.DownloadPage{}, nil
+}
 func (f *fakeDownloadSvc) Get(ctx context.Context, id string) (*data.Download, error) { return nil, data.ErrNotFound }
 func (f *fakeDownloadSvc) Add(ctx context.Context, d *data.Download) (*data.Download, bool, error) {
     return nil, false, nil
@@ -23,7 +27,22 @@ func (f *fakeDownloadSvc) Add(ctx context.Context, d *data.Download) (*data.Down
 func (f *fakeDownloadSvc) UpdateDesiredStatus(ctx context.Context, id string, status data.DownloadStatus) (*data.Download, error) {
     return nil, nil
 }
+func (f *fakeDownloadSvc) SetPriority(ctx context.Context, id string, priority int) (*data.Download, error) {
+    return nil, nil
+}
+func (f *fakeDownloadSvc) Move(ctx context.Context, id string, to data.QueueMove) (*data.Download, error) {
+    return nil, nil
+}
+func (f *fakeDownloadSvc) SetOptions(ctx context.Context, id string, p data.DownloadOptionsPatch) (*data.Download, error) {
+    return nil, nil
+}
+func (f *fakeDownloadSvc) SelectFiles(ctx context.Context, id string, indexes []int) (*data.Download, error) {
+    return nil, nil
+}
 func (f *fakeDownloadSvc) Delete(ctx context.Context, id string, deleteFiles bool) error { return nil }
+func (f *fakeDownloadSvc) History(ctx context.Context, id string) (data.Transitions, error) {
+    return nil, nil
+}
 
 // fakeDownloader allows toggling Ping behaviour.
 type fakeDownloader struct{ pingErr error }
@@ -71,3 +90,36 @@ func TestReadyzFailure(t *testing.T) {
         t.Fatalf("expected 503, got %d", w.Code)
     }
 }
+
+// fakeNotifier adds notification connection state to fakeDownloader.
+type fakeNotifier struct {
+    fakeDownloader
+    connected, established bool
+}
+
+func (f *fakeNotifier) NotificationsConnected() bool   { return f.connected }
+func (f *fakeNotifier) NotificationsEstablished() bool { return f.established }
+
+func TestReadyzReportsNotifications(t *testing.T) {
+    for _, tc := range []struct {
+        connected, established bool
+        code                   int
+        body                   string
+    }{
+        {true, true, http.StatusOK, `{"ready":true,"notifications":"connected"}`},
+        // Not connected yet since start: ready, the first connect is pending.
+        {false, false, http.StatusOK, `{"ready":true,"notifications":"connecting"}`},
+        {false, true, http.StatusServiceUnavailable, `{"ready":false,"error":"aria2 notifications disconnected","notifications":"disconnected"}`},
+    } {
+        r := New(slog.Default(), &fakeDownloadSvc{}, &fakeNotifier{connected: tc.connected, established: tc.established})
+        req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+        if w.Code != tc.code {
+            t.Fatalf("connected=%v established=%v: expected %d, got %d", tc.connected, tc.established, tc.code, w.Code)
+        }
+        if got := strings.TrimSpace(w.Body.String()); got != tc.body {
+            t.Fatalf("connected=%v established=%v: body %s, want %s", tc.connected, tc.established, got, tc.body)
+        }
+    }
+}
@@ -0,0 +1,280 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/downloader"
+	"github.com/tinoosan/torrus/internal/history"
+	"github.com/tinoosan/torrus/internal/metrics"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+// DefaultMaxActive is the default cap on concurrently active downloads.
+const DefaultMaxActive = 5
+
+// DefaultInterval is how often the scheduler re-evaluates the queue when
+// nothing has kicked it, as a safety net for missed notifications.
+const DefaultInterval = 30 * time.Second
+
+// Gate can hold back a queued download that would otherwise be admitted,
+// e.g. because its target disk is short of space.
+type Gate interface {
+	// Admit reports whether d may start now.
+	Admit(d *data.Download) bool
+}
+
+// Scheduler admits Queued downloads as active slots become free. All state
+// is derived from the repository on every pass, so the queue survives
+// restarts without extra bookkeeping.
+type Scheduler struct {
+	repo      repo.DownloadRepo
+	dlr       downloader.Downloader
+	log       *slog.Logger
+	maxActive int
+	interval  time.Duration
+	gate      Gate
+
+	kick chan struct{}
+
+	mu        sync.RWMutex
+	positions map[string]int
+
+	// synced is the GID order last pushed to a downloader.Reorderer.
+	synced []string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a Scheduler that keeps at most maxActive downloads active.
+// A maxActive of zero or less admits every queued download immediately.
+func New(log *slog.Logger, r repo.DownloadRepo, dlr downloader.Downloader, maxActive int) *Scheduler {
+	if log == nil {
+		log = slog.Default()
+	}
+	return &Scheduler{
+		repo:      r,
+		dlr:       dlr,
+		log:       log,
+		maxActive: maxActive,
+		interval:  DefaultInterval,
+		kick:      make(chan struct{}, 1),
+		positions: make(map[string]int),
+		ctx:       context.Background(),
+	}
+}
+
+// SetGate makes every admission subject to g. Downloads it refuses stay
+// queued, keep their position and are reconsidered on the next pass.
+func (s *Scheduler) SetGate(g Gate) {
+	s.gate = g
+}
+
+// Start launches the scheduling loop. The first pass runs immediately so
+// downloads queued before a restart are picked up.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		t := time.NewTicker(s.interval)
+		defer t.Stop()
+		s.pass()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-s.kick:
+			case <-t.C:
+			}
+			s.pass()
+		}
+	}()
+}
+
+// Stop terminates the scheduling loop.
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+		s.wg.Wait()
+	}
+}
+
+// Kick requests a scheduling pass. It never blocks; kicks that arrive while
+// a pass is pending are coalesced.
+func (s *Scheduler) Kick() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Position returns the 1-based queue position of a download as of the last
+// pass, or 0 when it is not waiting in the queue.
+func (s *Scheduler) Position(id string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.positions[id]
+}
+
+// StatusChanged implements reconciler.StatusListener. Any reconciled
+// transition may free or consume a slot, so it triggers a pass.
+func (s *Scheduler) StatusChanged(ctx context.Context, from data.DownloadStatus, dl *data.Download) {
+	s.Kick()
+}
+
+// pass promotes queued downloads into free slots in queue order,
+// recomputes the positions of those still waiting and mirrors their order
+// into the backend's own queue.
+func (s *Scheduler) pass() {
+	// Seeding torrents still hold a backend slot and upload bandwidth, so
+	// they count against the cap until they complete.
+	active, err := s.list(data.StatusActive, data.StatusSeeding)
+	if err != nil {
+		s.log.Error("scheduler: list active", "err", err)
+		return
+	}
+	queued, err := s.list(data.StatusQueued)
+	if err != nil {
+		s.log.Error("scheduler: list queued", "err", err)
+		return
+	}
+
+	free := len(queued)
+	if s.maxActive > 0 {
+		free = s.maxActive - len(active)
+	}
+	positions := make(map[string]int, len(queued))
+	var waiting data.Downloads
+	for _, d := range queued {
+		if free > 0 && s.ctx.Err() == nil && (s.gate == nil || s.gate.Admit(d)) {
+			if s.promote(d) {
+				free--
+			}
+			continue
+		}
+		positions[d.ID] = len(positions) + 1
+		waiting = append(waiting, d)
+	}
+
+	s.mu.Lock()
+	s.positions = positions
+	s.mu.Unlock()
+	metrics.QueuedDownloads.Set(float64(len(positions)))
+	s.reorder(waiting)
+}
+
+// reorder pushes the order of waiting downloads that already have a backend
+// task to downloaders that keep their own queue. It only calls out when that
+// order changed since the last successful push.
+func (s *Scheduler) reorder(waiting data.Downloads) {
+	ro, ok := s.dlr.(downloader.Reorderer)
+	if !ok {
+		return
+	}
+	var gids []string
+	var held data.Downloads
+	for _, d := range waiting {
+		if d.GID != "" {
+			gids = append(gids, d.GID)
+			held = append(held, d)
+		}
+	}
+	if slices.Equal(gids, s.synced) {
+		return
+	}
+	if err := ro.Reorder(s.ctx, held); err != nil {
+		s.log.Warn("scheduler: reorder backend queue", "err", err)
+		return
+	}
+	s.synced = gids
+}
+
+// list returns every download with one of the given statuses in queue order.
+func (s *Scheduler) list(st ...data.DownloadStatus) (data.Downloads, error) {
+	q := data.DownloadQuery{Statuses: st, Sort: data.SortQueue, Limit: data.MaxQueryLimit}
+	var out data.Downloads
+	for {
+		page, err := s.repo.Query(s.ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, page.Items...)
+		if page.NextCursor == "" {
+			return out, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// promote starts (or resumes, when the backend already holds it) a queued
+// download and marks it Active. It reports whether the download now
+// occupies a slot.
+func (s *Scheduler) promote(d *data.Download) bool {
+	lg := s.log.With("id", d.ID)
+	ctx := history.WithCause(s.ctx, data.CauseScheduler)
+	gid := d.GID
+	var err error
+	if gid != "" {
+		err = s.dlr.Resume(s.ctx, d)
+	} else if err = repo.LoadPayload(s.ctx, s.repo, d); err == nil {
+		gid, err = s.dlr.Start(s.ctx, d)
+	}
+	if err != nil {
+		lg.Error("scheduler: promote failed", "err", err)
+		_, _ = s.repo.Update(ctx, d.ID, func(dl *data.Download) error {
+			if dl.Status == data.StatusQueued {
+				dl.Status = data.StatusError
+				dl.Failure = downloader.StartFailure(data.FailureSourceScheduler, err, time.Now())
+			}
+			return nil
+		})
+		return false
+	}
+
+	var desired data.DownloadStatus
+	promoted := false
+	_, err = s.repo.Update(ctx, d.ID, func(dl *data.Download) error {
+		dl.GID = gid
+		desired = dl.DesiredStatus
+		promoted = dl.Status == data.StatusQueued
+		if promoted {
+			dl.Status = data.StatusActive
+			dl.Failure = nil
+			if dl.DesiredStatus == "" || dl.DesiredStatus == data.StatusQueued {
+				dl.DesiredStatus = data.StatusActive
+			}
+		}
+		return nil
+	})
+	started := &data.Download{ID: d.ID, GID: gid, Source: d.Source, TargetPath: d.TargetPath}
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		// Deleted while starting: drop the task we just created.
+		_ = s.dlr.Delete(s.ctx, started, false)
+		return false
+	case err != nil:
+		lg.Error("scheduler: persist promotion", "err", err)
+		return false
+	}
+	if promoted {
+		lg.Info("scheduler promoted download", "gid", gid)
+		return true
+	}
+	// The user paused or cancelled while the start was in flight; apply it
+	// to the task that now exists in the backend.
+	switch desired {
+	case data.StatusPaused:
+		_ = s.dlr.Pause(s.ctx, started)
+	case data.StatusCancelled:
+		_ = s.dlr.Cancel(s.ctx, started)
+	}
+	return false
+}
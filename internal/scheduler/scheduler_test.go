@@ -0,0 +1,247 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+type stubDL struct {
+	mu      sync.Mutex
+	started []string
+	resumed []string
+	failFor map[string]bool
+}
+
+func (s *stubDL) Start(ctx context.Context, d *data.Download) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failFor[d.Source] {
+		return "", errors.New("boom")
+	}
+	s.started = append(s.started, d.Source)
+	return "gid-" + d.Source, nil
+}
+func (s *stubDL) Pause(ctx context.Context, d *data.Download) error { return nil }
+func (s *stubDL) Resume(ctx context.Context, d *data.Download) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resumed = append(s.resumed, d.Source)
+	return nil
+}
+func (s *stubDL) Cancel(ctx context.Context, d *data.Download) error { return nil }
+func (s *stubDL) Delete(ctx context.Context, d *data.Download, deleteFiles bool) error {
+	return nil
+}
+
+func seed(t *testing.T, r repo.DownloadRepo, n int) []*data.Download {
+	t.Helper()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var out []*data.Download
+	for i := 0; i < n; i++ {
+		d, err := r.Add(context.Background(), &data.Download{Source: strconv.Itoa(i), TargetPath: "/t", Status: data.StatusQueued, DesiredStatus: data.StatusQueued, CreatedAt: base.Add(time.Duration(i) * time.Second)})
+		if err != nil {
+			t.Fatalf("add: %v", err)
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+func newTestScheduler(r repo.DownloadRepo, dl *stubDL, max int) *Scheduler {
+	s := New(slog.New(slog.NewTextHandler(io.Discard, nil)), r, dl, max)
+	s.ctx = context.Background()
+	return s
+}
+
+func status(t *testing.T, r repo.DownloadRepo, id string) data.DownloadStatus {
+	t.Helper()
+	d, err := r.Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	return d.Status
+}
+
+// TestPassPromotesFIFOUpToCap ensures only maxActive downloads run, the rest
+// get queue positions, and freed slots are refilled in order.
+func TestPassPromotesFIFOUpToCap(t *testing.T) {
+	r := repo.NewInMemoryDownloadRepo()
+	dls := seed(t, r, 4)
+	stub := &stubDL{}
+	s := newTestScheduler(r, stub, 2)
+
+	s.pass()
+	if len(stub.started) != 2 || stub.started[0] != "0" || stub.started[1] != "1" {
+		t.Fatalf("started = %v", stub.started)
+	}
+	got, _ := r.Get(context.Background(), dls[0].ID)
+	if got.Status != data.StatusActive || got.DesiredStatus != data.StatusActive || got.GID != "gid-0" {
+		t.Fatalf("promoted download = %#v", got)
+	}
+	if s.Position(dls[0].ID) != 0 || s.Position(dls[2].ID) != 1 || s.Position(dls[3].ID) != 2 {
+		t.Fatalf("positions = %v", s.positions)
+	}
+
+	// Completing one frees a slot for the head of the queue.
+	_, _ = r.Update(context.Background(), dls[1].ID, func(d *data.Download) error { d.Status = data.StatusComplete; return nil })
+	s.pass()
+	if status(t, r, dls[2].ID) != data.StatusActive || status(t, r, dls[3].ID) != data.StatusQueued || s.Position(dls[3].ID) != 1 {
+		t.Fatalf("slot not refilled in order: %v", stub.started)
+	}
+}
+
+// TestPassCountsSeeding ensures seeding torrents occupy slots until they
+// complete.
+func TestPassCountsSeeding(t *testing.T) {
+	r := repo.NewInMemoryDownloadRepo()
+	dls := seed(t, r, 3)
+	stub := &stubDL{}
+	s := newTestScheduler(r, stub, 2)
+	for _, d := range dls[:2] {
+		_, _ = r.Update(context.Background(), d.ID, func(d *data.Download) error { d.Status = data.StatusSeeding; return nil })
+	}
+
+	s.pass()
+	if len(stub.started) != 0 || s.Position(dls[2].ID) != 1 {
+		t.Fatalf("started %v while seeding filled every slot", stub.started)
+	}
+
+	_, _ = r.Update(context.Background(), dls[0].ID, func(d *data.Download) error { d.Status = data.StatusComplete; return nil })
+	s.pass()
+	if status(t, r, dls[2].ID) != data.StatusActive {
+		t.Fatalf("slot freed by completed seed not refilled: %v", stub.started)
+	}
+}
+
+type reorderDL struct {
+	stubDL
+	reorders [][]string
+}
+
+func (s *reorderDL) Reorder(ctx context.Context, ds data.Downloads) error {
+	var gids []string
+	for _, d := range ds {
+		gids = append(gids, d.GID)
+	}
+	s.reorders = append(s.reorders, gids)
+	return nil
+}
+
+// TestPassHonoursPriorityAndReordersBackend ensures higher priorities are
+// admitted first and the waiting order of downloads the backend already
+// holds is pushed to it only when it changes.
+func TestPassHonoursPriorityAndReordersBackend(t *testing.T) {
+	r := repo.NewInMemoryDownloadRepo()
+	dls := seed(t, r, 4)
+	_, _ = r.Update(context.Background(), dls[3].ID, func(d *data.Download) error { d.Priority = 1; return nil })
+	_, _ = r.Update(context.Background(), dls[1].ID, func(d *data.Download) error { d.GID = "g1"; return nil })
+	_, _ = r.Update(context.Background(), dls[2].ID, func(d *data.Download) error { d.GID = "g2"; return nil })
+
+	stub := &reorderDL{}
+	s := New(slog.New(slog.NewTextHandler(io.Discard, nil)), r, stub, 1)
+	s.pass()
+	if len(stub.started) != 1 || stub.started[0] != "3" {
+		t.Fatalf("started = %v", stub.started)
+	}
+	if len(stub.reorders) != 1 || len(stub.reorders[0]) != 2 || stub.reorders[0][0] != "g1" || stub.reorders[0][1] != "g2" {
+		t.Fatalf("reorders = %v", stub.reorders)
+	}
+
+	s.pass()
+	if len(stub.reorders) != 1 {
+		t.Fatalf("unchanged order pushed again: %v", stub.reorders)
+	}
+	_, _ = r.Update(context.Background(), dls[2].ID, func(d *data.Download) error { d.Priority = 2; return nil })
+	s.pass()
+	if len(stub.reorders) != 2 || stub.reorders[1][0] != "g2" || s.Position(dls[2].ID) != 1 {
+		t.Fatalf("reorders = %v positions = %v", stub.reorders, s.positions)
+	}
+}
+
+// TestPassSurvivesRestart ensures a fresh scheduler counts persisted Active
+// downloads and resumes paused backend tasks rather than restarting them.
+func TestPassSurvivesRestart(t *testing.T) {
+	r := repo.NewInMemoryDownloadRepo()
+	dls := seed(t, r, 3)
+	_, _ = r.Update(context.Background(), dls[0].ID, func(d *data.Download) error { d.Status = data.StatusActive; return nil })
+	_, _ = r.Update(context.Background(), dls[1].ID, func(d *data.Download) error { d.GID = "paused-gid"; return nil })
+
+	stub := &stubDL{}
+	newTestScheduler(r, stub, 2).pass()
+	if len(stub.started) != 0 || len(stub.resumed) != 1 || stub.resumed[0] != "1" {
+		t.Fatalf("started=%v resumed=%v", stub.started, stub.resumed)
+	}
+	if status(t, r, dls[2].ID) != data.StatusQueued {
+		t.Fatal("cap exceeded after restart")
+	}
+}
+
+// TestPassFailedStartDoesNotConsumeSlot ensures a failed start marks the
+// download Failed and the next queued item takes the slot.
+func TestPassFailedStartDoesNotConsumeSlot(t *testing.T) {
+	r := repo.NewInMemoryDownloadRepo()
+	dls := seed(t, r, 2)
+	stub := &stubDL{failFor: map[string]bool{"0": true}}
+	newTestScheduler(r, stub, 1).pass()
+	if status(t, r, dls[0].ID) != data.StatusError || status(t, r, dls[1].ID) != data.StatusActive {
+		t.Fatalf("statuses = %v, %v", status(t, r, dls[0].ID), status(t, r, dls[1].ID))
+	}
+	if got, _ := r.Get(context.Background(), dls[0].ID); got.Failure == nil || got.Failure.Source != data.FailureSourceScheduler {
+		t.Fatalf("failure = %+v", got.Failure)
+	}
+}
+
+// TestStartRunsInitialPassAndKick ensures the loop promotes on start and on kick.
+func TestStartRunsInitialPassAndKick(t *testing.T) {
+	r := repo.NewInMemoryDownloadRepo()
+	dls := seed(t, r, 2)
+	s := New(slog.New(slog.NewTextHandler(io.Discard, nil)), r, &stubDL{}, 1)
+	s.Start(context.Background())
+	defer s.Stop()
+
+	waitFor := func(cond func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			if cond() {
+				return
+			}
+		}
+		t.Fatal("condition not met")
+	}
+	waitFor(func() bool { return status(t, r, dls[0].ID) == data.StatusActive && s.Position(dls[1].ID) == 1 })
+
+	_, _ = r.Update(context.Background(), dls[0].ID, func(d *data.Download) error { d.Status = data.StatusComplete; return nil })
+	s.StatusChanged(context.Background(), data.StatusActive, &data.Download{ID: dls[0].ID, Status: data.StatusComplete})
+	waitFor(func() bool { return status(t, r, dls[1].ID) == data.StatusActive })
+}
+
+type gateFunc func(d *data.Download) bool
+
+func (f gateFunc) Admit(d *data.Download) bool { return f(d) }
+
+// TestPassGateHoldsDownloads ensures downloads the gate refuses stay queued
+// with a position while later ones may take the free slot.
+func TestPassGateHoldsDownloads(t *testing.T) {
+	r := repo.NewInMemoryDownloadRepo()
+	dls := seed(t, r, 3)
+	stub := &stubDL{}
+	s := newTestScheduler(r, stub, 2)
+	s.SetGate(gateFunc(func(d *data.Download) bool { return d.ID != dls[0].ID }))
+
+	s.pass()
+	if status(t, r, dls[0].ID) != data.StatusQueued || s.Position(dls[0].ID) != 1 {
+		t.Fatalf("gated download not held: %v pos %d", status(t, r, dls[0].ID), s.Position(dls[0].ID))
+	}
+	if len(stub.started) != 2 || stub.started[0] != "1" || stub.started[1] != "2" {
+		t.Fatalf("started = %v", stub.started)
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+// Bandwidth manages the global bandwidth settings.
+type Bandwidth interface {
+	// Get returns the stored settings along with the limits in force.
+	Get(ctx context.Context) (*data.BandwidthSettings, error)
+	// Set validates and replaces the settings and applies them right away.
+	Set(ctx context.Context, s *data.BandwidthSettings) (*data.BandwidthSettings, error)
+}
+
+// BandwidthApplier pushes the stored bandwidth settings to the downloader.
+type BandwidthApplier interface {
+	// Apply re-evaluates the stored settings now.
+	Apply(ctx context.Context) error
+	// Active returns the limits in force, or nil if not yet evaluated.
+	Active() *data.ActiveBandwidth
+}
+
+// bandwidth implements the Bandwidth service.
+type bandwidth struct {
+	repo    repo.SettingsRepo
+	applier BandwidthApplier
+}
+
+// NewBandwidth constructs a Bandwidth service backed by the given
+// repository. Saved settings are applied through a.
+func NewBandwidth(r repo.SettingsRepo, a BandwidthApplier) Bandwidth {
+	return &bandwidth{repo: r, applier: a}
+}
+
+// Get returns the stored settings and the limits in force.
+func (bs *bandwidth) Get(ctx context.Context) (*data.BandwidthSettings, error) {
+	s, err := bs.repo.GetBandwidth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s = s.Clone()
+	s.Active = bs.applier.Active()
+	return s, nil
+}
+
+// Set validates, persists and applies s. A failure to reach the downloader
+// does not fail the call; the applier retries on its next evaluation.
+func (bs *bandwidth) Set(ctx context.Context, s *data.BandwidthSettings) (*data.BandwidthSettings, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	s.UpdatedAt = time.Now()
+	s.Active = nil
+	if err := bs.repo.SaveBandwidth(ctx, s); err != nil {
+		return nil, err
+	}
+	_ = bs.applier.Apply(ctx)
+	return bs.Get(ctx)
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/tinoosan/torrus/internal/data"
+)
+
+// MaxBatchSize caps how many downloads a single batch may touch.
+const MaxBatchSize = 1000
+
+// DefaultBatchConcurrency is the number of items processed in parallel when
+// the caller does not choose a limit.
+const DefaultBatchConcurrency = 8
+
+var (
+	// ErrBatchTooLarge is returned when a batch selects more than MaxBatchSize downloads.
+	ErrBatchTooLarge = errors.New("batch selects too many downloads")
+	// ErrBatchTarget is returned when a batch names neither IDs nor a filter, or both.
+	ErrBatchTarget = errors.New("batch requires exactly one of ids or filter")
+	// ErrBatchAction is returned when a batch sets neither a desired status
+	// nor delete, or both.
+	ErrBatchAction = errors.New("batch requires exactly one of desiredStatus or delete")
+)
+
+// BatchRequest selects downloads either by ID or by filter and applies a
+// single action to each.
+type BatchRequest struct {
+	IDs    []string
+	Filter *data.DownloadQuery
+
+	// DesiredStatus applies UpdateDesiredStatus to each download.
+	DesiredStatus data.DownloadStatus
+	// Delete removes each download, purging files when DeleteFiles is set.
+	Delete      bool
+	DeleteFiles bool
+}
+
+// BatchResult is the outcome for one download in a batch. Download is the
+// updated snapshot for desired-status actions and nil for deletes.
+type BatchResult struct {
+	ID       string
+	Download *data.Download
+	Err      error
+}
+
+// Batch applies one action to many downloads through a Download service,
+// running at most a fixed number of operations at a time.
+type Batch struct {
+	svc         Download
+	concurrency int
+}
+
+// NewBatch constructs a Batch over svc. A non-positive concurrency uses
+// DefaultBatchConcurrency.
+func NewBatch(svc Download, concurrency int) *Batch {
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+	return &Batch{svc: svc, concurrency: concurrency}
+}
+
+// Run resolves the targets of req and applies its action to each. Results
+// are returned in target order; per-item failures are reported in the
+// results rather than as an error. Run itself only fails when the request is
+// malformed or the filter cannot be resolved.
+func (b *Batch) Run(ctx context.Context, req BatchRequest) ([]BatchResult, error) {
+	if (len(req.IDs) > 0) == (req.Filter != nil) {
+		return nil, ErrBatchTarget
+	}
+	if (req.DesiredStatus != "") == req.Delete {
+		return nil, ErrBatchAction
+	}
+	if req.DesiredStatus != "" && !AllowedStatuses[req.DesiredStatus] {
+		return nil, data.ErrBadStatus
+	}
+
+	ids := dedupe(req.IDs)
+	if req.Filter != nil {
+		var err error
+		if ids, err = b.resolve(ctx, *req.Filter); err != nil {
+			return nil, err
+		}
+	}
+	if len(ids) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	results := make([]BatchResult, len(ids))
+	sem := make(chan struct{}, b.concurrency)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			res := BatchResult{ID: id}
+			if err := ctx.Err(); err != nil {
+				res.Err = err
+			} else if req.Delete {
+				res.Err = b.svc.Delete(ctx, id, req.DeleteFiles)
+			} else {
+				res.Download, res.Err = b.svc.UpdateDesiredStatus(ctx, id, req.DesiredStatus)
+			}
+			results[i] = res
+		}(i, id)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// resolve lists the IDs of every download matching q, failing once more than
+// MaxBatchSize match.
+func (b *Batch) resolve(ctx context.Context, q data.DownloadQuery) ([]string, error) {
+	q.Limit = data.MaxQueryLimit
+	q.Cursor = ""
+	var ids []string
+	for {
+		page, err := b.svc.Query(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range page.Items {
+			ids = append(ids, d.ID)
+		}
+		if len(ids) > MaxBatchSize {
+			return nil, ErrBatchTooLarge
+		}
+		if page.NextCursor == "" {
+			return ids, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+func dedupe(ids []string) []string {
+	seen := make(map[string]struct{}, len(ids))
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		out = append(out, id)
+	}
+	return out
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+// gatedSvc records the peak number of concurrent calls and fails for IDs
+// listed in errs.
+type gatedSvc struct {
+	Download
+	inflight atomic.Int32
+	peak     atomic.Int32
+	errs     map[string]error
+
+	mu      sync.Mutex
+	deleted map[string]bool
+}
+
+func (g *gatedSvc) enter() func() {
+	n := g.inflight.Add(1)
+	for {
+		p := g.peak.Load()
+		if n <= p || g.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	return func() { g.inflight.Add(-1) }
+}
+
+func (g *gatedSvc) UpdateDesiredStatus(ctx context.Context, id string, status data.DownloadStatus) (*data.Download, error) {
+	defer g.enter()()
+	if err := g.errs[id]; err != nil {
+		return nil, err
+	}
+	return &data.Download{ID: id, DesiredStatus: status}, nil
+}
+
+func (g *gatedSvc) Delete(ctx context.Context, id string, deleteFiles bool) error {
+	defer g.enter()()
+	g.mu.Lock()
+	g.deleted[id] = deleteFiles
+	g.mu.Unlock()
+	return g.errs[id]
+}
+
+func TestBatchRunBoundsConcurrencyAndKeepsOrder(t *testing.T) {
+	g := &gatedSvc{errs: map[string]error{"3": data.ErrNotFound, "5": data.ErrConflict}}
+	var ids []string
+	for i := 0; i < 20; i++ {
+		ids = append(ids, strconv.Itoa(i))
+	}
+	ids = append(ids, "0") // duplicates are applied once
+	res, err := NewBatch(g, 3).Run(context.Background(), BatchRequest{IDs: ids, DesiredStatus: data.StatusPaused})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(res) != 20 {
+		t.Fatalf("expected 20 results, got %d", len(res))
+	}
+	for i, r := range res {
+		if r.ID != strconv.Itoa(i) {
+			t.Fatalf("result %d has id %s", i, r.ID)
+		}
+	}
+	if !errors.Is(res[3].Err, data.ErrNotFound) || !errors.Is(res[5].Err, data.ErrConflict) || res[4].Err != nil || res[4].Download.DesiredStatus != data.StatusPaused {
+		t.Fatalf("unexpected per-item results: %+v %+v %+v", res[3], res[4], res[5])
+	}
+	if p := g.peak.Load(); p > 3 || p < 2 {
+		t.Fatalf("peak concurrency = %d, want 2..3", p)
+	}
+}
+
+func TestBatchRunResolvesFilter(t *testing.T) {
+	ctx := context.Background()
+	r := repo.NewInMemoryDownloadRepo()
+	for i := 0; i < 4; i++ {
+		st := data.StatusActive
+		if i%2 == 0 {
+			st = data.StatusPaused
+		}
+		_, _ = r.Add(ctx, &data.Download{Source: strconv.Itoa(i), TargetPath: "/t", Status: st})
+	}
+	g := &gatedSvc{Download: NewDownload(r, &stubDownloader{}), deleted: map[string]bool{}}
+	res, err := NewBatch(g, 0).Run(ctx, BatchRequest{Filter: &data.DownloadQuery{Statuses: []data.DownloadStatus{data.StatusPaused}}, Delete: true, DeleteFiles: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(res) != 2 || len(g.deleted) != 2 {
+		t.Fatalf("expected 2 paused downloads deleted, got %d results, %v", len(res), g.deleted)
+	}
+	for id, files := range g.deleted {
+		if !files {
+			t.Fatalf("deleteFiles not propagated for %s", id)
+		}
+	}
+}
+
+func TestBatchRunRejectsMalformed(t *testing.T) {
+	b := NewBatch(&gatedSvc{}, 1)
+	cases := []struct {
+		req  BatchRequest
+		want error
+	}{
+		{BatchRequest{DesiredStatus: data.StatusPaused}, ErrBatchTarget},
+		{BatchRequest{IDs: []string{"a"}, Filter: &data.DownloadQuery{}, Delete: true}, ErrBatchTarget},
+		{BatchRequest{IDs: []string{"a"}}, ErrBatchAction},
+		{BatchRequest{IDs: []string{"a"}, DesiredStatus: data.StatusPaused, Delete: true}, ErrBatchAction},
+		{BatchRequest{IDs: []string{"a"}, DesiredStatus: data.StatusComplete}, data.ErrBadStatus},
+		{BatchRequest{IDs: make([]string, 0)}, ErrBatchTarget},
+	}
+	for i, tc := range cases {
+		if _, err := b.Run(context.Background(), tc.req); !errors.Is(err, tc.want) {
+			t.Fatalf("case %d: err = %v, want %v", i, err, tc.want)
+		}
+	}
+	ids := make([]string, MaxBatchSize+1)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+	}
+	if _, err := b.Run(context.Background(), BatchRequest{IDs: ids, Delete: true}); !errors.Is(err, ErrBatchTooLarge) {
+		t.Fatalf("oversized batch: err = %v", err)
+	}
+}
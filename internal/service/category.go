@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/pathsafe"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+// Category manages download categories.
+type Category interface {
+	List(ctx context.Context) (data.Categories, error)
+	Get(ctx context.Context, name string) (*data.Category, error)
+	// Create validates and stores a category.
+	Create(ctx context.Context, c *data.Category) (*data.Category, error)
+	Update(ctx context.Context, name string, patch CategoryPatch) (*data.Category, error)
+	// Delete removes a category. Downloads created with it keep its name and
+	// the defaults they were created with.
+	Delete(ctx context.Context, name string) error
+}
+
+// CategoryPatch lists the mutable category fields. Nil fields are left
+// as-is; an empty Options or PostProcess object clears it.
+type CategoryPatch struct {
+	TargetPath  *string
+	Options     *data.DownloadOptions
+	PostProcess *data.PostProcess
+}
+
+// category implements the Category service.
+type category struct {
+	repo  repo.CategoryRepo
+	roots pathsafe.Roots
+}
+
+// NewCategory constructs a Category service backed by the given repository.
+// Category target paths and destinations must lie under roots, if any.
+func NewCategory(r repo.CategoryRepo, roots pathsafe.Roots) Category {
+	return &category{repo: r, roots: roots}
+}
+
+// List returns all categories sorted by name.
+func (cs *category) List(ctx context.Context) (data.Categories, error) {
+	return cs.repo.ListCategories(ctx)
+}
+
+// Get retrieves a category by name.
+func (cs *category) Get(ctx context.Context, name string) (*data.Category, error) {
+	return cs.repo.GetCategory(ctx, name)
+}
+
+// Create validates and persists a new category.
+func (cs *category) Create(ctx context.Context, c *data.Category) (*data.Category, error) {
+	c.Normalize()
+	if err := cs.validate(c); err != nil {
+		return nil, err
+	}
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = time.Now()
+	}
+	return cs.repo.AddCategory(ctx, c)
+}
+
+// Update applies patch to the category with the given name.
+func (cs *category) Update(ctx context.Context, name string, patch CategoryPatch) (*data.Category, error) {
+	return cs.repo.UpdateCategory(ctx, name, func(c *data.Category) error {
+		if patch.TargetPath != nil {
+			c.TargetPath = *patch.TargetPath
+		}
+		if patch.Options != nil {
+			c.Options = patch.Options.Clone()
+		}
+		if patch.PostProcess != nil {
+			c.PostProcess = patch.PostProcess.Clone()
+		}
+		c.Normalize()
+		return cs.validate(c)
+	})
+}
+
+// validate checks c and keeps its paths under the allowed roots.
+func (cs *category) validate(c *data.Category) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	return checkRoots(cs.roots, c.TargetPath, c.PostProcess, data.ErrInvalidCategory)
+}
+
+// Delete removes a category.
+func (cs *category) Delete(ctx context.Context, name string) error {
+	return cs.repo.DeleteCategory(ctx, name)
+}
+
+// applyCategory normalizes the labels of a new download and applies the
+// defaults of its category, which must exist.
+func (ds *download) applyCategory(ctx context.Context, d *data.Download) error {
+	labels, err := data.NormalizeLabels(d.Labels)
+	if err != nil {
+		return err
+	}
+	d.Labels = labels
+	if d.Category == "" {
+		return nil
+	}
+	if ds.categories == nil {
+		return fmt.Errorf("%w: unknown category %q", data.ErrInvalidCategory, d.Category)
+	}
+	c, err := ds.categories.GetCategory(ctx, d.Category)
+	if errors.Is(err, data.ErrCategoryNotFound) {
+		return fmt.Errorf("%w: unknown category %q", data.ErrInvalidCategory, d.Category)
+	}
+	if err != nil {
+		return err
+	}
+	c.ApplyTo(d)
+	return nil
+}
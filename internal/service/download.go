@@ -3,6 +3,7 @@ package service
 import (
     "context"
     "errors"
+    "fmt"
     "strings"
     "sync"
     "time"
@@ -10,6 +11,9 @@ import (
     "github.com/tinoosan/torrus/internal/data"
     "github.com/tinoosan/torrus/internal/downloader"
     "github.com/tinoosan/torrus/internal/fp"
+    "github.com/tinoosan/torrus/internal/history"
+    "github.com/tinoosan/torrus/internal/lifecycle"
+    "github.com/tinoosan/torrus/internal/pathsafe"
     "github.com/tinoosan/torrus/internal/repo"
     "github.com/tinoosan/torrus/internal/reqid"
 )
@@ -17,13 +21,29 @@ import (
 // Download provides high-level operations for managing downloads.
 type Download interface {
 	List(ctx context.Context) (data.Downloads, error)
+	// Query returns one page of downloads matching q.
+	Query(ctx context.Context, q data.DownloadQuery) (*data.DownloadPage, error)
 	Get(ctx context.Context, id string) (*data.Download, error)
 	// Add inserts a new download or returns an existing one if it already
 	// exists (idempotent). The returned 'created' flag indicates whether a new
 	// row was created (true) or an existing one was returned (false).
 	Add(ctx context.Context, d *data.Download) (*data.Download, bool, error)
 	UpdateDesiredStatus(ctx context.Context, id string, status data.DownloadStatus) (*data.Download, error)
+	// SetPriority changes a download's priority, which re-orders it within
+	// the queue when it is Queued.
+	SetPriority(ctx context.Context, id string, priority int) (*data.Download, error)
+	// Move repositions a Queued download within the queue. It returns
+	// data.ErrNotQueued for downloads in any other status.
+	Move(ctx context.Context, id string, to data.QueueMove) (*data.Download, error)
+	// SetOptions changes the transfer options of a download, applying them
+	// to its running backend task when the downloader supports it.
+	SetOptions(ctx context.Context, id string, p data.DownloadOptionsPatch) (*data.Download, error)
+	// SelectFiles restricts a multi-file download to the files with the
+	// given indexes and drops its file filter.
+	SelectFiles(ctx context.Context, id string, indexes []int) (*data.Download, error)
 	Delete(ctx context.Context, id string, deleteFiles bool) error
+	// History returns the status transitions of a download, oldest first.
+	History(ctx context.Context, id string) (data.Transitions, error)
 }
 
 var (
@@ -36,24 +56,113 @@ var (
 	}
 )
 
+// Scheduler admits queued downloads into a bounded set of active slots.
+type Scheduler interface {
+	// Kick requests a scheduling pass without blocking.
+	Kick()
+	// Position reports a download's 1-based queue position, or 0.
+	Position(id string) int
+}
+
+// Option configures optional collaborators of the Download service.
+type Option func(*download)
+
+// WithScheduler routes activation through s: downloads that should run are
+// marked Queued and started by the scheduler when a slot is free, instead of
+// being started immediately.
+func WithScheduler(s Scheduler) Option {
+	return func(ds *download) { ds.sched = s }
+}
+
+// WithCategories resolves the category of new downloads from r, filling in
+// the category's target path, options and post-processing defaults.
+func WithCategories(r repo.CategoryRepo) Option {
+	return func(ds *download) { ds.categories = r }
+}
+
+// WithRoots restricts the target path and post-processing destination of
+// new downloads to the given allowed roots.
+func WithRoots(roots pathsafe.Roots) Option {
+	return func(ds *download) { ds.roots = roots }
+}
+
+// WithHistory serves download history from r. Transitions are written by
+// a history.Repo wrapping the download repository.
+func WithHistory(r repo.HistoryRepo) Option {
+	return func(ds *download) { ds.history = r }
+}
+
 // download implements the Download service.
 type download struct {
-	repo repo.ExtendedRepo
-	dlr  downloader.Downloader
+	repo       repo.ExtendedRepo
+	dlr        downloader.Downloader
+	sched      Scheduler
+	categories repo.CategoryRepo
+	roots      pathsafe.Roots
+	history    repo.HistoryRepo
 
 	startMu      sync.Mutex
 	startCancels map[string]context.CancelFunc
+
+	// moveMu serializes queue moves, which read the queue and then rewrite
+	// the ordering keys of one or two downloads.
+	moveMu sync.Mutex
 }
 
 // NewDownload constructs a Download service backed by the given repository and downloader.
-func NewDownload(r repo.DownloadRepo, dlr downloader.Downloader) Download {
+func NewDownload(r repo.DownloadRepo, dlr downloader.Downloader, opts ...Option) Download {
 	// If the repository does not implement ExtendedRepo, wrap it in a minimal
 	// adapter that satisfies the interface but disables fingerprint lookups.
 	ext, ok := r.(repo.ExtendedRepo)
 	if !ok {
 		ext = &extendedRepoAdapter{DownloadRepo: r}
 	}
-	return &download{repo: ext, dlr: dlr, startCancels: make(map[string]context.CancelFunc)}
+	ds := &download{repo: ext, dlr: dlr, startCancels: make(map[string]context.CancelFunc)}
+	for _, opt := range opts {
+		opt(ds)
+	}
+	return ds
+}
+
+// withPosition fills in the computed queue position of d, if any.
+func (ds *download) withPosition(d *data.Download) *data.Download {
+	if d != nil && ds.sched != nil && d.Status == data.StatusQueued {
+		d.QueuePosition = ds.sched.Position(d.ID)
+	}
+	return d
+}
+
+// kick asks the scheduler, when configured, to re-evaluate the queue.
+func (ds *download) kick() {
+	if ds.sched != nil {
+		ds.sched.Kick()
+	}
+}
+
+// checkRoots reports an error unless targetPath and the post-processing
+// destination, if any, resolve under one of roots. A bad target path wraps
+// targetErr; a bad destination wraps data.ErrInvalidPostProcess.
+func checkRoots(roots pathsafe.Roots, targetPath string, pp *data.PostProcess, targetErr error) error {
+	if len(roots) == 0 {
+		return nil
+	}
+	if _, err := roots.Check(targetPath); err != nil {
+		return fmt.Errorf("%w: %v", targetErr, err)
+	}
+	if pp != nil && pp.Dest != "" {
+		if _, err := roots.Check(pp.Dest); err != nil {
+			return fmt.Errorf("%w: dest: %v", data.ErrInvalidPostProcess, err)
+		}
+	}
+	return nil
+}
+
+// start starts d in the downloader, loading its uploaded payload first.
+func (ds *download) start(ctx context.Context, d *data.Download) (string, error) {
+	if err := repo.LoadPayload(ctx, ds.repo, d); err != nil {
+		return "", fmt.Errorf("load payload: %w", err)
+	}
+	return ds.dlr.Start(ctx, d)
 }
 
 // extendedRepoAdapter bridges a DownloadRepo that lacks DownloadFinder
@@ -69,22 +178,116 @@ func (a *extendedRepoAdapter) GetByFingerprint(ctx context.Context, fp string) (
 
 // List returns all downloads from the repository.
 func (ds *download) List(ctx context.Context) (data.Downloads, error) {
-	return ds.repo.List(ctx)
+	dls, err := ds.repo.List(ctx)
+	for _, d := range dls {
+		ds.withPosition(d)
+	}
+	return dls, err
+}
+
+// Query returns a filtered, sorted page of downloads from the repository.
+func (ds *download) Query(ctx context.Context, q data.DownloadQuery) (*data.DownloadPage, error) {
+	page, err := ds.repo.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range page.Items {
+		ds.withPosition(d)
+	}
+	return page, nil
 }
 
 // Get retrieves a download by its ID.
 func (ds *download) Get(ctx context.Context, id string) (*data.Download, error) {
-	return ds.repo.Get(ctx, id)
+	d, err := ds.repo.Get(ctx, id)
+	return ds.withPosition(d), err
+}
+
+// History returns the transitions of download id, which remain available
+// after the download is deleted. It returns data.ErrNotFound for a download
+// without any history that does not exist.
+func (ds *download) History(ctx context.Context, id string) (data.Transitions, error) {
+	ts := data.Transitions{}
+	if ds.history != nil {
+		var err error
+		if ts, err = ds.history.ListTransitions(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	if len(ts) == 0 {
+		if _, err := ds.repo.Get(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	return ts, nil
 }
 
 // Add validates and persists a new download request.
 func (ds *download) Add(ctx context.Context, d *data.Download) (*data.Download, bool, error) {
+	ctx = history.WithCause(ctx, data.CauseAPI)
+	if len(d.Payload) > 0 {
+		// Uploaded files are identified by their content, so re-uploading
+		// the same torrent or metalink is idempotent.
+		switch d.PayloadType {
+		case data.PayloadTorrent:
+			src, err := fp.TorrentSource(d.Payload)
+			if err != nil {
+				return nil, false, data.ErrInvalidPayload
+			}
+			d.Source = src
+		case data.PayloadMetalink:
+			// aria2 starts one task per metalink file, but a download
+			// tracks a single task.
+			n, err := fp.MetalinkFiles(d.Payload)
+			if err != nil {
+				return nil, false, data.ErrInvalidPayload
+			}
+			if n != 1 {
+				return nil, false, fmt.Errorf("%w: metalink describes %d files, want exactly one", data.ErrInvalidPayload, n)
+			}
+			d.Source = fp.MetalinkSource(d.Payload)
+		default:
+			return nil, false, data.ErrInvalidPayload
+		}
+	}
 	if strings.TrimSpace(d.Source) == "" {
 		return nil, false, data.ErrInvalidSource
 	}
+	if err := ds.applyCategory(ctx, d); err != nil {
+		return nil, false, err
+	}
 	if strings.TrimSpace(d.TargetPath) == "" {
 		return nil, false, data.ErrTargetPath
 	}
+	if err := checkRoots(ds.roots, d.TargetPath, d.PostProcess, data.ErrTargetPath); err != nil {
+		return nil, false, err
+	}
+	if err := d.Options.Validate(); err != nil {
+		return nil, false, err
+	}
+	if d.Options.IsZero() {
+		d.Options = nil
+	}
+	if err := d.FileFilter.Validate(); err != nil {
+		return nil, false, err
+	}
+	if d.FileFilter.IsZero() {
+		d.FileFilter = nil
+	}
+	if err := d.PostProcess.Validate(); err != nil {
+		return nil, false, err
+	}
+	if err := d.Retry.Validate(); err != nil {
+		return nil, false, err
+	}
+	if d.Retry.IsZero() {
+		d.Retry = nil
+	}
+	d.RetryStatus = nil
+	d.PostProcessStatus = nil
+	if d.PostProcess != nil {
+		d.PostProcessStatus = &data.PostProcessStatus{State: data.PostProcessPending, UpdatedAt: time.Now()}
+	}
 
 	if d.CreatedAt.IsZero() {
 		d.CreatedAt = time.Now()
@@ -96,6 +299,10 @@ func (ds *download) Add(ctx context.Context, d *data.Download) (*data.Download,
 		d.Status = data.StatusQueued
 	case data.StatusActive:
 		d.Status = data.StatusActive
+		if ds.sched != nil {
+			// The scheduler starts it once a slot is free.
+			d.Status = data.StatusQueued
+		}
 	case data.StatusPaused:
 		d.Status = data.StatusPaused
 	case data.StatusCancelled:
@@ -110,6 +317,9 @@ func (ds *download) Add(ctx context.Context, d *data.Download) (*data.Download,
 	if err != nil {
 		return nil, false, err
 	}
+	if created {
+		ds.kick()
+	}
 
 	// Only trigger a new start when this call actually created the download.
 	// Idempotent hits (created=false) must not re-start already active items.
@@ -118,7 +328,7 @@ func (ds *download) Add(ctx context.Context, d *data.Download) (*data.Download,
         // - cctx: cancellable context used for the downloader call
         // - persistCtx: non-cancellable context for repository writes to avoid
         //   losing GID/status updates if a concurrent Delete cancels cctx.
-        base := context.Background()
+        base := history.WithCause(context.Background(), data.CauseAPI)
         if rid, ok := reqid.From(ctx); ok {
             base = reqid.With(base, rid)
         }
@@ -133,15 +343,12 @@ func (ds *download) Add(ctx context.Context, d *data.Download) (*data.Download,
                 delete(ds.startCancels, d.ID)
                 ds.startMu.Unlock()
             }()
-            gid, derr := ds.dlr.Start(cctx, d)
+            gid, derr := ds.start(cctx, d)
             if derr != nil {
                 if errors.Is(derr, context.Canceled) {
                     return
                 }
-                _, _ = ds.repo.Update(persist, d.ID, func(dl *data.Download) error {
-                    dl.Status = data.StatusError
-                    return nil
-                })
+                ds.fail(persist, d.ID, derr)
                 return
             }
             _, err := ds.repo.Update(persist, d.ID, func(dl *data.Download) error {
@@ -149,21 +356,19 @@ func (ds *download) Add(ctx context.Context, d *data.Download) (*data.Download,
                 return nil
             })
             if err != nil {
-                _, _ = ds.repo.Update(persist, d.ID, func(dl *data.Download) error {
-                    dl.Status = data.StatusError
-                    return nil
-                })
+                _ = ds.setStatus(persist, d.ID, data.StatusError)
                 return
             }
             d.GID = gid
         }(saved, ctxStart, persistCtx)
     }
-	return saved, created, nil
+	return ds.withPosition(saved), created, nil
 }
 
 // UpdateDesiredStatus changes the desired state of a download and performs the
 // necessary side effects to reach it.
 func (ds *download) UpdateDesiredStatus(ctx context.Context, id string, status data.DownloadStatus) (*data.Download, error) {
+	ctx = history.WithCause(ctx, data.CauseAPI)
 	// Guard invalid desired statuses up front (service-level policy).
 	switch status {
 	case data.StatusActive, data.StatusResume, data.StatusPaused, data.StatusCancelled:
@@ -171,33 +376,54 @@ func (ds *download) UpdateDesiredStatus(ctx context.Context, id string, status d
 		return nil, data.ErrBadStatus
 	}
 
-	// Always fetch the latest state first.
-	cur, err := ds.repo.Get(ctx, id)
-	if err != nil {
-		return nil, err
-	}
-
-	// Persist desiredStatus (so callers see intent even if the actual action fails).
-	_, err = ds.repo.Update(ctx, id, func(dl *data.Download) error {
+	// Persist desiredStatus (so callers see intent even if the actual action
+	// fails), unless the state machine rejects it for the current status.
+	cur, err := ds.repo.Update(ctx, id, func(dl *data.Download) error {
+		if err := lifecycle.Request(dl.Status, status); err != nil {
+			return err
+		}
 		dl.DesiredStatus = status
+		// The user takes over from any pending automatic retry; restarting
+		// by hand also gives the download a fresh set of attempts.
+		if dl.Status == data.StatusError && dl.RetryStatus != nil {
+			dl.RetryStatus.NextRetryAt = nil
+			if status == data.StatusActive || status == data.StatusResume {
+				dl.RetryStatus.Attempts = 0
+			}
+		}
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	// A seeding download already finished downloading and keeps running in
+	// the backend; there is nothing to start or resume.
+	if cur.Status == data.StatusSeeding && (status == data.StatusActive || status == data.StatusResume) {
+		return ds.Get(ctx, id)
+	}
+
+	// With a scheduler, activation is queued rather than performed here; the
+	// scheduler starts or resumes the download once a slot is free.
+	if ds.sched != nil && (status == data.StatusActive || status == data.StatusResume) {
+		if cur.Status != data.StatusActive {
+			if err := ds.setStatus(ctx, id, data.StatusQueued); err != nil {
+				return nil, err
+			}
+			ds.kick()
+		}
+		return ds.Get(ctx, id)
+	}
+
 	switch status {
 	case data.StatusActive:
 		// Start if we don't have a GID yet (fresh start).
 		// If we *do* have a GID, keep it simple for MVP: just set Status=Active
 		// and return (future: introduce Resume in downloader and call it here).
 		if cur.GID == "" {
-			gid, derr := ds.dlr.Start(ctx, cur) // uses Source + TargetPath from cur
+			gid, derr := ds.start(ctx, cur) // uses Source + TargetPath from cur
 			if derr != nil {
-				_, _ = ds.repo.Update(ctx, id, func(dl *data.Download) error {
-					dl.Status = data.StatusError
-					return nil
-				})
+				ds.fail(ctx, id, derr)
 				if errors.Is(derr, data.ErrConflict) {
 					return nil, data.ErrConflict
 				}
@@ -208,18 +434,11 @@ func (ds *download) UpdateDesiredStatus(ctx context.Context, id string, status d
 				return nil
 			})
 			if err != nil {
-				_, _ = ds.repo.Update(ctx, id, func(dl *data.Download) error {
-					dl.Status = data.StatusError
-					return nil
-				})
+				_ = ds.setStatus(ctx, id, data.StatusError)
 				return nil, err
 			}
 		}
-		_, err = ds.repo.Update(ctx, id, func(dl *data.Download) error {
-			dl.Status = data.StatusActive
-			return nil
-		})
-		if err != nil {
+		if err := ds.setStatus(ctx, id, data.StatusActive); err != nil {
 			return nil, err
 		}
 
@@ -228,22 +447,16 @@ func (ds *download) UpdateDesiredStatus(ctx context.Context, id string, status d
 		if cur.GID != "" {
 			derr := ds.dlr.Resume(ctx, cur)
 			if derr != nil {
-				_, _ = ds.repo.Update(ctx, id, func(dl *data.Download) error {
-					dl.Status = data.StatusError
-					return nil
-				})
+				ds.fail(ctx, id, derr)
 				if errors.Is(derr, data.ErrConflict) {
 					return nil, data.ErrConflict
 				}
 				return nil, derr
 			}
 		} else {
-			gid, derr := ds.dlr.Start(ctx, cur)
+			gid, derr := ds.start(ctx, cur)
 			if derr != nil {
-				_, _ = ds.repo.Update(ctx, id, func(dl *data.Download) error {
-					dl.Status = data.StatusError
-					return nil
-				})
+				ds.fail(ctx, id, derr)
 				if errors.Is(derr, data.ErrConflict) {
 					return nil, data.ErrConflict
 				}
@@ -254,18 +467,11 @@ func (ds *download) UpdateDesiredStatus(ctx context.Context, id string, status d
 				return nil
 			})
 			if err != nil {
-				_, _ = ds.repo.Update(ctx, id, func(dl *data.Download) error {
-					dl.Status = data.StatusError
-					return nil
-				})
+				_ = ds.setStatus(ctx, id, data.StatusError)
 				return nil, err
 			}
 		}
-		_, err = ds.repo.Update(ctx, id, func(dl *data.Download) error {
-			dl.Status = data.StatusActive
-			return nil
-		})
-		if err != nil {
+		if err := ds.setStatus(ctx, id, data.StatusActive); err != nil {
 			return nil, err
 		}
 
@@ -274,18 +480,11 @@ func (ds *download) UpdateDesiredStatus(ctx context.Context, id string, status d
 		if cur.GID != "" {
 			derr := ds.dlr.Pause(ctx, cur)
 			if derr != nil {
-				_, _ = ds.repo.Update(ctx, id, func(dl *data.Download) error {
-					dl.Status = data.StatusError
-					return nil
-				})
+				_ = ds.setStatus(ctx, id, data.StatusError)
 				return nil, derr
 			}
 		}
-		_, err = ds.repo.Update(ctx, id, func(dl *data.Download) error {
-			dl.Status = data.StatusPaused
-			return nil
-		})
-		if err != nil {
+		if err := ds.setStatus(ctx, id, data.StatusPaused); err != nil {
 			return nil, err
 		}
 
@@ -294,25 +493,20 @@ func (ds *download) UpdateDesiredStatus(ctx context.Context, id string, status d
 		if cur.GID != "" {
 			derr := ds.dlr.Cancel(ctx, cur)
 			if derr != nil && !isDownloaderNotFound(derr) {
-				_, _ = ds.repo.Update(ctx, id, func(dl *data.Download) error {
-					dl.Status = data.StatusError
-					return nil
-				})
+				_ = ds.setStatus(ctx, id, data.StatusError)
 				return nil, derr
 			}
 		}
-		_, err = ds.repo.Update(ctx, id, func(dl *data.Download) error {
-			dl.GID = ""
-			dl.Status = data.StatusCancelled
-			return nil
-		})
-		if err != nil {
+		if err := ds.setStatus(ctx, id, data.StatusCancelled); err != nil {
 			return nil, err
 		}
 	}
 
+	// Pausing or cancelling may free a slot for the next queued download.
+	ds.kick()
+
 	// Return the latest snapshot.
-	return ds.repo.Get(ctx, id)
+	return ds.Get(ctx, id)
 }
 
 // Delete removes a download. If deleteFiles is true, the downloader is asked to
@@ -337,7 +531,44 @@ func (ds *download) Delete(ctx context.Context, id string, deleteFiles bool) err
         return err
     }
 
-    return ds.repo.Delete(ctx, id)
+    if err := ds.repo.Delete(ctx, id); err != nil {
+        return err
+    }
+    ds.kick()
+    return nil
+}
+
+// setStatus moves download id to status to. It returns a
+// *lifecycle.TransitionError, and leaves the download alone, if the download
+// changed meanwhile so that to is no longer a legal next status.
+func (ds *download) setStatus(ctx context.Context, id string, to data.DownloadStatus) error {
+	_, err := ds.repo.Update(ctx, id, func(dl *data.Download) error {
+		if err := lifecycle.Transition(dl.Status, to); err != nil {
+			return err
+		}
+		dl.Status = to
+		switch to {
+		case data.StatusActive:
+			dl.Failure = nil
+		case data.StatusCancelled:
+			dl.GID = ""
+		}
+		return nil
+	})
+	return err
+}
+
+// fail marks download id Failed after the downloader could not start or
+// resume it.
+func (ds *download) fail(ctx context.Context, id string, err error) {
+	_, _ = ds.repo.Update(ctx, id, func(dl *data.Download) error {
+		if err := lifecycle.Transition(dl.Status, data.StatusError); err != nil {
+			return err
+		}
+		dl.Status = data.StatusError
+		dl.Failure = downloader.StartFailure(data.FailureSourceService, err, time.Now())
+		return nil
+	})
 }
 
 func isDownloaderNotFound(err error) bool {
@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"reflect"
 	"strconv"
 	"testing"
 	"time"
@@ -84,6 +86,10 @@ func (r *basicRepo) List(ctx context.Context) (data.Downloads, error) {
 	return r.downloads.Clone(), nil
 }
 
+func (r *basicRepo) Query(ctx context.Context, q data.DownloadQuery) (*data.DownloadPage, error) {
+	return &data.DownloadPage{Items: r.downloads.Clone()}, nil
+}
+
 func (r *basicRepo) Get(ctx context.Context, id string) (*data.Download, error) {
 	for _, d := range r.downloads {
 		if d.ID == id {
@@ -257,6 +263,47 @@ func TestUpdateDesiredStatus(t *testing.T) {
 		if got.Status != data.StatusError {
 			t.Fatalf("status not failed: %s", got.Status)
 		}
+		if f := got.Failure; f == nil || f.Code != data.FailureBackend || f.Source != data.FailureSourceService || f.Message != "boom" || f.Timestamp.IsZero() {
+			t.Fatalf("unexpected failure: %+v", got.Failure)
+		}
+	})
+
+	t.Run("conflict is recorded as file_exists", func(t *testing.T) {
+		r := repo.NewInMemoryDownloadRepo()
+		d, _ := r.Add(ctx, &data.Download{Source: "s", TargetPath: "t"})
+		dl := &stubDownloader{startFn: func(ctx context.Context, d *data.Download) (string, error) {
+			return "", fmt.Errorf("%w: target exists", data.ErrConflict)
+		}}
+		svc := NewDownload(r, dl)
+
+		if _, err := svc.UpdateDesiredStatus(ctx, d.ID, data.StatusActive); !errors.Is(err, data.ErrConflict) {
+			t.Fatalf("expected ErrConflict, got %v", err)
+		}
+		got, _ := r.Get(ctx, d.ID)
+		if got.Failure == nil || got.Failure.Code != data.FailureFileExists {
+			t.Fatalf("unexpected failure: %+v", got.Failure)
+		}
+	})
+
+	t.Run("restarting a failed download resets retries", func(t *testing.T) {
+		r := repo.NewInMemoryDownloadRepo()
+		next := time.Now().Add(time.Minute)
+		d, _ := r.Add(ctx, &data.Download{Source: "s", TargetPath: "t", Status: data.StatusError,
+			RetryStatus: &data.RetryStatus{Attempts: 2, NextRetryAt: &next},
+			Failure:     &data.Failure{Code: data.FailureNetwork, DownloaderCode: 6}})
+		dl := &stubDownloader{startFn: func(ctx context.Context, d *data.Download) (string, error) { return "g", nil }}
+		svc := NewDownload(r, dl)
+
+		got, err := svc.UpdateDesiredStatus(ctx, d.ID, data.StatusActive)
+		if err != nil {
+			t.Fatalf("UpdateDesiredStatus: %v", err)
+		}
+		if rs := got.RetryStatus; rs.Attempts != 0 || rs.NextRetryAt != nil {
+			t.Fatalf("unexpected retry status: %+v", rs)
+		}
+		if got.Status != data.StatusActive || got.Failure != nil {
+			t.Fatalf("failure not cleared on restart: %v %+v", got.Status, got.Failure)
+		}
 	})
 
 	t.Run("invalid status", func(t *testing.T) {
@@ -428,3 +475,279 @@ func TestServiceDelete_CancelOnly_Mode(t *testing.T) {
     if !dl.deleted { t.Fatalf("expected downloader.Delete to be called") }
     if _, err := r.Get(ctx, d.ID); !errors.Is(err, data.ErrNotFound) { t.Fatalf("record still present") }
 }
+
+type stubScheduler struct {
+	kicks     int
+	positions map[string]int
+}
+
+func (s *stubScheduler) Kick()                  { s.kicks++ }
+func (s *stubScheduler) Position(id string) int { return s.positions[id] }
+
+// TestSchedulerQueuesActivation ensures that with a scheduler, activation is
+// deferred to it: downloads are queued instead of started and expose their
+// queue position.
+func TestSchedulerQueuesActivation(t *testing.T) {
+	ctx := context.Background()
+	r := repo.NewInMemoryDownloadRepo()
+	dlr := &stubDownloader{}
+	sched := &stubScheduler{positions: map[string]int{}}
+	svc := NewDownload(r, dlr, WithScheduler(sched))
+
+	saved, _, err := svc.Add(ctx, &data.Download{Source: "s", TargetPath: "t", DesiredStatus: data.StatusActive})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if saved.Status != data.StatusQueued || saved.DesiredStatus != data.StatusActive || dlr.started || sched.kicks != 1 {
+		t.Fatalf("add not queued: %#v started=%v kicks=%d", saved, dlr.started, sched.kicks)
+	}
+
+	sched.positions[saved.ID] = 3
+	got, _ := svc.Get(ctx, saved.ID)
+	if got.QueuePosition != 3 {
+		t.Fatalf("queuePosition = %d", got.QueuePosition)
+	}
+
+	// Pausing leaves the queue; re-activating re-queues without starting.
+	if _, err := svc.UpdateDesiredStatus(ctx, saved.ID, data.StatusPaused); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	got, err = svc.UpdateDesiredStatus(ctx, saved.ID, data.StatusActive)
+	if err != nil {
+		t.Fatalf("activate: %v", err)
+	}
+	if got.Status != data.StatusQueued || dlr.started || sched.kicks != 3 {
+		t.Fatalf("activate not queued: %#v started=%v kicks=%d", got, dlr.started, sched.kicks)
+	}
+}
+
+// TestMoveReordersQueue walks a download through every move and checks the
+// resulting queue order, including priority adoption across bands.
+func TestMoveReordersQueue(t *testing.T) {
+	ctx := context.Background()
+	r := repo.NewInMemoryDownloadRepo()
+	sched := &stubScheduler{positions: map[string]int{}}
+	svc := NewDownload(r, &stubDownloader{}, WithScheduler(sched))
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ids := map[string]string{}
+	for i, src := range []string{"a", "b", "c", "d"} {
+		d, _, err := svc.Add(ctx, &data.Download{Source: src, TargetPath: "t", CreatedAt: base.Add(time.Duration(i) * time.Second)})
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		ids[d.ID] = src
+	}
+	byName := func(src string) string {
+		for id, s := range ids {
+			if s == src {
+				return id
+			}
+		}
+		return ""
+	}
+	order := func() string {
+		page, err := r.Query(ctx, data.DownloadQuery{Statuses: []data.DownloadStatus{data.StatusQueued}, Sort: data.SortQueue})
+		if err != nil {
+			t.Fatalf("Query: %v", err)
+		}
+		out := ""
+		for _, d := range page.Items {
+			out += ids[d.ID]
+		}
+		return out
+	}
+
+	steps := []struct {
+		src  string
+		to   data.QueueMove
+		want string
+	}{
+		{"c", data.MoveTop, "cabd"},
+		{"c", data.MoveUp, "cabd"},
+		{"a", data.MoveBottom, "cbda"},
+		{"d", data.MoveUp, "cdba"},
+		{"c", data.MoveDown, "dcba"},
+		{"a", data.MoveDown, "dcba"},
+	}
+	for _, st := range steps {
+		if _, err := svc.Move(ctx, byName(st.src), st.to); err != nil {
+			t.Fatalf("Move(%s, %s): %v", st.src, st.to, err)
+		}
+		if got := order(); got != st.want {
+			t.Fatalf("after %s %s: order = %s, want %s", st.src, st.to, got, st.want)
+		}
+	}
+
+	// A higher priority jumps the queue; moves stay within a priority and
+	// never rewrite it.
+	if _, err := svc.SetPriority(ctx, byName("a"), 5); err != nil {
+		t.Fatalf("SetPriority: %v", err)
+	}
+	if got := order(); got != "adcb" {
+		t.Fatalf("after priority: order = %s", got)
+	}
+	for _, to := range []data.QueueMove{data.MoveUp, data.MoveTop} {
+		got, err := svc.Move(ctx, byName("d"), to)
+		if err != nil {
+			t.Fatalf("Move: %v", err)
+		}
+		if o := order(); o != "adcb" || got.Priority != 0 {
+			t.Fatalf("after %s: order = %s priority = %d", to, o, got.Priority)
+		}
+	}
+	if _, err := svc.Move(ctx, byName("b"), data.MoveTop); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if o := order(); o != "abdc" {
+		t.Fatalf("after top within priority: order = %s", o)
+	}
+
+	if _, err := svc.UpdateDesiredStatus(ctx, byName("b"), data.StatusPaused); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if _, err := svc.Move(ctx, byName("b"), data.MoveTop); !errors.Is(err, data.ErrNotQueued) {
+		t.Fatalf("expected ErrNotQueued, got %v", err)
+	}
+	if _, err := svc.Move(ctx, "missing", data.MoveTop); !errors.Is(err, data.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := svc.Move(ctx, byName("a"), "sideways"); !errors.Is(err, data.ErrInvalidMove) {
+		t.Fatalf("expected ErrInvalidMove, got %v", err)
+	}
+}
+
+// optionsDL records live option changes.
+type optionsDL struct {
+	stubDownloader
+	changed []data.DownloadOptionsPatch
+	err     error
+}
+
+func (o *optionsDL) ChangeOptions(ctx context.Context, d *data.Download, p data.DownloadOptionsPatch) error {
+	if o.err != nil {
+		return o.err
+	}
+	o.changed = append(o.changed, p)
+	return nil
+}
+
+func TestSetOptionsAppliesToLiveTask(t *testing.T) {
+	ctx := context.Background()
+	r := repo.NewInMemoryDownloadRepo()
+	dl := &optionsDL{}
+	svc := NewDownload(r, dl)
+
+	d, _, err := svc.Add(ctx, &data.Download{Source: "s", TargetPath: "t", Options: &data.DownloadOptions{Split: 4}})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	limit := int64(512)
+	got, err := svc.SetOptions(ctx, d.ID, data.DownloadOptionsPatch{MaxDownloadLimit: &limit})
+	if err != nil {
+		t.Fatalf("SetOptions: %v", err)
+	}
+	if got.Options.MaxDownloadLimit != 512 || got.Options.Split != 4 {
+		t.Fatalf("options = %+v", got.Options)
+	}
+	if len(dl.changed) != 0 {
+		t.Fatalf("no backend task yet, but ChangeOptions called")
+	}
+
+	_, _ = r.Update(ctx, d.ID, func(x *data.Download) error {
+		x.GID, x.Status = "g1", data.StatusActive
+		return nil
+	})
+	split, limit0 := 8, int64(0)
+	if _, err := svc.SetOptions(ctx, d.ID, data.DownloadOptionsPatch{Split: &split}); err != nil {
+		t.Fatalf("SetOptions: %v", err)
+	}
+	if len(dl.changed) != 1 || *dl.changed[0].Split != 8 || dl.changed[0].MaxDownloadLimit != nil {
+		t.Fatalf("changed = %+v", dl.changed)
+	}
+
+	// A backend that refuses the change leaves the stored options alone.
+	dl.err = errors.New("aria2 down")
+	if _, err := svc.SetOptions(ctx, d.ID, data.DownloadOptionsPatch{MaxDownloadLimit: &limit0}); err == nil {
+		t.Fatalf("expected backend error")
+	}
+	if got, _ := svc.Get(ctx, d.ID); got.Options.MaxDownloadLimit != 512 || got.Options.Split != 8 {
+		t.Fatalf("options changed despite backend error: %+v", got.Options)
+	}
+
+	if _, _, err := svc.Add(ctx, &data.Download{Source: "s2", TargetPath: "t", Options: &data.DownloadOptions{MaxConnectionsPerServer: 17}}); !errors.Is(err, data.ErrInvalidOptions) {
+		t.Fatalf("expected ErrInvalidOptions, got %v", err)
+	}
+}
+
+// selectDL records live file selections.
+type selectDL struct {
+	stubDownloader
+	selected [][]int
+}
+
+func (s *selectDL) SelectFiles(ctx context.Context, d *data.Download, indexes []int) error {
+	s.selected = append(s.selected, indexes)
+	return nil
+}
+
+func TestSelectFiles(t *testing.T) {
+	ctx := context.Background()
+	r := repo.NewInMemoryDownloadRepo()
+	dl := &selectDL{}
+	svc := NewDownload(r, dl)
+
+	d, _, err := svc.Add(ctx, &data.Download{Source: "s", TargetPath: "t", FileFilter: &data.FileFilter{Include: []string{"*.mkv"}}})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if _, err := svc.SelectFiles(ctx, d.ID, []int{1}); !errors.Is(err, data.ErrFilesUnknown) {
+		t.Fatalf("expected ErrFilesUnknown, got %v", err)
+	}
+
+	_, _ = r.Update(ctx, d.ID, func(x *data.Download) error {
+		x.GID, x.Status = "g1", data.StatusActive
+		x.Files = []data.DownloadFile{{Path: "a", Index: 1, Selected: true}, {Path: "b", Index: 2, Selected: true}, {Path: "c", Index: 3, Selected: true}}
+		return nil
+	})
+	got, err := svc.SelectFiles(ctx, d.ID, []int{3, 1, 3})
+	if err != nil {
+		t.Fatalf("SelectFiles: %v", err)
+	}
+	if len(dl.selected) != 1 || !reflect.DeepEqual(dl.selected[0], []int{1, 3}) {
+		t.Fatalf("selected = %v", dl.selected)
+	}
+	if !reflect.DeepEqual(data.SelectedIndexes(got.Files), []int{1, 3}) || got.FileFilter != nil {
+		t.Fatalf("download = %+v", got)
+	}
+
+	for _, idx := range [][]int{nil, {4}} {
+		if _, err := svc.SelectFiles(ctx, d.ID, idx); !errors.Is(err, data.ErrInvalidFileSelection) {
+			t.Fatalf("%v: expected ErrInvalidFileSelection, got %v", idx, err)
+		}
+	}
+	if _, _, err := svc.Add(ctx, &data.Download{Source: "s2", TargetPath: "t", FileFilter: &data.FileFilter{Exclude: []string{"["}}}); !errors.Is(err, data.ErrInvalidFileSelection) {
+		t.Fatalf("expected ErrInvalidFileSelection, got %v", err)
+	}
+}
+
+func TestActivateSeedingIsNoop(t *testing.T) {
+	ctx := context.Background()
+	r := repo.NewInMemoryDownloadRepo()
+	dl := &stubDownloader{
+		startFn:  func(ctx context.Context, d *data.Download) (string, error) { t.Fatal("unexpected Start"); return "", nil },
+		resumeFn: func(ctx context.Context, d *data.Download) error { t.Fatal("unexpected Resume"); return nil },
+	}
+	svc := NewDownload(r, dl)
+	d, _ := r.Add(ctx, &data.Download{Source: "s", TargetPath: "t", GID: "g", Status: data.StatusSeeding})
+
+	for _, status := range []data.DownloadStatus{data.StatusActive, data.StatusResume} {
+		got, err := svc.UpdateDesiredStatus(ctx, d.ID, status)
+		if err != nil {
+			t.Fatalf("%s: %v", status, err)
+		}
+		if got.Status != data.StatusSeeding || got.GID != "g" {
+			t.Fatalf("%s: seeding download changed: %+v", status, got)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/downloader"
+)
+
+// SelectFiles validates indexes against the known files of a download and,
+// when the download has a live backend task, applies the selection to it.
+// The stored selection replaces any file filter given on create, so the
+// reconciler no longer re-applies that filter.
+func (ds *download) SelectFiles(ctx context.Context, id string, indexes []int) (*data.Download, error) {
+	cur, err := ds.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	sel, err := data.ValidateSelection(cur.Files, indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	fs, ok := ds.dlr.(downloader.FileSelector)
+	if ok && cur.GID != "" {
+		switch cur.Status {
+		case data.StatusQueued, data.StatusActive, data.StatusPaused:
+			if err := fs.SelectFiles(ctx, cur, sel); err != nil && !isDownloaderNotFound(err) {
+				return nil, err
+			}
+		}
+	}
+
+	if _, err := ds.repo.Update(ctx, id, func(dl *data.Download) error {
+		data.MarkSelected(dl.Files, sel)
+		dl.FileFilter = nil
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ds.Get(ctx, id)
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/downloader"
+)
+
+// SetOptions merges p into the stored options of a download. When the
+// download has a live backend task the change is applied to it first, so a
+// backend that refuses it leaves the stored options untouched. Terminal
+// downloads only have their stored options updated.
+func (ds *download) SetOptions(ctx context.Context, id string, p data.DownloadOptionsPatch) (*data.Download, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	cur, err := ds.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	oc, ok := ds.dlr.(downloader.OptionChanger)
+	if ok && cur.GID != "" {
+		switch cur.Status {
+		case data.StatusQueued, data.StatusActive, data.StatusPaused, data.StatusSeeding:
+			live := *cur
+			live.Options = p.Apply(cur.Options)
+			// A task that vanished from the backend picks the stored
+			// options up when it is started again.
+			if err := oc.ChangeOptions(ctx, &live, p); err != nil && !isDownloaderNotFound(err) {
+				return nil, err
+			}
+		}
+	}
+
+	if _, err := ds.repo.Update(ctx, id, func(dl *data.Download) error {
+		dl.Options = p.Apply(dl.Options)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ds.Get(ctx, id)
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+
+	"github.com/tinoosan/torrus/internal/data"
+)
+
+// SetPriority stores a new priority for the download. The scheduler picks up
+// the new queue order on its next pass.
+func (ds *download) SetPriority(ctx context.Context, id string, priority int) (*data.Download, error) {
+	_, err := ds.repo.Update(ctx, id, func(dl *data.Download) error {
+		dl.Priority = priority
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	ds.kick()
+	return ds.Get(ctx, id)
+}
+
+// Move repositions a Queued download among the queued downloads of the same
+// priority, rewriting only its queue order; priority is left to the user.
+// Top and bottom place it ahead of the first or behind the last of them, up
+// and down swap it with its neighbour. Moving past either end is a no-op.
+func (ds *download) Move(ctx context.Context, id string, to data.QueueMove) (*data.Download, error) {
+	if !to.Valid() {
+		return nil, data.ErrInvalidMove
+	}
+	ds.moveMu.Lock()
+	defer ds.moveMu.Unlock()
+
+	queue, err := ds.queue(ctx)
+	if err != nil {
+		return nil, err
+	}
+	i := -1
+	for j, d := range queue {
+		if d.ID == id {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		if _, err := ds.repo.Get(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, data.ErrNotQueued
+	}
+
+	// The queue is sorted by priority first, so downloads of the same
+	// priority are contiguous: [first, last] is cur's stretch of it.
+	cur, first, last := queue[i], i, i
+	for first > 0 && queue[first-1].Priority == cur.Priority {
+		first--
+	}
+	for last < len(queue)-1 && queue[last+1].Priority == cur.Priority {
+		last++
+	}
+	switch {
+	case to == data.MoveTop && i > first:
+		err = ds.setOrder(ctx, id, queue[first].QueueOrder-1)
+	case to == data.MoveBottom && i < last:
+		err = ds.setOrder(ctx, id, queue[last].QueueOrder+1)
+	case to == data.MoveUp && i > first:
+		err = ds.swapOrder(ctx, cur, queue[i-1])
+	case to == data.MoveDown && i < last:
+		err = ds.swapOrder(ctx, cur, queue[i+1])
+	}
+	if err != nil {
+		return nil, err
+	}
+	ds.kick()
+	return ds.Get(ctx, id)
+}
+
+// queue lists every Queued download in admission order.
+func (ds *download) queue(ctx context.Context) (data.Downloads, error) {
+	q := data.DownloadQuery{Statuses: []data.DownloadStatus{data.StatusQueued}, Sort: data.SortQueue, Limit: data.MaxQueryLimit}
+	var out data.Downloads
+	for {
+		page, err := ds.repo.Query(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, page.Items...)
+		if page.NextCursor == "" {
+			return out, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// setOrder rewrites the queue order of a download that must still be Queued.
+func (ds *download) setOrder(ctx context.Context, id string, order int64) error {
+	_, err := ds.repo.Update(ctx, id, func(dl *data.Download) error {
+		if dl.Status != data.StatusQueued {
+			return data.ErrNotQueued
+		}
+		dl.QueueOrder = order
+		return nil
+	})
+	return err
+}
+
+// swapOrder exchanges the queue orders of two queued downloads.
+func (ds *download) swapOrder(ctx context.Context, a, b *data.Download) error {
+	if err := ds.setOrder(ctx, a.ID, b.QueueOrder); err != nil {
+		return err
+	}
+	if err := ds.setOrder(ctx, b.ID, a.QueueOrder); err != nil {
+		// b left the queue meanwhile; put a back where it was.
+		_ = ds.setOrder(ctx, a.ID, a.QueueOrder)
+		return err
+	}
+	return nil
+}
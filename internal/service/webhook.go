@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+// Webhook manages outbound webhook subscriptions.
+type Webhook interface {
+	List(ctx context.Context) (data.Webhooks, error)
+	Get(ctx context.Context, id string) (*data.Webhook, error)
+	// Create validates and stores a webhook. When no secret is supplied a
+	// random one is generated. The returned webhook includes the secret; all
+	// other methods redact it.
+	Create(ctx context.Context, w *data.Webhook) (*data.Webhook, error)
+	Update(ctx context.Context, id string, patch WebhookPatch) (*data.Webhook, error)
+	Delete(ctx context.Context, id string) error
+	// Deliveries returns the most recent deliveries for a webhook, newest first.
+	Deliveries(ctx context.Context, id string, limit int) (data.WebhookDeliveries, error)
+}
+
+// WebhookPatch lists the mutable webhook fields. Nil fields are left as-is.
+type WebhookPatch struct {
+	URL     *string
+	Secret  *string
+	Events  *[]data.WebhookEvent
+	Enabled *bool
+}
+
+// DefaultDeliveryLimit bounds Deliveries when the caller passes no limit.
+const DefaultDeliveryLimit = 50
+
+// webhook implements the Webhook service.
+type webhook struct {
+	repo repo.WebhookRepo
+}
+
+// NewWebhook constructs a Webhook service backed by the given repository.
+func NewWebhook(r repo.WebhookRepo) Webhook {
+	return &webhook{repo: r}
+}
+
+// List returns all webhooks with secrets redacted.
+func (ws *webhook) List(ctx context.Context) (data.Webhooks, error) {
+	hooks, err := ws.repo.ListWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make(data.Webhooks, 0, len(hooks))
+	for _, h := range hooks {
+		out = append(out, h.Redacted())
+	}
+	return out, nil
+}
+
+// Get retrieves a webhook by ID with its secret redacted.
+func (ws *webhook) Get(ctx context.Context, id string) (*data.Webhook, error) {
+	h, err := ws.repo.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return h.Redacted(), nil
+}
+
+// Create validates and persists a new webhook.
+func (ws *webhook) Create(ctx context.Context, w *data.Webhook) (*data.Webhook, error) {
+	if err := validateWebhookURL(w.URL); err != nil {
+		return nil, err
+	}
+	if err := validateWebhookEvents(w.Events); err != nil {
+		return nil, err
+	}
+	if w.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		w.Secret = secret
+	}
+	if w.CreatedAt.IsZero() {
+		w.CreatedAt = time.Now()
+	}
+	return ws.repo.AddWebhook(ctx, w)
+}
+
+// Update applies patch to the webhook with the given ID.
+func (ws *webhook) Update(ctx context.Context, id string, patch WebhookPatch) (*data.Webhook, error) {
+	if patch.URL != nil {
+		if err := validateWebhookURL(*patch.URL); err != nil {
+			return nil, err
+		}
+	}
+	if patch.Events != nil {
+		if err := validateWebhookEvents(*patch.Events); err != nil {
+			return nil, err
+		}
+	}
+	if patch.Secret != nil && *patch.Secret == "" {
+		return nil, data.ErrInvalidWebhook
+	}
+	h, err := ws.repo.UpdateWebhook(ctx, id, func(w *data.Webhook) error {
+		if patch.URL != nil {
+			w.URL = *patch.URL
+		}
+		if patch.Secret != nil {
+			w.Secret = *patch.Secret
+		}
+		if patch.Events != nil {
+			w.Events = *patch.Events
+		}
+		if patch.Enabled != nil {
+			w.Enabled = *patch.Enabled
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h.Redacted(), nil
+}
+
+// Delete removes a webhook and its delivery log.
+func (ws *webhook) Delete(ctx context.Context, id string) error {
+	return ws.repo.DeleteWebhook(ctx, id)
+}
+
+// Deliveries lists recent deliveries for a webhook.
+func (ws *webhook) Deliveries(ctx context.Context, id string, limit int) (data.WebhookDeliveries, error) {
+	if limit <= 0 {
+		limit = DefaultDeliveryLimit
+	}
+	return ws.repo.ListDeliveries(ctx, id, limit)
+}
+
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return data.ErrInvalidWebhook
+	}
+	return nil
+}
+
+func validateWebhookEvents(evs []data.WebhookEvent) error {
+	for _, e := range evs {
+		if !e.Valid() {
+			return data.ErrInvalidWebhook
+		}
+	}
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,388 @@
+// Package storage watches free space on the allowed download roots. It holds
+// back queued downloads that would not fit and, when a disk is nearly full,
+// pauses the downloads writing to it until space is available again.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/downloader"
+	"github.com/tinoosan/torrus/internal/history"
+	"github.com/tinoosan/torrus/internal/metrics"
+	"github.com/tinoosan/torrus/internal/pathsafe"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+// Defaults for Config fields left at zero.
+const (
+	DefaultMinFree      = 1 << 30   // 1 GiB
+	DefaultCriticalFree = 256 << 20 // 256 MiB
+	DefaultInterval     = 30 * time.Second
+)
+
+// Level describes how much free space a root has left.
+type Level string
+
+const (
+	// LevelOK means downloads under the root are admitted normally.
+	LevelOK Level = "ok"
+	// LevelLow means free space, minus what active downloads still need, is
+	// below MinFree: queued downloads under the root are not started.
+	LevelLow Level = "low"
+	// LevelCritical means free space is below CriticalFree: active
+	// downloads under the root are paused and queued again.
+	LevelCritical Level = "critical"
+)
+
+// Config tunes the Watcher.
+type Config struct {
+	// Roots are the directories to watch; with none the Watcher is idle and
+	// admits every download.
+	Roots pathsafe.Roots
+	// MinFree is the free space, in bytes, a root must keep after the
+	// remaining bytes of its active downloads and of the download being
+	// admitted. Held downloads resume once it is met again.
+	MinFree int64
+	// CriticalFree is the free space, in bytes, below which active
+	// downloads under a root are held. It is capped at MinFree.
+	CriticalFree int64
+	// Interval is how often free space is checked.
+	Interval time.Duration
+}
+
+// RootStatus is the state of one root as of the last check.
+type RootStatus struct {
+	Path       string `json:"path"`
+	TotalBytes int64  `json:"totalBytes"`
+	FreeBytes  int64  `json:"freeBytes"`
+	// ReservedBytes is what active downloads under the root still have to
+	// write, as far as their total length is known.
+	ReservedBytes int64     `json:"reservedBytes"`
+	Level         Level     `json:"level"`
+	CheckedAt     time.Time `json:"checkedAt"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// Report is the storage state returned by GET /v1/storage.
+type Report struct {
+	MinFreeBytes      int64        `json:"minFreeBytes"`
+	CriticalFreeBytes int64        `json:"criticalFreeBytes"`
+	Roots             []RootStatus `json:"roots"`
+}
+
+// Usage is the size and free space of a filesystem.
+type Usage struct {
+	Total int64
+	Free  int64
+}
+
+// Watcher periodically checks the free space of each root. It implements
+// scheduler.Gate.
+type Watcher struct {
+	repo  repo.DownloadRepo
+	dlr   downloader.Downloader
+	log   *slog.Logger
+	cfg   Config
+	usage func(path string) (Usage, error)
+	kick  interface{ Kick() }
+
+	mu    sync.Mutex
+	roots []RootStatus
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a Watcher. Zero Config fields take their defaults.
+func New(log *slog.Logger, r repo.DownloadRepo, dlr downloader.Downloader, cfg Config) *Watcher {
+	if log == nil {
+		log = slog.Default()
+	}
+	if cfg.MinFree <= 0 {
+		cfg.MinFree = DefaultMinFree
+	}
+	if cfg.CriticalFree <= 0 {
+		cfg.CriticalFree = DefaultCriticalFree
+	}
+	if cfg.CriticalFree > cfg.MinFree {
+		cfg.CriticalFree = cfg.MinFree
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	w := &Watcher{repo: r, dlr: dlr, log: log, cfg: cfg, usage: diskUsage}
+	for _, root := range cfg.Roots {
+		w.roots = append(w.roots, RootStatus{Path: root, Level: LevelOK})
+	}
+	return w
+}
+
+// SetKicker registers a scheduler to kick when a root recovers, so held
+// downloads resume without waiting for its next periodic pass.
+func (w *Watcher) SetKicker(k interface{ Kick() }) {
+	w.kick = k
+}
+
+// Start checks free space immediately and then every interval until Stop.
+func (w *Watcher) Start(ctx context.Context) {
+	if len(w.cfg.Roots) == 0 {
+		return
+	}
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		t := time.NewTicker(w.cfg.Interval)
+		defer t.Stop()
+		for {
+			w.Check(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+// Stop terminates the loop and waits for an in-flight check.
+func (w *Watcher) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+		w.wg.Wait()
+	}
+}
+
+// Report returns the state of every root as of the last check.
+func (w *Watcher) Report() Report {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return Report{
+		MinFreeBytes:      w.cfg.MinFree,
+		CriticalFreeBytes: w.cfg.CriticalFree,
+		Roots:             append([]RootStatus{}, w.roots...),
+	}
+}
+
+// Check refreshes the free space of every root, holds the active downloads
+// of roots at the critical level and kicks the scheduler when a root is no
+// longer low.
+func (w *Watcher) Check(ctx context.Context) {
+	if len(w.cfg.Roots) == 0 {
+		return
+	}
+	active, err := w.list(ctx, data.StatusActive)
+	if err != nil {
+		w.log.Error("storage: list active", "err", err)
+		return
+	}
+	reserved := make([]int64, len(w.cfg.Roots))
+	byRoot := make([]data.Downloads, len(w.cfg.Roots))
+	for _, d := range active {
+		if i := w.rootOf(d.TargetPath); i >= 0 {
+			reserved[i] += remaining(d)
+			byRoot[i] = append(byRoot[i], d)
+		}
+	}
+
+	now := time.Now()
+	next := make([]RootStatus, len(w.cfg.Roots))
+	for i, root := range w.cfg.Roots {
+		st := RootStatus{Path: root, ReservedBytes: reserved[i], Level: LevelOK, CheckedAt: now}
+		u, err := w.usage(root)
+		if err != nil {
+			// Unknown free space never blocks downloads.
+			st.Error = err.Error()
+			w.log.Warn("storage: check free space", "root", root, "err", err)
+		} else {
+			st.TotalBytes, st.FreeBytes = u.Total, u.Free
+			st.Level = w.level(u.Free, reserved[i])
+		}
+		next[i] = st
+		metrics.StorageFreeBytes.WithLabelValues(root).Set(float64(st.FreeBytes))
+		metrics.StorageTotalBytes.WithLabelValues(root).Set(float64(st.TotalBytes))
+		metrics.StorageReservedBytes.WithLabelValues(root).Set(float64(st.ReservedBytes))
+		metrics.StorageLevel.WithLabelValues(root).Set(levelValue(st.Level))
+	}
+
+	w.mu.Lock()
+	prev := w.roots
+	w.roots = next
+	w.mu.Unlock()
+
+	recovered := false
+	for i, st := range next {
+		if st.Level != prev[i].Level {
+			w.log.Info("storage level changed", "root", st.Path, "from", prev[i].Level, "to", st.Level, "free", st.FreeBytes)
+			recovered = recovered || st.Level == LevelOK
+		}
+		if st.Level == LevelCritical {
+			for _, d := range byRoot[i] {
+				w.hold(ctx, st.Path, d)
+			}
+		}
+	}
+	if recovered && w.kick != nil {
+		w.kick.Kick()
+	}
+}
+
+// Admit implements scheduler.Gate. A download under a root is admitted when
+// the root is not critical and its free space, minus the remaining bytes of
+// active downloads and of d itself, stays at or above MinFree. Admitted
+// bytes are reserved until the next check so that one pass does not
+// overcommit a root. Downloads outside every root, and roots whose free
+// space is unknown, are always admitted.
+func (w *Watcher) Admit(d *data.Download) bool {
+	i := w.rootOf(d.TargetPath)
+	if i < 0 {
+		return true
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	st := &w.roots[i]
+	if st.CheckedAt.IsZero() || st.Error != "" {
+		return true
+	}
+	need := remaining(d)
+	if st.FreeBytes < w.cfg.CriticalFree || st.FreeBytes-st.ReservedBytes-need < w.cfg.MinFree {
+		w.log.Info("storage: holding queued download", "id", d.ID, "root", st.Path, "free", st.FreeBytes, "reserved", st.ReservedBytes, "need", need)
+		return false
+	}
+	st.ReservedBytes += need
+	return true
+}
+
+// hold pauses an active download and returns it to the queue, keeping its
+// desired status, so the scheduler resumes it once the root recovers.
+func (w *Watcher) hold(ctx context.Context, root string, d *data.Download) {
+	ctx = history.WithCause(ctx, data.CauseStorage)
+	lg := w.log.With("id", d.ID, "root", root)
+	held := false
+	_, err := w.repo.Update(ctx, d.ID, func(dl *data.Download) error {
+		held = dl.Status == data.StatusActive
+		if held {
+			dl.Status = data.StatusQueued
+		}
+		return nil
+	})
+	if err != nil || !held {
+		if err != nil {
+			lg.Error("storage: hold download", "err", err)
+		}
+		return
+	}
+	if err := w.dlr.Pause(ctx, d); err != nil {
+		lg.Error("storage: pause download", "err", err)
+		_, _ = w.repo.Update(ctx, d.ID, func(dl *data.Download) error {
+			if dl.Status == data.StatusQueued {
+				dl.Status = data.StatusActive
+			}
+			return nil
+		})
+		return
+	}
+	metrics.StorageHolds.WithLabelValues(root).Inc()
+	lg.Warn("storage: free space critical; download paused and queued")
+}
+
+// level classifies a root by its free space and the bytes reserved on it.
+func (w *Watcher) level(free, reserved int64) Level {
+	switch {
+	case free < w.cfg.CriticalFree:
+		return LevelCritical
+	case free-reserved < w.cfg.MinFree:
+		return LevelLow
+	}
+	return LevelOK
+}
+
+// rootOf returns the index of the most specific root holding path, or -1.
+func (w *Watcher) rootOf(path string) int {
+	if len(w.cfg.Roots) == 0 || path == "" {
+		return -1
+	}
+	resolved, err := w.cfg.Roots.Check(path)
+	if err != nil {
+		return -1
+	}
+	best := -1
+	for i, root := range w.cfg.Roots {
+		if (resolved == root || pathsafe.Within(root, resolved)) && (best < 0 || len(root) > len(w.cfg.Roots[best])) {
+			best = i
+		}
+	}
+	return best
+}
+
+// list returns every download with the given status.
+func (w *Watcher) list(ctx context.Context, st data.DownloadStatus) (data.Downloads, error) {
+	q := data.DownloadQuery{Statuses: []data.DownloadStatus{st}, Limit: data.MaxQueryLimit}
+	var out data.Downloads
+	for {
+		page, err := w.repo.Query(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, page.Items...)
+		if page.NextCursor == "" {
+			return out, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// remaining returns the bytes d still has to write, from the total length
+// the downloader reported, or 0 when the total is not known yet.
+func remaining(d *data.Download) int64 {
+	if d.Progress == nil || d.Progress.Total <= 0 || d.Progress.Completed >= d.Progress.Total {
+		return 0
+	}
+	return d.Progress.Total - d.Progress.Completed
+}
+
+func levelValue(l Level) float64 {
+	switch l {
+	case LevelLow:
+		return 1
+	case LevelCritical:
+		return 2
+	}
+	return 0
+}
+
+// ParseSize parses a byte count with an optional K, M, G or T suffix
+// (binary multiples, case-insensitive, optional trailing "B" or "iB").
+func ParseSize(orig string) (int64, error) {
+	s := strings.TrimSpace(strings.ToUpper(orig))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	mult := int64(1)
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		case 'T':
+			mult = 1 << 40
+		}
+		if mult > 1 {
+			s = s[:n-1]
+		}
+	}
+	v, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || v < 0 || v > math.MaxInt64/mult {
+		return 0, fmt.Errorf("invalid size %q", orig)
+	}
+	return v * mult, nil
+}
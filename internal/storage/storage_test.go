@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/pathsafe"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+type stubDL struct {
+	mu     sync.Mutex
+	paused []string
+}
+
+func (s *stubDL) Start(ctx context.Context, d *data.Download) (string, error) { return "gid", nil }
+func (s *stubDL) Pause(ctx context.Context, d *data.Download) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = append(s.paused, d.ID)
+	return nil
+}
+func (s *stubDL) Resume(ctx context.Context, d *data.Download) error { return nil }
+func (s *stubDL) Cancel(ctx context.Context, d *data.Download) error { return nil }
+func (s *stubDL) Delete(ctx context.Context, d *data.Download, deleteFiles bool) error {
+	return nil
+}
+
+type kicker struct{ n int }
+
+func (k *kicker) Kick() { k.n++ }
+
+// newTestWatcher returns a Watcher over two roots whose free space is read
+// from free.
+func newTestWatcher(t *testing.T, r repo.DownloadRepo, dl *stubDL, free map[string]int64) (*Watcher, string, string) {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	for _, d := range []string{a, b} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	roots, err := pathsafe.ParseRoots(a + "," + b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := New(slog.New(slog.NewTextHandler(io.Discard, nil)), r, dl, Config{Roots: roots, MinFree: 100, CriticalFree: 10})
+	w.usage = func(path string) (Usage, error) {
+		return Usage{Total: 1000, Free: free[filepath.Base(path)]}, nil
+	}
+	return w, a, b
+}
+
+func add(t *testing.T, r repo.DownloadRepo, d *data.Download) *data.Download {
+	t.Helper()
+	out, err := r.Add(context.Background(), d)
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	return out
+}
+
+// TestAdmitPredictsFit ensures a queued download is admitted only when its
+// remaining bytes, plus those of active downloads, leave MinFree on its root.
+func TestAdmitPredictsFit(t *testing.T) {
+	r := repo.NewInMemoryDownloadRepo()
+	w, a, b := newTestWatcher(t, r, &stubDL{}, map[string]int64{"a": 300, "b": 50})
+	add(t, r, &data.Download{Source: "running", TargetPath: a, Status: data.StatusActive, Progress: &data.DownloadProgress{Completed: 50, Total: 150}})
+
+	// Before the first check nothing is known, so nothing is held.
+	if !w.Admit(&data.Download{TargetPath: b}) {
+		t.Fatal("unchecked root held a download")
+	}
+	w.Check(context.Background())
+
+	rep := w.Report()
+	if len(rep.Roots) != 2 || rep.Roots[0].ReservedBytes != 100 || rep.Roots[0].Level != LevelOK || rep.Roots[1].Level != LevelLow {
+		t.Fatalf("report = %+v", rep)
+	}
+	big := &data.Download{TargetPath: filepath.Join(a, "tv"), Progress: &data.DownloadProgress{Total: 200}}
+	if w.Admit(big) {
+		t.Fatal("download that does not fit was admitted")
+	}
+	small := &data.Download{TargetPath: filepath.Join(a, "tv"), Progress: &data.DownloadProgress{Completed: 120, Total: 200}}
+	if !w.Admit(small) {
+		t.Fatal("download that fits was held")
+	}
+	// The admitted bytes are reserved for the rest of the pass.
+	if w.Admit(small) {
+		t.Fatal("second admission overcommitted the root")
+	}
+	if w.Admit(&data.Download{TargetPath: b}) {
+		t.Fatal("low root admitted a download")
+	}
+	if !w.Admit(&data.Download{TargetPath: "/elsewhere"}) {
+		t.Fatal("download outside the roots was held")
+	}
+}
+
+// TestCheckHoldsAndRecovers ensures active downloads on a critical root are
+// paused and queued, and the scheduler is kicked once the root recovers.
+func TestCheckHoldsAndRecovers(t *testing.T) {
+	r := repo.NewInMemoryDownloadRepo()
+	stub := &stubDL{}
+	free := map[string]int64{"a": 5, "b": 500}
+	w, a, b := newTestWatcher(t, r, stub, free)
+	k := &kicker{}
+	w.SetKicker(k)
+	onA := add(t, r, &data.Download{Source: "1", TargetPath: a, GID: "g1", Status: data.StatusActive, DesiredStatus: data.StatusActive})
+	onB := add(t, r, &data.Download{Source: "2", TargetPath: b, GID: "g2", Status: data.StatusActive, DesiredStatus: data.StatusActive})
+
+	w.Check(context.Background())
+	got, _ := r.Get(context.Background(), onA.ID)
+	if got.Status != data.StatusQueued || got.DesiredStatus != data.StatusActive || len(stub.paused) != 1 || stub.paused[0] != onA.ID {
+		t.Fatalf("download on critical root not held: %v paused=%v", got.Status, stub.paused)
+	}
+	if got, _ := r.Get(context.Background(), onB.ID); got.Status != data.StatusActive {
+		t.Fatalf("download on healthy root held: %v", got.Status)
+	}
+	if w.Admit(got) {
+		t.Fatal("held download admitted while critical")
+	}
+
+	free["a"] = 500
+	w.Check(context.Background())
+	if k.n != 1 {
+		t.Fatalf("kicks = %d, want 1", k.n)
+	}
+	got, _ = r.Get(context.Background(), onA.ID)
+	if !w.Admit(got) {
+		t.Fatal("held download not admitted after recovery")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{"0": 0, "512": 512, "4k": 4 << 10, "10M": 10 << 20, "2GiB": 2 << 30, " 1 TB": 1 << 40}
+	for in, want := range cases {
+		if got, err := ParseSize(in); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "G", "-1", "1.5G", "10X"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q) accepted", in)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd)
+
+package storage
+
+import "errors"
+
+// diskUsage is not implemented on this platform; roots report an error and
+// never hold downloads.
+func diskUsage(path string) (Usage, error) {
+	return Usage{}, errors.New("free space is not available on this platform")
+}
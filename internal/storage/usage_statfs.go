@@ -0,0 +1,16 @@
+//go:build linux || darwin || freebsd
+
+package storage
+
+import "syscall"
+
+// diskUsage reports the size of the filesystem holding path and the space
+// available to unprivileged users on it.
+func diskUsage(path string) (Usage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return Usage{}, err
+	}
+	bsize := int64(st.Bsize)
+	return Usage{Total: int64(st.Blocks) * bsize, Free: int64(st.Bavail) * bsize}, nil
+}
@@ -0,0 +1,338 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/metrics"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+// Header names set on every delivery request.
+const (
+	HeaderEvent     = "X-Torrus-Event"
+	HeaderDelivery  = "X-Torrus-Delivery"
+	HeaderSignature = "X-Torrus-Signature-256"
+)
+
+// Payload is the JSON body posted to webhook endpoints.
+type Payload struct {
+	Event      data.WebhookEvent   `json:"event"`
+	Timestamp  time.Time           `json:"timestamp"`
+	FromStatus data.DownloadStatus `json:"fromStatus,omitempty"`
+	Download   *data.Download      `json:"download"`
+}
+
+// Config tunes delivery behaviour. Zero values fall back to defaults.
+type Config struct {
+	// MaxAttempts is the total number of delivery attempts per event.
+	MaxAttempts int
+	// BaseBackoff is the delay before the first retry; it doubles on each
+	// subsequent retry up to MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Timeout bounds each HTTP attempt.
+	Timeout time.Duration
+	// Workers is the number of concurrent deliveries.
+	Workers int
+}
+
+func (c Config) withDefaults() Config {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 5
+	}
+	if c.BaseBackoff <= 0 {
+		c.BaseBackoff = time.Second
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 5 * time.Minute
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	if c.Workers <= 0 {
+		c.Workers = 4
+	}
+	return c
+}
+
+type notice struct {
+	from data.DownloadStatus
+	dl   *data.Download
+}
+
+// Dispatcher turns reconciled status changes into signed webhook deliveries.
+// StatusChanged never blocks the caller; matching, persistence and HTTP work
+// happen on background goroutines started by Start.
+type Dispatcher struct {
+	repo   repo.WebhookRepo
+	client *http.Client
+	log    *slog.Logger
+	cfg    Config
+	now    func() time.Time
+
+	notices chan notice
+	jobs    chan *data.WebhookDelivery
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher constructs a Dispatcher backed by the given repository.
+func NewDispatcher(log *slog.Logger, r repo.WebhookRepo, cfg Config) *Dispatcher {
+	if log == nil {
+		log = slog.Default()
+	}
+	cfg = cfg.withDefaults()
+	return &Dispatcher{
+		repo:    r,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		log:     log,
+		cfg:     cfg,
+		now:     time.Now,
+		notices: make(chan notice, 256),
+		jobs:    make(chan *data.WebhookDelivery, 256),
+	}
+}
+
+// SetHTTPClient overrides the client used for deliveries.
+func (d *Dispatcher) SetHTTPClient(c *http.Client) {
+	if c != nil {
+		d.client = c
+	}
+}
+
+// Start launches the fan-out loop and delivery workers, and reschedules any
+// deliveries left pending by a previous run.
+func (d *Dispatcher) Start(ctx context.Context) {
+	d.ctx, d.cancel = context.WithCancel(ctx)
+	d.wg.Add(1)
+	go d.fanOut()
+	for i := 0; i < d.cfg.Workers; i++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+	pending, err := d.repo.PendingDeliveries(d.ctx)
+	if err != nil {
+		d.log.Error("webhook: load pending deliveries", "err", err)
+		return
+	}
+	for _, del := range pending {
+		wait := time.Duration(0)
+		if del.NextAttemptAt != nil {
+			wait = del.NextAttemptAt.Sub(d.now())
+		}
+		d.schedule(del, wait)
+	}
+}
+
+// Stop terminates background work. Pending retries remain in the delivery log
+// and are resumed by the next Start.
+func (d *Dispatcher) Stop() {
+	if d.cancel != nil {
+		d.cancel()
+		d.wg.Wait()
+	}
+}
+
+// StatusChanged implements reconciler.StatusListener.
+func (d *Dispatcher) StatusChanged(ctx context.Context, from data.DownloadStatus, dl *data.Download) {
+	select {
+	case d.notices <- notice{from: from, dl: dl.Clone()}:
+	default:
+		d.log.Warn("webhook: notice queue full, dropping event", "id", dl.ID, "status", dl.Status)
+	}
+}
+
+func (d *Dispatcher) fanOut() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case n := <-d.notices:
+			d.enqueue(n)
+		}
+	}
+}
+
+// enqueue records a pending delivery for every enabled webhook interested in
+// the notice and hands each to the workers.
+func (d *Dispatcher) enqueue(n notice) {
+	ev, ok := data.WebhookEventFor(n.dl.Status)
+	if !ok {
+		return
+	}
+	hooks, err := d.repo.ListWebhooks(d.ctx)
+	if err != nil {
+		d.log.Error("webhook: list", "err", err)
+		return
+	}
+	now := d.now()
+	body, err := json.Marshal(Payload{Event: ev, Timestamp: now, FromStatus: n.from, Download: n.dl.Redacted()})
+	if err != nil {
+		d.log.Error("webhook: marshal payload", "id", n.dl.ID, "err", err)
+		return
+	}
+	for _, h := range hooks {
+		if !h.Enabled || !h.Wants(ev) {
+			continue
+		}
+		del, err := d.repo.AddDelivery(d.ctx, &data.WebhookDelivery{
+			WebhookID:  h.ID,
+			Event:      ev,
+			DownloadID: n.dl.ID,
+			Payload:    body,
+			State:      data.DeliveryPending,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		})
+		if err != nil {
+			d.log.Error("webhook: record delivery", "webhook_id", h.ID, "err", err)
+			continue
+		}
+		d.schedule(del, 0)
+	}
+}
+
+// schedule queues del for a worker after wait has elapsed.
+func (d *Dispatcher) schedule(del *data.WebhookDelivery, wait time.Duration) {
+	if wait <= 0 {
+		select {
+		case d.jobs <- del:
+			return
+		default:
+		}
+		// Queue is momentarily full; retry shortly off the caller's goroutine.
+		wait = 100 * time.Millisecond
+	}
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-t.C:
+		}
+		select {
+		case <-d.ctx.Done():
+		case d.jobs <- del:
+		}
+	}()
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case del := <-d.jobs:
+			d.attempt(del)
+		}
+	}
+}
+
+// attempt performs one delivery attempt and records its outcome, scheduling
+// a retry with exponential backoff when attempts remain.
+func (d *Dispatcher) attempt(del *data.WebhookDelivery) {
+	lg := d.log.With("webhook_id", del.WebhookID, "delivery_id", del.ID, "event", del.Event)
+	hook, err := d.repo.GetWebhook(d.ctx, del.WebhookID)
+	if err != nil {
+		if !errors.Is(err, data.ErrWebhookNotFound) {
+			lg.Error("webhook: get", "err", err)
+		}
+		// Deleted webhooks take their delivery log with them; nothing to do.
+		return
+	}
+
+	code, err := d.post(hook, del)
+	del.Attempts++
+	del.ResponseCode = code
+	del.UpdatedAt = d.now()
+	del.NextAttemptAt = nil
+	if err == nil {
+		del.State = data.DeliverySucceeded
+		del.LastError = ""
+		metrics.WebhookDeliveries.WithLabelValues("succeeded").Inc()
+		lg.Info("webhook delivered", "attempt", del.Attempts, "code", code)
+	} else {
+		del.LastError = err.Error()
+		if del.Attempts >= d.cfg.MaxAttempts {
+			del.State = data.DeliveryFailed
+			metrics.WebhookDeliveries.WithLabelValues("failed").Inc()
+			lg.Warn("webhook delivery failed", "attempt", del.Attempts, "err", err)
+		} else {
+			wait := d.backoff(del.Attempts)
+			next := del.UpdatedAt.Add(wait)
+			del.NextAttemptAt = &next
+			metrics.WebhookDeliveries.WithLabelValues("retried").Inc()
+			lg.Info("webhook delivery retry scheduled", "attempt", del.Attempts, "wait", wait, "err", err)
+		}
+	}
+	if err := d.repo.SaveDelivery(d.ctx, del); err != nil && !errors.Is(err, data.ErrNotFound) {
+		lg.Error("webhook: save delivery", "err", err)
+	}
+	if del.State == data.DeliveryPending {
+		d.schedule(del, del.NextAttemptAt.Sub(d.now()))
+	}
+}
+
+// backoff returns the delay before retry number attempt (1-based).
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	wait := d.cfg.BaseBackoff
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if wait >= d.cfg.MaxBackoff {
+			return d.cfg.MaxBackoff
+		}
+	}
+	return wait
+}
+
+func (d *Dispatcher) post(hook *data.Webhook, del *data.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, hook.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Torrus-Webhook/1")
+	req.Header.Set(HeaderEvent, string(del.Event))
+	req.Header.Set(HeaderDelivery, del.ID)
+	if hook.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(hook.Secret, del.Payload))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header value for body: "sha256=" followed by
+// the hex HMAC-SHA256 of body keyed with secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
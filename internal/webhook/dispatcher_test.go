@@ -0,0 +1,118 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tinoosan/torrus/internal/data"
+	"github.com/tinoosan/torrus/internal/repo"
+)
+
+// waitFor polls cond until it holds or the deadline passes.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("condition not met before deadline")
+}
+
+// TestDispatcherSignsAndRetries ensures deliveries are signed, retried on
+// non-2xx responses, and recorded as succeeded once the endpoint accepts.
+func TestDispatcherSignsAndRetries(t *testing.T) {
+	var calls atomic.Int32
+	var badSig atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(HeaderSignature) != Sign("s3cret", body) || r.Header.Get(HeaderEvent) != "download.complete" {
+			badSig.Store(true)
+		}
+		var p Payload
+		if err := json.Unmarshal(body, &p); err != nil || p.Download == nil || p.Download.ID != "dl1" || p.FromStatus != data.StatusActive {
+			badSig.Store(true)
+		}
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	rpo := repo.NewInMemoryWebhookRepo()
+	ctx := context.Background()
+	hook, _ := rpo.AddWebhook(ctx, &data.Webhook{URL: srv.URL, Secret: "s3cret", Enabled: true, Events: []data.WebhookEvent{data.WebhookDownloadComplete}})
+	// A disabled hook and one filtered to another event must not be called.
+	_, _ = rpo.AddWebhook(ctx, &data.Webhook{URL: srv.URL, Enabled: false})
+	_, _ = rpo.AddWebhook(ctx, &data.Webhook{URL: srv.URL, Enabled: true, Events: []data.WebhookEvent{data.WebhookDownloadFailed}})
+
+	d := NewDispatcher(slog.New(slog.NewTextHandler(io.Discard, nil)), rpo, Config{BaseBackoff: 10 * time.Millisecond, MaxAttempts: 3})
+	d.Start(ctx)
+	defer d.Stop()
+
+	d.StatusChanged(ctx, data.StatusActive, &data.Download{ID: "dl1", Status: data.StatusComplete})
+
+	var del *data.WebhookDelivery
+	waitFor(t, func() bool {
+		ds, _ := rpo.ListDeliveries(ctx, hook.ID, 10)
+		if len(ds) == 1 && ds[0].State == data.DeliverySucceeded {
+			del = ds[0]
+			return true
+		}
+		return false
+	})
+	if del.Attempts != 2 || del.ResponseCode != http.StatusNoContent {
+		t.Fatalf("unexpected delivery record: %#v", del)
+	}
+	if badSig.Load() {
+		t.Fatal("delivery had wrong signature, event header, or payload")
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected 2 calls, got %d", n)
+	}
+}
+
+// TestDispatcherGivesUp ensures a delivery is marked failed after the
+// configured number of attempts.
+func TestDispatcherGivesUp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	rpo := repo.NewInMemoryWebhookRepo()
+	ctx := context.Background()
+	hook, _ := rpo.AddWebhook(ctx, &data.Webhook{URL: srv.URL, Enabled: true})
+
+	d := NewDispatcher(slog.New(slog.NewTextHandler(io.Discard, nil)), rpo, Config{BaseBackoff: time.Millisecond, MaxAttempts: 2})
+	d.Start(ctx)
+	defer d.Stop()
+
+	d.StatusChanged(ctx, data.StatusActive, &data.Download{ID: "dl1", Status: data.StatusError})
+
+	waitFor(t, func() bool {
+		ds, _ := rpo.ListDeliveries(ctx, hook.ID, 10)
+		return len(ds) == 1 && ds[0].State == data.DeliveryFailed && ds[0].Attempts == 2 && ds[0].LastError != ""
+	})
+}
+
+func TestBackoffCaps(t *testing.T) {
+	d := NewDispatcher(nil, nil, Config{BaseBackoff: time.Second, MaxBackoff: 5 * time.Second})
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := d.backoff(i + 1); got != w {
+			t.Fatalf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}